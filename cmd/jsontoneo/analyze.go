@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type analyzeOptions struct {
+	algo     string
+	property string
+	project  string
+	relTypes stringList
+	top      int
+	output   outputOptions
+	cluster  *clusterOptions
+}
+
+// analyzeAlgos maps the -algo values of the analyze command to the GDS
+// procedure writing its result, and the property it writes by default.
+var analyzeAlgos = map[string]struct{ proc, property string }{
+	"louvain":  {"gds.louvain.write", "community"},
+	"pagerank": {"gds.pageRank.write", "pagerank"},
+}
+
+// defaultAnalyzeRels are the relationships the analyze command projects by
+// default. SEEN_IN and the history relationships are left out: every host of
+// a scan would end up in one community around its Scan node.
+var defaultAnalyzeRels = []string{"BELONGS_TO", "PRESENTS", "RESOLVES_TO", "USES"}
+
+func analyzeAlgoNames() []string { return []string{"louvain", "pagerank"} }
+
+func analyzeFlags(fs *flag.FlagSet) func() {
+	opts := analyzeOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.algo, "algo", "", "Algorithm to run (louvain|pagerank)")
+	fs.StringVar(&opts.property, "property", "", "Property to write the result to (default community for louvain, pagerank for pagerank)")
+	fs.StringVar(&opts.project, "project", "", "Only analyze the nodes of this project")
+	fs.Var(&opts.relTypes, "rel-types", "Relationship types to project, comma-separated (default "+strings.Join(defaultAnalyzeRels, ",")+")")
+	fs.IntVar(&opts.top, "top", 10, "Show this many communities or nodes")
+	opts.output.register(fs, "text")
+	opts.cluster.register(fs, false)
+
+	return func() {
+		algo, ok := analyzeAlgos[opts.algo]
+		if !ok {
+			log.Fatalf("Invalid -algo %q (expected louvain or pagerank)", opts.algo)
+		}
+		if opts.property == "" {
+			opts.property = algo.property
+		}
+		opts.output.check()
+		relTypes := []string(opts.relTypes)
+		if len(relTypes) == 0 {
+			relTypes = defaultAnalyzeRels
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeWrite)
+		defer session.Close()
+		defer opts.cluster.save()
+
+		version, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+			res, err := tx.Run("RETURN gds.version()", nil)
+			if err != nil {
+				return nil, err
+			}
+			rec, err := res.Single()
+			if err != nil {
+				return nil, err
+			}
+			return rec.Values[0], nil
+		})
+		if err != nil {
+			log.Fatalf("Graph Data Science library not available, install the GDS plugin in Neo4j: %v", err)
+		}
+		log.Printf("Using GDS %v", version)
+
+		graph := fmt.Sprintf("jsontoneo-%s-%d", opts.algo, os.Getpid())
+		params := map[string]any{"graph": graph, "types": relTypes, "project": opts.project, "property": opts.property, "top": opts.top}
+
+		// Cypher-projectie, zodat ontbrekende labels of types geen fout geven.
+		_, err = session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			res, err := tx.Run(`
+			MATCH (s)-[r]->(t)
+			WHERE type(r) IN $types AND `+neo4jwriter.ProjectCond("s")+` AND `+neo4jwriter.ProjectCond("t")+`
+			WITH gds.graph.project($graph, s, t, {}, {undirectedRelationshipTypes: ['*']}) AS g
+			RETURN g.nodeCount, g.relationshipCount
+			`, params)
+			if err != nil {
+				return nil, err
+			}
+			rec, err := res.Single()
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("Projected %v nodes and %v relationships", rec.Values[0], rec.Values[1])
+			return nil, nil
+		})
+		if err != nil {
+			log.Fatalf("Projection query error: %v", err)
+		}
+		err = runAnalysis(session, algo.proc, opts, params)
+		// De projectie staat in het geheugen van de server; altijd opruimen.
+		_, dropErr := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			return tx.Run("CALL gds.graph.drop($graph, false) YIELD graphName RETURN graphName", params)
+		})
+		if dropErr != nil {
+			log.Printf("Error dropping projection %s: %v", graph, dropErr)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// runAnalysis runs proc on the projection and prints its results.
+func runAnalysis(session neo4j.Session, proc string, opts analyzeOptions, params map[string]any) error {
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run("CALL "+proc+"($graph, {writeProperty: $property}) YIELD nodePropertiesWritten RETURN nodePropertiesWritten", params)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := res.Single()
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Wrote %s to %v nodes", opts.property, rec.Values[0])
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Error running %s: %w", opts.algo, err)
+	}
+
+	if opts.algo == "louvain" {
+		var communities []analyzeCommunity
+		if communities, err = loadCommunities(session, params); err == nil {
+			err = opts.output.write(communities, communities, func(w io.Writer) error {
+				return printCommunities(w, communities)
+			})
+		}
+	} else {
+		var nodes []centralNode
+		if nodes, err = loadCentral(session, params); err == nil {
+			err = opts.output.write(nodes, nodes, func(w io.Writer) error {
+				return printCentral(w, nodes)
+			})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading results: %w", err)
+	}
+	return nil
+}
+
+// analyzeCommunity is a community found by louvain, with some of its hosts.
+type analyzeCommunity struct {
+	Community any      `json:"community"`
+	Nodes     int      `json:"nodes"`
+	Hosts     int      `json:"hosts"`
+	Sample    []string `json:"sample"`
+}
+
+// centralNode is a node with its pagerank score.
+type centralNode struct {
+	Label string  `json:"label"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+}
+
+// loadCommunities returns the largest communities with some of their hosts.
+func loadCommunities(session neo4j.Session, params map[string]any) ([]analyzeCommunity, error) {
+	communities := []analyzeCommunity{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		communities = communities[:0]
+		res, err := tx.Run(`
+		MATCH (n)
+		WHERE n[$property] IS NOT NULL AND `+neo4jwriter.ProjectCond("n")+`
+		WITH n[$property] AS community, count(n) AS nodes, collect(n.url) AS hosts
+		RETURN community, nodes, hosts[..5] AS sample, size(hosts) AS hostCount
+		ORDER BY nodes DESC, community
+		LIMIT $top
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Community query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			communities = append(communities, analyzeCommunity{v[0], propInt(v[1]), propInt(v[3]), propStrings(v[2])})
+		}
+		return nil, res.Err()
+	})
+	return communities, err
+}
+
+// loadCentral returns the nodes with the highest score, the hubs of the
+// infrastructure.
+func loadCentral(session neo4j.Session, params map[string]any) ([]centralNode, error) {
+	nodes := []centralNode{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		nodes = nodes[:0]
+		res, err := tx.Run(`
+		MATCH (n)
+		WHERE n[$property] IS NOT NULL AND `+neo4jwriter.ProjectCond("n")+`
+		RETURN labels(n)[0] AS label, coalesce(n.url, n.number, n.address, n.name, n.subject_cn) AS name, n[$property] AS score
+		ORDER BY score DESC
+		LIMIT $top
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Score query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			score, _ := v[2].(float64)
+			nodes = append(nodes, centralNode{propString(v[0]), propString(v[1]), score})
+		}
+		return nil, res.Err()
+	})
+	return nodes, err
+}
+
+func printCommunities(w io.Writer, communities []analyzeCommunity) error {
+	lines := make([]string, len(communities))
+	for i, c := range communities {
+		lines[i] = fmt.Sprintf("  %-10v %6d nodes %6d hosts  %s", c.Community, c.Nodes, c.Hosts, strings.Join(c.Sample, ", "))
+	}
+	return printLines(w, "Largest communities", lines)
+}
+
+func printCentral(w io.Writer, nodes []centralNode) error {
+	lines := make([]string, len(nodes))
+	for i, n := range nodes {
+		lines[i] = fmt.Sprintf("  %-12s %-50s %8.4f", n.Label, n.Name, n.Score)
+	}
+	return printLines(w, "Most central nodes", lines)
+}
+
+func printLines(w io.Writer, title string, lines []string) error {
+	fmt.Fprintln(w, title)
+	if len(lines) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+	return nil
+}
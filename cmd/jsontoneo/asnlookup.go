@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// asnInfo is the origin ASN of an IP and the announced prefix it is in.
+type asnInfo struct {
+	Number  uint
+	Name    string
+	Country string
+	Prefix  netip.Prefix
+}
+
+// asnResolver finds the origin ASN of an IP; false when it is not routed.
+type asnResolver interface {
+	lookup(addr netip.Addr) (asnInfo, bool, error)
+}
+
+// cymruResolver asks the IP to ASN mapping of Team Cymru over DNS. Answers
+// and ASN names are cached, as many nodes share an IP or ASN.
+type cymruResolver struct {
+	resolver *net.Resolver
+	cache    map[netip.Addr]asnInfo
+	names    map[uint]string
+}
+
+func newCymruResolver() *cymruResolver {
+	return &cymruResolver{resolver: net.DefaultResolver, cache: map[netip.Addr]asnInfo{}, names: map[uint]string{}}
+}
+
+func (c *cymruResolver) lookup(addr netip.Addr) (asnInfo, bool, error) {
+	if info, ok := c.cache[addr]; ok {
+		return info, info.Number != 0, nil
+	}
+
+	// 1.2.3.4 wordt 4.3.2.1.origin.asn.cymru.com, IPv6 per nibble omgekeerd.
+	var name string
+	if addr.Is4() || addr.Is4In6() {
+		b := addr.Unmap().As4()
+		name = fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", b[3], b[2], b[1], b[0])
+	} else {
+		b := addr.As16()
+		var nibbles []string
+		for i := len(b) - 1; i >= 0; i-- {
+			nibbles = append(nibbles, strconv.FormatUint(uint64(b[i]&0xf), 16), strconv.FormatUint(uint64(b[i]>>4), 16))
+		}
+		name = strings.Join(nibbles, ".") + ".origin6.asn.cymru.com"
+	}
+	records, err := c.txt(name)
+	if err != nil {
+		return asnInfo{}, false, err
+	}
+
+	// "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"; bij meerdere prefixen de meest specifieke.
+	var best asnInfo
+	for _, r := range records {
+		fields := cymruFields(r)
+		if len(fields) < 3 {
+			continue
+		}
+		asns := strings.Fields(fields[0])
+		prefix, err := netip.ParsePrefix(fields[1])
+		if len(asns) == 0 || err != nil {
+			continue
+		}
+		n, err := strconv.ParseUint(asns[0], 10, 32)
+		if err != nil || (best.Number != 0 && prefix.Bits() <= best.Prefix.Bits()) {
+			continue
+		}
+		best = asnInfo{Number: uint(n), Country: fields[2], Prefix: prefix.Masked()}
+	}
+	if best.Number != 0 {
+		if best.Name, err = c.name(best.Number); err != nil {
+			return asnInfo{}, false, err
+		}
+	}
+	c.cache[addr] = best
+	return best, best.Number != 0, nil
+}
+
+// name returns the name of ASN n, e.g. "CLOUDFLARENET, US".
+func (c *cymruResolver) name(n uint) (string, error) {
+	if name, ok := c.names[n]; ok {
+		return name, nil
+	}
+	records, err := c.txt(fmt.Sprintf("AS%d.asn.cymru.com", n))
+	if err != nil {
+		return "", err
+	}
+	name := ""
+	// "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"
+	if len(records) > 0 {
+		if fields := cymruFields(records[0]); len(fields) >= 5 {
+			name = fields[4]
+		}
+	}
+	c.names[n] = name
+	return name, nil
+}
+
+// txt looks up the TXT records of name; a name that does not exist has
+// none.
+func (c *cymruResolver) txt(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	records, err := c.resolver.LookupTXT(ctx, name)
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	return records, err
+}
+
+func cymruFields(record string) []string {
+	fields := strings.Split(record, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+// pyasnDB is an IP to ASN database in the format of pyasn
+// (pyasn_util_convert.py): a prefix and an ASN per line, separated by a tab,
+// with comments starting with a semicolon.
+type pyasnDB struct {
+	prefixes map[netip.Prefix]uint
+	// lengths holds the prefix lengths in the database, longest first.
+	lengths []int
+	names   map[uint]string
+}
+
+func loadPyasnDB(path, namesPath string) (*pyasnDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db := &pyasnDB{prefixes: map[netip.Prefix]uint{}, names: map[uint]string{}}
+	lengths := map[int]bool{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a prefix and an ASN", path, line)
+		}
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		n, err := strconv.ParseUint(strings.TrimPrefix(fields[1], "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid ASN %q", path, line, fields[1])
+		}
+		prefix = prefix.Masked()
+		db.prefixes[prefix] = uint(n)
+		lengths[prefix.Bits()] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for n := range lengths {
+		db.lengths = append(db.lengths, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(db.lengths)))
+
+	if namesPath != "" {
+		// pyasn_util_asnames.py schrijft {"13335": "CLOUDFLARENET, US", ...}.
+		data, err := os.ReadFile(namesPath)
+		if err != nil {
+			return nil, err
+		}
+		var names map[string]string
+		if err := json.Unmarshal(data, &names); err != nil {
+			return nil, fmt.Errorf("%s: %w", namesPath, err)
+		}
+		for k, name := range names {
+			if n, err := strconv.ParseUint(strings.TrimPrefix(k, "AS"), 10, 32); err == nil {
+				db.names[uint(n)] = name
+			}
+		}
+	}
+	return db, nil
+}
+
+// lookup returns the ASN of the longest prefix containing addr.
+func (db *pyasnDB) lookup(addr netip.Addr) (asnInfo, bool, error) {
+	addr = addr.Unmap()
+	for _, bits := range db.lengths {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if n, ok := db.prefixes[prefix]; ok {
+			return asnInfo{Number: n, Name: db.names[n], Prefix: prefix}, true, nil
+		}
+	}
+	return asnInfo{}, false, nil
+}
+
+// enrichASN links IP nodes and hosts without an ASN to the ASN announcing
+// their IP.
+func enrichASN(session neo4j.Session, opts enrichOptions) {
+	var resolver asnResolver
+	source := "Team Cymru"
+	if opts.asnDB != "" {
+		db, err := loadPyasnDB(opts.asnDB, opts.asnNames)
+		if err != nil {
+			log.Fatalf("Error reading ASN database: %v", err)
+		}
+		resolver, source = db, opts.asnDB
+	} else {
+		if opts.asnNames != "" {
+			log.Fatal("-asn-names needs -asn-db")
+		}
+		resolver = newCymruResolver()
+	}
+
+	targets, err := loadEnrichTargets(session, opts, asnPending)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+	var rows []map[string]any
+	unrouted, failed := 0, 0
+	for _, t := range targets {
+		addr, err := netip.ParseAddr(t.ip)
+		if err != nil {
+			unrouted++
+			continue
+		}
+		info, ok, err := resolver.lookup(addr)
+		if err != nil {
+			log.Printf("Warning: ASN lookup of %s: %v", t.ip, err)
+			failed++
+			continue
+		}
+		if !ok {
+			unrouted++
+			continue
+		}
+		row := map[string]any{
+			"id": t.id, "project": t.project,
+			"asn": fmt.Sprintf("AS%d", info.Number), "as_name": nil, "as_country": nil,
+			"as_range": info.Prefix.String(),
+		}
+		if info.Name != "" {
+			row["as_name"] = info.Name
+		}
+		if info.Country != "" {
+			row["as_country"] = info.Country
+		}
+		rows = append(rows, row)
+	}
+
+	linked := 0
+	for start := 0; start < len(rows); start += opts.batchSize {
+		batch := rows[start:min(start+opts.batchSize, len(rows))]
+		n, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			return linkASNs(tx, batch)
+		})
+		if err != nil {
+			log.Fatalf("Error writing ASNs: %v", err)
+		}
+		linked += n.(int)
+	}
+	log.Printf("Linked %d of %d nodes without an ASN using %s (%d not routed, %d failed)",
+		linked, len(targets), source, unrouted, failed)
+}
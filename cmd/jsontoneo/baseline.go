@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type baselineOptions struct {
+	scanID  string
+	project string
+	clear   bool
+}
+
+func baselineFlags(fs *flag.FlagSet) func() {
+	var opts baselineOptions
+	fs.StringVar(&opts.scanID, "scan", "", "Pin this Scan as the baseline")
+	fs.StringVar(&opts.project, "project", "", "Pin or clear the baseline of this project")
+	fs.BoolVar(&opts.clear, "clear", false, "Unpin the baseline and remove the :NewSinceBaseline labels")
+
+	return func() {
+		if opts.scanID != "" && opts.clear {
+			log.Fatal("Usage: jsontoneo baseline [-scan <id> | -clear] [-project <name>]")
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+		defer session.Close()
+
+		switch {
+		case opts.scanID != "":
+			n, err := pinBaseline(session, opts.scanID, opts.project)
+			if err != nil {
+				log.Fatalf("Error pinning baseline: %v", err)
+			}
+			log.Printf("Pinned scan %s as baseline, %d hosts seen since are :NewSinceBaseline", opts.scanID, n)
+		case opts.clear:
+			if _, err := pinBaseline(session, "", opts.project); err != nil {
+				log.Fatalf("Error clearing baseline: %v", err)
+			}
+			log.Print("Cleared the baseline")
+		default:
+			id, err := currentBaseline(session, opts.project)
+			if err != nil {
+				log.Fatalf("Error reading baseline: %v", err)
+			}
+			if id == "" {
+				fmt.Println("No baseline pinned")
+				return
+			}
+			fmt.Println(id)
+		}
+	}
+}
+
+// pinBaseline labels the scan :Baseline, replacing the baseline of the
+// project, and relabels the hosts seen in later scans but not in the
+// baseline :NewSinceBaseline. An empty scanID only clears the baseline. It
+// returns the number of hosts labeled.
+func pinBaseline(session neo4j.Session, scanID, project string) (int, error) {
+	n, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		params := map[string]any{"id": scanID, "project": project}
+		if scanID != "" {
+			res, err := tx.Run(`MATCH (s:Scan {id: $id}) WHERE `+neo4jwriter.ProjectCond("s")+` RETURN s.id`, params)
+			if err != nil {
+				return nil, fmt.Errorf("Scan query error: %w", err)
+			}
+			if !res.Next() {
+				return nil, fmt.Errorf("no scan with id %s", scanID)
+			}
+		}
+
+		if _, err := tx.Run(`
+		MATCH (b:Scan:Baseline) WHERE `+neo4jwriter.ProjectCond("b")+`
+		REMOVE b:Baseline
+		`, params); err != nil {
+			return nil, fmt.Errorf("Scan query error: %w", err)
+		}
+		if _, err := tx.Run(`
+		MATCH (h:Host:NewSinceBaseline) WHERE `+neo4jwriter.ProjectCond("h")+`
+		REMOVE h:NewSinceBaseline
+		`, params); err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		if scanID == "" {
+			return 0, nil
+		}
+
+		res, err := tx.Run(`
+		MATCH (b:Scan {id: $id})
+		SET b:Baseline
+		WITH b
+		MATCH (h:Host)-[:SEEN_IN]->(s:Scan)
+		WHERE s.started_at > b.started_at AND `+neo4jwriter.ProjectCond("h")+`
+		  AND NOT (h)-[:SEEN_IN]->(b)
+		WITH DISTINCT h
+		SET h:NewSinceBaseline
+		RETURN count(h)
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		rec, err := res.Single()
+		if err != nil {
+			return nil, err
+		}
+		return propInt(rec.Values[0]), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n.(int), nil
+}
+
+// currentBaseline returns the id of the pinned baseline scan, or "".
+func currentBaseline(session neo4j.Session, project string) (string, error) {
+	id, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		MATCH (b:Scan:Baseline) WHERE `+neo4jwriter.ProjectCond("b")+`
+		RETURN b.id ORDER BY b.started_at DESC LIMIT 1
+		`, map[string]any{"project": project})
+		if err != nil {
+			return nil, err
+		}
+		if !res.Next() {
+			return "", res.Err()
+		}
+		return propString(res.Record().Values[0]), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id.(string), nil
+}
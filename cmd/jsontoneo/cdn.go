@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type cdnOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	cluster *clusterOptions
+}
+
+// cdnLeak is a hostname that resolves to CDN IPs and to IPs outside the CDN,
+// the latter likely the origin behind it.
+type cdnLeak struct {
+	Hostname  string   `json:"hostname"`
+	CDNs      []string `json:"cdns"`
+	CDNIPs    []string `json:"cdn_ips"`
+	OriginIPs []string `json:"origin_ips"`
+	URLs      []string `json:"urls"`
+}
+
+// cdnHost is what the graph knows about the IPs of one Host node.
+type cdnHost struct {
+	url      string
+	hostname string
+	ip       string
+	cdn      any
+	cdnName  string
+	a        []string
+	// ipNodes are the IP nodes it resolves to, with their cdn property.
+	ipNodes map[string]any
+}
+
+func cdnFlags(fs *flag.FlagSet) func() {
+	opts := cdnOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only check hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only check the hosts of this project")
+	opts.output.register(fs, "table")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		hosts, err := loadCDNHosts(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		leaks := findCDNLeaks(hosts)
+		err = opts.output.write(leaks, leaks, func(w io.Writer) error {
+			return printCDNLeaks(w, leaks)
+		})
+		if err != nil {
+			log.Fatalf("Error writing hosts: %v", err)
+		}
+	}
+}
+
+func loadCDNHosts(session neo4j.Session, opts cdnOptions) ([]cdnHost, error) {
+	var hosts []cdnHost
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		hosts = hosts[:0]
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:RESOLVES_TO]->(i:IP)
+		RETURN h.url, h.input, h.ip, h.cdn, h.cdn_name, h.a, collect([i.address, i.cdn])
+		`, map[string]any{"scope": opts.scope, "project": opts.project})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			r := model.HttpxResult{URL: propString(v[0]), Input: propString(v[1])}
+			h := cdnHost{
+				url:      r.URL,
+				hostname: r.Hostname(),
+				ip:       propString(v[2]),
+				cdn:      v[3],
+				cdnName:  propString(v[4]),
+				a:        propStrings(v[5]),
+				ipNodes:  map[string]any{},
+			}
+			nodes, _ := v[6].([]any)
+			for _, n := range nodes {
+				if pair, ok := n.([]any); ok && len(pair) == 2 && pair[0] != nil {
+					h.ipNodes[propString(pair[0])] = pair[1]
+				}
+			}
+			hosts = append(hosts, h)
+		}
+		return nil, res.Err()
+	})
+	return hosts, err
+}
+
+// findCDNLeaks classifies every IP as CDN or not, from the cdn flag httpx
+// sets on the hosts it reached over that IP and the cdn property of IP
+// nodes, and returns the hostnames with IPs of both kinds. IPs nothing is
+// known about, such as the other A records of a host, count for neither.
+func findCDNLeaks(hosts []cdnHost) []*cdnLeak {
+	isCDN := map[string]bool{}
+	cdnNames := map[string]string{}
+	classify := func(ip string, cdn any, name string) {
+		b, ok := cdn.(bool)
+		if ip == "" || !ok {
+			return
+		}
+		// Eén CDN-waarneming is genoeg: de CDN-check van httpx kan een
+		// adres missen, maar markeert geen origin als CDN.
+		isCDN[ip] = isCDN[ip] || b
+		if b && name != "" {
+			cdnNames[ip] = name
+		}
+	}
+	for _, h := range hosts {
+		classify(h.ip, h.cdn, h.cdnName)
+		for ip, cdn := range h.ipNodes {
+			classify(ip, cdn, "")
+		}
+	}
+
+	byName := map[string]*cdnLeak{}
+	ips := map[string]map[string]bool{}
+	for _, h := range hosts {
+		l := byName[h.hostname]
+		if l == nil {
+			l = &cdnLeak{Hostname: h.hostname}
+			byName[h.hostname] = l
+			ips[h.hostname] = map[string]bool{}
+		}
+		l.URLs = append(l.URLs, h.url)
+		for _, ip := range append([]string{h.ip}, h.a...) {
+			ips[h.hostname][ip] = true
+		}
+		for ip := range h.ipNodes {
+			ips[h.hostname][ip] = true
+		}
+	}
+
+	leaks := []*cdnLeak{}
+	for name, l := range byName {
+		cdns := map[string]bool{}
+		for ip := range ips[name] {
+			cdn, known := isCDN[ip]
+			switch {
+			case !known:
+			case cdn:
+				l.CDNIPs = append(l.CDNIPs, ip)
+				if n := cdnNames[ip]; n != "" {
+					cdns[n] = true
+				}
+			default:
+				l.OriginIPs = append(l.OriginIPs, ip)
+			}
+		}
+		if len(l.CDNIPs) == 0 || len(l.OriginIPs) == 0 {
+			continue
+		}
+		for n := range cdns {
+			l.CDNs = append(l.CDNs, n)
+		}
+		sort.Strings(l.CDNs)
+		sort.Strings(l.CDNIPs)
+		sort.Strings(l.OriginIPs)
+		sort.Strings(l.URLs)
+		leaks = append(leaks, l)
+	}
+	sort.Slice(leaks, func(i, j int) bool { return leaks[i].Hostname < leaks[j].Hostname })
+	return leaks
+}
+
+func printCDNLeaks(w io.Writer, leaks []*cdnLeak) error {
+	if len(leaks) == 0 {
+		_, err := fmt.Fprintln(w, "No hosts resolve to both CDN and non-CDN IPs")
+		return err
+	}
+	for _, l := range leaks {
+		cdn := "CDN"
+		if len(l.CDNs) > 0 {
+			cdn = strings.Join(l.CDNs, ", ")
+		}
+		fmt.Fprintln(w, l.Hostname)
+		fmt.Fprintf(w, "    %-8s %s (%s)\n", "cdn:", strings.Join(l.CDNIPs, ", "), cdn)
+		fmt.Fprintf(w, "    %-8s %s\n", "origin:", strings.Join(l.OriginIPs, ", "))
+		fmt.Fprintf(w, "    %-8s %s\n", "urls:", strings.Join(l.URLs, ", "))
+	}
+	_, err := fmt.Fprintf(w, "\n%d hosts with a possible origin leak\n", len(leaks))
+	return err
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"gopkg.in/yaml.v2"
+)
+
+const censysAPI = "https://search.censys.io/api/v2/"
+
+// censysDelay keeps the requests under the rate limit of the free tier.
+const censysDelay = 2500 * time.Millisecond
+
+var (
+	errCensysNotFound     = errors.New("no information available")
+	errCensysUnauthorized = errors.New("invalid API ID or secret")
+)
+
+// censysConfig is ~/.config/jsontoneo/censys.yaml: the number of requests
+// enrich censys may send per run, per project, as every lookup costs API
+// credits. Projects it does not list get the default budget; a budget of 0
+// keeps a project out of Censys.
+type censysConfig struct {
+	Budget   *int           `yaml:"budget"`
+	Projects map[string]int `yaml:"projects"`
+}
+
+func loadCensysConfig() (censysConfig, error) {
+	var config censysConfig
+	path := filepath.Join(configDir(), "censys.yaml")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// budget returns the budget of project; def applies when the config does
+// not set one.
+func (c censysConfig) budget(project string, def int) int {
+	if n, ok := c.Projects[project]; ok {
+		return n
+	}
+	if c.Budget != nil {
+		return *c.Budget
+	}
+	return def
+}
+
+// censysHost holds the fields of /v2/hosts/{ip} that enrich censys writes.
+type censysHost struct {
+	Services []struct {
+		Port                int    `json:"port"`
+		ServiceName         string `json:"service_name"`
+		ExtendedServiceName string `json:"extended_service_name"`
+		Certificate         string `json:"certificate"`
+		TLS                 struct {
+			Certificates struct {
+				LeafFingerprint string `json:"leaf_fp_sha_256"`
+				LeafData        struct {
+					SubjectDN string   `json:"subject_dn"`
+					IssuerDN  string   `json:"issuer_dn"`
+					Names     []string `json:"names"`
+				} `json:"leaf_data"`
+			} `json:"certificates"`
+		} `json:"tls"`
+	} `json:"services"`
+	LastUpdated string `json:"last_updated_at"`
+}
+
+type censysClient struct {
+	base, id, secret string
+	client           *http.Client
+	last             time.Time
+	requests         int
+}
+
+// host looks up ip, waiting out the rate limit.
+func (c *censysClient) host(ip string) (*censysHost, error) {
+	for attempt := 0; ; attempt++ {
+		if wait := censysDelay - time.Since(c.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		c.last = time.Now()
+		c.requests++
+
+		req, err := http.NewRequest(http.MethodGet, c.base+"hosts/"+url.PathEscape(ip), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.id, c.secret)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("User-Agent", "jsontoneo/"+version)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < 3:
+			resp.Body.Close()
+			wait := 10 * time.Second << attempt
+			log.Printf("Rate limited by Censys, waiting %s", wait)
+			time.Sleep(wait)
+			continue
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, errCensysNotFound
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			resp.Body.Close()
+			return nil, errCensysUnauthorized
+		case resp.StatusCode != http.StatusOK:
+			var body struct {
+				Error string `json:"error"`
+			}
+			json.NewDecoder(resp.Body).Decode(&body)
+			resp.Body.Close()
+			if body.Error != "" {
+				return nil, fmt.Errorf("status %s: %s", resp.Status, body.Error)
+			}
+			return nil, fmt.Errorf("status %s", resp.Status)
+		}
+		var body struct {
+			Result censysHost `json:"result"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		return &body.Result, err
+	}
+}
+
+// row returns the row writeCensys writes for ip: the node properties and
+// the certificates per port. A nil h, an IP Censys knows nothing of, only
+// clears the properties.
+func (h *censysHost) row(ip, project string) map[string]any {
+	props := map[string]any{"censys_services": nil, "censys_ports": nil, "censys_updated": nil}
+	certs := []map[string]any{}
+	row := map[string]any{"ip": ip, "project": project, "props": props, "certs": certs}
+	if h == nil {
+		return row
+	}
+	var services []string
+	var ports []int
+	for _, s := range h.Services {
+		name := s.ExtendedServiceName
+		if name == "" {
+			name = s.ServiceName
+		}
+		services = append(services, fmt.Sprintf("%d/%s", s.Port, name))
+		ports = append(ports, s.Port)
+
+		fp := s.TLS.Certificates.LeafFingerprint
+		if fp == "" {
+			fp = s.Certificate
+		}
+		if fp == "" {
+			continue
+		}
+		leaf := s.TLS.Certificates.LeafData
+		certProps := map[string]any{}
+		if cn := dnField(leaf.SubjectDN, "CN"); cn != "" {
+			certProps["subject_cn"] = cn
+		}
+		if cn := dnField(leaf.IssuerDN, "CN"); cn != "" {
+			certProps["issuer_cn"] = cn
+		}
+		if len(leaf.Names) > 0 {
+			certProps["subject_an"] = leaf.Names
+		}
+		certs = append(certs, map[string]any{"fingerprint": fp, "port": fmt.Sprint(s.Port), "props": certProps})
+	}
+	sort.Ints(ports)
+	if len(services) > 0 {
+		props["censys_services"] = services
+		props["censys_ports"] = ports
+	}
+	if t, err := time.Parse(time.RFC3339, h.LastUpdated); err == nil {
+		props["censys_updated"] = t
+	}
+	row["certs"] = certs
+	return row
+}
+
+// dnField returns attribute attr of a distinguished name such as
+// "C=US, O=Let's Encrypt, CN=R3".
+func dnField(dn, attr string) string {
+	for _, part := range strings.Split(dn, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok && strings.EqualFold(k, attr) {
+			return v
+		}
+	}
+	return ""
+}
+
+// enrichCensys looks up the IPs of IP nodes and hosts with the Censys Hosts
+// API and writes their services and certificates. Like enrich shodan it
+// resumes from a cursor, but with a budget and cursor per project.
+func enrichCensys(session neo4j.Session, opts enrichOptions) {
+	id, secret, _ := strings.Cut(opts.apiKey, ":")
+	if opts.apiKey == "" {
+		id, secret = os.Getenv("CENSYS_API_ID"), os.Getenv("CENSYS_API_SECRET")
+	}
+	if id == "" || secret == "" {
+		log.Fatal("enrich censys needs -api-key ID:SECRET or $CENSYS_API_ID and $CENSYS_API_SECRET")
+	}
+	config, err := loadCensysConfig()
+	if err != nil {
+		log.Fatalf("Error reading Censys config: %v", err)
+	}
+
+	targets, err := loadEnrichTargets(session, opts, censysPending)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+	byProject := map[string][]enrichTarget{}
+	for _, t := range targets {
+		byProject[t.project] = append(byProject[t.project], t)
+	}
+	projects := make([]string, 0, len(byProject))
+	for p := range byProject {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+
+	client := &censysClient{base: censysAPI, id: id, secret: secret, client: &http.Client{Timeout: 30 * time.Second}}
+	for _, project := range projects {
+		budget := opts.budget
+		if !opts.budgetSet {
+			budget = config.budget(project, opts.budget)
+		}
+		name := project
+		if name == "" {
+			name = "(no project)"
+		}
+		if budget <= 0 {
+			log.Printf("%s: no Censys budget, skipped", name)
+			continue
+		}
+		cursorFile := enrichCursorFile(opts, "censys", project)
+		addrs := resumeAddrs(publicAddrs(byProject[project]), cursorFile)
+		enrichCensysProject(session, opts, client, name, project, addrs, budget, cursorFile)
+	}
+}
+
+func enrichCensysProject(session neo4j.Session, opts enrichOptions, client *censysClient, name, project string, addrs []netip.Addr, budget int, cursorFile string) {
+	start := client.requests
+	var rows []map[string]any
+	found, failed, done := 0, 0, 0
+	flush := func() {
+		if len(rows) == 0 {
+			return
+		}
+		if err := writeCensys(session, rows); err != nil {
+			log.Fatalf("Error writing Censys data: %v", err)
+		}
+		writeCursor(cursorFile, propString(rows[len(rows)-1]["ip"]))
+		rows = rows[:0]
+	}
+	for _, addr := range addrs {
+		if client.requests-start >= budget {
+			break
+		}
+		h, err := client.host(addr.String())
+		switch {
+		case errors.Is(err, errCensysNotFound):
+			h = nil
+		case errors.Is(err, errCensysUnauthorized):
+			flush()
+			log.Fatal("Censys rejected the API ID or secret")
+		case err != nil:
+			log.Printf("Warning: Censys lookup of %s: %v", addr, err)
+			failed++
+			continue
+		default:
+			found++
+		}
+		rows = append(rows, h.row(addr.String(), project))
+		done++
+		if len(rows) >= opts.batchSize {
+			flush()
+		}
+	}
+	flush()
+
+	requests := client.requests - start
+	if left := len(addrs) - done - failed; left > 0 {
+		log.Printf("%s: looked up %d IPs in %d Censys requests (%d known to Censys, %d failed); %d left, run again to resume",
+			name, done, requests, found, failed, left)
+		return
+	}
+	os.Remove(cursorFile)
+	log.Printf("%s: looked up %d IPs in %d Censys requests (%d known to Censys, %d failed)",
+		name, done, requests, found, failed)
+}
+
+// writeCensys sets the Censys properties of the IP nodes and hosts with the
+// IPs of rows, all of one project, and links them to the certificates they
+// present. Hosts only get the certificate of their own port.
+func writeCensys(session neo4j.Session, rows []map[string]any) error {
+	certKey := "{fingerprint: cert.fingerprint, project: ''}"
+	if rows[0]["project"] != "" {
+		certKey = "{fingerprint: cert.fingerprint, project: row.project}"
+	}
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, q := range []string{
+			`MATCH (n:IP {address: row.ip}) WHERE coalesce(n.project, '') = row.project`,
+			`MATCH (n:Host {ip: row.ip}) WHERE coalesce(n.project, '') = row.project`,
+		} {
+			res, err := tx.Run(`
+			UNWIND $rows AS row
+			`+q+`
+			SET n += row.props, n.censys_checked_at = datetime()
+			WITH n, row
+			UNWIND row.certs AS cert
+			WITH n, row, cert WHERE n:IP OR toString(n.port) = cert.port
+			MERGE (c:Certificate `+certKey+`)
+			SET c.subject_cn = coalesce(c.subject_cn, cert.props.subject_cn),
+			    c.issuer_cn  = coalesce(c.issuer_cn, cert.props.issuer_cn),
+			    c.subject_an = coalesce(c.subject_an, cert.props.subject_an)
+			MERGE (n)-[p:PRESENTS]->(c)
+			SET p.port = cert.port
+			`, map[string]any{"rows": rows})
+			if err != nil {
+				return nil, fmt.Errorf("Censys query error: %w", err)
+			}
+			if _, err := res.Consume(); err != nil {
+				return nil, fmt.Errorf("Censys query error: %w", err)
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type certsOptions struct {
+	scope    string
+	project  string
+	output   outputOptions
+	expiring string
+	cluster  *clusterOptions
+}
+
+// certEntry is a certificate presented by a host, as listed by the certs
+// command.
+type certEntry struct {
+	URL      string    `json:"url"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+	Expired  bool      `json:"expired"`
+}
+
+func certsFlags(fs *flag.FlagSet) func() {
+	opts := certsOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only list hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only list the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.StringVar(&opts.expiring, "expiring", "30d", "List certificates expiring within this period, e.g. 30d or 2w, and the expired ones")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		within, err := parseAge(opts.expiring)
+		if err != nil {
+			log.Fatalf("Invalid -expiring: %v", err)
+		}
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		certs, err := loadExpiringCerts(session, opts, time.Now().Add(within))
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		err = opts.output.write(certs, certs, func(w io.Writer) error {
+			return printCerts(w, certs, opts.expiring)
+		})
+		if err != nil {
+			log.Fatalf("Error writing certificates: %v", err)
+		}
+	}
+}
+
+// loadExpiringCerts returns the certificates that expire before cutoff, the
+// ones with the fewest days left first.
+func loadExpiringCerts(session neo4j.Session, opts certsOptions, cutoff time.Time) ([]certEntry, error) {
+	certs := []certEntry{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		certs = certs[:0]
+		res, err := tx.Run(`
+		MATCH (h:Host)-[:PRESENTS]->(c:Certificate)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		  AND c.not_after IS NOT NULL
+		  AND c.not_after < $cutoff
+		RETURN h.url AS url, c.subject_cn AS subject, c.issuer_cn AS issuer, c.not_after AS not_after
+		ORDER BY c.not_after, url
+		`, map[string]any{"scope": opts.scope, "project": opts.project, "cutoff": cutoff.UTC()})
+		if err != nil {
+			return nil, fmt.Errorf("Certificate query error: %w", err)
+		}
+		now := time.Now()
+		for res.Next() {
+			v := res.Record().Values
+			notAfter, _ := v[3].(time.Time)
+			certs = append(certs, certEntry{
+				URL:      propString(v[0]),
+				Subject:  propString(v[1]),
+				Issuer:   propString(v[2]),
+				NotAfter: notAfter,
+				// Naar beneden afronden: een certificaat dat vandaag verloopt heeft 0 dagen.
+				DaysLeft: int(math.Floor(notAfter.Sub(now).Hours() / 24)),
+				Expired:  notAfter.Before(now),
+			})
+		}
+		return nil, res.Err()
+	})
+	return certs, err
+}
+
+func printCerts(w io.Writer, certs []certEntry, within string) error {
+	if len(certs) == 0 {
+		_, err := fmt.Fprintf(w, "No certificates expired or expiring within %s\n", within)
+		return err
+	}
+	fmt.Fprintf(w, "%9s  %-10s  %-40s  %s\n", "DAYS LEFT", "EXPIRES", "SUBJECT", "URL")
+	expired := 0
+	for _, c := range certs {
+		days := fmt.Sprint(c.DaysLeft)
+		if c.Expired {
+			days = "expired"
+			expired++
+		}
+		fmt.Fprintf(w, "%9s  %-10s  %-40s  %s\n", days, c.NotAfter.Local().Format("2006-01-02"), c.Subject, c.URL)
+	}
+	_, err := fmt.Fprintf(w, "\n%d certificates, %d expired\n", len(certs), expired)
+	return err
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// cloudRefresh is how old the downloaded ranges may get before they are
+// fetched again.
+const cloudRefresh = 24 * time.Hour
+
+// cloudSource is a provider's list of published IP ranges. Azure publishes
+// its service tags under a URL that changes every week, so it is only read
+// from a local copy.
+type cloudSource struct {
+	provider, file string
+	urls           []string
+	parse          func(data []byte) ([]cloudRange, error)
+}
+
+var cloudSources = []cloudSource{
+	{"AWS", "aws.json", []string{"https://ip-ranges.amazonaws.com/ip-ranges.json"}, parseAWSRanges},
+	{"GCP", "gcp.json", []string{"https://www.gstatic.com/ipranges/cloud.json"}, parseGCPRanges},
+	{"Azure", "azure.json", nil, parseAzureRanges},
+	{"Cloudflare", "cloudflare.txt", []string{"https://www.cloudflare.com/ips-v4", "https://www.cloudflare.com/ips-v6"}, parseCloudflareRanges},
+}
+
+// cloudRange is a published prefix with the service and region it is for.
+type cloudRange struct {
+	Prefix   netip.Prefix
+	Provider string
+	Service  string
+	Region   string
+}
+
+func parseAWSRanges(data []byte) ([]cloudRange, error) {
+	var doc struct {
+		Prefixes []struct {
+			IPPrefix   string `json:"ip_prefix"`
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Region     string `json:"region"`
+			Service    string `json:"service"`
+		} `json:"prefixes"`
+		IPv6Prefixes []struct {
+			IPv6Prefix string `json:"ipv6_prefix"`
+			Region     string `json:"region"`
+			Service    string `json:"service"`
+		} `json:"ipv6_prefixes"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var ranges []cloudRange
+	add := func(prefix, service, region string) {
+		if p, err := netip.ParsePrefix(prefix); err == nil {
+			ranges = append(ranges, cloudRange{p.Masked(), "AWS", service, region})
+		}
+	}
+	for _, p := range doc.Prefixes {
+		add(p.IPPrefix, p.Service, p.Region)
+	}
+	for _, p := range doc.IPv6Prefixes {
+		add(p.IPv6Prefix, p.Service, p.Region)
+	}
+	return ranges, nil
+}
+
+func parseGCPRanges(data []byte) ([]cloudRange, error) {
+	var doc struct {
+		Prefixes []struct {
+			IPv4Prefix string `json:"ipv4Prefix"`
+			IPv6Prefix string `json:"ipv6Prefix"`
+			Service    string `json:"service"`
+			Scope      string `json:"scope"`
+		} `json:"prefixes"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var ranges []cloudRange
+	for _, p := range doc.Prefixes {
+		for _, s := range []string{p.IPv4Prefix, p.IPv6Prefix} {
+			if prefix, err := netip.ParsePrefix(s); err == nil {
+				ranges = append(ranges, cloudRange{prefix.Masked(), "GCP", p.Service, p.Scope})
+			}
+		}
+	}
+	return ranges, nil
+}
+
+// parseAzureRanges reads ServiceTags_Public_*.json. The tags overlap: the
+// regional tags of a service (AzureCloud.westeurope, Storage.westeurope)
+// are the ones with a region.
+func parseAzureRanges(data []byte) ([]cloudRange, error) {
+	var doc struct {
+		Values []struct {
+			Name       string `json:"name"`
+			Properties struct {
+				Region          string   `json:"region"`
+				SystemService   string   `json:"systemService"`
+				AddressPrefixes []string `json:"addressPrefixes"`
+			} `json:"properties"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var ranges []cloudRange
+	for _, v := range doc.Values {
+		if v.Properties.Region == "" {
+			continue
+		}
+		service := v.Properties.SystemService
+		if service == "" {
+			service, _, _ = strings.Cut(v.Name, ".")
+		}
+		for _, s := range v.Properties.AddressPrefixes {
+			if prefix, err := netip.ParsePrefix(s); err == nil {
+				ranges = append(ranges, cloudRange{prefix.Masked(), "Azure", service, v.Properties.Region})
+			}
+		}
+	}
+	return ranges, nil
+}
+
+func parseCloudflareRanges(data []byte) ([]cloudRange, error) {
+	var ranges []cloudRange
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q", line)
+		}
+		ranges = append(ranges, cloudRange{prefix.Masked(), "Cloudflare", "CDN", ""})
+	}
+	return ranges, scanner.Err()
+}
+
+// cloudRanges finds the most specific published range of an IP.
+type cloudRanges struct {
+	prefixes map[netip.Prefix]cloudRange
+	// lengths holds the prefix lengths in the ranges, longest first.
+	lengths []int
+}
+
+func newCloudRanges(ranges []cloudRange) *cloudRanges {
+	c := &cloudRanges{prefixes: map[netip.Prefix]cloudRange{}}
+	lengths := map[int]bool{}
+	for _, r := range ranges {
+		// AWS zet alles ook onder AMAZON; de specifieke dienst wint.
+		if old, ok := c.prefixes[r.Prefix]; ok && (r.Service == "AMAZON" || old.Service != "AMAZON") {
+			continue
+		}
+		c.prefixes[r.Prefix] = r
+		lengths[r.Prefix.Bits()] = true
+	}
+	for n := range lengths {
+		c.lengths = append(c.lengths, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(c.lengths)))
+	return c
+}
+
+func (c *cloudRanges) lookup(addr netip.Addr) (cloudRange, bool) {
+	addr = addr.Unmap()
+	for _, bits := range c.lengths {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if r, ok := c.prefixes[prefix]; ok {
+			return r, true
+		}
+	}
+	return cloudRange{}, false
+}
+
+// loadCloudRanges reads the ranges of every provider from dir. Without a
+// dir they are downloaded to ~/.config/jsontoneo/cloud once a day, falling
+// back to the copy there when the download fails. Providers without ranges
+// are left out with a warning.
+func loadCloudRanges(dir string) (*cloudRanges, []string) {
+	fetch := dir == ""
+	if fetch {
+		dir = filepath.Join(configDir(), "cloud")
+	}
+	var all []cloudRange
+	var providers []string
+	for _, src := range cloudSources {
+		path := filepath.Join(dir, src.file)
+		if info, err := os.Stat(path); fetch && src.urls != nil && (err != nil || time.Since(info.ModTime()) > cloudRefresh) {
+			if err := downloadCloudRanges(src, path); err != nil {
+				log.Printf("Warning: fetching the %s ranges: %v", src.provider, err)
+			}
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) && src.urls == nil {
+			log.Printf("No %s ranges: put a copy in %s", src.provider, path)
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: reading the %s ranges: %v", src.provider, err)
+			continue
+		}
+		ranges, err := src.parse(data)
+		if err != nil {
+			log.Printf("Warning: reading the %s ranges: %s: %v", src.provider, path, err)
+			continue
+		}
+		all = append(all, ranges...)
+		providers = append(providers, src.provider)
+	}
+	return newCloudRanges(all), providers
+}
+
+// downloadCloudRanges writes the ranges at the URLs of src to path, one
+// after the other.
+func downloadCloudRanges(src cloudSource, path string) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	var buf bytes.Buffer
+	for _, u := range src.urls {
+		resp, err := client.Get(u)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("status %s", resp.Status)
+		}
+		_, err = io.Copy(&buf, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+	if _, err := src.parse(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// enrichCloud tags IP nodes and hosts with the cloud provider, service and
+// region whose published ranges hold their IP.
+func enrichCloud(session neo4j.Session, opts enrichOptions) {
+	ranges, providers := loadCloudRanges(opts.cloudDir)
+	if len(providers) == 0 {
+		log.Fatal("No cloud ranges to look up IPs in")
+	}
+	targets, err := loadEnrichTargets(session, opts, cloudPending)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+
+	var rows []map[string]any
+	found := 0
+	for _, t := range targets {
+		row := map[string]any{"id": t.id, "provider": nil, "service": nil, "region": nil}
+		if addr, err := netip.ParseAddr(t.ip); err == nil {
+			if r, ok := ranges.lookup(addr); ok {
+				row["provider"], row["service"] = r.Provider, r.Service
+				if r.Region != "" {
+					row["region"] = r.Region
+				}
+				found++
+			}
+		}
+		rows = append(rows, row)
+	}
+	for start := 0; start < len(rows); start += opts.batchSize {
+		batch := rows[start:min(start+opts.batchSize, len(rows))]
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			res, err := tx.Run(`
+			UNWIND $rows AS row
+			MATCH (n) WHERE elementId(n) = row.id
+			SET n.cloud_provider = row.provider, n.cloud_service = row.service, n.cloud_region = row.region,
+			    n.cloud_checked_at = datetime()
+			`, map[string]any{"rows": batch})
+			if err != nil {
+				return nil, fmt.Errorf("Cloud query error: %w", err)
+			}
+			return res.Consume()
+		})
+		if err != nil {
+			log.Fatalf("Error writing cloud ranges: %v", err)
+		}
+	}
+	log.Printf("Tagged %d of %d nodes with a cloud provider (%s)", found, len(targets), strings.Join(providers, ", "))
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pocahon/jsontoneo/pkg/mapping"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"github.com/pocahon/jsontoneo/pkg/parser"
+)
+
+// flagValueCompletions maps a flag to a function returning the values offered
+// when completing its argument. Keys are "command -flag" for values specific
+// to one command, or "-flag" for values shared by every command with that
+// flag. The key "command" (without flag) completes positional arguments.
+var flagValueCompletions = map[string]func() []string{
+	"completion":                   func() []string { return []string{"bash", "zsh", "fish"} },
+	"import -summary":              func() []string { return []string{"text", "json"} },
+	"import -output":               func() []string { return []string{"neo4j", "cypher"} },
+	"import -target":               func() []string { return targetNames },
+	"import -from":                 func() []string { return []string{"file", "elasticsearch"} },
+	"import -cypher-template-mode": func() []string { return []string{"augment", "replace"} },
+	"import -parser":               func() []string { return append([]string{"auto"}, parser.Names()...) },
+	"import -enrich":               enricherNames,
+	"import -mapping":              mapping.Profiles,
+	"enrich":                       func() []string { return enrichKinds },
+	"export -format":               exportFormats,
+	"diff -format":                 outputFormats("text"),
+	"diff -output":                 outputFormats("text"),
+	"stats -format":                outputFormats("table"),
+	"report -format":               reportFormats,
+	"query":                        presetNames,
+	"query -format":                outputFormats("table"),
+	"takeover -format":             outputFormats("table"),
+	"pivot -format":                outputFormats("table"),
+	"pivot -by":                    func() []string { return pivotKinds },
+	"analyze -algo":                analyzeAlgoNames,
+	"analyze -format":              outputFormats("text"),
+	"tech -format":                 outputFormats("table"),
+	"certs -format":                outputFormats("table"),
+	"exposure -format":             outputFormats("table"),
+	"exposure -by":                 func() []string { return []string{"asn", "netblock"} },
+	"search -format":               outputFormats("table"),
+	"path -format":                 outputFormats("text"),
+	"history -format":              outputFormats("text"),
+	"dead -format":                 outputFormats("table"),
+	"cdn -format":                  outputFormats("table"),
+	"eol -format":                  outputFormats("table"),
+	"unscanned -format":            outputFormats("table", "list"),
+	"coverage -format":             outputFormats("table"),
+	"consume -source":              consumerSourceNames,
+	"-log-target":                  logTargetNames,
+	"-merge-strategy":              func() []string { return mergeStrategies },
+	"-out-of-scope":                func() []string { return []string{"drop", "label"} },
+	"-on-max-new-nodes":            func() []string { return []string{"abort", "dry-run"} },
+	"-scheme":                      func() []string { return []string{"http", "https"} },
+	"-only-fields":                 neo4jwriter.FieldNames,
+	"-skip-fields":                 neo4jwriter.FieldNames,
+	"-hash-fields":                 neo4jwriter.FieldNames,
+}
+
+// fileFlags lists flags whose argument is a path on disk. A flag that also
+// has values in flagValueCompletions completes both.
+var fileFlags = map[string]bool{
+	"f":               true,
+	"scope-file":      true,
+	"mapping":         true,
+	"hooks":           true,
+	"rules":           true,
+	"script":          true,
+	"cypher-template": true,
+	"o":               true,
+	"out":             true,
+}
+
+func completionFlags(fs *flag.FlagSet) func() {
+	return func() {
+		if fs.NArg() != 1 {
+			log.Fatal("Usage: jsontoneo completion bash|zsh|fish")
+		}
+
+		var script string
+		switch fs.Arg(0) {
+		case "bash":
+			script = bashCompletion()
+		case "zsh":
+			script = zshCompletion()
+		case "fish":
+			script = fishCompletion()
+		default:
+			log.Fatalf("Unsupported shell %q (expected bash, zsh or fish)", fs.Arg(0))
+		}
+		fmt.Fprint(os.Stdout, script)
+	}
+}
+
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+	file   bool
+	values []string
+}
+
+// commandFlags returns the flags of c as registered by its flags function.
+func commandFlags(c command) []completionFlag {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.flags(fs)
+
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		cf := completionFlag{name: f.Name, usage: f.Usage, file: fileFlags[f.Name]}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			cf.isBool = true
+		}
+		cf.values = completionValues(c.name + " -" + f.Name)
+		if cf.values == nil {
+			cf.values = completionValues("-" + f.Name)
+		}
+		flags = append(flags, cf)
+	})
+	return flags
+}
+
+func completionValues(key string) []string {
+	fn, ok := flagValueCompletions[key]
+	if !ok {
+		return nil
+	}
+	values := fn()
+	sort.Strings(values)
+	return values
+}
+
+func bashCompletion() string {
+	var b strings.Builder
+	names := strings.Join(commandNames(), " ")
+
+	b.WriteString("# bash completion for jsontoneo\n")
+	b.WriteString("# Load with: source <(jsontoneo completion bash)\n\n")
+	b.WriteString("_jsontoneo() {\n")
+	b.WriteString("    local cur prev cmd\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    cmd=import\n")
+	b.WriteString("    if [[ ${COMP_CWORD} -gt 1 ]]; then\n")
+	b.WriteString("        case \"${COMP_WORDS[1]}\" in\n")
+	fmt.Fprintf(&b, "            %s) cmd=\"${COMP_WORDS[1]}\" ;;\n", strings.ReplaceAll(names, " ", "|"))
+	b.WriteString("        esac\n")
+	b.WriteString("    fi\n\n")
+	b.WriteString("    if [[ ${COMP_CWORD} -eq 1 && ${cur} != -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=( $(compgen -W %q -- \"${cur}\") )\n", names)
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n\n")
+	b.WriteString("    case \"${cmd}\" in\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "    %s)\n", c.name)
+		b.WriteString("        case \"${prev}\" in\n")
+		var flagWords []string
+		for _, f := range commandFlags(c) {
+			flagWords = append(flagWords, "-"+f.name)
+			switch {
+			case f.isBool:
+			case f.values != nil && f.file:
+				fmt.Fprintf(&b, "        -%s|--%s) COMPREPLY=( $(compgen -W %q -- \"${cur}\") $(compgen -f -- \"${cur}\") ); return ;;\n", f.name, f.name, strings.Join(f.values, " "))
+			case f.values != nil:
+				fmt.Fprintf(&b, "        -%s|--%s) COMPREPLY=( $(compgen -W %q -- \"${cur}\") ); return ;;\n", f.name, f.name, strings.Join(f.values, " "))
+			case f.file:
+				fmt.Fprintf(&b, "        -%s|--%s) COMPREPLY=( $(compgen -f -- \"${cur}\") ); return ;;\n", f.name, f.name)
+			default:
+				fmt.Fprintf(&b, "        -%s|--%s) return ;;\n", f.name, f.name)
+			}
+		}
+		b.WriteString("        esac\n")
+		words := strings.Join(flagWords, " ")
+		if values := completionValues(c.name); values != nil {
+			words = strings.TrimSpace(words + " " + strings.Join(values, " "))
+		}
+		fmt.Fprintf(&b, "        COMPREPLY=( $(compgen -W %q -- \"${cur}\") )\n", words)
+		b.WriteString("        ;;\n")
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("complete -o default -F _jsontoneo jsontoneo\n")
+	return b.String()
+}
+
+func zshCompletion() string {
+	var b strings.Builder
+
+	b.WriteString("#compdef jsontoneo\n")
+	b.WriteString("# zsh completion for jsontoneo\n")
+	b.WriteString("# Load with: source <(jsontoneo completion zsh)\n\n")
+	b.WriteString("_jsontoneo() {\n")
+	b.WriteString("    local -a subcmds\n")
+	b.WriteString("    subcmds=(\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "        %s\n", zshQuote(c.name+":"+c.summary))
+	}
+	b.WriteString("    )\n\n")
+	b.WriteString("    if (( CURRENT == 2 )) && [[ ${words[CURRENT]} != -* ]]; then\n")
+	b.WriteString("        _describe 'command' subcmds\n")
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n\n")
+	b.WriteString("    local cmd=import\n")
+	b.WriteString("    case ${words[2]} in\n")
+	fmt.Fprintf(&b, "        %s)\n", strings.Join(commandNames(), "|"))
+	b.WriteString("            cmd=${words[2]}\n")
+	b.WriteString("            shift words\n")
+	b.WriteString("            (( CURRENT-- ))\n")
+	b.WriteString("            ;;\n")
+	b.WriteString("    esac\n\n")
+	b.WriteString("    case ${cmd} in\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "    %s)\n", c.name)
+		b.WriteString("        _arguments")
+		for _, f := range commandFlags(c) {
+			desc := zshEscape(f.usage)
+			switch {
+			case f.isBool:
+				fmt.Fprintf(&b, " \\\n            %s", zshQuote("-"+f.name+"["+desc+"]"))
+			case f.values != nil && f.file:
+				fmt.Fprintf(&b, " \\\n            %s", zshQuote("-"+f.name+"["+desc+"]:"+f.name+":_alternative \""+f.name+":"+f.name+":("+strings.Join(f.values, " ")+")\" \"files:file:_files\""))
+			case f.values != nil:
+				fmt.Fprintf(&b, " \\\n            %s", zshQuote("-"+f.name+"["+desc+"]:"+f.name+":("+strings.Join(f.values, " ")+")"))
+			case f.file:
+				fmt.Fprintf(&b, " \\\n            %s", zshQuote("-"+f.name+"["+desc+"]:file:_files"))
+			default:
+				fmt.Fprintf(&b, " \\\n            %s", zshQuote("-"+f.name+"["+desc+"]:"+f.name+":"))
+			}
+		}
+		if values := completionValues(c.name); values != nil {
+			fmt.Fprintf(&b, " \\\n            %s", zshQuote("1:argument:("+strings.Join(values, " ")+")"))
+		}
+		b.WriteString("\n        ;;\n")
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("if [[ $zsh_eval_context[-1] == loadautofunc ]]; then\n")
+	b.WriteString("    _jsontoneo \"$@\"\n")
+	b.WriteString("else\n")
+	b.WriteString("    compdef _jsontoneo jsontoneo\n")
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+func fishCompletion() string {
+	var b strings.Builder
+
+	b.WriteString("# fish completion for jsontoneo\n")
+	b.WriteString("# Load with: jsontoneo completion fish | source\n\n")
+	b.WriteString("complete -c jsontoneo -f\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c jsontoneo -n __fish_use_subcommand -a %s -d %s\n", c.name, fishQuote(c.summary))
+	}
+	for _, c := range commands {
+		cond := fishQuote("__fish_seen_subcommand_from " + c.name)
+		if c.name == "import" {
+			// Zonder subcommand gelden de flags van import.
+			cond = fishQuote("__fish_use_subcommand; or __fish_seen_subcommand_from import")
+		}
+		for _, f := range commandFlags(c) {
+			line := fmt.Sprintf("complete -c jsontoneo -n %s -o %s -d %s", cond, f.name, fishQuote(f.usage))
+			switch {
+			case f.isBool:
+			case f.values != nil && f.file:
+				line += " -r -F -a " + fishQuote(strings.Join(f.values, " "))
+			case f.values != nil:
+				line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+			case f.file:
+				line += " -r -F"
+			default:
+				line += " -x"
+			}
+			b.WriteString(line + "\n")
+		}
+		if values := completionValues(c.name); values != nil {
+			fmt.Fprintf(&b, "complete -c jsontoneo -n %s -a %s\n", cond, fishQuote(strings.Join(values, " ")))
+		}
+	}
+	return b.String()
+}
+
+func zshEscape(s string) string {
+	r := strings.NewReplacer("[", "\\[", "]", "\\]", ":", "\\:")
+	return r.Replace(s)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Neo4jConfig struct {
+	URI      string `yaml:"uri"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// configDir returns ~/.config/jsontoneo.
+func configDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("Error getting user home directory: %v", err)
+	}
+	return filepath.Join(home, ".config", "jsontoneo")
+}
+
+// loadConfig reads the Neo4j configuration, prompting for it and writing it
+// to disk on the first run.
+func loadConfig() Neo4jConfig {
+	dir := configDir()
+	configPath := filepath.Join(dir, "neo4j_config.yaml")
+
+	var config Neo4jConfig
+
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			log.Fatalf("Error creating config directory: %v", err)
+		}
+
+		reader := bufio.NewReader(os.Stdin)
+
+		fmt.Print("Enter Neo4j URI [default neo4j://localhost:7687]: ")
+		uriInput, _ := reader.ReadString('\n')
+		uriInput = strings.TrimSpace(uriInput)
+		if uriInput == "" {
+			uriInput = "neo4j://localhost:7687"
+		}
+
+		fmt.Print("Enter Neo4j Username [default neo4j]: ")
+		usernameInput, _ := reader.ReadString('\n')
+		usernameInput = strings.TrimSpace(usernameInput)
+		if usernameInput == "" {
+			usernameInput = "neo4j"
+		}
+
+		fmt.Print("Enter Neo4j Password [default neo4jpass]: ")
+		passwordInput, _ := reader.ReadString('\n')
+		passwordInput = strings.TrimSpace(passwordInput)
+		if passwordInput == "" {
+			passwordInput = "neo4jpass"
+		}
+
+		config = Neo4jConfig{
+			URI:      uriInput,
+			Username: usernameInput,
+			Password: passwordInput,
+		}
+
+		yamlData, err := yaml.Marshal(&config)
+		if err != nil {
+			log.Fatalf("Error marshalling YAML: %v", err)
+		}
+
+		err = os.WriteFile(configPath, yamlData, 0600)
+		if err != nil {
+			log.Fatalf("Error writing config file: %v", err)
+		}
+		fmt.Printf("Configuration file created at %s\n", configPath)
+	} else {
+		yamlData, err := os.ReadFile(configPath)
+		if err != nil {
+			log.Fatalf("Error reading config file: %v", err)
+		}
+		err = yaml.Unmarshal(yamlData, &config)
+		if err != nil {
+			log.Fatalf("Error parsing config file: %v", err)
+		}
+	}
+
+	return config
+}
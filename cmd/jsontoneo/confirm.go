@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// confirmDestructive asks the user to confirm an operation that removes count
+// nodes from the graph, e.g. "delete 42 nodes". With yes set (the -yes flag
+// of destructive subcommands) the prompt is skipped, for use in automation.
+// Without a terminal to ask on, the operation is refused unless yes is set.
+func confirmDestructive(action string, count int64, yes bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("refusing to %s without confirmation; use -yes in non-interactive use", action)
+	}
+
+	fmt.Fprintf(os.Stderr, "This will %s (%d nodes). Continue? [y/N]: ", action, count)
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"github.com/pocahon/jsontoneo/pkg/parser"
+)
+
+// consumerMessage is a message read from a stream; ack is whatever the
+// source needs to acknowledge it.
+type consumerMessage struct {
+	value []byte
+	ack   any
+}
+
+// consumerSource is a message stream that can be consumed continuously.
+type consumerSource interface {
+	// fetch blocks until the next message arrives or ctx is done.
+	fetch(ctx context.Context) (consumerMessage, error)
+	// commit acknowledges msgs once they have been written to the graph.
+	commit(ctx context.Context, msgs []consumerMessage) error
+	// name identifies the stream, e.g. kafka://broker:9092/httpx.
+	name() string
+	close() error
+}
+
+// consumerSources maps the -source values of the consume command to the
+// function opening that stream.
+var consumerSources = map[string]func(opts consumeOptions) (consumerSource, error){
+	"kafka": newKafkaSource,
+	"nats":  newNATSSource,
+	"redis": newRedisSource,
+}
+
+func consumerSourceNames() []string {
+	names := make([]string, 0, len(consumerSources))
+	for name := range consumerSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type consumeOptions struct {
+	source       string
+	brokers      stringList
+	topic        string
+	group        string
+	url          string
+	stream       string
+	subject      string
+	consumer     string
+	claimIdle    time.Duration
+	batchSize    int
+	batchTimeout time.Duration
+	logTarget    string
+	importOptions
+}
+
+func consumeFlags(fs *flag.FlagSet) func() {
+	var opts consumeOptions
+	opts.cluster = &clusterOptions{}
+	var filters filterFlags
+	var onlyFields, skipFields, hashFields, tags stringList
+	var scopeFile, operator, ttl string
+	fs.StringVar(&opts.source, "source", "kafka", "Stream to consume ("+strings.Join(consumerSourceNames(), "|")+")")
+	fs.Var(&opts.brokers, "brokers", "Kafka broker addresses (comma-separated, repeatable)")
+	fs.StringVar(&opts.topic, "topic", "", "Kafka topic to consume")
+	fs.StringVar(&opts.group, "group", "jsontoneo", "Kafka or Redis consumer group, or NATS durable consumer name")
+	fs.StringVar(&opts.url, "url", "", "NATS or Redis server URL (default nats://127.0.0.1:4222 or redis://localhost:6379/0)")
+	fs.StringVar(&opts.stream, "stream", "", "NATS JetStream stream name or Redis stream key")
+	fs.StringVar(&opts.subject, "subject", "", "Only consume this NATS subject of the stream")
+	fs.StringVar(&opts.consumer, "consumer", "", "Redis consumer name within the group (default the hostname)")
+	fs.DurationVar(&opts.claimIdle, "claim-idle", 5*time.Minute, "Take over Redis entries that another consumer of the group left unacknowledged this long (0 disables)")
+	fs.IntVar(&opts.batchSize, "batch-size", 500, "Write and commit at most N records per transaction")
+	fs.DurationVar(&opts.batchTimeout, "batch-timeout", 5*time.Second, "Write a partial batch after waiting this long for more records")
+	fs.StringVar(&opts.logTarget, "log-target", "stderr", "Where to log ("+strings.Join(logTargetNames(), "|")+")")
+	filters.register(fs)
+	opts.cluster.register(fs, false)
+	fs.StringVar(&scopeFile, "scope-file", "", "Scope file with in-scope and !out-of-scope hosts, globs and CIDRs, one per line")
+	fs.StringVar(&opts.outOfScope, "out-of-scope", "drop", "What to do with out-of-scope records: drop, or label to write them as :OutOfScope")
+	fs.Var(&onlyFields, "only-fields", "Only write these of the known Host properties, plus the url key; extra properties are still written (comma-separated, repeatable)")
+	fs.Var(&skipFields, "skip-fields", "Do not write these Host properties, known or extra like header.set_cookie or header.* (comma-separated, repeatable)")
+	fs.Var(&hashFields, "hash-fields", "Write these Host properties as SHA-256 hashes, keyed with $JSONTONEO_HASH_KEY when set (comma-separated, repeatable)")
+	fs.Var(&tags, "tag", "Add this tag to the tags property of every node touched (comma-separated, repeatable)")
+	fs.BoolVar(&opts.tagLabels, "tag-labels", false, "Also add the -tag values as labels")
+	fs.StringVar(&opts.project, "project", "", "Import into this project, kept apart from other projects in the same database")
+	fs.StringVar(&opts.mergeStrategy, "merge-strategy", "overwrite", "overwrite Host properties, or versioned to also record changes of status, title and tech as Observation nodes")
+	fs.StringVar(&operator, "operator", "", "Name recorded as imported_by on the Scan node (default the OS user)")
+	fs.BoolVar(&opts.attributeNodes, "attribute-nodes", false, "Also record imported_by, imported_from and tool_version on the nodes the consumer creates")
+	fs.StringVar(&ttl, "ttl", os.Getenv("JSONTONEO_TTL"), "Set expires_at this far ahead on the nodes written, e.g. 180d, for purge -expired (default $JSONTONEO_TTL)")
+
+	return func() {
+		if err := setLogTarget(opts.logTarget); err != nil {
+			log.Fatalf("Invalid -log-target: %v", err)
+		}
+		if opts.batchSize < 1 {
+			log.Fatal("-batch-size must be at least 1")
+		}
+		filter, err := filters.build()
+		if err != nil {
+			log.Fatalf("Invalid filter: %v", err)
+		}
+		opts.filter = filter
+		if !slices.Contains(mergeStrategies, opts.mergeStrategy) {
+			log.Fatalf("Invalid -merge-strategy %q (expected %s)", opts.mergeStrategy, strings.Join(mergeStrategies, " or "))
+		}
+		if opts.outOfScope != "drop" && opts.outOfScope != "label" {
+			log.Fatalf("Invalid -out-of-scope %q (expected drop or label)", opts.outOfScope)
+		}
+		if scopeFile != "" {
+			if opts.scope, err = loadScopeFile(scopeFile); err != nil {
+				log.Fatalf("Error reading scope file: %v", err)
+			}
+		}
+		opts.tags = tags
+		opts.fields, err = neo4jwriter.NewFieldSelection(onlyFields, skipFields, hashFields, hashKey())
+		if err != nil {
+			log.Fatalf("Invalid field selection: %v", err)
+		}
+		opts.attribution = neo4jwriter.NewAttribution(operator)
+		if opts.ttl, err = parseTTL(ttl); err != nil {
+			log.Fatalf("Invalid -ttl: %v", err)
+		}
+
+		open, ok := consumerSources[opts.source]
+		if !ok {
+			log.Fatalf("Invalid -source %q (expected %s)", opts.source, strings.Join(consumerSourceNames(), ", "))
+		}
+		src, err := open(opts)
+		if err != nil {
+			log.Fatalf("Error opening %s source: %v", opts.source, err)
+		}
+
+		os.Exit(exitCode(runConsumer(src, opts)))
+	}
+}
+
+// runConsumer writes the records of src to Neo4j until it is interrupted.
+// Records are written in batches of one transaction each, and a batch is only
+// committed on the stream after it has been written, so an interrupted
+// consumer picks up where it left off. Because all writes MERGE, records that
+// are delivered again do not create duplicates.
+func runConsumer(src consumerSource, opts consumeOptions) *neo4jwriter.Summary {
+	defer src.close()
+	traceCtx, stopTracing := startTracing()
+	defer stopTracing()
+
+	driver := connect()
+	defer driver.Close()
+	out := neo4jwriter.NewNeo4jTarget(driver, opts.cluster.configure)
+	defer out.Close()
+
+	scanID := neo4jwriter.NewScanID()
+	if err := neo4jwriter.CreateScan(out, scanID, src.name(), opts.writerOptions()); err != nil {
+		log.Fatalf("Error creating scan node: %v", err)
+	}
+	log.Printf("Scan ID: %s, consuming %s", scanID, src.name())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	imp := neo4jwriter.New(out, scanID, opts.writerOptions())
+	summary := &neo4jwriter.Summary{ScanID: scanID, File: src.name()}
+	start := time.Now()
+
+	err := consume(ctx, src, opts.batchSize, opts.batchTimeout, func(batch []consumerMessage) error {
+		failed := summary.Failed
+		imp.ImportBatch(traceCtx, parseMessages(batch, summary), summary)
+		opts.cluster.save()
+		log.Printf("Processed batch of %d records (%d written, %d failed in total)", len(batch), summary.Written, summary.Failed)
+		if summary.Failed > failed {
+			return fmt.Errorf("%d records of the last batch could not be written", summary.Failed-failed)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Stopped consuming %s: %v", src.name(), err)
+	}
+
+	if err := neo4jwriter.FinishScan(out, scanID); err != nil {
+		log.Printf("Error finishing scan node: %v", err)
+	}
+	summary.Finish(time.Since(start))
+	if err := summary.Print(os.Stdout, "text"); err != nil {
+		log.Printf("Error printing summary: %v", err)
+	}
+	return summary
+}
+
+// consume reads src in batches of batchSize messages, or of what arrived
+// within batchTimeout of the first one, and passes them to write. A batch is
+// only committed on the stream once write succeeds. When write fails consume
+// stops without committing it, so the batch is delivered again when the
+// consumer is restarted. It returns nil once ctx is done.
+func consume(ctx context.Context, src consumerSource, batchSize int, batchTimeout time.Duration, write func(batch []consumerMessage) error) error {
+	flush := func(batch []consumerMessage) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := write(batch); err != nil {
+			return fmt.Errorf("%w; not committing the batch of %d messages, they will be delivered again", err, len(batch))
+		}
+		if err := src.commit(context.Background(), batch); err != nil {
+			log.Printf("Error committing %d messages, they will be delivered again: %v", len(batch), err)
+		}
+		return nil
+	}
+
+	var batch []consumerMessage
+	var deadline time.Time
+	for {
+		fetchCtx, cancel := ctx, context.CancelFunc(func() {})
+		if len(batch) > 0 {
+			fetchCtx, cancel = context.WithDeadline(ctx, deadline)
+		}
+		msg, err := src.fetch(fetchCtx)
+		cancel()
+
+		if err == nil {
+			if len(batch) == 0 {
+				deadline = time.Now().Add(batchTimeout)
+			}
+			batch = append(batch, msg)
+			if len(batch) >= batchSize {
+				if err := flush(batch); err != nil {
+					return err
+				}
+				batch = nil
+			}
+			continue
+		}
+
+		if err := flush(batch); err != nil {
+			return err
+		}
+		batch = nil
+		if ctx.Err() != nil {
+			return nil
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("reading: %w", err)
+		}
+	}
+}
+
+// parseMessages decodes the httpx JSON records of a batch.
+func parseMessages(batch []consumerMessage, summary *neo4jwriter.Summary) []model.HttpxResult {
+	results := make([]model.HttpxResult, 0, len(batch))
+	for _, msg := range batch {
+		summary.Read++
+		value := bytes.TrimSpace(msg.value)
+		if len(value) == 0 {
+			summary.Skipped++
+			continue
+		}
+		result, err := parser.ParseHttpx(value)
+		if err != nil {
+			log.Printf("Error parsing JSON: %v", err)
+			summary.ParseErrors++
+			continue
+		}
+		summary.Parsed++
+		results = append(results, result)
+	}
+	return results
+}
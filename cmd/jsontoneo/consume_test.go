@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSource delivers msgs and records the batches that are committed. Once
+// msgs is exhausted and no batch is pending it calls done, which cancels the
+// consumer; a non-nil err is returned instead.
+type fakeSource struct {
+	msgs      []string
+	err       error
+	done      func()
+	committed [][]string
+}
+
+func (s *fakeSource) fetch(ctx context.Context) (consumerMessage, error) {
+	if len(s.msgs) > 0 {
+		msg := s.msgs[0]
+		s.msgs = s.msgs[1:]
+		return consumerMessage{value: []byte(msg), ack: msg}, nil
+	}
+	if s.err != nil {
+		return consumerMessage{}, s.err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		s.done()
+	}
+	<-ctx.Done()
+	return consumerMessage{}, ctx.Err()
+}
+
+func (s *fakeSource) commit(ctx context.Context, msgs []consumerMessage) error {
+	s.committed = append(s.committed, values(msgs))
+	return nil
+}
+
+func (s *fakeSource) name() string { return "fake" }
+func (s *fakeSource) close() error { return nil }
+
+func values(msgs []consumerMessage) []string {
+	var v []string
+	for _, msg := range msgs {
+		v = append(v, string(msg.value))
+	}
+	return v
+}
+
+func TestConsume(t *testing.T) {
+	tests := []struct {
+		name            string
+		readErr         error
+		failAt          int
+		written, commit [][]string
+		err             string
+	}{
+		{
+			name:    "all written",
+			written: [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+			commit:  [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+		},
+		{
+			name:    "write fails",
+			failAt:  2,
+			written: [][]string{{"a", "b"}, {"c", "d"}},
+			commit:  [][]string{{"a", "b"}},
+			err:     "not committing the batch of 2 messages",
+		},
+		{
+			name:    "read error",
+			readErr: errors.New("connection reset"),
+			written: [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+			commit:  [][]string{{"a", "b"}, {"c", "d"}, {"e"}},
+			err:     "reading: connection reset",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			src := &fakeSource{msgs: []string{"a", "b", "c", "d", "e"}, err: tt.readErr, done: cancel}
+
+			var written [][]string
+			err := consume(ctx, src, 2, 10*time.Millisecond, func(batch []consumerMessage) error {
+				written = append(written, values(batch))
+				if len(written) == tt.failAt {
+					return errors.New("1 records of the last batch could not be written")
+				}
+				return nil
+			})
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatal(err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("consume = %v, want %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(written, tt.written) {
+				t.Errorf("written = %v, want %v", written, tt.written)
+			}
+			if !reflect.DeepEqual(src.committed, tt.commit) {
+				t.Errorf("committed = %v, want %v", src.committed, tt.commit)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type coverageOptions struct {
+	scopeFile string
+	project   string
+	output    outputOptions
+	cluster   *clusterOptions
+}
+
+// coverageAssets are the names and IPs the graph knows of, and the ones
+// httpx probed: those a Host was written for.
+type coverageAssets struct {
+	names       map[string]bool
+	probedNames map[string]bool
+	ips         map[string]bool
+	probedIPs   map[string]bool
+}
+
+// coverageRule is the coverage of an in-scope line of the scope file: of the
+// names (or, for a CIDR, IPs) it matches, how many were probed.
+type coverageRule struct {
+	Rule     string   `json:"rule"`
+	Known    int      `json:"known"`
+	Probed   int      `json:"probed"`
+	Coverage *float64 `json:"coverage"`
+}
+
+type coverageReport struct {
+	Rules    []coverageRule `json:"rules"`
+	Empty    []string       `json:"empty"`
+	Known    int            `json:"known"`
+	Probed   int            `json:"probed"`
+	Coverage *float64       `json:"coverage"`
+}
+
+func coverageFlags(fs *flag.FlagSet) func() {
+	opts := coverageOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scopeFile, "scope-file", "", "Scope file to measure the coverage of, as for import -scope-file")
+	fs.StringVar(&opts.project, "project", "", "Only count the nodes of this project")
+	opts.output.register(fs, "table")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		if opts.scopeFile == "" {
+			log.Fatal("Usage: jsontoneo coverage -scope-file scope.txt")
+		}
+		opts.output.check()
+		rules, err := loadScopeFile(opts.scopeFile)
+		if err != nil {
+			log.Fatalf("Error reading scope file: %v", err)
+		}
+		if len(rules.in) == 0 {
+			log.Fatalf("%s has no in-scope lines", opts.scopeFile)
+		}
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		assets, err := loadCoverageAssets(session, opts.project)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		r := measureCoverage(rules, assets)
+		err = opts.output.write(r, r.Rules, func(w io.Writer) error {
+			return r.print(w)
+		})
+		if err != nil {
+			log.Fatalf("Error writing coverage: %v", err)
+		}
+	}
+}
+
+// loadCoverageAssets reads the hostnames and IPs of the hosts, and the names
+// and IPs known from Domain, Certificate and IP nodes.
+func loadCoverageAssets(session neo4j.Session, project string) (coverageAssets, error) {
+	a := coverageAssets{names: map[string]bool{}, probedNames: map[string]bool{}, ips: map[string]bool{}, probedIPs: map[string]bool{}}
+	params := map[string]any{"project": project}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, m := range []map[string]bool{a.names, a.probedNames, a.ips, a.probedIPs} {
+			clear(m)
+		}
+		res, err := tx.Run(`
+		MATCH (h:Host) WHERE `+neo4jwriter.ProjectCond("h")+`
+		RETURN h.url, h.input, h.ip
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			r := model.HttpxResult{URL: propString(v[0]), Input: propString(v[1])}
+			for _, s := range []string{r.Hostname(), propString(v[2])} {
+				switch {
+				case s == "":
+				case net.ParseIP(s) != nil:
+					a.ips[s], a.probedIPs[s] = true, true
+				default:
+					a.names[s], a.probedNames[s] = true, true
+				}
+			}
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		res, err = tx.Run(`
+		MATCH (d:Domain) WHERE `+neo4jwriter.ProjectCond("d")+`
+		RETURN toLower(d.name) AS name
+		UNION
+		MATCH (c:Certificate) WHERE `+neo4jwriter.ProjectCond("c")+`
+		UNWIND coalesce(c.subject_an, []) + [c.subject_cn] AS name
+		RETURN toLower(name) AS name
+		UNION
+		MATCH (i:IP) WHERE `+neo4jwriter.ProjectCond("i")+`
+		RETURN i.address AS name
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Name query error: %w", err)
+		}
+		for res.Next() {
+			s := propString(res.Record().Values[0])
+			switch {
+			case s == "" || strings.HasPrefix(s, "*."):
+			case net.ParseIP(s) != nil:
+				a.ips[s] = true
+			default:
+				a.names[s] = true
+			}
+		}
+		return nil, res.Err()
+	})
+	return a, err
+}
+
+// measureCoverage counts per in-scope rule the names or IPs it matches and
+// how many of them were probed. Assets matching an out-of-scope rule do not
+// count.
+func measureCoverage(rules *scopeRules, a coverageAssets) coverageReport {
+	excluded := func(host string, ips []net.IP) bool {
+		for _, r := range rules.out {
+			if r.match(host, ips) {
+				return true
+			}
+		}
+		return false
+	}
+
+	r := coverageReport{Rules: []coverageRule{}, Empty: []string{}}
+	known, probed := map[string]bool{}, map[string]bool{}
+	for _, rule := range rules.in {
+		c := coverageRule{Rule: rule.String()}
+		count := func(asset string, host string, ips []net.IP, wasProbed bool) {
+			if !rule.match(host, ips) || excluded(host, ips) {
+				return
+			}
+			c.Known++
+			known[asset] = true
+			if wasProbed {
+				c.Probed++
+				probed[asset] = true
+			}
+		}
+		if rule.cidr != nil {
+			for s := range a.ips {
+				count(s, s, []net.IP{net.ParseIP(s)}, a.probedIPs[s])
+			}
+		} else {
+			for s := range a.names {
+				count(s, s, nil, a.probedNames[s])
+			}
+		}
+		c.Coverage = percentage(c.Probed, c.Known)
+		if c.Known == 0 {
+			r.Empty = append(r.Empty, c.Rule)
+		}
+		r.Rules = append(r.Rules, c)
+	}
+	r.Known, r.Probed = len(known), len(probed)
+	r.Coverage = percentage(r.Probed, r.Known)
+	return r
+}
+
+// percentage returns part as a percentage of total, or nil without a total.
+func percentage(part, total int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	p := 100 * float64(part) / float64(total)
+	return &p
+}
+
+func (r coverageReport) print(w io.Writer) error {
+	fmt.Fprintf(w, "%-40s  %8s  %8s  %8s\n", "RULE", "KNOWN", "PROBED", "COVERAGE")
+	for _, c := range r.Rules {
+		fmt.Fprintf(w, "%-40s  %8d  %8d  %8s\n", c.Rule, c.Known, c.Probed, formatPercentage(c.Coverage))
+	}
+	fmt.Fprintf(w, "\n%d known names and IPs in scope, %d probed (%s)\n", r.Known, r.Probed, formatPercentage(r.Coverage))
+	if len(r.Empty) > 0 {
+		fmt.Fprintf(w, "\nNothing in the graph for %d in-scope lines:\n", len(r.Empty))
+		for _, rule := range r.Empty {
+			fmt.Fprintf(w, "  %s\n", rule)
+		}
+	}
+	return nil
+}
+
+func formatPercentage(p *float64) string {
+	if p == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", *p)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type deadOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	scans   int
+	cluster *clusterOptions
+}
+
+// deadHost is a host that does not resolve, or that did not answer with a
+// 2xx or 3xx status in any of the recent scans that saw it.
+type deadHost struct {
+	URL        string     `json:"url"`
+	Unresolved bool       `json:"unresolved"`
+	Dead       bool       `json:"dead"`
+	Statuses   []int      `json:"statuses,omitempty"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+}
+
+func deadFlags(fs *flag.FlagSet) func() {
+	opts := deadOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only list hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only list the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.IntVar(&opts.scans, "scans", 3, "Look at the responses of this many most recent scans")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		if opts.scans < 1 {
+			log.Fatal("-scans must be at least 1")
+		}
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		hosts, err := loadDeadHosts(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		err = opts.output.write(hosts, hosts, func(w io.Writer) error {
+			return printDeadHosts(w, hosts, opts.scans)
+		})
+		if err != nil {
+			log.Fatalf("Error writing hosts: %v", err)
+		}
+	}
+}
+
+// loadDeadHosts returns the hosts without an IP, and the hosts whose every
+// recorded status in the last opts.scans scans was outside 200-399. Hosts
+// none of those scans saw are left to purge -older-than.
+func loadDeadHosts(session neo4j.Session, opts deadOptions) ([]deadHost, error) {
+	hosts := []deadHost{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		hosts = hosts[:0]
+		res, err := tx.Run(`
+		OPTIONAL MATCH (s:Scan) WHERE `+neo4jwriter.ProjectCond("s")+`
+		WITH s ORDER BY s.started_at DESC LIMIT $scans
+		WITH collect(s) AS recent
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[r:SEEN_IN]->(s:Scan) WHERE s IN recent
+		WITH h, r, s ORDER BY s.started_at DESC
+		WITH h, collect(r.status) AS statuses
+		WITH h, statuses,
+		     NOT (h)-[:RESOLVES_TO]->() AND coalesce(h.ip, '') = '' AS unresolved,
+		     size(statuses) > 0 AND none(st IN statuses WHERE st >= 200 AND st < 400) AS dead
+		WHERE unresolved OR dead
+		RETURN h.url, unresolved, dead, statuses, h.last_seen
+		ORDER BY h.url
+		`, map[string]any{"scope": opts.scope, "project": opts.project, "scans": opts.scans})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			h := deadHost{URL: propString(v[0])}
+			h.Unresolved, _ = v[1].(bool)
+			h.Dead, _ = v[2].(bool)
+			if statuses, ok := v[3].([]any); ok {
+				for _, s := range statuses {
+					h.Statuses = append(h.Statuses, propInt(s))
+				}
+			}
+			if t, ok := v[4].(time.Time); ok {
+				h.LastSeen = &t
+			}
+			hosts = append(hosts, h)
+		}
+		return nil, res.Err()
+	})
+	return hosts, err
+}
+
+func printDeadHosts(w io.Writer, hosts []deadHost, scans int) error {
+	if len(hosts) == 0 {
+		_, err := fmt.Fprintln(w, "No unresolved or dead hosts")
+		return err
+	}
+	fmt.Fprintf(w, "%-20s  %-16s  %-10s  %s\n", "REASON", "STATUSES", "LAST SEEN", "URL")
+	unresolved, dead := 0, 0
+	for _, h := range hosts {
+		var reasons, statuses []string
+		if h.Unresolved {
+			reasons = append(reasons, "unresolved")
+			unresolved++
+		}
+		if h.Dead {
+			reasons = append(reasons, "no 2xx/3xx")
+			dead++
+		}
+		for _, s := range h.Statuses {
+			statuses = append(statuses, fmt.Sprint(s))
+		}
+		lastSeen := "-"
+		if h.LastSeen != nil {
+			lastSeen = h.LastSeen.Local().Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%-20s  %-16s  %-10s  %s\n", strings.Join(reasons, ", "), strings.Join(statuses, ","), lastSeen, h.URL)
+	}
+	_, err := fmt.Fprintf(w, "\n%d hosts: %d unresolved, %d without a 2xx/3xx response in the last %d scans\n", len(hosts), unresolved, dead, scans)
+	return err
+}
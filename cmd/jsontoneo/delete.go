@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type deleteOptions struct {
+	matchHost stringList
+	tech      stringList
+	ipCIDR    stringList
+	project   string
+	dryRun    bool
+	yes       bool
+}
+
+// deletePlan is what a delete would do: the matching hosts, and the nodes
+// that only exist because of them.
+type deletePlan struct {
+	Hosts   []string
+	Orphans int64
+	Changes int64
+	ids     []string
+}
+
+func deleteFlags(fs *flag.FlagSet) func() {
+	var opts deleteOptions
+	fs.Var(&opts.matchHost, "match-host", "Delete hosts matching this glob, e.g. '*.old-client.com' (repeatable)")
+	fs.Var(&opts.tech, "tech", "Delete hosts running one of these technologies, e.g. WordPress (repeatable)")
+	fs.Var(&opts.ipCIDR, "ip-cidr", "Delete hosts whose IP is in this range, e.g. 10.0.0.0/8 (repeatable)")
+	fs.StringVar(&opts.project, "project", "", "Only delete hosts of this project")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Only show what would be deleted")
+	fs.BoolVar(&opts.yes, "yes", false, "Do not ask for confirmation")
+
+	return func() {
+		if len(opts.matchHost) == 0 && len(opts.tech) == 0 && len(opts.ipCIDR) == 0 {
+			log.Fatal("Usage: jsontoneo delete [-match-host '*.example.com'] [-tech WordPress] [-ip-cidr 10.0.0.0/8] [-dry-run]")
+		}
+		filter, err := (&filterFlags{matchHost: opts.matchHost, matchTech: opts.tech}).build()
+		if err != nil {
+			log.Fatalf("Invalid filter: %v", err)
+		}
+		var cidrs []*net.IPNet
+		for _, c := range opts.ipCIDR {
+			_, cidr, err := net.ParseCIDR(c)
+			if err != nil {
+				log.Fatalf("Invalid -ip-cidr %q: %v", c, err)
+			}
+			cidrs = append(cidrs, cidr)
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+		defer session.Close()
+
+		plan, err := planDelete(session, opts.project, func(r *model.HttpxResult) bool {
+			return filter.Match(r) && matchCIDRs(cidrs, r.Host)
+		})
+		if err != nil {
+			log.Fatalf("Error finding hosts: %v", err)
+		}
+		plan.print(os.Stdout)
+		if opts.dryRun || len(plan.Hosts) == 0 {
+			return
+		}
+
+		total := int64(len(plan.Hosts)) + plan.Orphans + plan.Changes
+		ok, err := confirmDestructive(fmt.Sprintf("delete %d hosts and their orphaned nodes", len(plan.Hosts)), total, opts.yes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			log.Print("Delete cancelled")
+			return
+		}
+
+		_, err = session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			return nil, deleteHosts(tx, plan.ids)
+		})
+		if err != nil {
+			log.Fatalf("Error deleting hosts: %v", err)
+		}
+		log.Printf("Deleted %d hosts, %d orphaned nodes and %d Change and Observation nodes", len(plan.Hosts), plan.Orphans, plan.Changes)
+	}
+}
+
+// matchCIDRs reports whether ip is in one of cidrs; no ranges match everything.
+func matchCIDRs(cidrs []*net.IPNet, ip string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	for _, cidr := range cidrs {
+		if parsed != nil && cidr.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// planDelete finds the hosts for which match returns true. The hosts are
+// matched the way import filters records, on their url, input, ip and tech.
+func planDelete(session neo4j.Session, project string, match func(*model.HttpxResult) bool) (*deletePlan, error) {
+	plan := &deletePlan{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+neo4jwriter.ProjectCond("h")+`
+		RETURN elementId(h) AS id, h.url AS url, h.input AS input, h.ip AS ip, h.tech AS tech
+		ORDER BY url
+		`, map[string]any{"project": project})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			result := model.HttpxResult{
+				URL:   propString(v[1]),
+				Input: propString(v[2]),
+				Host:  propString(v[3]),
+				Tech:  propStrings(v[4]),
+			}
+			if !match(&result) {
+				continue
+			}
+			plan.ids = append(plan.ids, propString(v[0]))
+			plan.Hosts = append(plan.Hosts, result.URL)
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		plan.Orphans, plan.Changes, err = countDependents(tx, plan.ids)
+		return nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (p *deletePlan) print(w io.Writer) {
+	fmt.Fprintf(w, "Matching hosts (%d)\n", len(p.Hosts))
+	for _, url := range p.Hosts {
+		fmt.Fprintf(w, "  %s\n", url)
+	}
+	fmt.Fprintf(w, "Orphaned ASN/IP/Tech nodes: %d\n", p.Orphans)
+	fmt.Fprintf(w, "Change and Observation nodes of these hosts: %d\n", p.Changes)
+}
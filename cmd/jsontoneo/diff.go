@@ -0,0 +1,427 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+// observation is what a scan saw of a host, as stored on SEEN_IN.
+type observation struct {
+	URL    string
+	Status int
+	Title  string
+	Port   string
+}
+
+// hostPort returns the hostname and port of the observation.
+func (o observation) hostPort() (string, string) {
+	u, err := url.Parse(o.URL)
+	if err != nil {
+		return o.URL, o.Port
+	}
+	port := o.Port
+	if port == "" {
+		port = u.Port()
+	}
+	if port == "" {
+		switch u.Scheme {
+		case "https":
+			port = "443"
+		case "http":
+			port = "80"
+		}
+	}
+	return u.Hostname(), port
+}
+
+type hostChange struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Field string `json:"field,omitempty"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+type scanDiff struct {
+	Project      string       `json:"project,omitempty"`
+	From         string       `json:"from"`
+	To           string       `json:"to"`
+	NewHosts     []string     `json:"new_hosts"`
+	RemovedHosts []string     `json:"removed_hosts"`
+	Changed      []hostChange `json:"changed"`
+	NewPorts     []hostChange `json:"new_ports"`
+
+	// after holds the observations of the new side, for alerts.
+	after map[string]observation
+	// since is set when the new side is a period rather than a scan.
+	since bool
+}
+
+// changes returns all differences as a flat list, as written to Change nodes.
+func (d *scanDiff) changes() []hostChange {
+	var all []hostChange
+	for _, u := range d.NewHosts {
+		all = append(all, hostChange{Type: "new_host", URL: u})
+	}
+	for _, u := range d.RemovedHosts {
+		all = append(all, hostChange{Type: "removed_host", URL: u})
+	}
+	all = append(all, d.Changed...)
+	all = append(all, d.NewPorts...)
+	return all
+}
+
+type diffOptions struct {
+	scans   stringList
+	since   string
+	output  outputOptions
+	write   bool
+	retire  bool
+	project string
+	notify  string
+	cluster *clusterOptions
+}
+
+func diffFlags(fs *flag.FlagSet) func() {
+	opts := diffOptions{cluster: &clusterOptions{}}
+	fs.Var(&opts.scans, "scan", "Scan ID to compare; give it twice (old, new), or once to compare with the previous scan")
+	fs.StringVar(&opts.since, "since", "", "Compare the scans of this period, e.g. 7d, with everything before it")
+	opts.output.register(fs, "text")
+	fs.BoolVar(&opts.write, "write", false, "Write the differences back to the graph as Change nodes")
+	fs.BoolVar(&opts.retire, "retire", false, "Label the removed hosts :Retired with a retired_at time")
+	fs.StringVar(&opts.project, "project", "", "Only compare the scans and hosts of this project")
+	fs.StringVar(&opts.notify, "notify", "", "Post the hosts seen for the first time to this Slack, Discord or generic webhook")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		opts.cluster.check()
+		if len(opts.scans) > 2 || (len(opts.scans) > 0 && opts.since != "") {
+			log.Fatal("Usage: jsontoneo diff [-scan OLD] [-scan NEW] | [-since 7d]")
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeWrite)
+		defer session.Close()
+		defer opts.cluster.save()
+
+		d, err := diffScans(session, opts)
+		if err != nil {
+			log.Fatalf("Error comparing scans: %v", err)
+		}
+
+		err = opts.output.write(d, d.changes(), d.print)
+		if err != nil {
+			log.Fatalf("Error writing diff: %v", err)
+		}
+
+		if opts.write {
+			n, err := writeChanges(session, d)
+			if err != nil {
+				log.Fatalf("Error writing Change nodes: %v", err)
+			}
+			log.Printf("Wrote %d Change nodes", n)
+		}
+		if opts.retire && len(d.RemovedHosts) > 0 {
+			_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+				return tx.Run(`
+				MATCH (h:Host) WHERE h.url IN $urls AND `+neo4jwriter.ProjectCond("h")+`
+				`+retireCypher, map[string]any{"urls": d.RemovedHosts, "project": d.Project})
+			})
+			if err != nil {
+				log.Fatalf("Error retiring hosts: %v", err)
+			}
+			log.Printf("Labeled %d removed hosts :Retired", len(d.RemovedHosts))
+		}
+		if opts.notify != "" {
+			hosts, err := firstSeen(session, d)
+			if err != nil {
+				log.Fatalf("Error finding new assets: %v", err)
+			}
+			if len(hosts) == 0 {
+				log.Print("No new assets, no alert sent")
+				return
+			}
+			if err := notifyNewAssets(opts.notify, d, hosts); err != nil {
+				log.Fatalf("Error sending alert: %v", err)
+			}
+			log.Printf("Alerted %d new assets", len(hosts))
+		}
+	}
+}
+
+// firstSeen returns the new hosts of d that no earlier scan has seen. A host
+// that was down in the previous scan is new in the diff, but not a new asset.
+func firstSeen(session neo4j.Session, d *scanDiff) ([]observation, error) {
+	urls := d.NewHosts
+	if !d.since && len(urls) > 0 {
+		found, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+			res, err := tx.Run(`
+			MATCH (to:Scan {id: $to})
+			MATCH (h:Host)-[:SEEN_IN]->(s:Scan)
+			WHERE h.url IN $urls AND `+neo4jwriter.ProjectCond("h")+`
+			WITH h, to, min(s.started_at) AS first
+			WHERE first >= to.started_at
+			RETURN h.url AS url
+			`, map[string]any{"to": d.To, "urls": urls, "project": d.Project})
+			if err != nil {
+				return nil, err
+			}
+			var urls []string
+			for res.Next() {
+				urls = append(urls, propString(res.Record().Values[0]))
+			}
+			return urls, res.Err()
+		})
+		if err != nil {
+			return nil, err
+		}
+		urls, _ = found.([]string)
+		sort.Strings(urls)
+	}
+
+	hosts := make([]observation, len(urls))
+	for i, u := range urls {
+		hosts[i] = d.after[u]
+	}
+	return hosts, nil
+}
+
+// diffScans resolves the scans to compare from opts and computes the diff.
+func diffScans(session neo4j.Session, opts diffOptions) (*scanDiff, error) {
+	var oldCond, newCond string
+	params := map[string]any{"project": opts.project}
+	d := &scanDiff{Project: opts.project}
+
+	if opts.since != "" {
+		age, err := parseAge(opts.since)
+		if err != nil {
+			return nil, err
+		}
+		cutoff := time.Now().Add(-age).UTC()
+		params["cutoff"] = cutoff
+		oldCond = "s.started_at < $cutoff"
+		newCond = "s.started_at >= $cutoff"
+		d.From = "before " + cutoff.Format(time.RFC3339)
+		d.To = "since " + cutoff.Format(time.RFC3339)
+		d.since = true
+	} else {
+		from, to, err := resolveDiffScans(session, opts.scans, opts.project)
+		if err != nil {
+			return nil, err
+		}
+		params["from"], params["to"] = from, to
+		oldCond, newCond = "s.id = $from", "s.id = $to"
+		d.From, d.To = from, to
+	}
+
+	before, err := loadObservations(session, oldCond, params)
+	if err != nil {
+		return nil, err
+	}
+	after, err := loadObservations(session, newCond, params)
+	if err != nil {
+		return nil, err
+	}
+	d.compare(before, after)
+	d.after = after
+	return d, nil
+}
+
+// resolveDiffScans returns the old and new scan IDs: the given ones, the
+// given scan and the one before it, or the two most recent scans.
+func resolveDiffScans(session neo4j.Session, scans []string, project string) (string, string, error) {
+	if len(scans) == 2 {
+		return scans[0], scans[1], nil
+	}
+
+	ids, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		query := `
+		MATCH (s:Scan)
+		WHERE ` + neo4jwriter.ProjectCond("s") + `
+		RETURN s.id AS id
+		ORDER BY s.started_at DESC
+		LIMIT 2
+		`
+		params := map[string]any{"project": project}
+		if len(scans) == 1 {
+			query = `
+			MATCH (n:Scan {id: $id})
+			MATCH (s:Scan) WHERE s.started_at <= n.started_at AND ` + neo4jwriter.ProjectCond("s") + `
+			RETURN s.id AS id
+			ORDER BY s.started_at DESC
+			LIMIT 2
+			`
+			params["id"] = scans[0]
+		}
+		res, err := tx.Run(query, params)
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		for res.Next() {
+			id, _ := res.Record().Get("id")
+			ids = append(ids, propString(id))
+		}
+		return ids, res.Err()
+	})
+	if err != nil {
+		return "", "", err
+	}
+	found := ids.([]string)
+	if len(found) < 2 {
+		return "", "", fmt.Errorf("need two scans to compare, found %d", len(found))
+	}
+	return found[1], found[0], nil
+}
+
+// loadObservations returns per URL the latest observation of the scans
+// matching cond. Hosts imported before observations were stored on SEEN_IN
+// fall back to the current Host properties.
+func loadObservations(session neo4j.Session, cond string, params map[string]any) (map[string]observation, error) {
+	obs, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		MATCH (h:Host)-[r:SEEN_IN]->(s:Scan)
+		WHERE `+cond+` AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN h.url AS url,
+		       coalesce(r.status, h.status) AS status,
+		       coalesce(r.title, h.title) AS title,
+		       coalesce(r.port, h.port) AS port
+		ORDER BY s.started_at
+		`, params)
+		if err != nil {
+			return nil, err
+		}
+		obs := map[string]observation{}
+		for res.Next() {
+			rec := res.Record()
+			o := observation{
+				URL:    propString(rec.Values[0]),
+				Status: propInt(rec.Values[1]),
+				Title:  propString(rec.Values[2]),
+				Port:   propString(rec.Values[3]),
+			}
+			obs[o.URL] = o
+		}
+		return obs, res.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obs.(map[string]observation), nil
+}
+
+func (d *scanDiff) compare(before, after map[string]observation) {
+	oldPorts := map[string]bool{}
+	for _, o := range before {
+		host, port := o.hostPort()
+		oldPorts[host+":"+port] = true
+	}
+
+	for u, o := range after {
+		prev, ok := before[u]
+		if !ok {
+			d.NewHosts = append(d.NewHosts, u)
+		} else {
+			if prev.Status != o.Status {
+				d.Changed = append(d.Changed, hostChange{Type: "changed", URL: u, Field: "status", Old: strconv.Itoa(prev.Status), New: strconv.Itoa(o.Status)})
+			}
+			if prev.Title != o.Title {
+				d.Changed = append(d.Changed, hostChange{Type: "changed", URL: u, Field: "title", Old: prev.Title, New: o.Title})
+			}
+		}
+
+		host, port := o.hostPort()
+		if port != "" && !oldPorts[host+":"+port] {
+			oldPorts[host+":"+port] = true
+			d.NewPorts = append(d.NewPorts, hostChange{Type: "new_port", URL: u, Field: "port", New: host + ":" + port})
+		}
+	}
+	for u := range before {
+		if _, ok := after[u]; !ok {
+			d.RemovedHosts = append(d.RemovedHosts, u)
+		}
+	}
+
+	sort.Strings(d.NewHosts)
+	sort.Strings(d.RemovedHosts)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		if d.Changed[i].URL != d.Changed[j].URL {
+			return d.Changed[i].URL < d.Changed[j].URL
+		}
+		return d.Changed[i].Field < d.Changed[j].Field
+	})
+	sort.Slice(d.NewPorts, func(i, j int) bool { return d.NewPorts[i].New < d.NewPorts[j].New })
+}
+
+func (d *scanDiff) print(w io.Writer) error {
+	fmt.Fprintf(w, "Diff %s -> %s\n", d.From, d.To)
+
+	fmt.Fprintf(w, "\nNew hosts (%d)\n", len(d.NewHosts))
+	for _, u := range d.NewHosts {
+		fmt.Fprintf(w, "  + %s\n", u)
+	}
+	fmt.Fprintf(w, "\nRemoved hosts (%d)\n", len(d.RemovedHosts))
+	for _, u := range d.RemovedHosts {
+		fmt.Fprintf(w, "  - %s\n", u)
+	}
+	fmt.Fprintf(w, "\nChanged (%d)\n", len(d.Changed))
+	for _, c := range d.Changed {
+		fmt.Fprintf(w, "  ~ %s %s: %q -> %q\n", c.URL, c.Field, c.Old, c.New)
+	}
+	fmt.Fprintf(w, "\nNew open ports (%d)\n", len(d.NewPorts))
+	for _, c := range d.NewPorts {
+		fmt.Fprintf(w, "  + %s (%s)\n", c.New, c.URL)
+	}
+	return nil
+}
+
+// writeChanges stores the differences as (:Change) nodes linked to their
+// Host, and returns how many were written.
+func writeChanges(session neo4j.Session, d *scanDiff) (int, error) {
+	changes := d.changes()
+	if len(changes) == 0 {
+		return 0, nil
+	}
+	rows := make([]map[string]any, len(changes))
+	for i, c := range changes {
+		rows[i] = map[string]any{"type": c.Type, "url": c.URL, "field": c.Field, "old": c.Old, "new": c.New}
+	}
+
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		_, err := tx.Run(`
+		UNWIND $changes AS c
+		MATCH (h:Host {url: c.url})
+		WHERE `+neo4jwriter.ProjectCond("h")+`
+		CREATE (ch:Change {
+		    type:        c.type,
+		    field:       c.field,
+		    old:         c.old,
+		    new:         c.new,
+		    from_scan:   $from,
+		    to_scan:     $to,
+		    detected_at: datetime()
+		})
+		SET ch.project = h.project
+		CREATE (h)-[:CHANGED]->(ch)
+		WITH ch
+		OPTIONAL MATCH (s:Scan {id: $to})
+		FOREACH (_ IN CASE WHEN s IS NULL THEN [] ELSE [1] END | CREATE (ch)-[:DETECTED_IN]->(s))
+		`, map[string]any{"changes": rows, "from": d.From, "to": d.To, "project": d.Project})
+		return nil, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(changes), nil
+}
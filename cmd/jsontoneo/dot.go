@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var labelShapes = map[string]string{
+	"Host": "box",
+	"IP":   "ellipse",
+	"ASN":  "hexagon",
+	"Tech": "note",
+}
+
+// writeDOT writes g as a Graphviz digraph, e.g. for rendering small scopes
+// with `dot -Tsvg` and embedding them in reports.
+func writeDOT(w io.Writer, g *exportGraph) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "digraph jsontoneo {")
+	fmt.Fprintln(bw, "  rankdir=LR;")
+	fmt.Fprintln(bw, `  node [style=filled, fontname="Helvetica", fontsize=10];`)
+	fmt.Fprintln(bw, `  edge [fontname="Helvetica", fontsize=8];`)
+
+	for _, n := range g.Nodes {
+		c := labelColor(n.Label())
+		shape := labelShapes[n.Label()]
+		if shape == "" {
+			shape = "ellipse"
+		}
+		fmt.Fprintf(bw, "  %s [label=%s, shape=%s, fillcolor=\"#%02x%02x%02x\"];\n",
+			dotQuote(n.ID), dotQuote(n.Name()), shape, c.r, c.g, c.b)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(bw, "  %s -> %s [label=%s];\n", dotQuote(e.Source), dotQuote(e.Target), dotQuote(e.Type))
+	}
+
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// esPageSize is the number of documents fetched per scroll request.
+const esPageSize = 1000
+
+type esSearchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Source json.RawMessage `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// esClient is a minimal client for the search and scroll APIs of
+// Elasticsearch and OpenSearch.
+type esClient struct {
+	base    *url.URL
+	headers headerList
+}
+
+// openElasticsearch scrolls through the documents of an index pattern that
+// match the query, and returns their _source as JSON lines, so they run
+// through the same pipeline as a file.
+func openElasticsearch(opts inputOptions) (*input, error) {
+	if opts.index == "" {
+		return nil, fmt.Errorf("-from elasticsearch requires -index")
+	}
+	rawURL := opts.esURL
+	if rawURL == "" {
+		rawURL = os.Getenv("ELASTICSEARCH_URL")
+	}
+	if rawURL == "" {
+		rawURL = "http://localhost:9200"
+	}
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client := &esClient{base: base, headers: opts.headers}
+
+	query, err := esQuery(opts.query)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(map[string]any{"size": esPageSize, "query": query, "sort": []string{"_doc"}})
+	page, err := client.do(http.MethodPost, "/"+url.PathEscape(opts.index)+"/_search?scroll=5m", body)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Reading %d documents from %s", page.Hits.Total.Value, opts.index)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(client.scroll(page, pw))
+	}()
+
+	source := *base
+	source.User = nil
+	return &input{Reader: pr, source: strings.TrimSuffix(source.String(), "/") + "/" + opts.index, closers: []io.Closer{pr}}, nil
+}
+
+// esQuery returns the query DSL for -query: a JSON query as is, a Lucene
+// query string otherwise, or match_all when empty.
+func esQuery(q string) (any, error) {
+	q = strings.TrimSpace(q)
+	switch {
+	case q == "":
+		return map[string]any{"match_all": map[string]any{}}, nil
+	case strings.HasPrefix(q, "{"):
+		var dsl map[string]any
+		if err := json.Unmarshal([]byte(q), &dsl); err != nil {
+			return nil, fmt.Errorf("invalid -query JSON: %w", err)
+		}
+		return dsl, nil
+	default:
+		return map[string]any{"query_string": map[string]any{"query": q}}, nil
+	}
+}
+
+// scroll writes the documents of page and all following pages to w, one per
+// line, and clears the scroll context when done.
+func (c *esClient) scroll(page *esSearchResponse, w io.Writer) error {
+	defer func() {
+		if page.ScrollID != "" {
+			body, _ := json.Marshal(map[string]any{"scroll_id": page.ScrollID})
+			c.do(http.MethodDelete, "/_search/scroll", body)
+		}
+	}()
+
+	var line bytes.Buffer
+	for len(page.Hits.Hits) > 0 {
+		for _, hit := range page.Hits.Hits {
+			line.Reset()
+			if err := json.Compact(&line, hit.Source); err != nil {
+				return err
+			}
+			line.WriteByte('\n')
+			if _, err := w.Write(line.Bytes()); err != nil {
+				return err
+			}
+		}
+
+		body, _ := json.Marshal(map[string]any{"scroll": "5m", "scroll_id": page.ScrollID})
+		next, err := c.do(http.MethodPost, "/_search/scroll", body)
+		if err != nil {
+			return err
+		}
+		page = next
+	}
+	return nil
+}
+
+func (c *esClient) do(method, path string, body []byte) (*esSearchResponse, error) {
+	u := *c.base
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	u.Path += ref.Path
+	u.RawPath = ""
+	u.RawQuery = ref.RawQuery
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, h := range c.headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	var out esSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
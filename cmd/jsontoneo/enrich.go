@@ -0,0 +1,352 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type enrichOptions struct {
+	geoIPDir  string
+	asnDB     string
+	asnNames  string
+	scope     string
+	project   string
+	batchSize int
+	all       bool
+	maxAge    string
+	rdapURL   string
+	rdapDelay time.Duration
+	apiKey    string
+	budget    int
+	budgetSet bool
+	cursor    string
+	pdnsURL   string
+	cloudDir  string
+	blockDir  string
+	timeout   time.Duration
+	faviconDB string
+	cluster   *clusterOptions
+	// since is now minus -max-age.
+	since time.Time
+}
+
+var enrichKinds = []string{"geoip", "whois", "asn", "shodan", "censys", "pdns", "cloud", "reputation", "tls", "favicon"}
+
+// enrichTarget is a node holding an IP: an IP node, or a Host with an ip.
+type enrichTarget struct {
+	id, ip, project string
+}
+
+func enrichFlags(fs *flag.FlagSet) func() {
+	opts := enrichOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.geoIPDir, "geoip-dir", "", "geoip: directory with the MaxMind City and ASN databases (default $JSONTONEO_GEOIP_DIR or /usr/share/GeoIP)")
+	fs.StringVar(&opts.asnDB, "asn-db", "", "asn: pyasn IP to ASN database (a prefix and an ASN per line) to use instead of querying Team Cymru over DNS")
+	fs.StringVar(&opts.asnNames, "asn-names", "", "asn: JSON file of ASN names for -asn-db, as written by pyasn_util_asnames.py")
+	fs.StringVar(&opts.scope, "scope", "", "Only enrich hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only enrich the nodes of this project")
+	fs.IntVar(&opts.batchSize, "batch-size", 500, "Write and commit at most N nodes per transaction")
+	fs.BoolVar(&opts.all, "all", false, "Also look up nodes that were enriched before")
+	fs.StringVar(&opts.maxAge, "max-age", "30d", "Look up domains, netblocks, IPs and hosts again once what was written for them is older than this (all but geoip and asn)")
+	fs.StringVar(&opts.rdapURL, "rdap-url", rdapBootstrap, "whois: RDAP server, by default the rdap.org bootstrap that redirects to the registry")
+	fs.DurationVar(&opts.rdapDelay, "rdap-delay", time.Second, "whois: wait this long between RDAP requests, to respect rate limits")
+	fs.StringVar(&opts.apiKey, "api-key", "", "shodan: Shodan API key (default $SHODAN_API_KEY); censys: API ID and secret as ID:SECRET (default $CENSYS_API_ID and $CENSYS_API_SECRET); pdns: SecurityTrails API key (default $SECURITYTRAILS_API_KEY), or the key or USER:PASSWORD of -pdns-url (default $PDNS_API_KEY)")
+	fs.IntVar(&opts.budget, "budget", 100, "shodan, censys, pdns: send at most N API requests in this run (censys: per project, overriding censys.yaml); the next run resumes where it stopped")
+	fs.StringVar(&opts.cursor, "cursor", "", "shodan, censys: file keeping the last IP looked up, to resume from (default ~/.config/jsontoneo/<kind>.cursor, censys adds the project to the name)")
+	fs.StringVar(&opts.pdnsURL, "pdns-url", "", "pdns: passive DNS server answering in the Common Output Format, queried as URL+domain (e.g. https://www.circl.lu/pdns/query/), instead of SecurityTrails")
+	fs.StringVar(&opts.cloudDir, "cloud-dir", "", "cloud: directory with local copies of the published ranges (aws.json, gcp.json, azure.json, cloudflare.txt) to use instead of downloading them")
+	fs.StringVar(&opts.blockDir, "blocklist-dir", "", "reputation: directory with local copies of blocklists, one IP or prefix per line (default ~/.config/jsontoneo/blocklists)")
+	fs.DurationVar(&opts.timeout, "timeout", 5*time.Second, "tls, favicon: timeout of a connection and TLS handshake, or of fetching a favicon")
+	fs.StringVar(&opts.faviconDB, "favicon-db", "", "favicon: JSON file of favicon hashes and the technology they belong to, added to the built-in ones")
+	opts.cluster.register(fs, false)
+
+	return func() {
+		// Ook vlaggen na de soort toestaan: enrich whois -scope example.com.
+		kind, rest := fs.Arg(0), fs.Args()[min(1, fs.NArg()):]
+		if err := fs.Parse(rest); err != nil {
+			if err == flag.ErrHelp {
+				os.Exit(exitOK)
+			}
+			os.Exit(exitFatal)
+		}
+		if fs.NArg() != 0 || !containsString(enrichKinds, kind) {
+			log.Fatalf("Usage: jsontoneo enrich %s [flags]", strings.Join(enrichKinds, "|"))
+		}
+		fs.Visit(func(f *flag.Flag) { opts.budgetSet = opts.budgetSet || f.Name == "budget" })
+		if opts.batchSize < 1 {
+			log.Fatal("-batch-size must be at least 1")
+		}
+		maxAge, err := parseAge(opts.maxAge)
+		if err != nil {
+			log.Fatalf("Invalid -max-age: %v", err)
+		}
+		opts.since = time.Now().Add(-maxAge)
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeWrite)
+		defer session.Close()
+
+		switch kind {
+		case "geoip":
+			enrichGeoIP(session, opts)
+		case "whois":
+			enrichWhois(session, opts)
+		case "asn":
+			enrichASN(session, opts)
+		case "shodan":
+			enrichShodan(session, opts)
+		case "censys":
+			enrichCensys(session, opts)
+		case "pdns":
+			enrichPDNS(session, opts)
+		case "cloud":
+			enrichCloud(session, opts)
+		case "reputation":
+			enrichReputation(session, opts)
+		case "tls":
+			enrichTLS(session, opts)
+		case "favicon":
+			enrichFavicon(session, opts)
+		}
+	}
+}
+
+// enrichGeoIP sets the country and city of IP nodes and host IPs, and links
+// them to their ASN.
+func enrichGeoIP(session neo4j.Session, opts enrichOptions) {
+	g, err := openGeoIP(geoIPDir(opts.geoIPDir))
+	if err != nil {
+		log.Fatalf("Error opening GeoIP databases: %v", err)
+	}
+	defer g.Close()
+
+	targets, err := loadEnrichTargets(session, opts, geoIPPending)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+	var rows []map[string]any
+	unknown := 0
+	for _, t := range targets {
+		info, ok := g.lookup(t.ip)
+		if !ok {
+			unknown++
+			continue
+		}
+		rows = append(rows, geoIPRow(t, info))
+	}
+
+	linked := 0
+	for start := 0; start < len(rows); start += opts.batchSize {
+		batch := rows[start:min(start+opts.batchSize, len(rows))]
+		n, err := writeGeoIP(session, batch)
+		if err != nil {
+			log.Fatalf("Error writing GeoIP data: %v", err)
+		}
+		linked += n
+	}
+	log.Printf("Enriched %d of %d nodes (%d distinct IPs, %d not in the databases), linked %d to an ASN",
+		len(rows), len(targets), len(g.cache), unknown, linked)
+}
+
+// geoIPPending, asnPending and the others return the condition for node v
+// still needing the enrichment.
+func geoIPPending(v string) string { return v + ".country IS NULL AND " + v + ".city IS NULL" }
+func asnPending(v string) string   { return "NOT (" + v + ")-[:BELONGS_TO]->(:ASN)" }
+func shodanPending(v string) string {
+	return "coalesce(" + v + ".shodan_checked_at < $since, true)"
+}
+func censysPending(v string) string {
+	return "coalesce(" + v + ".censys_checked_at < $since, true)"
+}
+func cloudPending(v string) string {
+	return "coalesce(" + v + ".cloud_checked_at < $since, true)"
+}
+func reputationPending(v string) string {
+	return "coalesce(" + v + ".reputation_checked_at < $since, true)"
+}
+
+// loadEnrichTargets returns the IP nodes and the hosts with an ip that are
+// pending, or all of them when opts.all is set. With a scope only hosts are
+// returned.
+func loadEnrichTargets(session neo4j.Session, opts enrichOptions, pending func(v string) string) ([]enrichTarget, error) {
+	var targets []enrichTarget
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		targets = nil
+		res, err := tx.Run(`
+		MATCH (i:IP)
+		WHERE $scope = '' AND i.address IS NOT NULL AND ($all OR `+pending("i")+`) AND `+neo4jwriter.ProjectCond("i")+`
+		RETURN elementId(i), i.address, i.project
+		UNION ALL
+		MATCH (h:Host)
+		WHERE coalesce(h.ip, '') <> '' AND `+hostCond+` AND ($all OR `+pending("h")+`) AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN elementId(h), h.ip, h.project
+		`, map[string]any{"all": opts.all, "scope": opts.scope, "project": opts.project, "since": opts.since})
+		if err != nil {
+			return nil, fmt.Errorf("IP query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			targets = append(targets, enrichTarget{id: propString(v[0]), ip: strings.TrimSpace(propString(v[1])), project: propString(v[2])})
+		}
+		return nil, res.Err()
+	})
+	return targets, err
+}
+
+// geoIPRow is the row writeGeoIP writes for t, with nil for what the
+// databases do not know.
+func geoIPRow(t enrichTarget, info geoIPInfo) map[string]any {
+	row := map[string]any{"id": t.id, "project": t.project, "country": nil, "city": nil, "asn": nil, "as_name": nil, "as_country": nil, "as_range": nil}
+	if info.Country != "" {
+		row["country"] = info.Country
+	}
+	if info.City != "" {
+		row["city"] = info.City
+	}
+	if info.ASN != 0 {
+		row["asn"] = fmt.Sprintf("AS%d", info.ASN)
+		row["as_name"] = info.ASName
+	}
+	return row
+}
+
+// writeGeoIP sets the country and city of a batch of nodes and links the
+// ones without an ASN to theirs, in one transaction. It returns the number
+// of nodes linked.
+func writeGeoIP(session neo4j.Session, rows []map[string]any) (int, error) {
+	linked := 0
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		UNWIND $rows AS row
+		MATCH (n) WHERE elementId(n) = row.id
+		SET n.country = coalesce(row.country, n.country),
+		    n.city    = coalesce(row.city, n.city)
+		`, map[string]any{"rows": rows})
+		if err != nil {
+			return nil, fmt.Errorf("GeoIP query error: %w", err)
+		}
+		if _, err := res.Consume(); err != nil {
+			return nil, fmt.Errorf("GeoIP query error: %w", err)
+		}
+
+		linked, err = linkASNs(tx, rows)
+		return nil, err
+	})
+	return linked, err
+}
+
+// linkASNs links the nodes of rows without an ASN to the ASN of their row
+// (asn, as_name, as_country, as_range), and returns how many it linked. The
+// name and country of an existing ASN are kept; the range is added to its
+// ranges.
+func linkASNs(tx neo4j.Transaction, rows []map[string]any) (int, error) {
+	var unscoped, scoped []map[string]any
+	for _, row := range rows {
+		if row["asn"] == nil {
+			continue
+		}
+		if row["project"] == "" {
+			unscoped = append(unscoped, row)
+		} else {
+			scoped = append(scoped, row)
+		}
+	}
+
+	linked := 0
+	// Een ASN hoort bij een project; zonder project bij het lege project.
+	for _, q := range []struct {
+		key  string
+		rows []map[string]any
+	}{{"{number: row.asn, project: ''}", unscoped}, {"{number: row.asn, project: row.project}", scoped}} {
+		if len(q.rows) == 0 {
+			continue
+		}
+		res, err := tx.Run(`
+		UNWIND $rows AS row
+		MATCH (n) WHERE elementId(n) = row.id AND NOT (n)-[:BELONGS_TO]->(:ASN)
+		MERGE (a:ASN `+q.key+`)
+		SET a.name    = coalesce(a.name, row.as_name),
+		    a.country = coalesce(a.country, row.as_country),
+		    a.range   = CASE WHEN row.as_range IS NULL OR row.as_range IN coalesce(a.range, []) THEN a.range
+		                     ELSE coalesce(a.range, []) + row.as_range END
+		MERGE (n)-[:BELONGS_TO]->(a)
+		RETURN count(n)
+		`, map[string]any{"rows": q.rows})
+		if err != nil {
+			return 0, fmt.Errorf("ASN query error: %w", err)
+		}
+		rec, err := res.Single()
+		if err != nil {
+			return 0, fmt.Errorf("ASN query error: %w", err)
+		}
+		linked += propInt(rec.Values[0])
+	}
+	return linked, nil
+}
+
+// publicAddrs returns the distinct public IPs of targets, in address order.
+func publicAddrs(targets []enrichTarget) []netip.Addr {
+	var addrs []netip.Addr
+	seen := map[netip.Addr]bool{}
+	for _, t := range targets {
+		addr, err := netip.ParseAddr(t.ip)
+		if err != nil || seen[addr] || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+			continue
+		}
+		seen[addr] = true
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+	return addrs
+}
+
+// enrichCursorFile returns the -cursor file of an enrichment, by default
+// name.cursor in the config directory, with the project in its name.
+func enrichCursorFile(opts enrichOptions, name, project string) string {
+	path := opts.cursor
+	if path == "" {
+		path = filepath.Join(configDir(), name+".cursor")
+	}
+	if project != "" {
+		ext := filepath.Ext(path)
+		path = strings.TrimSuffix(path, ext) + "-" + project + ext
+	}
+	return path
+}
+
+// resumeAddrs returns the addresses after the one in the cursor file, left
+// by an earlier run that ran out of budget.
+func resumeAddrs(addrs []netip.Addr, cursorFile string) []netip.Addr {
+	data, err := os.ReadFile(cursorFile)
+	if err != nil {
+		return addrs
+	}
+	cursor, err := netip.ParseAddr(strings.TrimSpace(string(data)))
+	if err != nil {
+		return addrs
+	}
+	log.Printf("Resuming after %s from %s", cursor, cursorFile)
+	i := sort.Search(len(addrs), func(i int) bool { return cursor.Less(addrs[i]) })
+	return addrs[i:]
+}
+
+func writeCursor(path, ip string) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		err = os.WriteFile(path, []byte(ip+"\n"), 0600)
+	}
+	if err != nil {
+		log.Printf("Warning: writing cursor: %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+// eolData is the built-in end-of-life dataset, keyed by the product names of
+// https://endoflife.date, with the technology names httpx reports for them.
+//
+//go:embed eol.json
+var eolData []byte
+
+const eolAPI = "https://endoflife.date/api/"
+
+type eolOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	data    string
+	fetch   bool
+	cluster *clusterOptions
+}
+
+type eolProduct struct {
+	Names  []string   `json:"names"`
+	Cycles []eolCycle `json:"cycles"`
+}
+
+// eolCycle is a release cycle, in the format of the endoflife.date API: eol
+// is the end-of-life date, or a boolean when there is no date.
+type eolCycle struct {
+	Cycle string
+	EOL   bool
+	Date  time.Time
+}
+
+func (c *eolCycle) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Cycle any `json:"cycle"`
+		EOL   any `json:"eol"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	c.Cycle = fmt.Sprint(raw.Cycle)
+	switch eol := raw.EOL.(type) {
+	case bool:
+		c.EOL = eol
+	case string:
+		d, err := time.Parse(time.DateOnly, eol)
+		if err != nil {
+			return fmt.Errorf("cycle %s: invalid eol %q", c.Cycle, eol)
+		}
+		c.Date = d
+	}
+	return nil
+}
+
+// ended reports whether the cycle is past its end of life at now.
+func (c eolCycle) ended(now time.Time) bool {
+	return c.EOL || (!c.Date.IsZero() && c.Date.Before(now))
+}
+
+// eolFinding is a technology version past its end of life, with the hosts
+// running it.
+type eolFinding struct {
+	Tech    string     `json:"tech"`
+	Version string     `json:"version"`
+	Cycle   string     `json:"cycle"`
+	EOL     *time.Time `json:"eol,omitempty"`
+	Hosts   int        `json:"hosts"`
+	URLs    []string   `json:"urls"`
+}
+
+func eolFlags(fs *flag.FlagSet) func() {
+	opts := eolOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only check hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only check the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.StringVar(&opts.data, "data", "", "Read the end-of-life dataset from this JSON file instead of the built-in one")
+	fs.BoolVar(&opts.fetch, "fetch", false, "Fetch the current release cycles of the products in the dataset from endoflife.date")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		opts.cluster.check()
+
+		data := eolData
+		if opts.data != "" {
+			var err error
+			if data, err = os.ReadFile(opts.data); err != nil {
+				log.Fatalf("Error reading -data: %v", err)
+			}
+		}
+		products, err := parseEOLData(data)
+		if err != nil {
+			log.Fatalf("Error parsing end-of-life dataset: %v", err)
+		}
+		if opts.fetch {
+			fetchEOLCycles(products)
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		techs, err := loadTechHosts(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		findings := findEOL(products, techs, time.Now())
+		err = opts.output.write(findings, findings, func(w io.Writer) error {
+			return printEOL(w, findings)
+		})
+		if err != nil {
+			log.Fatalf("Error writing findings: %v", err)
+		}
+	}
+}
+
+func parseEOLData(data []byte) (map[string]*eolProduct, error) {
+	var products map[string]*eolProduct
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// fetchEOLCycles replaces the cycles of each product with the ones
+// endoflife.date lists now. A product that cannot be fetched keeps the cycles
+// of the dataset.
+func fetchEOLCycles(products map[string]*eolProduct) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	for name, p := range products {
+		resp, err := client.Get(eolAPI + name + ".json")
+		if err != nil {
+			log.Printf("Warning: fetching %s from endoflife.date: %v", name, err)
+			continue
+		}
+		var cycles []eolCycle
+		if resp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("status %s", resp.Status)
+		} else {
+			err = json.NewDecoder(resp.Body).Decode(&cycles)
+		}
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("Warning: fetching %s from endoflife.date: %v", name, err)
+			continue
+		}
+		p.Cycles = cycles
+	}
+}
+
+// loadTechHosts returns the hosts per technology, from the tech lists of the
+// hosts and the Tech nodes they use.
+func loadTechHosts(session neo4j.Session, opts eolOptions) (map[string][]string, error) {
+	techs := map[string][]string{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		clear(techs)
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		UNWIND coalesce(h.tech, []) AS tech
+		RETURN tech, collect(DISTINCT h.url) AS urls
+		UNION
+		MATCH (h:Host)-[:USES]->(t:Tech)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN CASE WHEN t.version IS NULL THEN t.name ELSE t.name + ':' + t.version END AS tech, collect(DISTINCT h.url) AS urls
+		`, map[string]any{"scope": opts.scope, "project": opts.project})
+		if err != nil {
+			return nil, fmt.Errorf("Tech query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			tech := propString(v[0])
+			techs[tech] = append(techs[tech], propStrings(v[1])...)
+		}
+		return nil, res.Err()
+	})
+	return techs, err
+}
+
+// findEOL returns the technology versions whose release cycle had ended at
+// now, the longest-unsupported first. Versions the dataset has no cycle for
+// are left out.
+func findEOL(products map[string]*eolProduct, techs map[string][]string, now time.Time) []*eolFinding {
+	byName := map[string]*eolProduct{}
+	for _, p := range products {
+		for _, n := range p.Names {
+			byName[strings.ToLower(n)] = p
+		}
+	}
+
+	found := map[string]*eolFinding{}
+	for tech, urls := range techs {
+		name, version := splitTech(tech)
+		p := byName[strings.ToLower(name)]
+		if p == nil || version == "" {
+			continue
+		}
+		c, ok := matchCycle(p.Cycles, version)
+		if !ok || !c.ended(now) {
+			continue
+		}
+		key := strings.ToLower(name) + ":" + version
+		f := found[key]
+		if f == nil {
+			f = &eolFinding{Tech: name, Version: version, Cycle: c.Cycle}
+			if !c.Date.IsZero() {
+				d := c.Date
+				f.EOL = &d
+			}
+			found[key] = f
+		}
+		f.URLs = append(f.URLs, urls...)
+	}
+
+	findings := make([]*eolFinding, 0, len(found))
+	for _, f := range found {
+		sort.Strings(f.URLs)
+		f.URLs = dedupSorted(f.URLs)
+		f.Hosts = len(f.URLs)
+		findings = append(findings, f)
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		// Zonder datum: al zo lang niet ondersteund dat niemand de datum bijhoudt.
+		if (a.EOL == nil) != (b.EOL == nil) {
+			return a.EOL == nil
+		}
+		if a.EOL != nil && !a.EOL.Equal(*b.EOL) {
+			return a.EOL.Before(*b.EOL)
+		}
+		if a.Tech != b.Tech {
+			return a.Tech < b.Tech
+		}
+		return compareVersions(a.Version, b.Version) < 0
+	})
+	return findings
+}
+
+// matchCycle returns the cycle of version: the longest cycle that equals the
+// version or is a dotted prefix of it, so 1.20.2 belongs to 1.20 and not 1.2.
+func matchCycle(cycles []eolCycle, version string) (eolCycle, bool) {
+	var best eolCycle
+	ok := false
+	for _, c := range cycles {
+		if version != c.Cycle && !strings.HasPrefix(version, c.Cycle+".") {
+			continue
+		}
+		if !ok || len(c.Cycle) > len(best.Cycle) {
+			best, ok = c, true
+		}
+	}
+	return best, ok
+}
+
+func dedupSorted(s []string) []string {
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func printEOL(w io.Writer, findings []*eolFinding) error {
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No technologies past their end of life")
+		return err
+	}
+	fmt.Fprintf(w, "%-24s  %-12s  %-6s  %-10s  %s\n", "TECH", "VERSION", "CYCLE", "EOL", "HOSTS")
+	hosts := map[string]bool{}
+	for _, f := range findings {
+		eol := "yes"
+		if f.EOL != nil {
+			eol = f.EOL.Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "%-24s  %-12s  %-6s  %-10s  %d\n", f.Tech, f.Version, f.Cycle, eol, f.Hosts)
+		for _, u := range f.URLs {
+			fmt.Fprintf(w, "    %s\n", u)
+			hosts[u] = true
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d versions past end of life on %d hosts\n", len(findings), len(hosts))
+	return err
+}
@@ -0,0 +1,29 @@
+package main
+
+import "github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+
+// Exit codes, so CI pipelines can tell a clean import from a partial one.
+// log.Fatal exits with exitFatal.
+const (
+	exitOK          = 0 // success
+	exitFatal       = 1 // fatal config, connection or usage error
+	exitParseErrors = 2 // completed, but some records could not be parsed
+	exitWriteErrors = 3 // completed, but some records could not be written
+	exitQuota       = 4 // stopped or dry-run after reaching -max-new-nodes
+	exitHookFailed  = 5 // completed, but a post-import hook failed
+)
+
+// exitCode returns the exit code for a completed import. Reaching the quota
+// takes precedence over write errors, which take precedence over parse errors.
+func exitCode(s *neo4jwriter.Summary) int {
+	switch {
+	case s.QuotaExceeded:
+		return exitQuota
+	case s.Failed > 0:
+		return exitWriteErrors
+	case s.ParseErrors > 0:
+		return exitParseErrors
+	default:
+		return exitOK
+	}
+}
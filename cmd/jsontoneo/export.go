@@ -0,0 +1,272 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+// exporters maps the -format values of the export command to their writer.
+var exporters = map[string]func(w io.Writer, g *exportGraph) error{
+	"graphml":     writeGraphML,
+	"dot":         writeDOT,
+	"jsonl":       writeJSONL,
+	"gexf":        writeGEXF,
+	"mtgx":        writeMTGX,
+	"maltego-csv": writeMaltegoCSV,
+}
+
+func exportFormats() []string {
+	formats := make([]string, 0, len(exporters))
+	for f := range exporters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+type exportNode struct {
+	ID     string
+	Labels []string
+	Props  map[string]any
+}
+
+// Label returns the primary label of the node.
+func (n *exportNode) Label() string {
+	if len(n.Labels) == 0 {
+		return ""
+	}
+	return n.Labels[0]
+}
+
+// Name returns a human readable name for the node.
+func (n *exportNode) Name() string {
+	for _, key := range []string{"url", "address", "name", "number", "id"} {
+		if v, ok := n.Props[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+	}
+	return n.ID
+}
+
+type exportEdge struct {
+	Source string
+	Target string
+	Type   string
+}
+
+// exportGraph is the subgraph written by the exporters. Host properties that
+// hold IPs and technologies are expanded into IP and Tech nodes, so the export
+// shows hosts sharing infrastructure.
+type exportGraph struct {
+	Nodes []*exportNode
+	Edges []exportEdge
+	index map[string]*exportNode
+	edges map[exportEdge]bool
+}
+
+func newExportGraph() *exportGraph {
+	return &exportGraph{index: map[string]*exportNode{}, edges: map[exportEdge]bool{}}
+}
+
+func (g *exportGraph) addNode(id string, labels []string, props map[string]any) *exportNode {
+	if n, ok := g.index[id]; ok {
+		return n
+	}
+	n := &exportNode{ID: id, Labels: labels, Props: props}
+	g.index[id] = n
+	g.Nodes = append(g.Nodes, n)
+	return n
+}
+
+func (g *exportGraph) addEdge(source, target, typ string) {
+	e := exportEdge{Source: source, Target: target, Type: typ}
+	if g.edges[e] {
+		return
+	}
+	g.edges[e] = true
+	g.Edges = append(g.Edges, e)
+}
+
+// truncate keeps the first n nodes and the edges between them.
+func (g *exportGraph) truncate(n int) {
+	if len(g.Nodes) <= n {
+		return
+	}
+	for _, node := range g.Nodes[n:] {
+		delete(g.index, node.ID)
+	}
+	g.Nodes = g.Nodes[:n]
+
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if g.index[e.Source] != nil && g.index[e.Target] != nil {
+			edges = append(edges, e)
+		} else {
+			delete(g.edges, e)
+		}
+	}
+	g.Edges = edges
+}
+
+// degree returns the number of edges per node id.
+func (g *exportGraph) degree() map[string]int {
+	deg := make(map[string]int, len(g.Nodes))
+	for _, e := range g.Edges {
+		deg[e.Source]++
+		deg[e.Target]++
+	}
+	return deg
+}
+
+type exportOptions struct {
+	format   string
+	project  string
+	match    string
+	output   string
+	maxNodes int
+	cluster  *clusterOptions
+}
+
+func exportFlags(fs *flag.FlagSet) func() {
+	opts := exportOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.format, "format", "graphml", "Export format ("+strings.Join(exportFormats(), "|")+")")
+	fs.StringVar(&opts.match, "match", "", "Only export hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.output, "o", "", "Write the export to this file instead of stdout")
+	fs.IntVar(&opts.maxNodes, "max-nodes", 0, "Export at most N nodes, 0 for no limit")
+	fs.StringVar(&opts.project, "project", "", "Only export the hosts of this project")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.cluster.check()
+		write, ok := exporters[opts.format]
+		if !ok {
+			log.Fatalf("Unknown -format %q (expected %s)", opts.format, strings.Join(exportFormats(), ", "))
+		}
+
+		driver := connect()
+		defer driver.Close()
+
+		g, err := loadExportGraph(driver, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		if opts.maxNodes > 0 && len(g.Nodes) > opts.maxNodes {
+			log.Printf("Export truncated to %d of %d nodes (-max-nodes)", opts.maxNodes, len(g.Nodes))
+			g.truncate(opts.maxNodes)
+		}
+
+		var w io.Writer = os.Stdout
+		if opts.output != "" {
+			file, err := os.Create(opts.output)
+			if err != nil {
+				log.Fatalf("Error creating output file: %v", err)
+			}
+			defer file.Close()
+			w = file
+		}
+		if err := write(w, g); err != nil {
+			log.Fatalf("Error writing export: %v", err)
+		}
+		if opts.output != "" {
+			log.Printf("Exported %d nodes and %d relationships to %s", len(g.Nodes), len(g.Edges), opts.output)
+		}
+	}
+}
+
+// loadExportGraph reads the matching hosts and their direct neighbours,
+// except Scan nodes, from Neo4j.
+func loadExportGraph(driver neo4j.Driver, opts exportOptions) (*exportGraph, error) {
+	session := opts.cluster.session(driver, neo4j.AccessModeRead)
+	defer session.Close()
+
+	g := newExportGraph()
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE ($match = '' OR toLower(h.url) CONTAINS toLower($match)) AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[r]-(n)
+		WHERE NOT n:Scan
+		RETURN h, r, n
+		ORDER BY h.url
+		`, map[string]any{"match": opts.match, "project": opts.project})
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			rec := res.Record()
+			host, _ := rec.Get("h")
+			h := host.(neo4j.Node)
+			addHostNode(g, h)
+
+			if n, ok := rec.Values[2].(neo4j.Node); ok {
+				g.addNode(n.ElementId, n.Labels, n.Props)
+				r := rec.Values[1].(neo4j.Relationship)
+				g.addEdge(r.StartElementId, r.EndElementId, r.Type)
+			}
+		}
+		return nil, res.Err()
+	})
+	return g, err
+}
+
+// addHostNode adds h and the IP and Tech nodes derived from its properties.
+func addHostNode(g *exportGraph, h neo4j.Node) {
+	if _, ok := g.index[h.ElementId]; ok {
+		return
+	}
+	g.addNode(h.ElementId, h.Labels, h.Props)
+
+	if ip, ok := h.Props["ip"].(string); ok && ip != "" {
+		id := "ip:" + ip
+		g.addNode(id, []string{"IP"}, map[string]any{"address": ip})
+		g.addEdge(h.ElementId, id, "RESOLVES_TO")
+	}
+	if techs, ok := h.Props["tech"].([]any); ok {
+		for _, t := range techs {
+			name := fmt.Sprint(t)
+			id := "tech:" + strings.ToLower(name)
+			g.addNode(id, []string{"Tech"}, map[string]any{"name": name})
+			g.addEdge(h.ElementId, id, "USES")
+		}
+	}
+}
+
+// propertyString renders a property value for formats that only hold strings.
+func propertyString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []any:
+		parts := make([]string, len(v))
+		for i, p := range v {
+			parts[i] = fmt.Sprint(p)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// propertyKeys returns the sorted property keys used by the nodes.
+func propertyKeys(nodes []*exportNode) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, n := range nodes {
+		for k := range n.Props {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type exposureOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	by      stringList
+	prefix  int
+	top     int
+	cluster *clusterOptions
+}
+
+// exposureGroup is the port exposure of the hosts in an ASN or netblock.
+type exposureGroup struct {
+	Name     string         `json:"name"`
+	Hosts    int            `json:"hosts"`
+	IPs      int            `json:"ips"`
+	Services int            `json:"services"`
+	Ports    []exposurePort `json:"ports"`
+
+	hosts    map[string]bool
+	ips      map[string]bool
+	services map[string]bool
+	ports    map[string]map[string]bool
+}
+
+type exposurePort struct {
+	Port     string `json:"port"`
+	Services int    `json:"services"`
+}
+
+type exposureReport struct {
+	ASNs      []*exposureGroup `json:"asns,omitempty"`
+	Netblocks []*exposureGroup `json:"netblocks,omitempty"`
+}
+
+func exposureFlags(fs *flag.FlagSet) func() {
+	opts := exposureOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only count hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only count the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.Var(&opts.by, "by", "Group by asn, netblock or both, comma-separated (default both)")
+	fs.IntVar(&opts.prefix, "prefix", 24, "Prefix length of the netblock of an IPv4 address outside the ranges of its ASN")
+	fs.IntVar(&opts.top, "top", 20, "Show this many ASNs and netblocks")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		if opts.prefix < 8 || opts.prefix > 32 {
+			log.Fatalf("-prefix must be between 8 and 32")
+		}
+		byASN, byNetblock := len(opts.by) == 0, len(opts.by) == 0
+		for _, by := range opts.by {
+			switch by {
+			case "asn":
+				byASN = true
+			case "netblock":
+				byNetblock = true
+			default:
+				log.Fatalf("Invalid -by %q (expected asn or netblock)", by)
+			}
+		}
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		asns, netblocks, err := loadExposure(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		var r exposureReport
+		if byASN {
+			r.ASNs = rankExposure(asns, opts.top)
+		}
+		if byNetblock {
+			r.Netblocks = rankExposure(netblocks, opts.top)
+		}
+		err = opts.output.write(r, r.rows(), func(w io.Writer) error {
+			return r.print(w, byASN, byNetblock)
+		})
+		if err != nil {
+			log.Fatalf("Error writing exposure: %v", err)
+		}
+	}
+}
+
+// loadExposure groups the open ports of the hosts by ASN and by netblock. A
+// service is an address and port; hosts on the same IP and port share one.
+func loadExposure(session neo4j.Session, opts exposureOptions) (map[string]*exposureGroup, map[string]*exposureGroup, error) {
+	asns := map[string]*exposureGroup{}
+	netblocks := map[string]*exposureGroup{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		clear(asns)
+		clear(netblocks)
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:BELONGS_TO]->(a:ASN)
+		RETURN h.url AS url, h.ip AS ip, h.port AS port, a.number AS asn, a.name AS name, a.range AS ranges
+		`, map[string]any{"scope": opts.scope, "project": opts.project})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			o := observation{URL: propString(v[0]), Port: propString(v[2])}
+			host, port := o.hostPort()
+			ip := propString(v[1])
+			addr := ip
+			if addr == "" {
+				addr = host
+			}
+
+			asn := "(no ASN)"
+			if n := propString(v[3]); n != "" {
+				asn = strings.TrimSpace(n + " " + propString(v[4]))
+			}
+			exposureGroupFor(asns, asn).add(o.URL, ip, addr, port)
+			if block := netblock(ip, propStrings(v[5]), opts.prefix); block != "" {
+				exposureGroupFor(netblocks, block).add(o.URL, ip, addr, port)
+			}
+		}
+		return nil, res.Err()
+	})
+	return asns, netblocks, err
+}
+
+func exposureGroupFor(groups map[string]*exposureGroup, name string) *exposureGroup {
+	g := groups[name]
+	if g == nil {
+		g = &exposureGroup{Name: name, hosts: map[string]bool{}, ips: map[string]bool{}, services: map[string]bool{}, ports: map[string]map[string]bool{}}
+		groups[name] = g
+	}
+	return g
+}
+
+func (g *exposureGroup) add(url, ip, addr, port string) {
+	g.hosts[url] = true
+	if ip != "" {
+		g.ips[ip] = true
+	}
+	if port == "" {
+		return
+	}
+	g.services[addr+":"+port] = true
+	if g.ports[port] == nil {
+		g.ports[port] = map[string]bool{}
+	}
+	g.ports[port][addr] = true
+}
+
+// netblock returns the range of the ASN that contains ip, or the /prefix
+// (/48 for IPv6) around it when none of the ranges does.
+func netblock(ip string, ranges []string, prefix int) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	for _, r := range ranges {
+		if p, err := netip.ParsePrefix(r); err == nil && p.Contains(addr) {
+			return p.Masked().String()
+		}
+	}
+	if addr.Is6() && !addr.Is4In6() {
+		prefix = 48
+	}
+	p, err := addr.Unmap().Prefix(prefix)
+	if err != nil {
+		return ""
+	}
+	return p.String()
+}
+
+// rankExposure returns the top groups with the most services, with their
+// ports from most to least exposed.
+func rankExposure(groups map[string]*exposureGroup, top int) []*exposureGroup {
+	ranked := make([]*exposureGroup, 0, len(groups))
+	for _, g := range groups {
+		g.Hosts, g.IPs, g.Services = len(g.hosts), len(g.ips), len(g.services)
+		g.Ports = g.Ports[:0]
+		for port, addrs := range g.ports {
+			g.Ports = append(g.Ports, exposurePort{Port: port, Services: len(addrs)})
+		}
+		sort.Slice(g.Ports, func(i, j int) bool {
+			if g.Ports[i].Services != g.Ports[j].Services {
+				return g.Ports[i].Services > g.Ports[j].Services
+			}
+			a, _ := strconv.Atoi(g.Ports[i].Port)
+			b, _ := strconv.Atoi(g.Ports[j].Port)
+			return a < b
+		})
+		ranked = append(ranked, g)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Services != ranked[j].Services {
+			return ranked[i].Services > ranked[j].Services
+		}
+		if ranked[i].Hosts != ranked[j].Hosts {
+			return ranked[i].Hosts > ranked[j].Hosts
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > top {
+		ranked = ranked[:top]
+	}
+	return ranked
+}
+
+// exposureRow is an ASN or netblock as a table row.
+type exposureRow struct {
+	By       string `json:"by"`
+	Name     string `json:"name"`
+	Hosts    int    `json:"hosts"`
+	IPs      int    `json:"ips"`
+	Services int    `json:"services"`
+	Ports    string `json:"ports"`
+}
+
+// rows flattens the report into a row per ASN and netblock, for csv and md
+// output.
+func (r exposureReport) rows() []exposureRow {
+	var rows []exposureRow
+	for _, by := range []struct {
+		name   string
+		groups []*exposureGroup
+	}{{"asn", r.ASNs}, {"netblock", r.Netblocks}} {
+		for _, g := range by.groups {
+			ports := make([]string, len(g.Ports))
+			for i, p := range g.Ports {
+				ports[i] = fmt.Sprintf("%s (%d)", p.Port, p.Services)
+			}
+			rows = append(rows, exposureRow{by.name, g.Name, g.Hosts, g.IPs, g.Services, strings.Join(ports, ", ")})
+		}
+	}
+	return rows
+}
+
+func (r exposureReport) print(w io.Writer, byASN, byNetblock bool) error {
+	if byASN {
+		printExposure(w, "Exposure per ASN", r.ASNs)
+	}
+	if byASN && byNetblock {
+		fmt.Fprintln(w)
+	}
+	if byNetblock {
+		printExposure(w, "Exposure per netblock", r.Netblocks)
+	}
+	return nil
+}
+
+func printExposure(w io.Writer, title string, groups []*exposureGroup) {
+	fmt.Fprintln(w, title)
+	if len(groups) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, g := range groups {
+		ports := make([]string, 0, len(g.Ports))
+		for i, p := range g.Ports {
+			if i == 10 {
+				ports = append(ports, fmt.Sprintf("+%d more", len(g.Ports)-10))
+				break
+			}
+			ports = append(ports, fmt.Sprintf("%s (%d)", p.Port, p.Services))
+		}
+		fmt.Fprintf(w, "  %-40s %6d services %6d hosts %6d IPs\n", g.Name, g.Services, g.Hosts, g.IPs)
+		if len(ports) > 0 {
+			fmt.Fprintf(w, "      ports: %s\n", strings.Join(ports, ", "))
+		}
+	}
+}
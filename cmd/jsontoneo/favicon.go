@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"github.com/spaolacci/murmur3"
+)
+
+// faviconData is the built-in favicon database: the technology each known
+// favicon hash belongs to.
+//
+//go:embed favicons.json
+var faviconData []byte
+
+// maxFavicon is the size from which a /favicon.ico response is not read.
+const maxFavicon = 1 << 20
+
+// faviconHash returns the favicon hash httpx and Shodan use: the signed
+// 32-bit MurmurHash3 of the base64 of data, in lines of 76 characters as
+// Python's base64.encodebytes writes it.
+func faviconHash(data []byte) int32 {
+	enc := base64.StdEncoding.EncodeToString(data)
+	var b strings.Builder
+	for len(enc) > 76 {
+		b.WriteString(enc[:76])
+		b.WriteByte('\n')
+		enc = enc[76:]
+	}
+	b.WriteString(enc)
+	b.WriteByte('\n')
+	return int32(murmur3.Sum32([]byte(b.String())))
+}
+
+// loadFaviconDB returns the built-in favicon database, with the entries of
+// path, in the same format, added to or replacing them.
+func loadFaviconDB(path string) (map[string]string, error) {
+	db := map[string]string{}
+	if err := json.Unmarshal(faviconData, &db); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return db, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var extra map[string]string
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for hash, tech := range extra {
+		db[hash] = tech
+	}
+	return db, nil
+}
+
+// faviconHost is a host in scope with its favicon hash, if any; fetch is
+// set for hosts without one whose /favicon.ico is to be fetched.
+type faviconHost struct {
+	url, project, favicon string
+	fetch                 bool
+}
+
+func loadFaviconHosts(session neo4j.Session, opts enrichOptions) ([]faviconHost, error) {
+	var hosts []faviconHost
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		hosts = nil
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN h.url, coalesce(h.project, ''), coalesce(h.favicon, ''),
+		       coalesce(h.favicon, '') = '' AND ($all OR coalesce(h.favicon_checked_at < $since, true))
+		ORDER BY h.url
+		`, map[string]any{"scope": opts.scope, "project": opts.project, "all": opts.all, "since": opts.since})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			hosts = append(hosts, faviconHost{url: propString(v[0]), project: propString(v[1]), favicon: propString(v[2]), fetch: v[3] == true})
+		}
+		return nil, res.Err()
+	})
+	return hosts, err
+}
+
+// fetchFavicon fetches /favicon.ico of the host of rawURL and returns its
+// hash; false when there is none. Servers that answer every path with a
+// page do not count as having one.
+func fetchFavicon(client *http.Client, rawURL string) (string, bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("invalid URL")
+	}
+	u = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/favicon.ico"}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("User-Agent", "jsontoneo/"+version)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/") {
+		return "", false, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFavicon+1))
+	if err != nil {
+		return "", false, err
+	}
+	if len(data) == 0 || len(data) > maxFavicon || bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return "", false, nil
+	}
+	return strconv.Itoa(int(faviconHash(data))), true, nil
+}
+
+// enrichFavicon fetches /favicon.ico of the hosts without a favicon hash
+// and stores its hash, then links every host whose hash is in the favicon
+// database to the Tech it belongs to.
+func enrichFavicon(session neo4j.Session, opts enrichOptions) {
+	db, err := loadFaviconDB(opts.faviconDB)
+	if err != nil {
+		log.Fatalf("Error reading favicon database: %v", err)
+	}
+	hosts, err := loadFaviconHosts(session, opts)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+
+	client := &http.Client{
+		Timeout: opts.timeout,
+		// Alleen de favicon van de host zelf, niet van waar hij heen verwijst.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+		Transport:     &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, 20)
+	fetched, failed := 0, 0
+	for i := range hosts {
+		if !hosts[i].fetch {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			hash, ok, err := fetchFavicon(client, hosts[i].url)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				failed++
+			case ok:
+				hosts[i].favicon = hash
+				fetched++
+			}
+		}()
+	}
+	wg.Wait()
+
+	var rows []map[string]any
+	matched := 0
+	for _, h := range hosts {
+		techs := []string{}
+		if tech, ok := db[h.favicon]; ok && h.favicon != "" {
+			techs = append(techs, tech)
+			matched++
+		}
+		if !h.fetch && len(techs) == 0 {
+			continue
+		}
+		var favicon any
+		if h.favicon != "" {
+			favicon = h.favicon
+		}
+		rows = append(rows, map[string]any{"url": h.url, "project": h.project, "fetched": h.fetch, "favicon": favicon, "techs": techs})
+	}
+	for start := 0; start < len(rows); start += opts.batchSize {
+		batch := rows[start:min(start+opts.batchSize, len(rows))]
+		if err := writeFavicons(session, batch); err != nil {
+			log.Fatalf("Error writing favicons: %v", err)
+		}
+	}
+	log.Printf("Fetched %d favicons (%d failed) and matched %d of %d hosts to a technology by favicon",
+		fetched, failed, matched, len(hosts))
+}
+
+// writeFavicons stores the fetched favicon hashes of rows and links the
+// hosts to their techs. USES relationships it creates get source "favicon".
+func writeFavicons(session neo4j.Session, rows []map[string]any) error {
+	var unscoped, scoped []map[string]any
+	for _, row := range rows {
+		if row["project"] == "" {
+			unscoped = append(unscoped, row)
+		} else {
+			scoped = append(scoped, row)
+		}
+	}
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, q := range []struct {
+			key  string
+			rows []map[string]any
+		}{{"{name: tech, project: ''}", unscoped}, {"{name: tech, project: row.project}", scoped}} {
+			if len(q.rows) == 0 {
+				continue
+			}
+			res, err := tx.Run(`
+			UNWIND $rows AS row
+			MATCH (h:Host {url: row.url}) WHERE coalesce(h.project, '') = row.project
+			FOREACH (_ IN CASE WHEN row.fetched THEN [1] ELSE [] END |
+				SET h.favicon_checked_at = datetime(), h.favicon = coalesce(row.favicon, h.favicon))
+			WITH h, row
+			UNWIND row.techs AS tech
+			MERGE (t:Tech `+q.key+`)
+			MERGE (h)-[u:USES]->(t)
+			ON CREATE SET u.source = 'favicon'
+			`, map[string]any{"rows": q.rows})
+			if err != nil {
+				return nil, fmt.Errorf("Favicon query error: %w", err)
+			}
+			if _, err := res.Consume(); err != nil {
+				return nil, fmt.Errorf("Favicon query error: %w", err)
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
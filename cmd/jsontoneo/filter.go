@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// stringList is a flag that can be repeated and accepts comma-separated
+// values, e.g. -status 200,301 -status 403.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// fileList is a repeatable flag of paths. Unlike stringList it does not
+// split on commas, which file names may contain.
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *fileList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// recordFilter decides which parsed records are written to the graph.
+// Empty criteria match everything.
+type recordFilter struct {
+	statuses     map[int]bool
+	schemes      map[string]bool
+	matchHosts   []string
+	excludeHosts []string
+	matchTechs   map[string]bool
+	excludeTechs map[string]bool
+}
+
+type filterFlags struct {
+	status      stringList
+	scheme      stringList
+	matchHost   stringList
+	excludeHost stringList
+	matchTech   stringList
+	excludeTech stringList
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.status, "status", "Only import records with these status codes (comma-separated, repeatable)")
+	fs.Var(&f.scheme, "scheme", "Only import records with this scheme, e.g. https (comma-separated, repeatable)")
+	fs.Var(&f.matchHost, "match-host", "Only import hosts matching this glob, e.g. '*.example.com' (repeatable)")
+	fs.Var(&f.excludeHost, "exclude-host", "Skip hosts matching this glob (repeatable)")
+	fs.Var(&f.matchTech, "match-tech", "Only import records with one of these technologies (repeatable)")
+	fs.Var(&f.excludeTech, "exclude-tech", "Skip records with one of these technologies, e.g. Cloudflare (repeatable)")
+}
+
+func (f *filterFlags) build() (*recordFilter, error) {
+	rf := &recordFilter{
+		schemes:      lowerSet(f.scheme),
+		matchTechs:   lowerSet(f.matchTech),
+		excludeTechs: lowerSet(f.excludeTech),
+	}
+	if len(f.status) > 0 {
+		rf.statuses = make(map[int]bool)
+		for _, s := range f.status {
+			code, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid status code %q", s)
+			}
+			rf.statuses[code] = true
+		}
+	}
+	for _, globs := range []struct {
+		in  stringList
+		out *[]string
+	}{{f.matchHost, &rf.matchHosts}, {f.excludeHost, &rf.excludeHosts}} {
+		for _, g := range globs.in {
+			g = strings.ToLower(g)
+			if _, err := path.Match(g, ""); err != nil {
+				return nil, fmt.Errorf("invalid host pattern %q: %w", g, err)
+			}
+			*globs.out = append(*globs.out, g)
+		}
+	}
+	return rf, nil
+}
+
+func lowerSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[strings.ToLower(v)] = true
+	}
+	return set
+}
+
+// match reports whether r passes the filter.
+func (f *recordFilter) Match(r *model.HttpxResult) bool {
+	if f.statuses != nil && !f.statuses[r.Status] {
+		return false
+	}
+	if f.schemes != nil && !f.schemes[strings.ToLower(r.Scheme)] {
+		return false
+	}
+
+	host := r.Hostname()
+	if len(f.matchHosts) > 0 && !matchAnyGlob(f.matchHosts, host) {
+		return false
+	}
+	if matchAnyGlob(f.excludeHosts, host) {
+		return false
+	}
+
+	if f.matchTechs != nil || f.excludeTechs != nil {
+		matched := false
+		for _, t := range r.Tech {
+			name := strings.ToLower(techName(t))
+			if f.excludeTechs[name] || f.excludeTechs[strings.ToLower(t)] {
+				return false
+			}
+			if f.matchTechs[name] || f.matchTechs[strings.ToLower(t)] {
+				matched = true
+			}
+		}
+		if f.matchTechs != nil && !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAnyGlob(globs []string, s string) bool {
+	for _, g := range globs {
+		if ok, _ := path.Match(g, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// techName strips the version from an httpx technology such as "Nginx:1.19.0".
+func techName(tech string) string {
+	name, _, _ := strings.Cut(tech, ":")
+	return name
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+func TestStringList(t *testing.T) {
+	var l stringList
+	for _, v := range []string{"200,301", " 403 ,", ""} {
+		if err := l.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := l.String(); got != "200,301,403" {
+		t.Errorf("stringList = %q, want 200,301,403", got)
+	}
+
+}
+
+func TestRecordFilter(t *testing.T) {
+	records := map[string]model.HttpxResult{
+		"api":   {URL: "https://api.example.com", Scheme: "https", Status: 200, Tech: []string{"Nginx:1.19.0"}},
+		"admin": {URL: "http://admin.example.com:8080", Scheme: "http", Status: 403, Tech: []string{"Jenkins", "Cloudflare"}},
+		"other": {URL: "https://www.other.org", Scheme: "https", Status: 301},
+	}
+	tests := []struct {
+		name  string
+		flags filterFlags
+		want  []string
+	}{
+		{"none", filterFlags{}, []string{"admin", "api", "other"}},
+		{"status", filterFlags{status: stringList{"200", "301"}}, []string{"api", "other"}},
+		{"scheme", filterFlags{scheme: stringList{"HTTP"}}, []string{"admin"}},
+		{"match host", filterFlags{matchHost: stringList{"*.Example.com"}}, []string{"admin", "api"}},
+		{"exclude host", filterFlags{excludeHost: stringList{"admin.*"}}, []string{"api", "other"}},
+		{"match tech without version", filterFlags{matchTech: stringList{"nginx"}}, []string{"api"}},
+		{"match tech with version", filterFlags{matchTech: stringList{"nginx:1.19.0"}}, []string{"api"}},
+		{"exclude tech", filterFlags{excludeTech: stringList{"cloudflare"}}, []string{"api", "other"}},
+		{"combined", filterFlags{status: stringList{"200", "403"}, excludeTech: stringList{"Cloudflare"}}, []string{"api"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.flags.build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, name := range slices.Sorted(maps.Keys(records)) {
+				r := records[name]
+				if f.Match(&r) {
+					got = append(got, name)
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordFilterErrors(t *testing.T) {
+	for _, flags := range []filterFlags{
+		{status: stringList{"2xx"}},
+		{matchHost: stringList{"[a-"}},
+		{excludeHost: stringList{"\\"}},
+	} {
+		if _, err := flags.build(); err == nil {
+			t.Errorf("build(%+v) = nil, want an error", flags)
+		}
+	}
+}
+
+func TestFileList(t *testing.T) {
+	var files fileList
+	for _, v := range []string{"a,b.tmpl", "c.tmpl"} {
+		if err := files.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(files) != 2 || files[0] != "a,b.tmpl" || files[1] != "c.tmpl" {
+		t.Errorf("fileList = %q, want [a,b.tmpl c.tmpl]", []string(files))
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+// orphanLabels are the labels of nodes that only exist for the hosts linked
+// to them.
+var orphanLabels = []string{"ASN", "IP", "Tech"}
+
+type gcOptions struct {
+	project string
+	dryRun  bool
+	yes     bool
+}
+
+func gcFlags(fs *flag.FlagSet) func() {
+	var opts gcOptions
+	fs.StringVar(&opts.project, "project", "", "Only remove orphaned nodes of this project")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Only show how many nodes would be removed")
+	fs.BoolVar(&opts.yes, "yes", false, "Do not ask for confirmation")
+
+	return func() {
+		driver := connect()
+		defer driver.Close()
+		session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+		defer session.Close()
+
+		params := map[string]any{"labels": orphanLabels, "project": opts.project}
+		counts := make(map[string]int64)
+		var total int64
+		_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+			// Een node met twee van de labels telt per label mee, maar één
+			// keer in het totaal.
+			res, err := tx.Run(`
+			MATCH (n)
+			WHERE any(l IN labels(n) WHERE l IN $labels) AND NOT (n)--() AND `+neo4jwriter.ProjectCond("n")+`
+			WITH collect(DISTINCT n) AS nodes
+			UNWIND $labels AS label
+			RETURN label, size([n IN nodes WHERE label IN labels(n)]), size(nodes)
+			`, params)
+			if err != nil {
+				return nil, fmt.Errorf("Orphan query error: %w", err)
+			}
+			for res.Next() {
+				v := res.Record().Values
+				counts[propString(v[0])] = int64(propInt(v[1]))
+				total = int64(propInt(v[2]))
+			}
+			return nil, res.Err()
+		})
+		if err != nil {
+			log.Fatalf("Error finding orphaned nodes: %v", err)
+		}
+
+		fmt.Fprintln(os.Stdout, "Orphaned nodes")
+		for _, label := range orphanLabels {
+			fmt.Fprintf(os.Stdout, "  %-5s %d\n", label, counts[label])
+		}
+		fmt.Fprintf(os.Stdout, "  %-5s %d\n", "Total", total)
+		if opts.dryRun || total == 0 {
+			return
+		}
+
+		ok, err := confirmDestructive("delete orphaned ASN, IP and Tech nodes", total, opts.yes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			log.Print("Cleanup cancelled")
+			return
+		}
+
+		deleted, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			res, err := tx.Run(`
+			MATCH (n)
+			WHERE any(l IN labels(n) WHERE l IN $labels) AND NOT (n)--() AND `+neo4jwriter.ProjectCond("n")+`
+			DELETE n
+			`, params)
+			if err != nil {
+				return nil, fmt.Errorf("Orphan query error: %w", err)
+			}
+			summary, err := res.Consume()
+			if err != nil {
+				return nil, fmt.Errorf("Orphan query error: %w", err)
+			}
+			return summary.Counters().NodesDeleted(), nil
+		})
+		if err != nil {
+			log.Fatalf("Error deleting orphaned nodes: %v", err)
+		}
+		log.Printf("Deleted %d orphaned nodes", deleted)
+	}
+}
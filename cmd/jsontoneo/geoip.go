@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// geoIPDatabases are the MaxMind database files looked for in the GeoIP
+// directory, the commercial ones first.
+var geoIPDatabases = struct{ city, asn []string }{
+	city: []string{"GeoIP2-City.mmdb", "GeoLite2-City.mmdb", "GeoIP2-Country.mmdb", "GeoLite2-Country.mmdb"},
+	asn:  []string{"GeoIP2-ASN.mmdb", "GeoLite2-ASN.mmdb"},
+}
+
+// geoIP looks up the country, city and ASN of IPs in local MaxMind
+// databases. Lookups are cached, as many records share an IP.
+type geoIP struct {
+	city, asn *maxminddb.Reader
+
+	mu    sync.Mutex
+	cache map[string]geoIPInfo
+}
+
+type geoIPInfo struct {
+	Country string
+	City    string
+	ASN     uint
+	ASName  string
+}
+
+type geoIPCityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+type geoIPASNRecord struct {
+	Number uint   `maxminddb:"autonomous_system_number"`
+	Org    string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoIPDir returns dir, or else $JSONTONEO_GEOIP_DIR or the directory
+// geoipupdate writes to.
+func geoIPDir(dir string) string {
+	if dir == "" {
+		dir = os.Getenv("JSONTONEO_GEOIP_DIR")
+	}
+	if dir == "" {
+		dir = "/usr/share/GeoIP"
+	}
+	return dir
+}
+
+// openGeoIP opens the City (or Country) and ASN databases in dir. One of
+// the two is enough.
+func openGeoIP(dir string) (*geoIP, error) {
+	g := &geoIP{cache: map[string]geoIPInfo{}}
+	var err error
+	if g.city, err = openMaxMind(dir, geoIPDatabases.city); err != nil {
+		return nil, err
+	}
+	if g.asn, err = openMaxMind(dir, geoIPDatabases.asn); err != nil {
+		g.Close()
+		return nil, err
+	}
+	if g.city == nil && g.asn == nil {
+		return nil, fmt.Errorf("no MaxMind database in %s (expected one of %s)", dir,
+			strings.Join(append(geoIPDatabases.city, geoIPDatabases.asn...), ", "))
+	}
+	return g, nil
+}
+
+// openMaxMind opens the first of names that exists in dir, or returns nil
+// when none does.
+func openMaxMind(dir string, names []string) (*maxminddb.Reader, error) {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		r, err := maxminddb.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return r, nil
+	}
+	return nil, nil
+}
+
+func (g *geoIP) Close() {
+	if g.city != nil {
+		g.city.Close()
+	}
+	if g.asn != nil {
+		g.asn.Close()
+	}
+}
+
+// lookup returns what the databases know of addr; false when it is not an
+// IP or they know nothing.
+func (g *geoIP) lookup(addr string) (geoIPInfo, bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return geoIPInfo{}, false
+	}
+	key := ip.String()
+	g.mu.Lock()
+	info, ok := g.cache[key]
+	g.mu.Unlock()
+	if ok {
+		return info, info != geoIPInfo{}
+	}
+
+	if g.city != nil {
+		var r geoIPCityRecord
+		if err := g.city.Lookup(ip, &r); err == nil {
+			info.Country, info.City = r.Country.ISOCode, r.City.Names["en"]
+		}
+	}
+	if g.asn != nil {
+		var r geoIPASNRecord
+		if err := g.asn.Lookup(ip, &r); err == nil {
+			info.ASN, info.ASName = r.Number, r.Org
+		}
+	}
+	g.mu.Lock()
+	g.cache[key] = info
+	g.mu.Unlock()
+	return info, info != geoIPInfo{}
+}
+
+// Apply adds the location of the IP of record (httpx host, or ip) as geoip
+// {country, city}, and its ASN when the record has none.
+func (g *geoIP) Apply(record []byte) ([][]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	var info geoIPInfo
+	found := false
+	for _, field := range []string{"host", "ip"} {
+		if addr, ok := doc[field].(string); ok {
+			if info, found = g.lookup(addr); found {
+				break
+			}
+		}
+	}
+	if !found {
+		return [][]byte{record}, nil
+	}
+
+	if info.Country != "" || info.City != "" {
+		doc["geoip"] = map[string]any{"country": info.Country, "city": info.City}
+	}
+	asn, _ := doc["asn"].(map[string]any)
+	if info.ASN != 0 && (asn == nil || asn["as_number"] == nil || asn["as_number"] == "") {
+		if asn == nil {
+			asn = map[string]any{}
+		}
+		asn["as_number"] = fmt.Sprintf("AS%d", info.ASN)
+		asn["as_name"] = info.ASName
+		doc["asn"] = asn
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{out}, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"time"
+)
+
+type rgb struct{ r, g, b uint8 }
+
+// labelColors are the GEXF node colors of the labels written by jsontoneo.
+// Other labels get a color derived from their name.
+var labelColors = map[string]rgb{
+	"Host": {66, 133, 244},
+	"IP":   {52, 168, 83},
+	"ASN":  {251, 188, 5},
+	"Tech": {234, 67, 53},
+	"Scan": {154, 160, 166},
+}
+
+func labelColor(label string) rgb {
+	if c, ok := labelColors[label]; ok {
+		return c
+	}
+	h := fnv.New32a()
+	h.Write([]byte(label))
+	sum := h.Sum32()
+	return rgb{uint8(sum >> 16), uint8(sum >> 8), uint8(sum)}
+}
+
+// writeGEXF writes g as GEXF for Gephi. Nodes are colored by label and sized
+// by degree, so hub infrastructure stands out in the attack-surface map.
+func writeGEXF(w io.Writer, g *exportGraph) error {
+	bw := bufio.NewWriter(w)
+	degree := g.degree()
+
+	keys := propertyKeys(g.Nodes)
+
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(bw, `<gexf xmlns="http://gexf.net/1.3" xmlns:viz="http://gexf.net/1.3/viz" version="1.3">`)
+	fmt.Fprintf(bw, "  <meta lastmodifieddate=\"%s\">\n", time.Now().Format("2006-01-02"))
+	fmt.Fprintf(bw, "    <creator>jsontoneo %s</creator>\n", xmlEscape(version))
+	fmt.Fprintln(bw, "  </meta>")
+	fmt.Fprintln(bw, `  <graph defaultedgetype="directed" mode="static">`)
+	fmt.Fprintln(bw, `    <attributes class="node">`)
+	fmt.Fprintln(bw, `      <attribute id="labels" title="labels" type="string"/>`)
+	fmt.Fprintln(bw, `      <attribute id="degree" title="degree" type="integer"/>`)
+	for i, k := range keys {
+		fmt.Fprintf(bw, "      <attribute id=\"p%d\" title=\"%s\" type=\"string\"/>\n", i, xmlEscape(k))
+	}
+	fmt.Fprintln(bw, "    </attributes>")
+
+	fmt.Fprintln(bw, "    <nodes>")
+	for _, n := range g.Nodes {
+		c := labelColor(n.Label())
+		// Logaritmisch schalen, anders domineren grote hubs de hele kaart.
+		size := 5 + 5*math.Log2(1+float64(degree[n.ID]))
+
+		fmt.Fprintf(bw, "      <node id=\"%s\" label=\"%s\">\n", xmlEscape(n.ID), xmlEscape(n.Name()))
+		fmt.Fprintln(bw, "        <attvalues>")
+		fmt.Fprintf(bw, "          <attvalue for=\"labels\" value=\"%s\"/>\n", xmlEscape(n.Label()))
+		fmt.Fprintf(bw, "          <attvalue for=\"degree\" value=\"%d\"/>\n", degree[n.ID])
+		for i, k := range keys {
+			if v, ok := n.Props[k]; ok && v != nil {
+				fmt.Fprintf(bw, "          <attvalue for=\"p%d\" value=\"%s\"/>\n", i, xmlEscape(propertyString(v)))
+			}
+		}
+		fmt.Fprintln(bw, "        </attvalues>")
+		fmt.Fprintf(bw, "        <viz:color r=\"%d\" g=\"%d\" b=\"%d\"/>\n", c.r, c.g, c.b)
+		fmt.Fprintf(bw, "        <viz:size value=\"%.1f\"/>\n", size)
+		fmt.Fprintln(bw, "      </node>")
+	}
+	fmt.Fprintln(bw, "    </nodes>")
+
+	fmt.Fprintln(bw, "    <edges>")
+	for i, e := range g.Edges {
+		fmt.Fprintf(bw, "      <edge id=\"e%d\" source=\"%s\" target=\"%s\" label=\"%s\"/>\n", i, xmlEscape(e.Source), xmlEscape(e.Target), xmlEscape(e.Type))
+	}
+	fmt.Fprintln(bw, "    </edges>")
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</gexf>")
+	return bw.Flush()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeGraphML writes g as GraphML, readable by Gephi, yEd and Cytoscape.
+func writeGraphML(w io.Writer, g *exportGraph) error {
+	bw := bufio.NewWriter(w)
+
+	keys := propertyKeys(g.Nodes)
+	keyID := make(map[string]string, len(keys))
+	for i, k := range keys {
+		keyID[k] = fmt.Sprintf("p%d", i)
+	}
+
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">`)
+	fmt.Fprintln(bw, `  <key id="labels" for="node" attr.name="labels" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <key id="name" for="node" attr.name="label" attr.type="string"/>`)
+	for _, k := range keys {
+		fmt.Fprintf(bw, "  <key id=\"%s\" for=\"node\" attr.name=\"%s\" attr.type=\"%s\"/>\n", keyID[k], xmlEscape(k), graphMLType(g.Nodes, k))
+	}
+	fmt.Fprintln(bw, `  <key id="type" for="edge" attr.name="type" attr.type="string"/>`)
+	fmt.Fprintln(bw, `  <graph id="jsontoneo" edgedefault="directed">`)
+
+	for _, n := range g.Nodes {
+		fmt.Fprintf(bw, "    <node id=\"%s\">\n", xmlEscape(n.ID))
+		fmt.Fprintf(bw, "      <data key=\"labels\">%s</data>\n", xmlEscape(":"+strings.Join(n.Labels, ":")))
+		fmt.Fprintf(bw, "      <data key=\"name\">%s</data>\n", xmlEscape(n.Name()))
+		for _, k := range keys {
+			if v, ok := n.Props[k]; ok && v != nil {
+				fmt.Fprintf(bw, "      <data key=\"%s\">%s</data>\n", keyID[k], xmlEscape(propertyString(v)))
+			}
+		}
+		fmt.Fprintln(bw, "    </node>")
+	}
+	for i, e := range g.Edges {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, xmlEscape(e.Source), xmlEscape(e.Target))
+		fmt.Fprintf(bw, "      <data key=\"type\">%s</data>\n", xmlEscape(e.Type))
+		fmt.Fprintln(bw, "    </edge>")
+	}
+
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	return bw.Flush()
+}
+
+// graphMLType returns the GraphML attribute type of property key: long when
+// every value is an integer, string otherwise.
+func graphMLType(nodes []*exportNode, key string) string {
+	for _, n := range nodes {
+		switch n.Props[key].(type) {
+		case nil, int64:
+		default:
+			return "string"
+		}
+	}
+	return "long"
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/pocahon/jsontoneo/ingestpb"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcIngestServer implements the streaming Ingest RPC on top of the same
+// driver and options as the HTTP endpoints.
+type grpcIngestServer struct {
+	ingestpb.UnimplementedIngestServer
+	srv *ingestServer
+}
+
+func newGRPCServer(srv *ingestServer) *grpc.Server {
+	server := grpc.NewServer(grpc.StreamInterceptor(srv.authenticateStream))
+	ingestpb.RegisterIngestServer(server, &grpcIngestServer{srv: srv})
+	return server
+}
+
+func (s *ingestServer) authenticateStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	md, _ := metadata.FromIncomingContext(ss.Context())
+	expected := []byte("Bearer " + s.opts.token)
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(v), expected) == 1 {
+			return handler(srv, ss)
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+}
+
+// Ingest imports the streamed records as one scan.
+func (g *grpcIngestServer) Ingest(stream ingestpb.Ingest_IngestServer) error {
+	remote := "unknown"
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		remote = p.Addr.String()
+	}
+
+	out := neo4jwriter.NewNeo4jTarget(g.srv.driver, g.srv.opts.cluster.configure)
+	defer out.Close()
+
+	source := "grpc://" + remote + "/ingest"
+	scanID := neo4jwriter.NewScanID()
+	opts := g.srv.opts.importOptions().writerOptions()
+	if err := neo4jwriter.CreateScan(out, scanID, source, opts); err != nil {
+		log.Printf("Error creating scan node: %v", err)
+		return status.Error(codes.Unavailable, "neo4j unavailable")
+	}
+
+	imp := neo4jwriter.New(out, scanID, opts)
+	summary := &neo4jwriter.Summary{ScanID: scanID, File: source}
+	start := time.Now()
+	ctx, span := tracer.Start(stream.Context(), "import", trace.WithAttributes(attribute.String("jsontoneo.scan_id", scanID)))
+	err := receiveRecords(ctx, stream, imp, summary)
+	endSpan(span, err)
+	if err := neo4jwriter.FinishScan(out, scanID); err != nil {
+		log.Printf("Error finishing scan node: %v", err)
+	}
+	summary.Finish(time.Since(start))
+	g.srv.opts.cluster.save()
+	log.Printf("Ingested %d records over gRPC from %s (scan %s, %d failed)", summary.Written, remote, scanID, summary.Failed)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(summaryProto(summary))
+}
+
+func receiveRecords(ctx context.Context, stream ingestpb.Ingest_IngestServer, imp *neo4jwriter.Importer, summary *neo4jwriter.Summary) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		summary.Read++
+		switch rec := req.Record.(type) {
+		case *ingestpb.IngestRequest_Httpx:
+			summary.Parsed++
+			imp.ImportRecord(ctx, httpxFromProto(rec.Httpx), 0, summary)
+		default:
+			summary.Skipped++
+		}
+	}
+}
+
+func httpxFromProto(m *ingestpb.HttpxResult) model.HttpxResult {
+	asn := m.GetAsn()
+	var cdn *bool
+	if m != nil {
+		cdn = m.Cdn
+	}
+	return model.HttpxResult{
+		Timestamp: m.GetTimestamp(),
+		ASN: model.ASN{
+			ASNumber:  asn.GetAsNumber(),
+			ASName:    asn.GetAsName(),
+			ASCountry: asn.GetAsCountry(),
+			ASRange:   asn.GetAsRange(),
+		},
+		Port:      m.GetPort(),
+		URL:       m.GetUrl(),
+		Input:     m.GetInput(),
+		Title:     m.GetTitle(),
+		Scheme:    m.GetScheme(),
+		Webserver: m.GetWebserver(),
+		Tech:      m.GetTech(),
+		Host:      m.GetHost(),
+		Status:    int(m.GetStatusCode()),
+		Words:     int(m.GetWords()),
+		Lines:     int(m.GetLines()),
+		Resolvers: m.GetResolvers(),
+		CNAME:     m.GetCname(),
+		Favicon:   m.GetFavicon(),
+		Jarm:      m.GetJarmHash(),
+		A:         m.GetA(),
+		CDN:       cdn,
+		CDNName:   m.GetCdnName(),
+	}
+}
+
+func summaryProto(s *neo4jwriter.Summary) *ingestpb.IngestSummary {
+	return &ingestpb.IngestSummary{
+		ScanId:               s.ScanID,
+		RecordsRead:          int64(s.Read),
+		RecordsParsed:        int64(s.Parsed),
+		RecordsFiltered:      int64(s.Filtered),
+		RecordsWritten:       int64(s.Written),
+		RecordsFailed:        int64(s.Failed),
+		NodesCreated:         int64(s.NodesCreated),
+		NodesMatched:         int64(s.NodesMatched),
+		RelationshipsCreated: int64(s.RelationshipsCreated),
+		RelationshipsMatched: int64(s.RelationshipsMatched),
+		PropertiesSet:        int64(s.PropertiesSet),
+		ElapsedSeconds:       s.ElapsedSeconds,
+	}
+}
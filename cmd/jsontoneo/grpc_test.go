@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pocahon/jsontoneo/ingestpb"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHttpxFromProto(t *testing.T) {
+	r := httpxFromProto(&ingestpb.HttpxResult{
+		Url:      "https://a.example.com",
+		Cname:    []string{"a.cdn.example.net"},
+		Favicon:  "-1234",
+		JarmHash: "29d29d",
+		A:        []string{"10.0.0.1"},
+		Cdn:      proto.Bool(false),
+		CdnName:  "cloudfront",
+	})
+	if len(r.CNAME) != 1 || r.Favicon != "-1234" || r.Jarm != "29d29d" || len(r.A) != 1 || r.CDNName != "cloudfront" {
+		t.Errorf("httpxFromProto = %+v", r)
+	}
+	if r.CDN == nil || *r.CDN {
+		t.Errorf("CDN = %v, want false", r.CDN)
+	}
+	if r := httpxFromProto(&ingestpb.HttpxResult{Url: "https://a.example.com"}); r.CDN != nil {
+		t.Errorf("CDN = %v without cdn, want nil", *r.CDN)
+	}
+}
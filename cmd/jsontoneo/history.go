@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type historyOptions struct {
+	host    string
+	project string
+	output  outputOptions
+	cluster *clusterOptions
+}
+
+// historyEntry is a point on the timeline of a host where its status, title
+// or technologies changed.
+type historyEntry struct {
+	URL     string    `json:"url"`
+	Time    time.Time `json:"time"`
+	Scan    string    `json:"scan,omitempty"`
+	Status  int       `json:"status"`
+	Title   string    `json:"title"`
+	Tech    []string  `json:"tech,omitempty"`
+	Changes []string  `json:"changes"`
+	// Versioned is false for entries from SEEN_IN, which do not hold the
+	// technologies.
+	Versioned bool `json:"versioned"`
+}
+
+func historyFlags(fs *flag.FlagSet) func() {
+	opts := historyOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.host, "host", "", "URL of the host, e.g. https://app.example.com, or a hostname for all its URLs")
+	fs.StringVar(&opts.project, "project", "", "Only look at the hosts of this project")
+	opts.output.register(fs, "text")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		if opts.host == "" {
+			log.Fatal("Usage: jsontoneo history -host URL")
+		}
+		opts.output.check()
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		entries, err := loadHistory(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		if len(entries) == 0 {
+			log.Fatalf("No host %s in the graph", opts.host)
+		}
+		err = opts.output.write(entries, entries, func(w io.Writer) error {
+			return printHistory(w, entries)
+		})
+		if err != nil {
+			log.Fatalf("Error writing history: %v", err)
+		}
+	}
+}
+
+// loadHistory returns the timeline of the hosts matching opts.host, from
+// their Observation nodes (see -merge-strategy versioned). A host without
+// observations falls back to what each scan saw of it on SEEN_IN.
+func loadHistory(session neo4j.Session, opts historyOptions) ([]historyEntry, error) {
+	host := strings.ToLower(opts.host)
+	params := map[string]any{
+		"url":     host,
+		"host":    "[a-z][a-z0-9+.-]*://" + regexp.QuoteMeta(host) + "(:[0-9]+)?(/.*)?",
+		"project": opts.project,
+	}
+	var entries []historyEntry
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		entries = nil
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE (toLower(h.url) = $url OR toLower(h.url) =~ $host) AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:OBSERVED]->(o:Observation)
+		OPTIONAL MATCH (o)-[:OBSERVED_IN]->(s:Scan)
+		RETURN h.url, o IS NOT NULL, o.observed_at, s.id, o.status, o.title, o.tech
+		ORDER BY h.url, o.observed_at
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Observation query error: %w", err)
+		}
+		var unversioned []string
+		for res.Next() {
+			v := res.Record().Values
+			if v[1] != true {
+				unversioned = append(unversioned, propString(v[0]))
+				continue
+			}
+			entries = append(entries, historyRow(v, true))
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+		if len(unversioned) == 0 {
+			return nil, nil
+		}
+
+		res, err = tx.Run(`
+		MATCH (h:Host)-[r:SEEN_IN]->(s:Scan)
+		WHERE h.url IN $urls AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN h.url, true, s.started_at, s.id, r.status, r.title, null
+		ORDER BY h.url, s.started_at
+		`, map[string]any{"urls": unversioned, "project": opts.project})
+		if err != nil {
+			return nil, fmt.Errorf("Scan query error: %w", err)
+		}
+		for res.Next() {
+			entries = append(entries, historyRow(res.Record().Values, false))
+		}
+		return nil, res.Err()
+	})
+	return historyChanges(entries), err
+}
+
+func historyRow(v []any, versioned bool) historyEntry {
+	e := historyEntry{
+		URL:       propString(v[0]),
+		Scan:      propString(v[3]),
+		Status:    propInt(v[4]),
+		Title:     propString(v[5]),
+		Tech:      propStrings(v[6]),
+		Versioned: versioned,
+	}
+	e.Time, _ = v[2].(time.Time)
+	return e
+}
+
+// historyChanges fills in what changed at each entry, per host in time
+// order, and leaves out the entries where nothing did.
+func historyChanges(entries []historyEntry) []historyEntry {
+	var out []historyEntry
+	for i, e := range entries {
+		if i == 0 || entries[i-1].URL != e.URL {
+			e.Changes = []string{"first seen"}
+			out = append(out, e)
+			continue
+		}
+		prev := entries[i-1]
+		if prev.Status != e.Status {
+			e.Changes = append(e.Changes, fmt.Sprintf("status %d → %d", prev.Status, e.Status))
+		}
+		if prev.Title != e.Title {
+			e.Changes = append(e.Changes, fmt.Sprintf("title %q → %q", prev.Title, e.Title))
+		}
+		if e.Versioned {
+			for _, t := range e.Tech {
+				if !containsString(prev.Tech, t) {
+					e.Changes = append(e.Changes, "tech +"+t)
+				}
+			}
+			for _, t := range prev.Tech {
+				if !containsString(e.Tech, t) {
+					e.Changes = append(e.Changes, "tech -"+t)
+				}
+			}
+		}
+		if len(e.Changes) > 0 {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func printHistory(w io.Writer, entries []historyEntry) error {
+	for i, e := range entries {
+		if i == 0 || entries[i-1].URL != e.URL {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintln(w, e.URL)
+			if !e.Versioned {
+				fmt.Fprintln(w, "  (from the scans that saw it; import with -merge-strategy versioned to track technologies)")
+			}
+		}
+		when := "-"
+		if !e.Time.IsZero() {
+			when = e.Time.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "  %-16s  %s\n", when, strings.Join(e.Changes, ", "))
+		if e.Changes[0] == "first seen" {
+			fmt.Fprintf(w, "  %-16s  status %d, title %q", "", e.Status, e.Title)
+			if len(e.Tech) > 0 {
+				fmt.Fprintf(w, ", tech %s", strings.Join(e.Tech, ", "))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"gopkg.in/yaml.v2"
+)
+
+// defaultHookTimeout bounds a hook without a timeout of its own.
+const defaultHookTimeout = 10 * time.Minute
+
+// hook is a Cypher statement or shell command run before or after an import.
+type hook struct {
+	Name   string `yaml:"name"`
+	Cypher string `yaml:"cypher"`
+	Shell  string `yaml:"shell"`
+	// OnFailure is abort (the default) or continue.
+	OnFailure string `yaml:"on_failure"`
+	Timeout   string `yaml:"timeout"`
+
+	timeout time.Duration
+}
+
+// hookConfig is the hooks file, by default ~/.config/jsontoneo/hooks.yaml.
+type hookConfig struct {
+	PreImport  []hook `yaml:"pre_import"`
+	PostImport []hook `yaml:"post_import"`
+}
+
+// hookEnv is what a hook gets to know about the import: as parameters of a
+// Cypher hook and as JSONTONEO_* variables of a shell hook.
+type hookEnv struct {
+	scanID  string
+	file    string
+	project string
+	// summary and code are only set after the import.
+	summary *neo4jwriter.Summary
+	code    int
+}
+
+// defaultHooksFile returns the hooks file used without -hooks, or "" when
+// there is none.
+func defaultHooksFile() string {
+	path := filepath.Join(configDir(), "hooks.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+func loadHooks(path string) (*hookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hooks hookConfig
+	if err := yaml.UnmarshalStrict(data, &hooks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for phase, list := range map[string][]hook{"pre_import": hooks.PreImport, "post_import": hooks.PostImport} {
+		for i := range list {
+			if err := list[i].compile(); err != nil {
+				return nil, fmt.Errorf("%s: %s hook %d: %w", path, phase, i+1, err)
+			}
+		}
+	}
+	return &hooks, nil
+}
+
+func (h *hook) compile() error {
+	switch {
+	case h.Cypher == "" && h.Shell == "":
+		return errors.New("needs cypher or shell")
+	case h.Cypher != "" && h.Shell != "":
+		return errors.New("has both cypher and shell")
+	case h.OnFailure != "" && h.OnFailure != "abort" && h.OnFailure != "continue":
+		return fmt.Errorf("invalid on_failure %q (expected abort or continue)", h.OnFailure)
+	}
+	h.timeout = defaultHookTimeout
+	if h.Timeout != "" {
+		d, err := time.ParseDuration(h.Timeout)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", h.Timeout)
+		}
+		h.timeout = d
+	}
+	if h.Name == "" {
+		h.Name = h.Cypher + h.Shell
+	}
+	return nil
+}
+
+// runHooks runs hooks in order. A failing hook is logged; unless it is set to
+// continue, the remaining hooks are skipped and its error is returned.
+func runHooks(phase string, hooks []hook, out neo4jwriter.Target, env hookEnv) error {
+	for _, h := range hooks {
+		start := time.Now()
+		var err error
+		if h.Cypher != "" {
+			err = h.runCypher(out, env)
+		} else {
+			err = h.runShell(phase, env)
+		}
+		if err == nil {
+			log.Printf("%s hook %q done in %s", phase, h.Name, time.Since(start).Round(time.Millisecond))
+			continue
+		}
+		if h.OnFailure == "continue" {
+			log.Printf("%s hook %q failed, continuing: %v", phase, h.Name, err)
+			continue
+		}
+		return fmt.Errorf("%s hook %q failed: %w", phase, h.Name, err)
+	}
+	return nil
+}
+
+// runCypher runs the statement in its own transaction, or writes it to the
+// script with -output cypher.
+func (h hook) runCypher(out neo4jwriter.Target, env hookEnv) error {
+	params := map[string]any{
+		"scan_id": env.scanID,
+		"file":    env.file,
+		"project": env.project,
+	}
+	_, err := out.Write(func(tx neo4jwriter.Runner) (neo4jwriter.Stats, error) {
+		res, err := tx.Run(h.Cypher, params)
+		if err != nil || res == nil {
+			return neo4jwriter.Stats{}, err
+		}
+		_, err = res.Consume()
+		return neo4jwriter.Stats{}, err
+	})
+	return err
+}
+
+// runShell runs the command with sh (cmd on Windows). Its output goes to
+// stderr, as stdout may carry the JSON summary.
+func (h hook) runShell(phase string, env hookEnv) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.Shell)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.Shell)
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"JSONTONEO_HOOK="+phase,
+		"JSONTONEO_SCAN_ID="+env.scanID,
+		"JSONTONEO_FILE="+env.file,
+		"JSONTONEO_PROJECT="+env.project,
+	)
+	if s := env.summary; s != nil {
+		cmd.Env = append(cmd.Env,
+			"JSONTONEO_EXIT_CODE="+strconv.Itoa(env.code),
+			"JSONTONEO_WRITTEN="+strconv.Itoa(s.Written),
+			"JSONTONEO_FAILED="+strconv.Itoa(s.Failed),
+			"JSONTONEO_PARSE_ERRORS="+strconv.Itoa(s.ParseErrors),
+			"JSONTONEO_NODES_CREATED="+strconv.Itoa(s.NodesCreated),
+		)
+	}
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", h.timeout)
+	}
+	return err
+}
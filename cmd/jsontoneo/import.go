@@ -0,0 +1,450 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/coerce"
+	"github.com/pocahon/jsontoneo/pkg/derive"
+	"github.com/pocahon/jsontoneo/pkg/mapping"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"github.com/pocahon/jsontoneo/pkg/parser"
+	"github.com/pocahon/jsontoneo/pkg/plugin"
+	"github.com/pocahon/jsontoneo/pkg/script"
+	"github.com/pocahon/jsontoneo/pkg/transform"
+)
+
+// mergeStrategies lists the values of -merge-strategy: overwrite replaces
+// the Host properties, versioned also keeps a timeline of the changes.
+var mergeStrategies = []string{"overwrite", "versioned"}
+
+type importOptions struct {
+	filePath      string
+	input         inputOptions
+	parser        string
+	mapping       *mapping.Mapping
+	transform     *transform.Transform
+	script        *script.Script
+	enrichers     []neo4jwriter.Enricher
+	coerce        coerce.Rules
+	flattenExtra  bool
+	derive        *derive.Rules
+	templates     *neo4jwriter.Templates
+	summaryFormat string
+	tui           bool
+	skip          int
+	limit         int
+	sample        float64
+	seed          int64
+	filter        *recordFilter
+	scope         *scopeRules
+	outOfScope    string
+	fields        neo4jwriter.FieldSelection
+	tags          []string
+	tagLabels     bool
+	project       string
+	mergeStrategy string
+	// attribution is recorded on the Scan node, and with attributeNodes on
+	// the nodes the import creates.
+	attribution    neo4jwriter.Attribution
+	attributeNodes bool
+	// ttl, when set, gives the nodes written an expires_at this far ahead.
+	ttl time.Duration
+	// maxNewNodes limits the nodes an import may create; onQuota is what
+	// happens when a record would exceed it: abort or dry-run.
+	maxNewNodes int
+	onQuota     string
+	notifyURL   string
+	// hooks run before and after the import, see hooks.go.
+	hooks   *hookConfig
+	output  string
+	outFile string
+	// target is the graph database written to, see target.go.
+	target      string
+	neptuneURL  string
+	gremlinURL  string
+	arangoURL   string
+	arangoDB    string
+	falkorURL   string
+	falkorGraph string
+	ageURL      string
+	ageGraph    string
+	dgraphURL   string
+	localStore  string
+	mirrors     stringList
+	// cluster routes the Neo4j sessions, see neo4j.go.
+	cluster *clusterOptions
+}
+
+func importFlags(fs *flag.FlagSet) func() {
+	opts := importOptions{cluster: &clusterOptions{}}
+	var filters filterFlags
+	var onlyFields, skipFields, hashFields, tags, enrich stringList
+	var templates fileList
+	var scopeFile, operator, ttl, mappingFile, transformExpr, scriptFile, templateMode, hooksFile, rulesFile, geoIPPath string
+	var noHooks, noRules bool
+	opts.coerce = coerce.Rules{}
+	fs.StringVar(&opts.filePath, "f", "", "Path, s3://bucket/key or http(s):// URL of the JSON file (JSON Lines or a JSON array, .gz is decompressed)")
+	fs.StringVar(&opts.input.from, "from", "file", "Where to read the input: file (see -f) or elasticsearch (see -index)")
+	fs.StringVar(&opts.input.index, "index", "", "Elasticsearch index or index pattern to read with -from elasticsearch, e.g. httpx-*")
+	fs.StringVar(&opts.input.query, "query", "", "Only read the documents matching this Lucene query string or JSON query DSL")
+	fs.StringVar(&opts.input.esURL, "es-url", "", "Elasticsearch URL, optionally with user:password (default $ELASTICSEARCH_URL or http://localhost:9200)")
+	fs.Var(&opts.input.headers, "header", "HTTP header sent when -f is a URL or to Elasticsearch, e.g. \"Authorization: Bearer $TOKEN\" (repeatable)")
+	fs.StringVar(&opts.input.s3Endpoint, "s3-endpoint", "", "Endpoint of an S3 compatible store such as MinIO, e.g. http://minio:9000")
+	fs.StringVar(&opts.parser, "parser", "auto", "Format of the input: auto to detect it from the first line, or one of "+strings.Join(parser.Names(), ", "))
+	fs.StringVar(&mappingFile, "mapping", "", "YAML mapping file declaring the nodes and relationships to create, or a built-in profile ("+strings.Join(mapping.Profiles(), ", ")+")")
+	fs.StringVar(&transformExpr, "transform", "", "jq expression applied to every record before it is mapped, e.g. 'select(.status_code < 500) | .title |= ascii_downcase'")
+	fs.StringVar(&scriptFile, "script", "", "Starlark script whose enrich(record) function rewrites or skips every record, after -transform")
+	fs.Var(&enrich, "enrich", "Run every record through this enricher, after -script: geoip, or an enricher plugin of ~/.config/jsontoneo/plugins.yaml (repeatable)")
+	fs.StringVar(&geoIPPath, "geoip-dir", "", "Directory with the MaxMind City and ASN databases for -enrich geoip (default $JSONTONEO_GEOIP_DIR or /usr/share/GeoIP)")
+	fs.Var(opts.coerce, "coerce", "Convert a property to a type before it is written: property=int|float|bool|string|datetime[:layout]|list[:separator] (repeatable)")
+	fs.StringVar(&rulesFile, "rules", "", "YAML file of drop and keep rules and derived properties on record fields (default ~/.config/jsontoneo/rules.yaml if it exists)")
+	fs.BoolVar(&noRules, "no-rules", false, "Do not apply the rules file")
+	fs.Var(&templates, "cypher-template", "File with a Go template of a Cypher statement to run for every record, with the record as .Record and $record (repeatable)")
+	fs.StringVar(&templateMode, "cypher-template-mode", "augment", "Run the -cypher-template statements next to the built-in queries (augment) or instead of them (replace)")
+	fs.StringVar(&opts.summaryFormat, "summary", "text", "Format of the end-of-run summary (text|json)")
+	fs.BoolVar(&opts.tui, "tui", false, "Show live import statistics and a browsable summary in the terminal")
+	fs.IntVar(&opts.skip, "skip", 0, "Skip the first N lines of the input")
+	fs.IntVar(&opts.limit, "limit", 0, "Import at most N lines (after -skip and -sample), 0 for no limit")
+	fs.Float64Var(&opts.sample, "sample", 0, "Import a random sample of this percentage (0-100] of the lines")
+	fs.Int64Var(&opts.seed, "seed", 0, "Random seed for -sample, for reproducible samples")
+	filters.register(fs)
+	fs.StringVar(&scopeFile, "scope-file", "", "Scope file with in-scope and !out-of-scope hosts, globs and CIDRs, one per line")
+	fs.StringVar(&opts.outOfScope, "out-of-scope", "drop", "What to do with out-of-scope records: drop, or label to write them as :OutOfScope")
+	fs.BoolVar(&opts.flattenExtra, "flatten-extra", false, "Also write httpx fields the Host model does not cover, nested ones as dot-joined properties such as tls.cipher")
+	fs.Var(&onlyFields, "only-fields", "Only write these of the known Host properties, plus the url key; extra and derived properties are still written (comma-separated, repeatable)")
+	fs.Var(&skipFields, "skip-fields", "Do not write these Host properties, e.g. words,lines,title or extra ones like header.set_cookie or header.* (comma-separated, repeatable)")
+	fs.Var(&hashFields, "hash-fields", "Write these Host properties, known, extra (header.set_cookie, header.*) or derived, as SHA-256 hashes, keyed with $JSONTONEO_HASH_KEY when set (comma-separated, repeatable)")
+	fs.Var(&tags, "tag", "Add this tag to the tags property of every node touched, e.g. an engagement name (comma-separated, repeatable)")
+	fs.BoolVar(&opts.tagLabels, "tag-labels", false, "Also add the -tag values as labels")
+	fs.StringVar(&opts.project, "project", "", "Import into this project, kept apart from other projects in the same database")
+	fs.StringVar(&opts.mergeStrategy, "merge-strategy", "overwrite", "overwrite Host properties, or versioned to also record changes of status, title and tech as Observation nodes")
+	fs.StringVar(&operator, "operator", "", "Name recorded as imported_by on the Scan node (default the OS user)")
+	fs.BoolVar(&opts.attributeNodes, "attribute-nodes", false, "Also record imported_by, imported_from and tool_version on the nodes the import creates")
+	fs.StringVar(&ttl, "ttl", os.Getenv("JSONTONEO_TTL"), "Set expires_at this far ahead on the nodes written, e.g. 180d, for purge -expired (default $JSONTONEO_TTL)")
+	fs.IntVar(&opts.maxNewNodes, "max-new-nodes", 0, "Stop before the import creates more than N new nodes, 0 for no limit")
+	fs.StringVar(&opts.onQuota, "on-max-new-nodes", "abort", "What to do when -max-new-nodes is reached: abort, or dry-run to count what the rest would create without writing it")
+	fs.StringVar(&opts.notifyURL, "notify-url", "", "Post the import summary to this Slack, Discord or generic webhook when the run finishes")
+	fs.StringVar(&hooksFile, "hooks", "", "YAML file of Cypher and shell hooks to run before and after the import (default ~/.config/jsontoneo/hooks.yaml if it exists)")
+	fs.BoolVar(&noHooks, "no-hooks", false, "Do not run the pre- and post-import hooks")
+	fs.StringVar(&opts.output, "output", "neo4j", "Where to write the import: neo4j, or cypher to write a script (see -out)")
+	fs.StringVar(&opts.outFile, "out", "", "Path of the Cypher script written with -output cypher")
+	fs.StringVar(&opts.target, "target", "neo4j", "Graph database to write to, or to write the -output cypher script for: "+strings.Join(targetNames, ", "))
+	fs.StringVar(&opts.neptuneURL, "neptune-url", os.Getenv("NEPTUNE_ENDPOINT"), "Neptune endpoint for -target neptune, e.g. https://my-cluster.cluster-xxxx.eu-west-1.neptune.amazonaws.com:8182 (default $NEPTUNE_ENDPOINT)")
+	fs.StringVar(&opts.gremlinURL, "gremlin-url", "", "Gremlin server for -target gremlin, optionally with user:password (default $GREMLIN_URL or ws://localhost:8182/gremlin)")
+	fs.StringVar(&opts.arangoURL, "arango-url", "", "ArangoDB server for -target arangodb, optionally with user:password (default $ARANGO_URL or http://localhost:8529)")
+	fs.StringVar(&opts.arangoDB, "arango-db", "jsontoneo", "ArangoDB database for -target arangodb, created when it does not exist")
+	fs.StringVar(&opts.falkorURL, "falkordb-url", "", "FalkorDB server for -target falkordb (default $FALKORDB_URL or redis://localhost:6379)")
+	fs.StringVar(&opts.falkorGraph, "falkordb-graph", "jsontoneo", "FalkorDB graph key for -target falkordb")
+	fs.StringVar(&opts.ageURL, "age-url", "", "PostgreSQL database with Apache AGE for -target age (default $AGE_URL or the PG* environment variables)")
+	fs.StringVar(&opts.ageGraph, "age-graph", "jsontoneo", "AGE graph for -target age, created when it does not exist")
+	fs.StringVar(&opts.dgraphURL, "dgraph-url", "", "Dgraph Alpha HTTP endpoint for -target dgraph, optionally with user:password for ACLs (default $DGRAPH_URL or http://localhost:8080)")
+	opts.cluster.register(fs, false)
+	fs.Var(&opts.mirrors, "mirror", "Also write every record to this target, failing independently of the main one: cypher=<file>, neo4j=<uri>, memgraph=<uri>, neptune=<endpoint> or falkordb=<url> (repeatable)")
+	fs.StringVar(&opts.localStore, "local-store", "", "Stage the import in this directory for jsontoneo sync: always with -target local (default "+defaultStore+"), and with -target neo4j when Neo4j cannot be reached")
+
+	return func() {
+		switch {
+		case opts.input.from == "file" && opts.filePath == "":
+			log.Fatal("Usage: jsontoneo [import] -f <path to JSON file>")
+		case opts.input.from == "elasticsearch" && opts.input.index == "":
+			log.Fatal("Usage: jsontoneo import -from elasticsearch -index <index pattern> [-query <query>]")
+		case opts.input.from != "file" && opts.input.from != "elasticsearch":
+			log.Fatalf("Invalid -from %q (expected file or elasticsearch)", opts.input.from)
+		}
+		if _, ok := parser.Get(opts.parser); !ok && opts.parser != "auto" {
+			log.Fatalf("Invalid -parser %q (expected auto or %s)", opts.parser, strings.Join(parser.Names(), ", "))
+		}
+		if opts.summaryFormat != "text" && opts.summaryFormat != "json" {
+			log.Fatalf("Invalid -summary %q (expected text or json)", opts.summaryFormat)
+		}
+		switch {
+		case opts.output == "cypher" && opts.outFile == "":
+			log.Fatal("-output cypher requires -out <script.cypher>")
+		case opts.output != "neo4j" && opts.output != "cypher":
+			log.Fatalf("Invalid -output %q (expected neo4j or cypher)", opts.output)
+		case !slices.Contains(targetNames, opts.target):
+			log.Fatalf("Invalid -target %q (expected %s)", opts.target, strings.Join(targetNames, " or "))
+		}
+		checkTarget(opts, len(templates) > 0)
+		switch {
+		case opts.maxNewNodes < 0:
+			log.Fatal("-max-new-nodes must not be negative")
+		case opts.onQuota != "abort" && opts.onQuota != "dry-run":
+			log.Fatalf("Invalid -on-max-new-nodes %q (expected abort or dry-run)", opts.onQuota)
+		case opts.maxNewNodes > 0 && opts.output == "cypher":
+			log.Fatal("-max-new-nodes cannot be used with -output cypher")
+		}
+		if opts.skip < 0 || opts.limit < 0 {
+			log.Fatal("-skip and -limit must not be negative")
+		}
+		if opts.sample < 0 || opts.sample > 100 {
+			log.Fatalf("Invalid -sample %v (expected a percentage between 0 and 100)", opts.sample)
+		}
+		filter, err := filters.build()
+		if err != nil {
+			log.Fatalf("Invalid filter: %v", err)
+		}
+		opts.filter = filter
+		if transformExpr != "" {
+			if opts.transform, err = transform.Compile(transformExpr); err != nil {
+				log.Fatalf("Invalid -transform: %v", err)
+			}
+		}
+		if scriptFile != "" {
+			if opts.script, err = script.Load(scriptFile); err != nil {
+				log.Fatalf("Invalid -script: %v", err)
+			}
+		}
+		for _, name := range enrich {
+			if name == "geoip" {
+				g, err := openGeoIP(geoIPDir(geoIPPath))
+				if err != nil {
+					log.Fatalf("Invalid -enrich geoip: %v", err)
+				}
+				opts.enrichers = append(opts.enrichers, g)
+				continue
+			}
+			e, ok := enrichers[name]
+			if !ok {
+				log.Fatalf("Invalid -enrich %q (enrichers: %s)", name, strings.Join(enricherNames(), ", "))
+			}
+			opts.enrichers = append(opts.enrichers, e)
+		}
+		if rulesFile == "" && !noRules {
+			rulesFile = defaultRulesFile()
+		}
+		if rulesFile != "" && !noRules {
+			rules, err := loadRules(rulesFile)
+			if err != nil {
+				log.Fatalf("Invalid rules: %v", err)
+			}
+			if len(rules.Rules) > 0 {
+				opts.enrichers = append(opts.enrichers, rules)
+			}
+			if len(rules.Derive) > 0 {
+				opts.derive = rules.derive
+			}
+		}
+		switch {
+		case templateMode != "augment" && templateMode != "replace":
+			log.Fatalf("Invalid -cypher-template-mode %q (expected augment or replace)", templateMode)
+		case len(templates) > 0:
+			if opts.templates, err = neo4jwriter.LoadTemplates(templates, templateMode == "replace"); err != nil {
+				log.Fatalf("Invalid -cypher-template: %v", err)
+			}
+		}
+		if mappingFile != "" {
+			if opts.parser != "auto" {
+				log.Fatal("-mapping and -parser cannot be combined")
+			}
+			if opts.mapping, err = mapping.Load(mappingFile); err != nil {
+				log.Fatalf("Invalid mapping: %v", err)
+			}
+		}
+		if !slices.Contains(mergeStrategies, opts.mergeStrategy) {
+			log.Fatalf("Invalid -merge-strategy %q (expected %s)", opts.mergeStrategy, strings.Join(mergeStrategies, " or "))
+		}
+		if opts.outOfScope != "drop" && opts.outOfScope != "label" {
+			log.Fatalf("Invalid -out-of-scope %q (expected drop or label)", opts.outOfScope)
+		}
+		if scopeFile != "" {
+			if opts.scope, err = loadScopeFile(scopeFile); err != nil {
+				log.Fatalf("Error reading scope file: %v", err)
+			}
+		}
+		opts.tags = tags
+		var derived []string
+		if opts.derive != nil {
+			derived = opts.derive.Names("", "Host")
+		}
+		opts.fields, err = neo4jwriter.NewFieldSelection(onlyFields, skipFields, hashFields, hashKey(), derived...)
+		if err != nil {
+			log.Fatalf("Invalid field selection: %v", err)
+		}
+		opts.attribution = neo4jwriter.NewAttribution(operator)
+		if opts.ttl, err = parseTTL(ttl); err != nil {
+			log.Fatalf("Invalid -ttl: %v", err)
+		}
+		if hooksFile == "" && !noHooks {
+			hooksFile = defaultHooksFile()
+		}
+		if hooksFile != "" && !noHooks {
+			if opts.hooks, err = loadHooks(hooksFile); err != nil {
+				log.Fatalf("Invalid hooks: %v", err)
+			}
+		}
+		os.Exit(runImport(opts))
+	}
+}
+
+// runImport runs the import and returns the exit code.
+func runImport(opts importOptions) int {
+	ctx, stopTracing := startTracing()
+	defer stopTracing()
+	defer plugin.Close()
+
+	in, err := openInput(opts.filePath, opts.input)
+	if err != nil {
+		log.Fatalf("Error opening JSON file: %v", err)
+	}
+	defer in.Close()
+
+	out, driver := openTarget(ctx, opts)
+	if driver != nil {
+		defer driver.Close()
+	}
+	defer func() {
+		if err := out.Close(); err != nil {
+			log.Printf("Error closing output: %v", err)
+		}
+	}()
+
+	file := opts.filePath
+	if file == "" {
+		file = in.source
+	}
+	wopts := opts.writerOptions()
+	scanID := neo4jwriter.NewScanID()
+	env := hookEnv{scanID: scanID, file: file, project: opts.project}
+	if opts.hooks != nil {
+		if err := runHooks("pre-import", opts.hooks.PreImport, out, env); err != nil {
+			log.Fatalf("Aborting import: %v", err)
+		}
+	}
+	if err := neo4jwriter.CreateScan(out, scanID, in.source, wopts); err != nil {
+		log.Fatalf("Error creating scan node: %v", err)
+	}
+	log.Printf("Scan ID: %s", scanID)
+
+	// De TUI pas starten als de verbinding staat, zodat fatale fouten leesbaar blijven.
+	var monitor *tui
+	if opts.tui {
+		monitor, err = newTUI(in.size)
+		if err != nil {
+			log.Fatalf("Error starting TUI: %v", err)
+		}
+		log.SetOutput(monitor)
+	}
+
+	switch {
+	case monitor != nil:
+		wopts.Observer = monitor
+	// Bij -summary json blijft stdout gereserveerd voor de JSON output.
+	case opts.summaryFormat == "text" && opts.output == "cypher":
+		wopts.Observer = printAdded("script")
+	case opts.summaryFormat == "text":
+		wopts.Observer = printAdded("Neo4j")
+	}
+	imp := neo4jwriter.New(out, scanID, wopts)
+
+	summary := &neo4jwriter.Summary{ScanID: scanID, File: file}
+	start := time.Now()
+
+	if err := imp.Run(ctx, in, summary); err != nil {
+		if monitor != nil {
+			monitor.restore()
+		}
+		log.Fatalf("Error reading file: %v", err)
+	}
+
+	if err := neo4jwriter.FinishScan(out, scanID); err != nil {
+		log.Printf("Error finishing scan node: %v", err)
+	}
+
+	summary.Finish(time.Since(start))
+	if fan, ok := out.(*neo4jwriter.FanoutTarget); ok {
+		summary.MirrorFailures = fan.Failures()
+	}
+	opts.cluster.save()
+	if monitor != nil {
+		monitor.finish(*summary)
+		log.SetOutput(os.Stderr)
+	}
+	code := exitCode(summary)
+	if opts.hooks != nil {
+		env.summary, env.code = summary, code
+		if err := runHooks("post-import", opts.hooks.PostImport, out, env); err != nil {
+			log.Printf("Error: %v", err)
+			if code == exitOK {
+				code = exitHookFailed
+			}
+		}
+	}
+	if err := summary.Print(os.Stdout, opts.summaryFormat); err != nil {
+		log.Printf("Error printing summary: %v", err)
+	}
+	if opts.notifyURL != "" {
+		if err := notifyImport(opts.notifyURL, summary); err != nil {
+			log.Printf("Error sending notification: %v", err)
+		}
+	}
+	return code
+}
+
+// writerOptions returns the options of the import for the neo4jwriter package.
+func (opts importOptions) writerOptions() neo4jwriter.Options {
+	w := neo4jwriter.Options{
+		Transform:       opts.transform,
+		Script:          opts.script,
+		Enrichers:       opts.enrichers,
+		Coerce:          opts.coerce,
+		FlattenExtra:    opts.flattenExtra,
+		Derive:          opts.derive,
+		Templates:       opts.templates,
+		Project:         opts.project,
+		Fields:          opts.fields,
+		Tags:            opts.tags,
+		TagLabels:       opts.tagLabels,
+		Versioned:       opts.mergeStrategy == "versioned",
+		Attribution:     opts.attribution,
+		AttributeNodes:  opts.attributeNodes,
+		TTL:             opts.ttl,
+		LabelOutOfScope: opts.outOfScope == "label",
+		Skip:            opts.skip,
+		Limit:           opts.limit,
+		Sample:          opts.sample,
+		Seed:            opts.seed,
+		MaxNewNodes:     opts.maxNewNodes,
+		QuotaDryRun:     opts.onQuota == "dry-run",
+		ToolVersion:     version,
+		ToolCommit:      commit,
+	}
+	if p, ok := parser.Get(opts.parser); ok {
+		w.Parser = p
+	}
+	if opts.mapping != nil {
+		w.Parser = opts.mapping
+	}
+	// Een nil pointer in een interface is niet nil, dus alleen zetten als er iets is.
+	if opts.filter != nil {
+		w.Filter = opts.filter
+	}
+	if opts.scope != nil {
+		w.Scope = opts.scope
+	}
+	return w
+}
+
+// hashKey returns the key for -hash-fields from $JSONTONEO_HASH_KEY, or nil.
+func hashKey() []byte {
+	if key := os.Getenv("JSONTONEO_HASH_KEY"); key != "" {
+		return []byte(key)
+	}
+	return nil
+}
+
+// printAdded prints every record written to stdout.
+type printAdded string
+
+func (to printAdded) Observe(result *model.HttpxResult, n int, summary neo4jwriter.Summary) {
+	if result != nil {
+		fmt.Printf("Added to %s: %s\n", string(to), result.URL)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// input is an opened import source.
+type input struct {
+	io.Reader
+	// size is the number of bytes the reader will return, or 0 if unknown.
+	size int64
+	// source identifies the input on the Scan node.
+	source  string
+	closers []io.Closer
+}
+
+func (in *input) Close() error {
+	var err error
+	for i := len(in.closers) - 1; i >= 0; i-- {
+		if cerr := in.closers[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type inputOptions struct {
+	from       string
+	s3Endpoint string
+	headers    headerList
+	esURL      string
+	index      string
+	query      string
+}
+
+// headerList is a repeatable "Name: value" flag. Unlike stringList it does
+// not split on commas, which are common in header values.
+type headerList []string
+
+func (l *headerList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *headerList) Set(value string) error {
+	if name, _, ok := strings.Cut(value, ":"); !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header %q (expected \"Name: value\")", value)
+	}
+	*l = append(*l, value)
+	return nil
+}
+
+// openInput opens the -f argument of an import: a local path, an
+// s3://bucket/key URL or an http(s):// URL. Inputs whose name ends in .gz
+// are decompressed. With -from elasticsearch the input is read from an index
+// instead.
+func openInput(path string, opts inputOptions) (*input, error) {
+	if opts.from == "elasticsearch" {
+		return openElasticsearch(opts)
+	}
+
+	var in *input
+	var err error
+	name := path
+	switch {
+	case strings.HasPrefix(path, "s3://"):
+		in, err = openS3(path, opts)
+	case strings.HasPrefix(path, "http://"), strings.HasPrefix(path, "https://"):
+		in, err = openHTTP(path, opts)
+		if u, perr := url.Parse(path); perr == nil {
+			name = u.Path
+		}
+	default:
+		in, err = openFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(in.Reader)
+		if err != nil {
+			in.Close()
+			return nil, err
+		}
+		in.Reader = gz
+		in.size = 0
+		in.closers = append(in.closers, gz)
+	}
+	return in, nil
+}
+
+func openFile(path string) (*input, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	in := &input{Reader: file, source: path, closers: []io.Closer{file}}
+	if info, err := file.Stat(); err == nil {
+		in.size = info.Size()
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		in.source = abs
+	}
+	return in, nil
+}
+
+// openS3 streams an object from S3 or an S3 compatible store such as MinIO,
+// using the standard AWS credential chain (environment, shared config and
+// credentials files, instance roles).
+func openS3(rawURL string, opts inputOptions) (*input, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 URL %q (expected s3://bucket/key)", rawURL)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opts.s3Endpoint != "" {
+			// MinIO en andere S3 compatibele stores gebruiken path-style URLs.
+			o.BaseEndpoint = aws.String(opts.s3Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	obj, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	return &input{
+		Reader:  obj.Body,
+		size:    aws.ToInt64(obj.ContentLength),
+		source:  rawURL,
+		closers: []io.Closer{obj.Body},
+	}, nil
+}
+
+// openHTTP streams the body of a GET request, e.g. a CI artifact, with the
+// -header values added to the request.
+func openHTTP(rawURL string, opts inputOptions) (*input, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range opts.headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	req.Header.Set("User-Agent", "jsontoneo/"+version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", req.URL.Redacted(), resp.Status)
+	}
+
+	// Query parameters kunnen tokens bevatten (presigned URLs), die horen niet op de Scan node.
+	source := *req.URL
+	source.RawQuery = ""
+	in := &input{Reader: resp.Body, source: source.Redacted(), closers: []io.Closer{resp.Body}}
+	if resp.ContentLength > 0 && !resp.Uncompressed {
+		in.size = resp.ContentLength
+	}
+	return in, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// httpxLine is a model.HttpxResult as written by the JSONL exporter, which
+// omits the asn object for hosts without ASN data like httpx does.
+type httpxLine struct {
+	model.HttpxResult
+	ASN *model.ASN `json:"asn,omitempty"`
+}
+
+// writeJSONL reconstructs httpx-like JSON lines from the Host nodes in g and
+// their ASN, so tools that read httpx output can consume curated data.
+func writeJSONL(w io.Writer, g *exportGraph) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	asns := map[string]*exportNode{}
+	for _, e := range g.Edges {
+		if e.Type == "BELONGS_TO" {
+			if n := g.index[e.Target]; n != nil && n.Label() == "ASN" {
+				asns[e.Source] = n
+			}
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if n.Label() != "Host" {
+			continue
+		}
+		line := httpxLine{HttpxResult: hostResult(n.Props)}
+		if a := asns[n.ID]; a != nil {
+			line.ASN = &model.ASN{
+				ASNumber:  propString(a.Props["number"]),
+				ASName:    propString(a.Props["name"]),
+				ASCountry: propString(a.Props["country"]),
+				ASRange:   propStrings(a.Props["range"]),
+			}
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// hostResult maps the properties of a Host node back to the httpx fields they
+// were imported from.
+func hostResult(props map[string]any) model.HttpxResult {
+	return model.HttpxResult{
+		Timestamp: propString(props["timestamp"]),
+		Port:      propString(props["port"]),
+		URL:       propString(props["url"]),
+		Input:     propString(props["input"]),
+		Title:     propString(props["title"]),
+		Scheme:    propString(props["scheme"]),
+		Webserver: propString(props["webserver"]),
+		Tech:      propStrings(props["tech"]),
+		Host:      propString(props["ip"]),
+		Status:    propInt(props["status"]),
+		Words:     propInt(props["words"]),
+		Lines:     propInt(props["lines"]),
+		Resolvers: propStrings(props["resolvers"]),
+		CNAME:     propStrings(props["cname"]),
+		Favicon:   propString(props["favicon"]),
+		Jarm:      propString(props["jarm"]),
+		A:         propStrings(props["a"]),
+		CDN:       propBool(props["cdn"]),
+		CDNName:   propString(props["cdn_name"]),
+		GeoIP:     model.GeoIP{Country: propString(props["country"]), City: propString(props["city"])},
+	}
+}
+
+func propString(v any) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func propInt(v any) int {
+	switch v := v.(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// propBool returns v if it is a bool, or nil when it is unknown.
+func propBool(v any) *bool {
+	b, ok := v.(bool)
+	if !ok {
+		return nil
+	}
+	return &b
+}
+
+func propStrings(v any) []string {
+	list, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]string, len(list))
+	for i, item := range list {
+		out[i] = propString(item)
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaSource struct {
+	reader *kafka.Reader
+	url    string
+}
+
+func newKafkaSource(opts consumeOptions) (consumerSource, error) {
+	if len(opts.brokers) == 0 || opts.topic == "" {
+		return nil, errors.New("-brokers and -topic are required")
+	}
+	return &kafkaSource{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: opts.brokers,
+			Topic:   opts.topic,
+			GroupID: opts.group,
+		}),
+		url: "kafka://" + strings.Join(opts.brokers, ",") + "/" + opts.topic,
+	}, nil
+}
+
+func (s *kafkaSource) fetch(ctx context.Context) (consumerMessage, error) {
+	msg, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return consumerMessage{}, err
+	}
+	return consumerMessage{value: msg.Value, ack: msg}, nil
+}
+
+// commit commits the offsets of msgs for the consumer group.
+func (s *kafkaSource) commit(ctx context.Context, msgs []consumerMessage) error {
+	kmsgs := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		kmsgs[i] = m.ack.(kafka.Message)
+	}
+	return s.reader.CommitMessages(ctx, kmsgs...)
+}
+
+func (s *kafkaSource) name() string {
+	return s.url
+}
+
+func (s *kafkaSource) close() error {
+	return s.reader.Close()
+}
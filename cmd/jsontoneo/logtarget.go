@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// logTargets maps the -log-target values to a function opening the log
+// writer. Platform specific targets register themselves in init.
+var logTargets = map[string]func() (io.Writer, error){
+	"stderr": func() (io.Writer, error) { return os.Stderr, nil },
+}
+
+func logTargetNames() []string {
+	names := make([]string, 0, len(logTargets))
+	for name := range logTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setLogTarget sends the log output to the named target. Targets other than
+// stderr add their own timestamps, so the log prefix is dropped.
+func setLogTarget(name string) error {
+	open, ok := logTargets[name]
+	if !ok {
+		return fmt.Errorf("unknown log target %q (expected %s)", name, strings.Join(logTargetNames(), ", "))
+	}
+	w, err := open()
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", name, err)
+	}
+	log.SetOutput(w)
+	if name != "stderr" {
+		log.SetFlags(0)
+	}
+	return nil
+}
+
+// isErrorLine reports whether a log line reports an error, to give it a
+// higher priority in syslog and the journal.
+func isErrorLine(p []byte) bool {
+	return strings.HasPrefix(string(p), "Error") || strings.Contains(string(p), " error")
+}
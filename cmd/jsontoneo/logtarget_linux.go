@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+)
+
+func init() {
+	logTargets["journald"] = openJournald
+}
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalWriter sends log lines to systemd-journald using its native
+// protocol, with a priority and SYSLOG_IDENTIFIER=jsontoneo.
+type journalWriter struct {
+	conn *net.UnixConn
+}
+
+func openJournald() (io.Writer, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journalWriter{conn: conn}, nil
+}
+
+func (j *journalWriter) Write(p []byte) (int, error) {
+	priority := 6 // info
+	if isErrorLine(p) {
+		priority = 3 // err
+	}
+	msg := bytes.TrimSuffix(p, []byte("\n"))
+
+	var buf bytes.Buffer
+	buf.WriteString("PRIORITY=" + strconv.Itoa(priority) + "\n")
+	buf.WriteString("SYSLOG_IDENTIFIER=jsontoneo\n")
+	if bytes.IndexByte(msg, '\n') >= 0 {
+		// Waarden met een newline worden binair gecodeerd: naam, newline, lengte (64-bit LE), waarde.
+		buf.WriteString("MESSAGE\n")
+		n := uint64(len(msg))
+		for i := 0; i < 8; i++ {
+			buf.WriteByte(byte(n >> (8 * i)))
+		}
+		buf.Write(msg)
+		buf.WriteByte('\n')
+	} else {
+		buf.WriteString("MESSAGE=")
+		buf.Write(msg)
+		buf.WriteByte('\n')
+	}
+	if _, err := j.conn.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
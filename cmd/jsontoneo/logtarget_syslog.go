@@ -0,0 +1,36 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+func init() {
+	logTargets["syslog"] = openSyslog
+}
+
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func openSyslog() (io.Writer, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "jsontoneo")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	var err error
+	if isErrorLine(p) {
+		err = s.w.Err(msg)
+	} else {
+		err = s.w.Info(msg)
+	}
+	return len(p), err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command is a jsontoneo subcommand. flags registers the command's flags on
+// fs and returns the function that runs the command once fs has been parsed.
+type command struct {
+	name    string
+	summary string
+	flags   func(fs *flag.FlagSet) func()
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"import", "Import httpx JSON output into Neo4j (default)", importFlags},
+		{"diff", "Compare two imports: new, removed and changed hosts and new open ports", diffFlags},
+		{"stats", "Print node and relationship counts, top technologies and ASNs, live hosts and recent scans", statsFlags},
+		{"query", "Run a saved query preset, such as hosts-by-tech or expiring-certs, and print the results", queryFlags},
+		{"takeover", "List hosts whose CNAME points at a takeover-prone service or a name that no longer exists", takeoverFlags},
+		{"pivot", "List IPs, favicons, JARM fingerprints and certificates shared by many hosts", pivotFlags},
+		{"analyze", "Run Neo4j GDS community detection (louvain) or centrality (pagerank) and write the scores to the nodes", analyzeFlags},
+		{"tech", "List each technology with the versions in use and the number of hosts per version", techFlags},
+		{"eol", "List technology versions past their end of life, with the hosts running them", eolFlags},
+		{"certs", "List hosts presenting certificates that expired or expire soon, by days remaining", certsFlags},
+		{"unscanned", "List names from certificate SANs and Domain nodes that no scanned host has", unscannedFlags},
+		{"coverage", "Measure how much of a scope file the graph covers and how much of it httpx probed", coverageFlags},
+		{"exposure", "Summarize open ports per ASN and per netblock", exposureFlags},
+		{"search", "Full-text search hosts by URL, title, web server and technology", searchFlags},
+		{"path", "Show the shortest path between two assets, e.g. a hostname and an ASN", pathFlags},
+		{"history", "Show when the status, title or technologies of a host changed, from versioned imports", historyFlags},
+		{"dead", "List hosts that do not resolve or only returned non-2xx/3xx responses in recent scans", deadFlags},
+		{"cdn", "List hosts that resolve to both CDN and non-CDN IPs, a likely origin leak", cdnFlags},
+		{"report", "Generate an attack-surface report from the graph", reportFlags},
+		{"enrich", "Add GeoIP data (geoip), RDAP registration data (whois), missing ASNs (asn), Shodan host data (shodan), Censys services and certificates (censys), passive DNS history (pdns), cloud provider ranges (cloud), blocklist hits (reputation), grabbed TLS certificates (tls) or favicon hashes (favicon) to the graph", enrichFlags},
+		{"consume", "Continuously import records from Kafka, NATS JetStream or Redis Streams into Neo4j", consumeFlags},
+		{"serve", "Accept scan output over HTTP and import it into Neo4j", serveFlags},
+		{"sync", "Replay imports staged in a local store, while Neo4j was unreachable, into Neo4j", syncFlags},
+		{"purge", "Delete or label hosts that have not been seen for a while", purgeFlags},
+		{"delete", "Delete the hosts matching a host, technology or IP range filter", deleteFlags},
+		{"repair", "Merge duplicate Host, ASN, IP, Tech and Scan nodes", repairFlags},
+		{"gc", "Remove ASN, IP and Tech nodes that no longer have any relationships", gcFlags},
+		{"migrate", "Upgrade a graph written by an older jsontoneo to the current schema version", migrateFlags},
+		{"baseline", "Pin a scan as baseline, so later imports label new hosts :NewSinceBaseline", baselineFlags},
+		{"rollback", "Undo an import: delete what one scan created and revert what it changed", rollbackFlags},
+		{"export", "Export hosts, IPs, technologies and ASNs from Neo4j to a graph file", exportFlags},
+		{"completion", "Generate a shell completion script (bash|zsh|fish)", completionFlags},
+	}
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// commandNames returns the names of all subcommands, sorted.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: jsontoneo [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'jsontoneo <command> -h' for the flags of a command, or 'jsontoneo --version' for build information.\n")
+}
+
+func main() {
+	args := os.Args[1:]
+
+	if len(args) > 0 && (args[0] == "-version" || args[0] == "--version") {
+		printVersion()
+		return
+	}
+
+	registerPlugins()
+
+	// Zonder subcommand blijft het oude gedrag (jsontoneo -f file) werken.
+	cmd, _ := lookupCommand("import")
+	if len(args) > 0 {
+		if c, ok := lookupCommand(args[0]); ok {
+			cmd = c
+			args = args[1:]
+		} else if args[0] == "help" {
+			usage()
+			return
+		}
+	}
+
+	// ContinueOnError: flag.ExitOnError zou met code 2 stoppen, wat
+	// "parse errors" betekent (zie exitcode.go).
+	fs := flag.NewFlagSet("jsontoneo "+cmd.name, flag.ContinueOnError)
+	run := cmd.flags(fs)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitOK)
+		}
+		os.Exit(exitFatal)
+	}
+	run()
+}
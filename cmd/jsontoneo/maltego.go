@@ -0,0 +1,153 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maltegoEntity describes how a node label is represented in Maltego: the
+// entity type, the name of its main property and that property's display
+// name.
+type maltegoEntity struct {
+	Type        string
+	Property    string
+	DisplayName string
+}
+
+var maltegoEntities = map[string]maltegoEntity{
+	"Host": {"maltego.URL", "url", "URL"},
+	"IP":   {"maltego.IPv4Address", "ipv4-address", "IP Address"},
+	"ASN":  {"maltego.AS", "as.number", "AS Number"},
+	"Tech": {"maltego.Phrase", "text", "Text"},
+	"Scan": {"maltego.Phrase", "text", "Text"},
+}
+
+func maltegoEntityFor(n *exportNode) maltegoEntity {
+	if e, ok := maltegoEntities[n.Label()]; ok {
+		return e
+	}
+	return maltegoEntity{"maltego.Phrase", "text", "Text"}
+}
+
+// maltegoValue returns the value of the main entity property of n. Maltego
+// expects AS numbers without the "AS" prefix.
+func maltegoValue(n *exportNode) string {
+	name := n.Name()
+	if n.Label() == "ASN" {
+		name = strings.TrimPrefix(strings.ToUpper(name), "AS")
+	}
+	return name
+}
+
+// writeMTGX writes g as a Maltego graph file (.mtgx), which can be opened
+// directly in Maltego.
+func writeMTGX(w io.Writer, g *exportGraph) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("Graphs/Graph1.graphml")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+
+	fmt.Fprintln(bw, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(bw, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:mtg="http://maltego.paterva.com/xml/mtgx">`)
+	fmt.Fprintln(bw, `  <key for="node" id="d0" attr.name="MaltegoEntity"/>`)
+	fmt.Fprintln(bw, `  <key for="edge" id="d1" attr.name="MaltegoLink"/>`)
+	fmt.Fprintln(bw, `  <graph edgedefault="directed" id="G">`)
+
+	ids := make(map[string]string, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+		e := maltegoEntityFor(n)
+
+		fmt.Fprintf(bw, "    <node id=\"%s\">\n", ids[n.ID])
+		fmt.Fprintln(bw, `      <data key="d0">`)
+		fmt.Fprintf(bw, "        <mtg:MaltegoEntity type=\"%s\">\n", e.Type)
+		fmt.Fprintln(bw, "          <mtg:Properties>")
+		writeMaltegoProperty(bw, e.Property, e.DisplayName, maltegoValue(n))
+		if n.Label() == "Host" {
+			if title := propertyString(n.Props["title"]); title != "" {
+				writeMaltegoProperty(bw, "short-title", "Short title", title)
+			}
+		}
+		fmt.Fprintln(bw, "          </mtg:Properties>")
+		fmt.Fprintln(bw, "        </mtg:MaltegoEntity>")
+		fmt.Fprintln(bw, "      </data>")
+		fmt.Fprintln(bw, "    </node>")
+	}
+	for i, e := range g.Edges {
+		fmt.Fprintf(bw, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", i, ids[e.Source], ids[e.Target])
+		fmt.Fprintln(bw, `      <data key="d1">`)
+		fmt.Fprintln(bw, `        <mtg:MaltegoLink type="maltego.link.manual-link">`)
+		fmt.Fprintln(bw, "          <mtg:Properties>")
+		writeMaltegoProperty(bw, "maltego.link.manual.type", "Label", e.Type)
+		fmt.Fprintln(bw, "          </mtg:Properties>")
+		fmt.Fprintln(bw, "        </mtg:MaltegoLink>")
+		fmt.Fprintln(bw, "      </data>")
+		fmt.Fprintln(bw, "    </edge>")
+	}
+
+	fmt.Fprintln(bw, "  </graph>")
+	fmt.Fprintln(bw, "</graphml>")
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeMaltegoProperty(w io.Writer, name, displayName, value string) {
+	fmt.Fprintf(w, "            <mtg:Property name=\"%s\" displayName=\"%s\" type=\"string\" nullable=\"true\" hidden=\"false\" readonly=\"false\">\n", name, displayName)
+	fmt.Fprintf(w, "              <mtg:Value>%s</mtg:Value>\n", xmlEscape(value))
+	fmt.Fprintln(w, "            </mtg:Property>")
+}
+
+// writeMaltegoCSV writes g as a table for Maltego's "Import Graph from Table"
+// wizard: one column per entity type (URL, IP, AS, technology), with a row per
+// host and as many rows as needed for its IPs, ASNs and technologies. Map the
+// url column to the other columns to create the links.
+func writeMaltegoCSV(w io.Writer, g *exportGraph) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"url", "title", "ip", "as_number", "tech"}); err != nil {
+		return err
+	}
+
+	neighbours := map[string]map[string][]string{}
+	for _, e := range g.Edges {
+		src, dst := g.index[e.Source], g.index[e.Target]
+		if src == nil || dst == nil || src.Label() != "Host" {
+			continue
+		}
+		if neighbours[src.ID] == nil {
+			neighbours[src.ID] = map[string][]string{}
+		}
+		neighbours[src.ID][dst.Label()] = append(neighbours[src.ID][dst.Label()], maltegoValue(dst))
+	}
+
+	for _, n := range g.Nodes {
+		if n.Label() != "Host" {
+			continue
+		}
+		nb := neighbours[n.ID]
+		rows := max(1, len(nb["IP"]), len(nb["ASN"]), len(nb["Tech"]))
+		for i := 0; i < rows; i++ {
+			row := []string{n.Name(), propertyString(n.Props["title"]), at(nb["IP"], i), at(nb["ASN"], i), at(nb["Tech"], i)}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func at(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+func migrateFlags(fs *flag.FlagSet) func() {
+	var dryRun bool
+	fs.BoolVar(&dryRun, "dry-run", false, "Only show the schema version and the migrations that would run")
+
+	return func() {
+		driver := connect()
+		defer driver.Close()
+		out := neo4jwriter.NewNeo4jTarget(driver)
+		defer out.Close()
+
+		version, err := schemaVersion(driver, out, dryRun)
+		if err != nil {
+			log.Fatalf("Error reading schema version: %v", err)
+		}
+		fmt.Fprintf(os.Stdout, "Graph schema version %d, jsontoneo schema version %d\n", version, neo4jwriter.SchemaVersion)
+		if version > neo4jwriter.SchemaVersion {
+			log.Fatal("The graph was written by a newer jsontoneo; upgrade jsontoneo instead")
+		}
+		pending := neo4jwriter.PendingMigrations(version)
+		if !dryRun {
+			if err := neo4jwriter.EnsureIndexes(out); err != nil {
+				log.Fatalf("Error creating indexes: %v", err)
+			}
+		}
+		if len(pending) == 0 {
+			fmt.Fprintln(os.Stdout, "The graph is up to date")
+			return
+		}
+		fmt.Fprintln(os.Stdout, "Pending migrations")
+		for _, m := range pending {
+			fmt.Fprintf(os.Stdout, "  %-3d %s\n", m.Version, m.Description)
+		}
+		if dryRun {
+			return
+		}
+
+		err = neo4jwriter.Migrate(out, func(m neo4jwriter.Migration, stats neo4jwriter.Stats) {
+			log.Printf("Migrated to version %d: %d nodes and %d relationships created, %d properties set",
+				m.Version, stats.NodesCreated, stats.RelsCreated, stats.PropertiesSet)
+		})
+		if err != nil {
+			log.Fatalf("Error migrating graph: %v", err)
+		}
+	}
+}
+
+// schemaVersion returns the schema version of the graph. A dry run reads it
+// in a read transaction, leaving a graph without a Schema node as it is.
+func schemaVersion(driver neo4j.Driver, out neo4jwriter.Target, dryRun bool) (int, error) {
+	if !dryRun {
+		return neo4jwriter.GraphSchema(out)
+	}
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+	version, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		return neo4jwriter.ReadSchema(tx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version.(int), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsSource consumes a JetStream stream through a durable pull consumer
+// with explicit acks, so unacknowledged messages are redelivered.
+type natsSource struct {
+	conn     *nats.Conn
+	iter     jetstream.MessagesContext
+	messages chan jetstream.Msg
+	errs     chan error
+	url      string
+}
+
+func newNATSSource(opts consumeOptions) (consumerSource, error) {
+	if opts.stream == "" {
+		return nil, errors.New("-stream is required")
+	}
+	url := opts.url
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	conn, err := nats.Connect(url, nats.Name("jsontoneo"))
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	consumer, err := js.CreateOrUpdateConsumer(context.Background(), opts.stream, jetstream.ConsumerConfig{
+		Durable:       opts.group,
+		FilterSubject: opts.subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("consumer %s on stream %s: %w", opts.group, opts.stream, err)
+	}
+	iter, err := consumer.Messages(jetstream.PullMaxMessages(opts.batchSize))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s := &natsSource{
+		conn:     conn,
+		iter:     iter,
+		messages: make(chan jetstream.Msg),
+		errs:     make(chan error, 1),
+		url:      url + "/" + opts.stream,
+	}
+	if opts.subject != "" {
+		s.url += "/" + opts.subject
+	}
+	go s.pump()
+	return s, nil
+}
+
+// pump moves messages from the blocking iterator to a channel, so fetch can
+// stop waiting when its context is done.
+func (s *natsSource) pump() {
+	for {
+		msg, err := s.iter.Next()
+		if err != nil {
+			s.errs <- err
+			return
+		}
+		s.messages <- msg
+	}
+}
+
+func (s *natsSource) fetch(ctx context.Context) (consumerMessage, error) {
+	select {
+	case msg := <-s.messages:
+		return consumerMessage{value: msg.Data(), ack: msg}, nil
+	case err := <-s.errs:
+		return consumerMessage{}, err
+	case <-ctx.Done():
+		return consumerMessage{}, ctx.Err()
+	}
+}
+
+func (s *natsSource) commit(ctx context.Context, msgs []consumerMessage) error {
+	var errs []error
+	for _, m := range msgs {
+		if err := m.ack.(jetstream.Msg).Ack(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *natsSource) name() string {
+	return s.url
+}
+
+func (s *natsSource) close() error {
+	s.iter.Stop()
+	return s.conn.Drain()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// connect loads the configuration and opens a driver to Neo4j.
+func connect() neo4j.Driver {
+	config := loadConfig()
+
+	driver, err := neo4j.NewDriver(config.URI, neo4j.BasicAuth(config.Username, config.Password, ""))
+	if err != nil {
+		log.Fatalf("Error connecting to Neo4j: %v", err)
+	}
+	return driver
+}
+
+// clusterOptions route the sessions of a command in a Neo4j cluster or on
+// Aura. Writes always go to the leader; reads go to the followers unless
+// readFrom is "leader". With a bookmark file, every session of the command
+// waits for the writes recorded in it, and the command records its own.
+type clusterOptions struct {
+	bookmarkFile string
+	readFrom     string
+
+	once    sync.Once
+	mu      sync.Mutex
+	manager neo4j.BookmarkManager
+}
+
+// register adds -bookmarks, and for commands that only read -read-from.
+func (c *clusterOptions) register(fs *flag.FlagSet, reads bool) {
+	fs.StringVar(&c.bookmarkFile, "bookmarks", "", "Causal consistency in a cluster: wait for the writes whose bookmarks are in this file, and store the bookmarks of this command's writes in it")
+	if reads {
+		fs.StringVar(&c.readFrom, "read-from", "followers", "Where a cluster runs the queries: followers, to keep the load off the leader, or leader, to always see the latest writes")
+	}
+}
+
+// check validates the options.
+func (c *clusterOptions) check() {
+	if c.readFrom != "" && c.readFrom != "followers" && c.readFrom != "leader" {
+		log.Fatalf("Unknown -read-from %q (expected followers or leader)", c.readFrom)
+	}
+}
+
+// configure sets the bookmark manager of a session.
+func (c *clusterOptions) configure(config *neo4j.SessionConfig) {
+	if c.bookmarkFile == "" {
+		return
+	}
+	c.once.Do(func() {
+		data, err := os.ReadFile(c.bookmarkFile)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Error reading bookmarks: %v", err)
+		}
+		c.manager = neo4j.NewBookmarkManager(neo4j.BookmarkManagerConfig{InitialBookmarks: strings.Fields(string(data))})
+	})
+	config.BookmarkManager = c.manager
+}
+
+// session opens a session in mode whose ReadTransaction runs where
+// -read-from says.
+func (c *clusterOptions) session(driver neo4j.Driver, mode neo4j.AccessMode) neo4j.Session {
+	config := neo4j.SessionConfig{AccessMode: mode}
+	c.configure(&config)
+	return routedSession{Session: driver.NewSession(config), leader: c.readFrom == "leader"}
+}
+
+// save stores the bookmarks of the command's writes in the bookmark file.
+func (c *clusterOptions) save() {
+	if c.manager == nil {
+		return
+	}
+	bookmarks, err := c.manager.GetBookmarks(context.Background())
+	if err != nil || len(bookmarks) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.WriteFile(c.bookmarkFile, []byte(strings.Join(bookmarks, "\n")+"\n"), 0o600); err != nil {
+		log.Printf("Error saving bookmarks: %v", err)
+	}
+}
+
+// routedSession runs read transactions on the leader when leader is set.
+// The driver routes a read transaction to a follower whatever the access
+// mode of its session, so these run as write transactions instead.
+type routedSession struct {
+	neo4j.Session
+	leader bool
+}
+
+func (s routedSession) ReadTransaction(work neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (any, error) {
+	if s.leader {
+		return s.Session.WriteTransaction(work, configurers...)
+	}
+	return s.Session.ReadTransaction(work, configurers...)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+// notifyImport posts the summary of a finished import to a Slack, Discord or
+// generic webhook. Slack and Discord get a formatted message; any other URL
+// receives the summary as JSON.
+func notifyImport(webhookURL string, s *neo4jwriter.Summary) error {
+	state := "finished"
+	if exitCode(s) != exitOK {
+		state = "finished with errors"
+	}
+	text := fmt.Sprintf("jsontoneo import of %s %s (scan %s): %d records read, %d written, %d filtered, %d parse errors, %d write errors, %d nodes created in %s",
+		s.File, state, s.ScanID, s.Read, s.Written, s.Filtered, s.ParseErrors, s.Failed, s.NodesCreated, s.Elapsed().Round(time.Second))
+
+	return postWebhook(webhookURL, text, s)
+}
+
+// maxAlertHosts is the number of hosts listed in a new-assets alert; the
+// JSON payload of a generic webhook has all of them.
+const maxAlertHosts = 25
+
+// notifyNewAssets posts the hosts a diff saw for the first time.
+func notifyNewAssets(webhookURL string, d *scanDiff, hosts []observation) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "jsontoneo: %d new assets in %s", len(hosts), d.To)
+	if d.Project != "" {
+		fmt.Fprintf(&b, " (project %s)", d.Project)
+	}
+	for i, h := range hosts {
+		if i == maxAlertHosts {
+			fmt.Fprintf(&b, "\n… and %d more", len(hosts)-maxAlertHosts)
+			break
+		}
+		fmt.Fprintf(&b, "\n• %s", h.URL)
+		if h.Status != 0 {
+			fmt.Fprintf(&b, " [%d]", h.Status)
+		}
+		if h.Title != "" {
+			fmt.Fprintf(&b, " %s", h.Title)
+		}
+	}
+
+	type asset struct {
+		URL    string `json:"url"`
+		Status int    `json:"status,omitempty"`
+		Title  string `json:"title,omitempty"`
+	}
+	assets := make([]asset, len(hosts))
+	for i, h := range hosts {
+		assets[i] = asset{URL: h.URL, Status: h.Status, Title: h.Title}
+	}
+	payload := map[string]any{"event": "new_assets", "project": d.Project, "from": d.From, "to": d.To, "hosts": assets}
+	return postWebhook(webhookURL, b.String(), payload)
+}
+
+// postWebhook posts text to a Slack or Discord webhook, or payload as JSON to
+// any other URL.
+func postWebhook(webhookURL, text string, payload any) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+
+	var body any
+	switch {
+	case u.Host == "hooks.slack.com":
+		body = map[string]string{"text": text}
+	case (u.Host == "discord.com" || u.Host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		body = map[string]string{"content": text}
+	default:
+		body = payload
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// outputOptions are the -format and -out flags of the commands that print
+// results. Besides its own text format every such command writes JSON, and
+// its rows as a CSV or markdown table, so the results can feed the next
+// stage of a pipeline.
+type outputOptions struct {
+	format string
+	out    string
+	// text are the formats the command prints itself, the default first.
+	text []string
+}
+
+func (o *outputOptions) register(fs *flag.FlagSet, text ...string) {
+	o.text = text
+	usage := "Output format (" + strings.Join(o.formats(), "|") + ")"
+	fs.StringVar(&o.format, "format", text[0], usage)
+	fs.StringVar(&o.format, "output", text[0], "Alias of -format")
+	fs.StringVar(&o.out, "out", "", "Write the output to this file instead of stdout")
+}
+
+func (o *outputOptions) formats() []string {
+	return append(append([]string(nil), o.text...), "json", "csv", "md")
+}
+
+// outputFormats returns the -format values of a command printing the text
+// formats itself, for completion.
+func outputFormats(text ...string) func() []string {
+	return func() []string {
+		o := outputOptions{text: text}
+		return o.formats()
+	}
+}
+
+func (o *outputOptions) check() {
+	formats := o.formats()
+	for _, f := range formats {
+		if o.format == f {
+			return
+		}
+	}
+	log.Fatalf("Invalid -format %q (expected %s)", o.format, strings.Join(formats, ", "))
+}
+
+// write writes a result to stdout or the -out file: in a text format with
+// print, as JSON v, or rows as a table. rows is a resultTable or a slice of
+// structs, whose JSON field names are the columns.
+func (o *outputOptions) write(v, rows any, print func(io.Writer) error) error {
+	w := io.Writer(os.Stdout)
+	var file *os.File
+	if o.out != "" {
+		var err error
+		if file, err = os.Create(o.out); err != nil {
+			return err
+		}
+		w = file
+	}
+
+	var err error
+	switch o.format {
+	case "json":
+		err = writeJSON(w, v)
+	case "csv", "md":
+		t, ok := rows.(resultTable)
+		if !ok {
+			t = tableOf(rows)
+		}
+		if o.format == "csv" {
+			err = t.writeCSV(w)
+		} else {
+			err = t.writeMarkdown(w)
+		}
+	default:
+		err = print(w)
+	}
+	if file != nil {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// resultTable is a result as rows of cells under a header.
+type resultTable struct {
+	header []string
+	rows   [][]string
+}
+
+// tableOf turns a slice of structs, or pointers to structs, into a table
+// with a column per exported field, named after its JSON name.
+func tableOf(rows any) resultTable {
+	var t resultTable
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		return t
+	}
+	typ := v.Type().Elem()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var fields []int
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		t.header = append(t.header, name)
+		fields = append(fields, i)
+	}
+	for i := 0; i < v.Len(); i++ {
+		row := reflect.Indirect(v.Index(i))
+		cells := make([]string, len(fields))
+		for j, f := range fields {
+			cells[j] = fieldString(row.Field(f))
+		}
+		t.rows = append(t.rows, cells)
+	}
+	return t
+}
+
+// fieldString formats a field as a table cell: lists comma-separated, maps
+// as key: value pairs and nested structs as JSON.
+func fieldString(v reflect.Value) string {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return ""
+	}
+	v = reflect.Indirect(v)
+	switch x := v.Interface().(type) {
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		return fieldString(v.Elem())
+	case reflect.Slice, reflect.Array:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fieldString(v.Index(i))
+		}
+		return strings.Join(items, ", ")
+	case reflect.Map:
+		items := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			items = append(items, fmt.Sprintf("%v: %s", k.Interface(), fieldString(v.MapIndex(k))))
+		}
+		sort.Strings(items)
+		return strings.Join(items, "; ")
+	case reflect.Struct:
+		b, _ := json.Marshal(v.Interface())
+		return string(b)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func (t resultTable) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write(t.header)
+	cw.WriteAll(t.rows)
+	return cw.Error()
+}
+
+func (t resultTable) writeMarkdown(w io.Writer) error {
+	cell := strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+	line := func(cells []string) {
+		escaped := make([]string, len(cells))
+		for i, c := range cells {
+			escaped[i] = cell.Replace(c)
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+	}
+	line(t.header)
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(t.header)))
+	for _, row := range t.rows {
+		line(row)
+	}
+	return nil
+}
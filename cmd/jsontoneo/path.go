@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type pathOptions struct {
+	from     string
+	to       string
+	project  string
+	output   outputOptions
+	maxHops  int
+	viaScans bool
+	cluster  *clusterOptions
+}
+
+// pathHop is a node on a path, with the relationship leading to the next.
+type pathHop struct {
+	Label string `json:"label"`
+	Name  string `json:"name"`
+	Rel   string `json:"rel,omitempty"`
+	// Forward is true when Rel points from this node to the next.
+	Forward bool `json:"forward,omitempty"`
+}
+
+// historyRels link nodes through the import history rather than the assets
+// themselves; every host is two hops from every other through its Scan.
+var historyRels = []string{"SEEN_IN", "DETECTED_IN", "CHANGED", "OBSERVED", "OBSERVED_IN"}
+
+func pathFlags(fs *flag.FlagSet) func() {
+	opts := pathOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.from, "from", "", "Start of the path: a URL, domain (matching its subdomains too), IP, ASN (AS13335), technology, certificate subject or scan ID")
+	fs.StringVar(&opts.to, "to", "", "End of the path, like -from")
+	fs.StringVar(&opts.project, "project", "", "Only follow the nodes of this project")
+	opts.output.register(fs, "text")
+	fs.IntVar(&opts.maxHops, "max-hops", 6, "Longest path to look for")
+	fs.BoolVar(&opts.viaScans, "via-scans", false, "Also follow Scan nodes and the import history")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		if opts.from == "" || opts.to == "" {
+			log.Fatal("Usage: jsontoneo path -from ASSET -to ASSET")
+		}
+		opts.output.check()
+		if opts.maxHops < 1 || opts.maxHops > 15 {
+			log.Fatalf("-max-hops must be between 1 and 15")
+		}
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		hops, err := shortestPath(session, opts)
+		if err != nil {
+			log.Fatalf("Error finding path: %v", err)
+		}
+		if hops == nil {
+			log.Fatalf("No path from %s to %s within %d hops", opts.from, opts.to, opts.maxHops)
+		}
+		err = opts.output.write(hops, hops, func(w io.Writer) error {
+			return printPath(w, hops)
+		})
+		if err != nil {
+			log.Fatalf("Error writing path: %v", err)
+		}
+	}
+}
+
+// assetCond matches the nodes of v against an asset given on the command
+// line, with the parameters it uses prefixed with p.
+func assetCond(v, p string) string {
+	return `((` + v + `:Host AND (toLower(` + v + `.url) = $` + p + `Lower OR toLower(` + v + `.url) =~ $` + p + `Host OR ` + v + `.ip = $` + p + `))
+	  OR (` + v + `:ASN AND toUpper(` + v + `.number) = $` + p + `ASN)
+	  OR (` + v + `:IP AND ` + v + `.address = $` + p + `)
+	  OR (` + v + `:Tech AND toLower(` + v + `.name) = $` + p + `Lower)
+	  OR (` + v + `:Certificate AND toLower(` + v + `.subject_cn) = $` + p + `Lower)
+	  OR (` + v + `:Scan AND ` + v + `.id = $` + p + `))
+	  AND ` + neo4jwriter.ProjectCond(v)
+}
+
+// assetParams adds the parameters of assetCond for the asset s to params.
+func assetParams(params map[string]any, p, s string) {
+	lower := strings.ToLower(s)
+	asn := strings.ToUpper(s)
+	if _, err := strconv.Atoi(s); err == nil {
+		asn = "AS" + s
+	}
+	params[p] = s
+	params[p+"Lower"] = lower
+	// Een domein matcht de URL's van het domein en zijn subdomeinen, met
+	// elke scheme en poort.
+	params[p+"Host"] = "[a-z][a-z0-9+.-]*://([a-z0-9_-]+\\.)*" + regexp.QuoteMeta(lower) + "(:[0-9]+)?(/.*)?"
+	params[p+"ASN"] = asn
+}
+
+func shortestPath(session neo4j.Session, opts pathOptions) ([]pathHop, error) {
+	params := map[string]any{"project": opts.project, "skip": historyRels}
+	assetParams(params, "from", opts.from)
+	assetParams(params, "to", opts.to)
+	filter := "all(r IN relationships(p) WHERE NOT type(r) IN $skip) AND none(n IN nodes(p)[1..-1] WHERE n:Scan)"
+	if opts.viaScans {
+		filter = "true"
+	}
+
+	var hops []pathHop
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		hops = nil
+		res, err := tx.Run(`
+		MATCH (a) WHERE `+assetCond("a", "from")+`
+		MATCH (b) WHERE `+assetCond("b", "to")+` AND a <> b
+		MATCH p = shortestPath((a)-[*..`+strconv.Itoa(opts.maxHops)+`]-(b))
+		WHERE `+filter+`
+		RETURN p
+		ORDER BY length(p)
+		LIMIT 1
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Path query error: %w", err)
+		}
+		if !res.Next() {
+			return nil, res.Err()
+		}
+		p, ok := res.Record().Values[0].(neo4j.Path)
+		if !ok {
+			return nil, fmt.Errorf("Path query error: unexpected result %T", res.Record().Values[0])
+		}
+		for i, n := range p.Nodes {
+			hop := pathHop{Label: strings.Join(n.Labels, ":"), Name: nodeName(n.Props)}
+			if i < len(p.Relationships) {
+				r := p.Relationships[i]
+				hop.Rel, hop.Forward = r.Type, r.StartElementId == n.ElementId
+			}
+			hops = append(hops, hop)
+		}
+		return nil, res.Err()
+	})
+	return hops, err
+}
+
+// nodeName returns what identifies a node to a reader.
+func nodeName(props map[string]any) string {
+	if n := propString(props["number"]); n != "" {
+		return strings.TrimSpace(n + " " + propString(props["name"]))
+	}
+	for _, key := range []string{"url", "address", "name", "subject_cn", "id"} {
+		if v := propString(props[key]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func printPath(w io.Writer, hops []pathHop) error {
+	fmt.Fprintf(w, "%d hops\n", len(hops)-1)
+	for _, h := range hops {
+		fmt.Fprintf(w, "  (%s) %s\n", h.Label, h.Name)
+		switch {
+		case h.Rel == "":
+		case h.Forward:
+			fmt.Fprintf(w, "     -[:%s]->\n", h.Rel)
+		default:
+			fmt.Fprintf(w, "     <-[:%s]-\n", h.Rel)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+const securityTrailsAPI = "https://api.securitytrails.com/v1/"
+
+// pdnsDelay keeps the requests within the rate limit of SecurityTrails.
+const pdnsDelay = time.Second
+
+var (
+	errPDNSUnauthorized = errors.New("invalid API key")
+	errPDNSBudget       = errors.New("request budget exhausted")
+)
+
+// pdnsRecord is an IP a domain resolved to between from and to.
+type pdnsRecord struct {
+	IP       string
+	From, To time.Time
+}
+
+// pdnsSource returns the A and AAAA history of a domain.
+type pdnsSource interface {
+	history(domain string) ([]pdnsRecord, error)
+}
+
+// pdnsClient sends the requests of both sources, within the budget.
+type pdnsClient struct {
+	key      string
+	client   *http.Client
+	last     time.Time
+	requests int
+	budget   int
+}
+
+// get returns the body of a 200 response to u; a 404 has an empty body.
+// The caller closes it.
+func (c *pdnsClient) get(u string, header func(*http.Request)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if c.requests >= c.budget {
+			return nil, errPDNSBudget
+		}
+		if wait := pdnsDelay - time.Since(c.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		c.last = time.Now()
+		c.requests++
+
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "jsontoneo/"+version)
+		header(req)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < 3:
+			resp.Body.Close()
+			wait := 5 * time.Second << attempt
+			log.Printf("Rate limited by the passive DNS API, waiting %s", wait)
+			time.Sleep(wait)
+			continue
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			resp.Body.Close()
+			return nil, errPDNSUnauthorized
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, nil
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			return nil, fmt.Errorf("status %s", resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+// securityTrails reads /history/{domain}/dns/a and /dns/aaaa, page by page.
+type securityTrails struct {
+	*pdnsClient
+	base string
+}
+
+func (s securityTrails) history(domain string) ([]pdnsRecord, error) {
+	var records []pdnsRecord
+	for _, typ := range []string{"a", "aaaa"} {
+		for page, pages := 1, 1; page <= pages; page++ {
+			u := fmt.Sprintf("%shistory/%s/dns/%s?page=%d", s.base, url.PathEscape(domain), typ, page)
+			resp, err := s.get(u, func(req *http.Request) {
+				req.Header.Set("APIKEY", s.key)
+				req.Header.Set("Accept", "application/json")
+			})
+			if err != nil {
+				return nil, err
+			}
+			if resp == nil {
+				break
+			}
+			var body struct {
+				Pages   int `json:"pages"`
+				Records []struct {
+					Values []struct {
+						IP   string `json:"ip"`
+						IPv6 string `json:"ipv6"`
+					} `json:"values"`
+					FirstSeen string `json:"first_seen"`
+					LastSeen  string `json:"last_seen"`
+				} `json:"records"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			pages = body.Pages
+			for _, r := range body.Records {
+				from, err1 := time.Parse(time.DateOnly, r.FirstSeen)
+				to, err2 := time.Parse(time.DateOnly, r.LastSeen)
+				if err1 != nil || err2 != nil {
+					continue
+				}
+				for _, v := range r.Values {
+					ip := v.IP
+					if ip == "" {
+						ip = v.IPv6
+					}
+					if ip != "" {
+						records = append(records, pdnsRecord{IP: ip, From: from, To: to})
+					}
+				}
+			}
+		}
+	}
+	return records, nil
+}
+
+// cofSource queries a passive DNS server answering in the Passive DNS
+// Common Output Format (CIRCL, Farsight and others): newline-delimited JSON
+// with rrname, rrtype, rdata, time_first and time_last. The domain is
+// appended to the URL.
+type cofSource struct {
+	*pdnsClient
+	base string
+}
+
+func (s cofSource) history(domain string) ([]pdnsRecord, error) {
+	resp, err := s.get(s.base+url.PathEscape(domain), func(req *http.Request) {
+		// CIRCL wil basic auth, Farsight een X-API-Key.
+		if user, pass, ok := strings.Cut(s.key, ":"); ok {
+			req.SetBasicAuth(user, pass)
+		} else if s.key != "" {
+			req.Header.Set("X-API-Key", s.key)
+		}
+		req.Header.Set("Accept", "application/json")
+	})
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var records []pdnsRecord
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var r struct {
+			RRName    string          `json:"rrname"`
+			RRType    string          `json:"rrtype"`
+			RData     json.RawMessage `json:"rdata"`
+			TimeFirst int64           `json:"time_first"`
+			TimeLast  int64           `json:"time_last"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &r) != nil || (r.RRType != "A" && r.RRType != "AAAA") {
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSuffix(r.RRName, "."), domain) || r.TimeFirst == 0 {
+			continue
+		}
+		// rdata is een string, of bij Farsight een lijst.
+		var rdata []string
+		if json.Unmarshal(r.RData, &rdata) != nil {
+			var s string
+			if json.Unmarshal(r.RData, &s) != nil {
+				continue
+			}
+			rdata = []string{s}
+		}
+		for _, ip := range rdata {
+			if isIP(ip) {
+				records = append(records, pdnsRecord{IP: ip, From: time.Unix(r.TimeFirst, 0).UTC(), To: time.Unix(r.TimeLast, 0).UTC()})
+			}
+		}
+	}
+	return records, scanner.Err()
+}
+
+// loadPDNSTargets returns the apex domains of the hosts in scope, with the
+// URLs of their hosts, keyed on project and domain. Domains whose history
+// was pulled less than -max-age ago are left out, unless opts.all is set.
+func loadPDNSTargets(session neo4j.Session, opts enrichOptions) (map[[2]string][]string, error) {
+	domains := map[[2]string][]string{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		clear(domains)
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN h.url, coalesce(h.project, '')
+		`, map[string]any{"scope": opts.scope, "project": opts.project})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			url, project := propString(v[0]), propString(v[1])
+			if apex := apexDomain(url); apex != "" && !isIP(apex) {
+				key := [2]string{project, apex}
+				domains[key] = append(domains[key], url)
+			}
+		}
+		if err := res.Err(); err != nil || opts.all {
+			return nil, err
+		}
+
+		res, err = tx.Run(`
+		MATCH (d:Domain) WHERE d.pdns_checked_at >= $since
+		RETURN d.name, coalesce(d.project, '')
+		`, map[string]any{"since": opts.since})
+		if err != nil {
+			return nil, fmt.Errorf("Domain query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			delete(domains, [2]string{propString(v[1]), propString(v[0])})
+		}
+		return nil, res.Err()
+	})
+	return domains, err
+}
+
+// enrichPDNS pulls the historical A and AAAA records of the apex domains of
+// the hosts in scope and writes them as (d:Domain)-[:RESOLVED_TO {from, to}]->(i:IP).
+func enrichPDNS(session neo4j.Session, opts enrichOptions) {
+	if opts.budget < 1 {
+		log.Fatal("-budget must be at least 1")
+	}
+	client := &pdnsClient{key: opts.apiKey, client: &http.Client{Timeout: 30 * time.Second}, budget: opts.budget}
+	var source pdnsSource
+	name := "SecurityTrails"
+	if opts.pdnsURL != "" {
+		if client.key == "" {
+			client.key = os.Getenv("PDNS_API_KEY")
+		}
+		source, name = cofSource{client, opts.pdnsURL}, opts.pdnsURL
+	} else {
+		if client.key == "" {
+			client.key = os.Getenv("SECURITYTRAILS_API_KEY")
+		}
+		if client.key == "" {
+			log.Fatal("enrich pdns needs -api-key or $SECURITYTRAILS_API_KEY, or a -pdns-url")
+		}
+		source = securityTrails{client, securityTrailsAPI}
+	}
+
+	targets, err := loadPDNSTargets(session, opts)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+	keys := sortedKeys(targets)
+
+	var rows []map[string]any
+	histories := map[string][]pdnsRecord{}
+	failed, resolutions := 0, 0
+	left := 0
+	for i, key := range keys {
+		records, ok := histories[key[1]]
+		if !ok {
+			records, err = source.history(key[1])
+			if errors.Is(err, errPDNSBudget) {
+				left = len(keys) - i
+				break
+			}
+			if errors.Is(err, errPDNSUnauthorized) {
+				log.Fatalf("%s rejected the API key", name)
+			}
+			if err != nil {
+				log.Printf("Warning: passive DNS lookup of %s: %v", key[1], err)
+				failed++
+				continue
+			}
+			histories[key[1]] = records
+		}
+		recs := make([]map[string]any, 0, len(records))
+		for _, r := range records {
+			recs = append(recs, map[string]any{"ip": r.IP, "from": r.From, "to": r.To})
+		}
+		resolutions += len(recs)
+		rows = append(rows, map[string]any{"project": key[0], "key": key[1], "hosts": targets[key], "records": recs, "source": name})
+	}
+	for start := 0; start < len(rows); start += opts.batchSize {
+		batch := rows[start:min(start+opts.batchSize, len(rows))]
+		if err := writePDNS(session, batch); err != nil {
+			log.Fatalf("Error writing passive DNS data: %v", err)
+		}
+	}
+
+	if left > 0 {
+		log.Printf("Wrote %d resolutions of %d domains from %d requests to %s (%d failed); %d domains left, run again to resume",
+			resolutions, len(rows), client.requests, name, failed, left)
+		return
+	}
+	log.Printf("Wrote %d resolutions of %d domains from %d requests to %s (%d failed)",
+		resolutions, len(rows), client.requests, name, failed)
+}
+
+// writePDNS merges the Domain and IP nodes of rows, links the hosts to
+// their domain and the domain to the IPs it resolved to. A resolution is
+// keyed on its from, so an IP the domain came back to gets a relationship
+// per period.
+func writePDNS(session neo4j.Session, rows []map[string]any) error {
+	var unscoped, scoped []map[string]any
+	for _, row := range rows {
+		if row["project"] == "" {
+			unscoped = append(unscoped, row)
+		} else {
+			scoped = append(scoped, row)
+		}
+	}
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, q := range []struct {
+			domain, ip string
+			rows       []map[string]any
+		}{
+			{"{name: row.key, project: ''}", "{address: rec.ip, project: ''}", unscoped},
+			{"{name: row.key, project: row.project}", "{address: rec.ip, project: row.project}", scoped},
+		} {
+			if len(q.rows) == 0 {
+				continue
+			}
+			for _, query := range []string{`
+				UNWIND $rows AS row
+				MERGE (d:Domain ` + q.domain + `)
+				SET d.pdns_checked_at = datetime(), d.pdns_source = row.source
+				WITH d, row
+				UNWIND row.hosts AS url
+				MATCH (h:Host {url: url}) WHERE coalesce(h.project, '') = row.project
+				MERGE (h)-[:IN_DOMAIN]->(d)
+				`, `
+				UNWIND $rows AS row
+				MATCH (d:Domain ` + q.domain + `)
+				UNWIND row.records AS rec
+				MERGE (i:IP ` + q.ip + `)
+				MERGE (d)-[r:RESOLVED_TO {from: rec.from}]->(i)
+				SET r.to = CASE WHEN r.to > rec.to THEN r.to ELSE rec.to END, r.source = row.source
+				`} {
+				res, err := tx.Run(query, map[string]any{"rows": q.rows})
+				if err != nil {
+					return nil, fmt.Errorf("Domain query error: %w", err)
+				}
+				if _, err := res.Consume(); err != nil {
+					return nil, fmt.Errorf("Domain query error: %w", err)
+				}
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
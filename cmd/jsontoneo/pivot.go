@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type pivotOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	by      stringList
+	min     int
+	top     int
+	cluster *clusterOptions
+}
+
+// pivotQueries maps the -by values of the pivot command to a query returning
+// the shared values and the URLs of the hosts sharing them.
+var pivotQueries = map[string]string{
+	"ip":      pivotPropQuery("ip", ""),
+	"favicon": pivotPropQuery("favicon", ""),
+	// Een JARM van alleen nullen betekent: geen TLS.
+	"jarm": pivotPropQuery("jarm", "AND h.jarm <> '"+strings.Repeat("0", 62)+"'"),
+	"cert": `
+		MATCH (h:Host)-[:PRESENTS]->(c:Certificate)
+		WHERE ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		WITH c, collect(DISTINCT h.url) AS hosts
+		WHERE size(hosts) >= $min
+		RETURN coalesce(c.fingerprint, c.subject_cn, '') AS value, hosts
+		`,
+}
+
+// pivotKinds is the order in which the pivot command reports.
+var pivotKinds = []string{"ip", "favicon", "jarm", "cert"}
+
+// pivotPropQuery groups the hosts on a Host property; cond is added to the
+// WHERE clause.
+func pivotPropQuery(prop, cond string) string {
+	return `
+		MATCH (h:Host)
+		WHERE h.` + prop + ` IS NOT NULL AND h.` + prop + ` <> '' ` + cond + `
+		  AND ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		WITH h.` + prop + ` AS value, collect(DISTINCT h.url) AS hosts
+		WHERE size(hosts) >= $min
+		RETURN value, hosts
+		`
+}
+
+// pivotGroup is a value shared by several hosts.
+type pivotGroup struct {
+	Kind   string   `json:"kind"`
+	Value  string   `json:"value"`
+	Hosts  []string `json:"hosts"`
+	Apexes []string `json:"apex_domains"`
+}
+
+func pivotFlags(fs *flag.FlagSet) func() {
+	opts := pivotOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only consider hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only consider the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.Var(&opts.by, "by", "Pivot on these, comma-separated: "+strings.Join(pivotKinds, ", ")+" (default all)")
+	fs.IntVar(&opts.min, "min", 3, "Only list values shared by at least this many hosts")
+	fs.IntVar(&opts.top, "top", 20, "List at most this many values per pivot")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		if opts.min < 2 {
+			log.Fatalf("-min must be at least 2")
+		}
+		kinds := []string(opts.by)
+		if len(kinds) == 0 {
+			kinds = pivotKinds
+		}
+		for _, k := range kinds {
+			if _, ok := pivotQueries[k]; !ok {
+				log.Fatalf("Invalid -by %q (expected %s)", k, strings.Join(pivotKinds, ", "))
+			}
+		}
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		groups, err := loadPivots(session, kinds, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		err = opts.output.write(groups, groups, func(w io.Writer) error {
+			return printPivots(w, kinds, groups)
+		})
+		if err != nil {
+			log.Fatalf("Error writing pivots: %v", err)
+		}
+	}
+}
+
+// loadPivots returns the shared values of each kind, those spanning the most
+// apex domains first, as they link assets that look unrelated.
+func loadPivots(session neo4j.Session, kinds []string, opts pivotOptions) ([]pivotGroup, error) {
+	params := map[string]any{"scope": opts.scope, "project": opts.project, "min": opts.min}
+	groups := []pivotGroup{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		groups = groups[:0]
+		for _, kind := range kinds {
+			res, err := tx.Run(pivotQueries[kind], params)
+			if err != nil {
+				return nil, fmt.Errorf("Pivot %s query error: %w", kind, err)
+			}
+			var found []pivotGroup
+			for res.Next() {
+				v := res.Record().Values
+				g := pivotGroup{Kind: kind, Value: propString(v[0]), Hosts: propStrings(v[1])}
+				sort.Strings(g.Hosts)
+				g.Apexes = apexDomains(g.Hosts)
+				found = append(found, g)
+			}
+			if err := res.Err(); err != nil {
+				return nil, fmt.Errorf("Pivot %s query error: %w", kind, err)
+			}
+			sort.Slice(found, func(i, j int) bool {
+				a, b := found[i], found[j]
+				if len(a.Apexes) != len(b.Apexes) {
+					return len(a.Apexes) > len(b.Apexes)
+				}
+				if len(a.Hosts) != len(b.Hosts) {
+					return len(a.Hosts) > len(b.Hosts)
+				}
+				return a.Value < b.Value
+			})
+			if len(found) > opts.top {
+				found = found[:opts.top]
+			}
+			groups = append(groups, found...)
+		}
+		return nil, nil
+	})
+	return groups, err
+}
+
+func apexDomains(urls []string) []string {
+	seen := map[string]bool{}
+	var apexes []string
+	for _, u := range urls {
+		if apex := apexDomain(u); !seen[apex] {
+			seen[apex] = true
+			apexes = append(apexes, apex)
+		}
+	}
+	sort.Strings(apexes)
+	return apexes
+}
+
+func printPivots(w io.Writer, kinds []string, groups []pivotGroup) error {
+	titles := map[string]string{"ip": "Shared IPs", "favicon": "Shared favicons", "jarm": "Shared JARM fingerprints", "cert": "Shared certificates"}
+	for i, kind := range kinds {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, titles[kind])
+		n := 0
+		for _, g := range groups {
+			if g.Kind != kind {
+				continue
+			}
+			n++
+			cross := ""
+			if len(g.Apexes) > 1 {
+				cross = "  [across apex domains]"
+			}
+			fmt.Fprintf(w, "  %s  %d hosts, %d apex domains%s\n", g.Value, len(g.Hosts), len(g.Apexes), cross)
+			fmt.Fprintf(w, "      apex: %s\n", strings.Join(g.Apexes, ", "))
+			hosts := g.Hosts
+			more := ""
+			if len(hosts) > 5 {
+				hosts, more = hosts[:5], fmt.Sprintf(" and %d more", len(g.Hosts)-5)
+			}
+			fmt.Fprintf(w, "      hosts: %s%s\n", strings.Join(hosts, ", "), more)
+		}
+		if n == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pocahon/jsontoneo/pkg/parser"
+	"github.com/pocahon/jsontoneo/pkg/plugin"
+	"gopkg.in/yaml.v2"
+)
+
+// pluginConfig is ~/.config/jsontoneo/plugins.yaml, declaring the parser
+// and enricher plugin binaries.
+type pluginConfig struct {
+	Parsers   []plugin.Spec `yaml:"parsers"`
+	Enrichers []plugin.Spec `yaml:"enrichers"`
+}
+
+// enrichers holds the enricher plugins by name, for -enrich.
+var enrichers = make(map[string]*plugin.EnricherPlugin)
+
+// registerPlugins registers the plugins of plugins.yaml. They are only
+// started when used.
+func registerPlugins() {
+	path := filepath.Join(configDir(), "plugins.yaml")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Error reading plugin config: %v", err)
+	}
+	var config pluginConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		log.Fatalf("Error parsing plugin config: %v", err)
+	}
+
+	for _, spec := range config.Parsers {
+		if err := checkSpec(spec); err != nil {
+			log.Fatalf("Invalid parser plugin in %s: %v", path, err)
+		}
+		if _, ok := parser.Get(spec.Name); ok {
+			log.Fatalf("Invalid parser plugin in %s: a parser named %s already exists", path, spec.Name)
+		}
+		parser.Register(plugin.NewParser(spec))
+	}
+	for _, spec := range config.Enrichers {
+		if err := checkSpec(spec); err != nil {
+			log.Fatalf("Invalid enricher plugin in %s: %v", path, err)
+		}
+		if _, ok := enrichers[spec.Name]; ok {
+			log.Fatalf("Invalid enricher plugin in %s: %s is declared twice", path, spec.Name)
+		}
+		if spec.Name == "geoip" {
+			log.Fatalf("Invalid enricher plugin in %s: geoip is a built-in enricher", path)
+		}
+		enrichers[spec.Name] = plugin.NewEnricher(spec)
+	}
+}
+
+func checkSpec(spec plugin.Spec) error {
+	switch {
+	case spec.Name == "":
+		return fmt.Errorf("plugin without a name")
+	case spec.Command == "":
+		return fmt.Errorf("plugin %s has no command", spec.Name)
+	}
+	return nil
+}
+
+// enricherNames returns the names of the built-in enrichers and the enricher
+// plugins, sorted.
+func enricherNames() []string {
+	names := []string{"geoip"}
+	for name := range enrichers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
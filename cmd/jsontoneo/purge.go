@@ -0,0 +1,353 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type purgeOptions struct {
+	olderThan string
+	expired   bool
+	project   string
+	scope     string
+	markStale bool
+	retire    bool
+	archive   string
+	dryRun    bool
+	yes       bool
+}
+
+// stalePlan is what a purge would do: the stale hosts, and the nodes that
+// only exist because of them.
+type stalePlan struct {
+	Cutoff  time.Time
+	Expired bool
+	Hosts   []staleHost
+	Orphans int64
+	Changes int64
+	// Scans counts the expired Scan nodes, with -expired and without -scope.
+	Scans   int64
+	ids     []string
+	project string
+}
+
+type staleHost struct {
+	URL      string
+	LastSeen time.Time
+}
+
+func purgeFlags(fs *flag.FlagSet) func() {
+	var opts purgeOptions
+	fs.StringVar(&opts.olderThan, "older-than", "", "Purge hosts not seen for this long, e.g. 90d")
+	fs.BoolVar(&opts.expired, "expired", false, "Purge the hosts and scans whose expires_at (see import -ttl) has passed")
+	fs.StringVar(&opts.scope, "scope", "", "Only purge hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only purge the hosts of this project")
+	fs.BoolVar(&opts.markStale, "mark-stale", false, "Label the hosts :Stale instead of deleting them")
+	fs.BoolVar(&opts.retire, "retire", false, "Label the hosts :Retired with a retired_at time instead of deleting them")
+	fs.StringVar(&opts.archive, "archive", "", "Write the hosts to this JSON Lines file (gzipped when it ends in .gz) before deleting them")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Only show what would be purged")
+	fs.BoolVar(&opts.yes, "yes", false, "Do not ask for confirmation")
+
+	return func() {
+		if (opts.olderThan == "") == !opts.expired {
+			log.Fatal("Usage: jsontoneo purge -older-than 90d | -expired [-scope example.com] [-mark-stale] [-dry-run]")
+		}
+		if opts.markStale && opts.retire {
+			log.Fatal("-mark-stale and -retire cannot be combined")
+		}
+		if opts.archive != "" && (opts.markStale || opts.retire) {
+			log.Fatal("-archive only applies when hosts are deleted")
+		}
+		cutoff := time.Now().UTC()
+		if !opts.expired {
+			age, err := parseAge(opts.olderThan)
+			if err != nil {
+				log.Fatalf("Invalid -older-than: %v", err)
+			}
+			cutoff = cutoff.Add(-age)
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+		defer session.Close()
+
+		plan, err := planPurge(session, cutoff, opts.expired, opts.project, opts.scope)
+		if err != nil {
+			log.Fatalf("Error finding stale hosts: %v", err)
+		}
+		label := ""
+		switch {
+		case opts.markStale:
+			label = "Stale"
+		case opts.retire:
+			label = "Retired"
+		}
+		plan.print(os.Stdout, label != "")
+		if opts.dryRun || len(plan.Hosts)+int(plan.Scans) == 0 {
+			return
+		}
+
+		if label == "" {
+			total := int64(len(plan.Hosts)) + plan.Orphans + plan.Changes + plan.Scans
+			ok, err := confirmDestructive(fmt.Sprintf("delete %d stale hosts and their orphaned nodes", len(plan.Hosts)), total, opts.yes)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if !ok {
+				log.Print("Purge cancelled")
+				return
+			}
+		}
+
+		if opts.archive != "" {
+			if err := archiveHosts(session, plan.ids, opts.archive); err != nil {
+				log.Fatalf("Error archiving hosts, nothing was deleted: %v", err)
+			}
+			log.Printf("Archived %d hosts to %s", len(plan.Hosts), opts.archive)
+		}
+		if err := plan.apply(session, label); err != nil {
+			log.Fatalf("Error purging hosts: %v", err)
+		}
+		if label != "" {
+			log.Printf("Labeled %d hosts :%s", len(plan.Hosts), label)
+		} else {
+			log.Printf("Deleted %d hosts, %d orphaned nodes, %d Change and Observation nodes and %d expired scans", len(plan.Hosts), plan.Orphans, plan.Changes, plan.Scans)
+		}
+	}
+}
+
+// planPurge finds the hosts last seen before cutoff. Hosts imported before
+// last_seen was recorded fall back to the start of their latest scan; hosts
+// without either are never purged. With expired it finds the hosts and scans
+// that expired before cutoff instead.
+func planPurge(session neo4j.Session, cutoff time.Time, expired bool, project, scope string) (*stalePlan, error) {
+	plan := &stalePlan{Cutoff: cutoff, Expired: expired, project: project}
+	when := "coalesce(h.last_seen, last_scan)"
+	if expired {
+		when = "h.expires_at"
+	}
+	params := map[string]any{"scope": scope, "cutoff": cutoff, "project": project}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE ($scope = '' OR toLower(h.url) CONTAINS toLower($scope)) AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:SEEN_IN]->(s:Scan)
+		WITH h, max(s.started_at) AS last_scan
+		WITH h, `+when+` AS last_seen
+		WHERE last_seen < $cutoff
+		RETURN elementId(h) AS id, h.url AS url, last_seen
+		ORDER BY last_seen, url
+		`, params)
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			v := res.Record().Values
+			lastSeen, _ := v[2].(time.Time)
+			plan.ids = append(plan.ids, propString(v[0]))
+			plan.Hosts = append(plan.Hosts, staleHost{URL: propString(v[1]), LastSeen: lastSeen})
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		plan.Orphans, plan.Changes, err = countDependents(tx, plan.ids)
+		if err != nil {
+			return nil, err
+		}
+		if !expired || scope != "" {
+			return nil, nil
+		}
+
+		res, err = tx.Run(`
+		MATCH (s:Scan)
+		WHERE s.expires_at < $cutoff AND `+neo4jwriter.ProjectCond("s")+`
+		RETURN count(s)
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Scan query error: %w", err)
+		}
+		rec, err := res.Single()
+		if err != nil {
+			return nil, err
+		}
+		plan.Scans = int64(propInt(rec.Values[0]))
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (p *stalePlan) print(w io.Writer, keep bool) {
+	if p.Expired {
+		fmt.Fprintf(w, "Hosts expired before %s (%d)\n", p.Cutoff.Format("2006-01-02 15:04"), len(p.Hosts))
+	} else {
+		fmt.Fprintf(w, "Hosts not seen since %s (%d)\n", p.Cutoff.Format("2006-01-02 15:04"), len(p.Hosts))
+	}
+	for _, h := range p.Hosts {
+		fmt.Fprintf(w, "  %s  %s\n", h.LastSeen.Format("2006-01-02"), h.URL)
+	}
+	if !keep {
+		fmt.Fprintf(w, "Orphaned ASN/IP/Tech nodes: %d\n", p.Orphans)
+		fmt.Fprintf(w, "Change and Observation nodes of these hosts: %d\n", p.Changes)
+	}
+	if p.Expired && !keep {
+		fmt.Fprintf(w, "Expired scans: %d\n", p.Scans)
+	}
+}
+
+// apply deletes the planned hosts, their Change and Observation nodes and the
+// nodes they leave orphaned, or, given a label, labels the hosts :Stale or
+// :Retired instead. An import that sees such a host again removes the label.
+func (p *stalePlan) apply(session neo4j.Session, label string) error {
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		switch label {
+		case "Stale":
+			_, err := tx.Run(`
+			MATCH (h:Host) WHERE elementId(h) IN $ids
+			SET h:Stale
+			`, map[string]any{"ids": p.ids})
+			return nil, err
+		case "Retired":
+			_, err := tx.Run(`
+			MATCH (h:Host) WHERE elementId(h) IN $ids
+			`+retireCypher, map[string]any{"ids": p.ids})
+			return nil, err
+		}
+
+		if err := deleteHosts(tx, p.ids); err != nil {
+			return nil, err
+		}
+		if p.Scans > 0 {
+			if _, err := tx.Run(`
+			MATCH (s:Scan)
+			WHERE s.expires_at < $cutoff AND `+neo4jwriter.ProjectCond("s")+`
+			DETACH DELETE s
+			`, map[string]any{"cutoff": p.Cutoff, "project": p.project}); err != nil {
+				return nil, fmt.Errorf("Scan query error: %w", err)
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// archiveHosts writes the hosts with the given element ids and their ASN as
+// httpx JSON lines to filename, so they can be restored with an import.
+func archiveHosts(session neo4j.Session, ids []string, filename string) error {
+	g := newExportGraph()
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		MATCH (h:Host) WHERE elementId(h) IN $ids
+		OPTIONAL MATCH (h)-[r:BELONGS_TO]->(a:ASN)
+		RETURN h, r, a
+		ORDER BY h.url
+		`, map[string]any{"ids": ids})
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			v := res.Record().Values
+			h := v[0].(neo4j.Node)
+			addHostNode(g, h)
+			if a, ok := v[2].(neo4j.Node); ok {
+				g.addNode(a.ElementId, a.Labels, a.Props)
+				g.addEdge(h.ElementId, a.ElementId, "BELONGS_TO")
+			}
+		}
+		return nil, res.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if strings.HasSuffix(filename, ".gz") {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+	if err := writeJSONL(w, g); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// retireCypher labels the matched hosts h :Retired. retired_at keeps the
+// first time a host was retired.
+const retireCypher = `SET h:Retired, h.retired_at = coalesce(h.retired_at, datetime())
+`
+
+// countDependents counts the ASN, IP and Tech nodes that would be orphaned by
+// deleting the hosts with the given element ids, and their Change and
+// Observation nodes.
+func countDependents(tx neo4j.Transaction, ids []string) (orphans, changes int64, err error) {
+	res, err := tx.Run(`
+	OPTIONAL MATCH (h)--(n)
+	WHERE elementId(h) IN $ids AND (n:ASN OR n:IP OR n:Tech)
+	  AND NOT EXISTS { MATCH (n)--(m) WHERE NOT elementId(m) IN $ids }
+	WITH count(DISTINCT n) AS orphans
+	OPTIONAL MATCH (h:Host)-[:CHANGED|OBSERVED]->(c)
+	WHERE elementId(h) IN $ids AND (c:Change OR c:Observation)
+	RETURN orphans, count(c) AS changes
+	`, map[string]any{"ids": ids})
+	if err != nil {
+		return 0, 0, fmt.Errorf("Orphan query error: %w", err)
+	}
+	rec, err := res.Single()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int64(propInt(rec.Values[0])), int64(propInt(rec.Values[1])), nil
+}
+
+// deleteHosts deletes the hosts with the given element ids, their Change and
+// Observation nodes and the ASN, IP and Tech nodes they leave orphaned.
+func deleteHosts(tx neo4j.Transaction, ids []string) error {
+	params := map[string]any{"ids": ids}
+	if _, err := tx.Run(`
+	MATCH (h)--(n)
+	WHERE elementId(h) IN $ids AND (n:ASN OR n:IP OR n:Tech)
+	  AND NOT EXISTS { MATCH (n)--(m) WHERE NOT elementId(m) IN $ids }
+	WITH DISTINCT n
+	DETACH DELETE n
+	`, params); err != nil {
+		return fmt.Errorf("Orphan query error: %w", err)
+	}
+	if _, err := tx.Run(`
+	MATCH (h:Host)-[:CHANGED|OBSERVED]->(c)
+	WHERE elementId(h) IN $ids AND (c:Change OR c:Observation)
+	DETACH DELETE c
+	`, params); err != nil {
+		return fmt.Errorf("Change query error: %w", err)
+	}
+	if _, err := tx.Run(`
+	MATCH (h:Host) WHERE elementId(h) IN $ids
+	DETACH DELETE h
+	`, params); err != nil {
+		return fmt.Errorf("Host query error: %w", err)
+	}
+	return nil
+}
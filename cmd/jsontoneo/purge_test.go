@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// fakeTx records the statements run in it and answers each with the rows
+// of the entry of rows whose key the statement contains.
+type fakeTx struct {
+	neo4j.Transaction
+	rows   map[string][][]any
+	stmts  []string
+	params []map[string]any
+}
+
+func (tx *fakeTx) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	tx.stmts = append(tx.stmts, cypher)
+	tx.params = append(tx.params, params)
+	for key, rows := range tx.rows {
+		if strings.Contains(cypher, key) {
+			return &fakeResult{rows: rows}, nil
+		}
+	}
+	return &fakeResult{}, nil
+}
+
+type fakeResult struct {
+	neo4j.Result
+	rows [][]any
+	i    int
+}
+
+func (r *fakeResult) Next() bool {
+	r.i++
+	return r.i <= len(r.rows)
+}
+
+func (r *fakeResult) Record() *neo4j.Record { return &neo4j.Record{Values: r.rows[r.i-1]} }
+func (r *fakeResult) Err() error            { return nil }
+
+func (r *fakeResult) Single() (*neo4j.Record, error) {
+	if len(r.rows) != 1 {
+		return nil, errors.New("result does not have exactly one record")
+	}
+	r.i = 1
+	return r.Record(), nil
+}
+
+// fakeSession runs every transaction in tx.
+type fakeSession struct {
+	neo4j.Session
+	tx *fakeTx
+}
+
+func (s *fakeSession) ReadTransaction(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (any, error) {
+	return work(s.tx)
+}
+
+func (s *fakeSession) WriteTransaction(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (any, error) {
+	return work(s.tx)
+}
+
+func TestPlanPurge(t *testing.T) {
+	cutoff := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	seen := cutoff.AddDate(0, -2, 0)
+	tests := []struct {
+		name    string
+		expired bool
+		scope   string
+		when    string
+		scans   int64
+	}{
+		{name: "older than", when: "coalesce(h.last_seen, last_scan) AS last_seen"},
+		{name: "expired", expired: true, when: "h.expires_at AS last_seen", scans: 4},
+		{name: "expired in scope", expired: true, scope: "example.com", when: "h.expires_at AS last_seen"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &fakeTx{rows: map[string][][]any{
+				"RETURN elementId(h)": {{"4:h:1", "https://a.example.com", seen}, {"4:h:2", "https://b.example.com", seen}},
+				"RETURN orphans":      {{int64(2), int64(3)}},
+				"RETURN count(s)":     {{int64(4)}},
+			}}
+			plan, err := planPurge(&fakeSession{tx: tx}, cutoff, tt.expired, "acme", tt.scope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(tx.stmts[0], tt.when) {
+				t.Errorf("host query does not select %q:\n%s", tt.when, tx.stmts[0])
+			}
+			if tx.params[0]["project"] != "acme" || tx.params[0]["cutoff"] != cutoff {
+				t.Errorf("host query params = %v", tx.params[0])
+			}
+			want := []staleHost{{"https://a.example.com", seen}, {"https://b.example.com", seen}}
+			if !reflect.DeepEqual(plan.Hosts, want) || !reflect.DeepEqual(plan.ids, []string{"4:h:1", "4:h:2"}) {
+				t.Errorf("hosts = %v, ids = %v", plan.Hosts, plan.ids)
+			}
+			if plan.Orphans != 2 || plan.Changes != 3 || plan.Scans != tt.scans {
+				t.Errorf("orphans, changes, scans = %d, %d, %d, want 2, 3, %d", plan.Orphans, plan.Changes, plan.Scans, tt.scans)
+			}
+		})
+	}
+}
+
+func TestPurgeApply(t *testing.T) {
+	tests := []struct {
+		label string
+		scans int64
+		want  []string
+	}{
+		{label: "Stale", want: []string{"SET h:Stale"}},
+		{label: "Retired", want: []string{"SET h:Retired"}},
+		{want: []string{"(n:ASN OR n:IP OR n:Tech)", "(c:Change OR c:Observation)", "DETACH DELETE h"}},
+		{scans: 1, want: []string{"(n:ASN OR n:IP OR n:Tech)", "(c:Change OR c:Observation)", "DETACH DELETE h", "DETACH DELETE s"}},
+	}
+	for _, tt := range tests {
+		tx := &fakeTx{}
+		plan := &stalePlan{ids: []string{"4:h:1"}, Scans: tt.scans}
+		if err := plan.apply(&fakeSession{tx: tx}, tt.label); err != nil {
+			t.Fatal(err)
+		}
+		if len(tx.stmts) != len(tt.want) {
+			t.Fatalf("apply(%q) ran %d statements, want %d:\n%s", tt.label, len(tx.stmts), len(tt.want), strings.Join(tx.stmts, "\n"))
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(tx.stmts[i], want) {
+				t.Errorf("apply(%q) statement %d does not contain %q:\n%s", tt.label, i, want, tx.stmts[i])
+			}
+			if ids, ok := tx.params[i]["ids"]; ok && !reflect.DeepEqual(ids, plan.ids) {
+				t.Errorf("apply(%q) statement %d ids = %v", tt.label, i, ids)
+			}
+		}
+	}
+}
+
+func TestStalePlanPrint(t *testing.T) {
+	plan := &stalePlan{
+		Cutoff:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Hosts:   []staleHost{{"https://a.example.com", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		Orphans: 2,
+		Changes: 3,
+	}
+	var buf bytes.Buffer
+	plan.print(&buf, false)
+	want := "Hosts not seen since 2024-03-01 12:00 (1)\n" +
+		"  2024-01-02  https://a.example.com\n" +
+		"Orphaned ASN/IP/Tech nodes: 2\n" +
+		"Change and Observation nodes of these hosts: 3\n"
+	if buf.String() != want {
+		t.Errorf("print =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	plan.print(&buf, true)
+	if strings.Contains(buf.String(), "Orphaned") {
+		t.Errorf("print with keep mentions orphans:\n%s", buf.String())
+	}
+}
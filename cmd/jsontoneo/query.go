@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type queryOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	limit   int
+	list    bool
+	cypher  string
+	params  cypherParams
+	write   bool
+	cluster *clusterOptions
+}
+
+// cypherParams collects -param name=value flags. Values are parsed as JSON
+// when they are valid JSON, so numbers, booleans and lists keep their type;
+// anything else is a string.
+type cypherParams map[string]any
+
+func (p cypherParams) String() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (p cypherParams) Set(value string) error {
+	name, raw, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		v = raw
+	}
+	p[name] = v
+	return nil
+}
+
+// queryPreset is a named Cypher query of the query command. Besides its own
+// params every preset gets $scope, $project and $limit.
+type queryPreset struct {
+	name    string
+	summary string
+	params  []presetParam
+	cypher  string
+}
+
+// presetParam is a parameter of a preset, given as name=value. A parameter
+// without default is required; an int default makes the value an int.
+type presetParam struct {
+	name string
+	def  any
+	help string
+}
+
+var queryPresets = []queryPreset{
+	{
+		name:    "hosts-by-tech",
+		summary: "Hosts running a technology",
+		params:  []presetParam{{name: "tech", help: "technology, matched case-insensitively as a substring, e.g. nginx"}},
+		cypher: `
+		MATCH (h:Host)
+		WHERE ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		  AND any(t IN coalesce(h.tech, []) WHERE toLower(t) CONTAINS toLower($tech))
+		RETURN h.url AS url, h.status AS status, h.title AS title, h.ip AS ip, h.tech AS tech
+		ORDER BY url
+		LIMIT $limit
+		`,
+	},
+	{
+		name:    "shared-ips",
+		summary: "IP addresses shared by more than one host",
+		params:  []presetParam{{name: "min", def: 2, help: "minimum number of hosts on the IP"}},
+		cypher: `
+		MATCH (h:Host)
+		WHERE h.ip IS NOT NULL AND ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		WITH h.ip AS ip, collect(h.url) AS hosts
+		WHERE size(hosts) >= $min
+		RETURN ip, size(hosts) AS count, hosts
+		ORDER BY count DESC, ip
+		LIMIT $limit
+		`,
+	},
+	{
+		// Veel hosts op een IP op een blocklist: gedeelde hosting, niet hun eigen infrastructuur.
+		name:    "blocklisted-ips",
+		summary: "Host IPs on a blocklist (enrich reputation), as shared or dedicated by the number of hosts on them",
+		params:  []presetParam{{name: "shared", def: 5, help: "number of hosts on an IP from which it counts as shared hosting"}},
+		cypher: `
+		MATCH (h:Host)
+		WHERE coalesce(h.ip, '') <> '' AND ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		OPTIONAL MATCH (i:IP {address: h.ip}) WHERE coalesce(i.project, '') = coalesce(h.project, '')
+		WITH h, coalesce(h.blocklists, i.blocklists, []) AS lists
+		WHERE size(lists) > 0
+		WITH h.ip AS ip, collect(lists)[0] AS lists, collect(DISTINCT h.url) AS hosts
+		WITH ip, lists, hosts, COUNT { MATCH (o:Host {ip: ip}) WHERE ` + neo4jwriter.ProjectCond("o") + ` } AS count
+		RETURN ip, lists, CASE WHEN count >= $shared THEN 'shared' ELSE 'dedicated' END AS hosting, count, hosts
+		ORDER BY hosting, count DESC, ip
+		LIMIT $limit
+		`,
+	},
+	{
+		name:    "hosts-on-asn",
+		summary: "Hosts in an ASN",
+		params:  []presetParam{{name: "asn", help: "ASN number, e.g. AS13335, or a substring of its name"}},
+		cypher: `
+		MATCH (h:Host)-[:BELONGS_TO]->(a:ASN)
+		WHERE ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		  AND (toUpper(a.number) = toUpper($asn) OR toLower(a.name) CONTAINS toLower($asn))
+		RETURN a.number AS asn, a.name AS name, h.url AS url, h.ip AS ip, h.status AS status
+		ORDER BY asn, url
+		LIMIT $limit
+		`,
+	},
+	{
+		name:    "expiring-certs",
+		summary: "Certificates expiring within a number of days, and the hosts presenting them",
+		params:  []presetParam{{name: "days", def: 30, help: "expiry window in days"}},
+		cypher: `
+		MATCH (h:Host)-[:PRESENTS]->(c:Certificate)
+		WHERE ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		  AND c.not_after < datetime() + duration({days: $days})
+		RETURN c.subject_cn AS subject, c.not_after AS not_after, collect(DISTINCT h.url) AS hosts
+		ORDER BY not_after
+		LIMIT $limit
+		`,
+	},
+	{
+		// Het laatste element van de CNAME-keten is het uiteindelijke doel; een
+		// Host voor dat doel met een IP telt ook als opgelost.
+		name:    "dangling-cnames",
+		summary: "CNAME chains whose final target has no IP, candidates for takeover triage",
+		cypher: `
+		MATCH (h:Host)
+		WHERE size(coalesce(h.cname, [])) > 0 AND ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		  AND coalesce(h.ip, '') = '' AND NOT (h)-[:RESOLVES_TO]->(:IP)
+		WITH h, toLower(last(h.cname)) AS target
+		WHERE NOT EXISTS {
+			MATCH (t:Host)
+			WHERE toLower(split(split(split(t.url, '://')[-1], '/')[0], ':')[0]) = target
+			  AND (coalesce(t.ip, '') <> '' OR (t)-[:RESOLVES_TO]->(:IP))
+		}
+		RETURN h.url AS url, target, h.cname AS chain, h.status AS status
+		ORDER BY target, url
+		LIMIT $limit
+		`,
+	},
+}
+
+func lookupPreset(name string) (queryPreset, bool) {
+	for _, p := range queryPresets {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return queryPreset{}, false
+}
+
+func presetNames() []string {
+	names := make([]string, 0, len(queryPresets))
+	for _, p := range queryPresets {
+		names = append(names, p.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func queryFlags(fs *flag.FlagSet) func() {
+	opts := queryOptions{params: cypherParams{}, cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only query hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only query the nodes of this project")
+	opts.output.register(fs, "table")
+	fs.IntVar(&opts.limit, "limit", 100, "Return at most this many rows of a preset")
+	fs.BoolVar(&opts.list, "list", false, "List the presets and their parameters")
+	fs.StringVar(&opts.cypher, "cypher", "", "Run this Cypher query instead of a preset; @file reads it from a file")
+	fs.Var(opts.params, "param", "Query parameter as name=value, e.g. -param tech=nginx or -param 'ports=[80,443]' (repeatable)")
+	fs.BoolVar(&opts.write, "write", false, "Run -cypher in a write transaction, so it may change the graph")
+	opts.cluster.register(fs, true)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: jsontoneo query [flags] <preset> [name=value ...]\n       jsontoneo query [flags] -cypher QUERY [-param name=value ...]\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output())
+		listPresets(fs.Output())
+	}
+
+	return func() {
+		if opts.list {
+			listPresets(os.Stdout)
+			return
+		}
+		opts.output.check()
+
+		query, mode := opts.cypher, neo4j.AccessModeRead
+		params := map[string]any(opts.params)
+		if query != "" {
+			if fs.NArg() > 0 {
+				log.Fatalf("-cypher cannot be combined with a preset")
+			}
+			fs.Visit(func(f *flag.Flag) {
+				if f.Name == "scope" || f.Name == "project" || f.Name == "limit" {
+					log.Fatalf("-%s only applies to presets; use WHERE and LIMIT in -cypher", f.Name)
+				}
+			})
+			if path, ok := strings.CutPrefix(query, "@"); ok {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					log.Fatalf("Error reading -cypher file: %v", err)
+				}
+				query = string(data)
+			}
+			if opts.write {
+				mode = neo4j.AccessModeWrite
+			}
+		} else {
+			if fs.NArg() == 0 {
+				log.Fatalf("Usage: jsontoneo query [flags] <preset> [name=value ...] (presets: %s), or -cypher QUERY", strings.Join(presetNames(), ", "))
+			}
+			preset, ok := lookupPreset(fs.Arg(0))
+			if !ok {
+				log.Fatalf("Unknown preset %q (expected one of %s)", fs.Arg(0), strings.Join(presetNames(), ", "))
+			}
+			if opts.write {
+				log.Fatalf("-write can only be used with -cypher")
+			}
+			if opts.limit <= 0 {
+				log.Fatalf("-limit must be positive")
+			}
+			var err error
+			if params, err = preset.bind(fs.Args()[1:], opts.params); err != nil {
+				log.Fatalf("Preset %s: %v", preset.name, err)
+			}
+			params["scope"], params["project"], params["limit"] = opts.scope, opts.project, opts.limit
+			query = preset.cypher
+		}
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, mode)
+		defer session.Close()
+
+		var keys []string
+		var rows [][]any
+		work := func(tx neo4j.Transaction) (any, error) {
+			rows = nil
+			res, err := tx.Run(query, params)
+			if err != nil {
+				return nil, err
+			}
+			if keys, err = res.Keys(); err != nil {
+				return nil, err
+			}
+			for res.Next() {
+				rows = append(rows, res.Record().Values)
+			}
+			return nil, res.Err()
+		}
+		var err error
+		if mode == neo4j.AccessModeWrite {
+			_, err = session.WriteTransaction(work)
+		} else {
+			_, err = session.ReadTransaction(work)
+		}
+		if err != nil {
+			log.Fatalf("Query error: %v", err)
+		}
+
+		objects := make([]map[string]any, len(rows))
+		table := resultTable{header: keys, rows: make([][]string, len(rows))}
+		for i, row := range rows {
+			objects[i] = make(map[string]any, len(keys))
+			table.rows[i] = make([]string, len(row))
+			for j, v := range row {
+				objects[i][keys[j]] = v
+				table.rows[i][j] = cellString(v)
+			}
+		}
+		err = opts.output.write(objects, table, func(w io.Writer) error {
+			return printTable(w, keys, rows)
+		})
+		if err != nil {
+			log.Fatalf("Error writing results: %v", err)
+		}
+	}
+}
+
+// bind turns name=value arguments and -param flags into query parameters,
+// filling in the defaults.
+func (p queryPreset) bind(args []string, flags cypherParams) (map[string]any, error) {
+	for name, v := range flags {
+		args = append(args, name+"="+fmt.Sprint(v))
+	}
+	params := make(map[string]any)
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			// Een enkele parameter mag zonder naam, b.v. "hosts-by-tech nginx".
+			if len(p.params) != 1 || len(args) != 1 {
+				return nil, fmt.Errorf("argument %q is not name=value", arg)
+			}
+			name, value = p.params[0].name, arg
+		}
+		var param *presetParam
+		for i := range p.params {
+			if p.params[i].name == name {
+				param = &p.params[i]
+			}
+		}
+		if param == nil {
+			return nil, fmt.Errorf("unknown parameter %q", name)
+		}
+		if _, isInt := param.def.(int); isInt {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s must be a number, got %q", name, value)
+			}
+			params[name] = n
+		} else {
+			params[name] = value
+		}
+	}
+	for _, param := range p.params {
+		if _, ok := params[param.name]; ok {
+			continue
+		}
+		if param.def == nil {
+			return nil, fmt.Errorf("missing parameter %s (%s)", param.name, param.help)
+		}
+		params[param.name] = param.def
+	}
+	return params, nil
+}
+
+func listPresets(w io.Writer) {
+	fmt.Fprintln(w, "Presets:")
+	for _, p := range queryPresets {
+		fmt.Fprintf(w, "  %-16s %s\n", p.name, p.summary)
+		for _, param := range p.params {
+			def := "required"
+			if param.def != nil {
+				def = fmt.Sprintf("default %v", param.def)
+			}
+			fmt.Fprintf(w, "      %s=...  %s (%s)\n", param.name, param.help, def)
+		}
+	}
+}
+
+func printTable(w io.Writer, keys []string, rows [][]any) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(keys, "\t")))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cellString(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d rows\n", len(rows))
+	return err
+}
+
+func cellString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Local().Format("2006-01-02 15:04:05")
+	case []any:
+		return strings.Join(propStrings(v), ", ")
+	default:
+		return propString(v)
+	}
+}
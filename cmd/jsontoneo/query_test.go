@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPresetBind(t *testing.T) {
+	preset := queryPreset{name: "test", params: []presetParam{
+		{name: "tech", help: "technology"},
+		{name: "limit", def: 25},
+		{name: "status", def: "any"},
+	}}
+	single := queryPreset{name: "single", params: []presetParam{{name: "tech", help: "technology"}}}
+	tests := []struct {
+		name   string
+		preset queryPreset
+		args   []string
+		flags  cypherParams
+		want   map[string]any
+		err    string
+	}{
+		{
+			name:   "defaults",
+			preset: preset,
+			args:   []string{"tech=nginx"},
+			want:   map[string]any{"tech": "nginx", "limit": 25, "status": "any"},
+		},
+		{
+			name:   "int",
+			preset: preset,
+			args:   []string{"tech=nginx", "limit=5", "status=a=b"},
+			want:   map[string]any{"tech": "nginx", "limit": 5, "status": "a=b"},
+		},
+		{
+			name:   "flags",
+			preset: preset,
+			flags:  cypherParams{"tech": "iis", "limit": 3},
+			want:   map[string]any{"tech": "iis", "limit": 3, "status": "any"},
+		},
+		{name: "unnamed", preset: single, args: []string{"nginx"}, want: map[string]any{"tech": "nginx"}},
+		{name: "unnamed with more params", preset: preset, args: []string{"nginx"}, err: "is not name=value"},
+		{name: "unknown", preset: preset, args: []string{"tech=x", "port=80"}, err: `unknown parameter "port"`},
+		{name: "not a number", preset: preset, args: []string{"tech=x", "limit=ten"}, err: "must be a number"},
+		{name: "missing", preset: preset, args: []string{"limit=5"}, err: "missing parameter tech (technology)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.preset.bind(tt.args, tt.flags)
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("bind = %v, want %q", err, tt.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case !reflect.DeepEqual(got, tt.want):
+				t.Errorf("bind = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPresets checks the built-in presets declare each parameter once and
+// have unique names.
+func TestPresets(t *testing.T) {
+	names := map[string]bool{}
+	for _, p := range queryPresets {
+		if names[p.name] {
+			t.Errorf("preset %s is declared twice", p.name)
+		}
+		names[p.name] = true
+		params := map[string]bool{}
+		for _, param := range p.params {
+			if params[param.name] {
+				t.Errorf("preset %s declares %s twice", p.name, param.name)
+			}
+			params[param.name] = true
+			if !strings.Contains(p.cypher, "$"+param.name) {
+				t.Errorf("preset %s does not use $%s", p.name, param.name)
+			}
+		}
+	}
+}
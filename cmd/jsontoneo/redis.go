@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisSource consumes a Redis stream as a member of a consumer group.
+// Entries stay pending until they are acknowledged after being written; on
+// start the consumer first reads back its own pending entries, so entries of
+// an interrupted run are not lost. Entries that another consumer of the group
+// left pending for claimIdle are taken over with XAUTOCLAIM.
+type redisSource struct {
+	client    *redis.Client
+	key       string
+	group     string
+	consumer  string
+	pending   []redis.XMessage
+	backlog   bool
+	cursor    string
+	claimIdle time.Duration
+	claimNext string
+	lastClaim time.Time
+	url       string
+}
+
+func newRedisSource(opts consumeOptions) (consumerSource, error) {
+	if opts.stream == "" {
+		return nil, errors.New("-stream is required")
+	}
+	url := opts.url
+	if url == "" {
+		url = "redis://localhost:6379/0"
+	}
+	redisOpts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(redisOpts)
+
+	err = client.XGroupCreateMkStream(context.Background(), opts.stream, opts.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		client.Close()
+		return nil, err
+	}
+
+	consumer := opts.consumer
+	if consumer == "" {
+		consumer, _ = os.Hostname()
+	}
+	return &redisSource{
+		client:    client,
+		key:       opts.stream,
+		group:     opts.group,
+		consumer:  consumer,
+		backlog:   true,
+		cursor:    "0",
+		claimIdle: opts.claimIdle,
+		claimNext: "0-0",
+		url:       strings.TrimSuffix(url, "/") + "/" + opts.stream,
+	}, nil
+}
+
+func (s *redisSource) fetch(ctx context.Context) (consumerMessage, error) {
+	for len(s.pending) == 0 {
+		if err := ctx.Err(); err != nil {
+			return consumerMessage{}, err
+		}
+		msgs, err := s.read(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return consumerMessage{}, ctx.Err()
+			}
+			return consumerMessage{}, err
+		}
+		s.pending = msgs
+	}
+
+	msg := s.pending[0]
+	s.pending = s.pending[1:]
+	return consumerMessage{value: []byte(redisValue(msg)), ack: msg.ID}, nil
+}
+
+// read returns the next entries for this consumer: first its own pending
+// entries, read once from the start of its backlog, then entries claimed from
+// idle consumers and finally new entries. It returns no entries when none
+// arrived within a second.
+func (s *redisSource) read(ctx context.Context) ([]redis.XMessage, error) {
+	if s.backlog {
+		msgs, err := s.readGroup(ctx, s.cursor, -1)
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) == 0 {
+			s.backlog = false
+			return nil, nil
+		}
+		s.cursor = msgs[len(msgs)-1].ID
+		return msgs, nil
+	}
+
+	// Een lopende XAUTOCLAIM-ronde wordt afgemaakt; een nieuwe begint pas
+	// als de vorige claimIdle geleden klaar was.
+	if s.claimIdle > 0 && (s.claimNext != "0-0" || time.Since(s.lastClaim) >= s.claimIdle) {
+		msgs, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   s.key,
+			Group:    s.group,
+			Consumer: s.consumer,
+			MinIdle:  s.claimIdle,
+			Start:    s.claimNext,
+			Count:    100,
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		s.claimNext = next
+		if next == "0-0" {
+			s.lastClaim = time.Now()
+		}
+		if len(msgs) > 0 {
+			return msgs, nil
+		}
+	}
+	return s.readGroup(ctx, ">", time.Second)
+}
+
+// readGroup reads at most 100 entries after id with XREADGROUP, waiting up
+// to block for them to arrive; a negative block does not wait.
+func (s *redisSource) readGroup(ctx context.Context, id string, block time.Duration) ([]redis.XMessage, error) {
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.group,
+		Consumer: s.consumer,
+		Streams:  []string{s.key, id},
+		Count:    100,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var msgs []redis.XMessage
+	for _, stream := range streams {
+		msgs = append(msgs, stream.Messages...)
+	}
+	return msgs, nil
+}
+
+// redisValue returns the JSON record of a stream entry: its "data" field, or
+// its only field.
+func redisValue(msg redis.XMessage) string {
+	if v, ok := msg.Values["data"]; ok {
+		s, _ := v.(string)
+		return s
+	}
+	if len(msg.Values) == 1 {
+		for _, v := range msg.Values {
+			s, _ := v.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+func (s *redisSource) commit(ctx context.Context, msgs []consumerMessage) error {
+	ids := make([]string, len(msgs))
+	for i, m := range msgs {
+		ids[i] = m.ack.(string)
+	}
+	return s.client.XAck(ctx, s.key, s.group, ids...).Err()
+}
+
+func (s *redisSource) name() string {
+	return s.url
+}
+
+func (s *redisSource) close() error {
+	return s.client.Close()
+}
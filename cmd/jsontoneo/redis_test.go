@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisSource(t *testing.T) {
+	m := miniredis.RunT(t)
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer client.Close()
+
+	add := func(data string) {
+		if err := client.XAdd(ctx, &redis.XAddArgs{Stream: "httpx", Values: []string{"data", data}}).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// deliver hands the new entries to consumer without acknowledging them,
+	// as an interrupted run leaves them.
+	deliver := func(consumer string) {
+		err := client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "jsontoneo", Consumer: consumer, Streams: []string{"httpx", ">"}, Block: -1}).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.XGroupCreateMkStream(ctx, "httpx", "jsontoneo", "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	add("a1")
+	add("a2")
+	deliver("a")
+	add("b1")
+	deliver("b")
+	add("new")
+
+	src, err := newRedisSource(consumeOptions{url: "redis://" + m.Addr(), stream: "httpx", group: "jsontoneo", consumer: "a", claimIdle: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.close()
+
+	// Het verlaten item van b is nog niet lang genoeg idle om over te nemen.
+	var batch []consumerMessage
+	for range 3 {
+		msg, err := src.fetch(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch = append(batch, msg)
+	}
+	if got, want := values(batch), []string{"a1", "a2", "new"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("fetched %v, want %v", got, want)
+	}
+	if err := src.commit(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+
+	m.SetTime(time.Now().Add(2 * time.Minute))
+	src.(*redisSource).lastClaim = time.Time{}
+	msg, err := src.fetch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.value) != "b1" {
+		t.Errorf("claimed %s, want b1", msg.value)
+	}
+	if err := src.commit(ctx, []consumerMessage{msg}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := client.XPending(ctx, "httpx", "jsontoneo").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != 0 {
+		t.Errorf("%d entries pending, want none", pending.Count)
+	}
+}
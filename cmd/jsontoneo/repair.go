@@ -0,0 +1,299 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type repairOptions struct {
+	project string
+	noAPOC  bool
+	dryRun  bool
+	yes     bool
+}
+
+// repairKeys are the properties that identify a node of each label. Nodes
+// with the same label, key and project are duplicates.
+var repairKeys = []struct {
+	label string
+	key   string
+}{
+	{"Host", "url"},
+	{"ASN", "number"},
+	{"IP", "address"},
+	{"Tech", "name"},
+	{"Scan", "id"},
+}
+
+// duplicateGroup is a set of nodes with the same key. The first node is the
+// one kept: the earliest seen, or else the earliest created.
+type duplicateGroup struct {
+	Label   string
+	Key     string
+	Project string
+	ids     []string
+}
+
+func repairFlags(fs *flag.FlagSet) func() {
+	var opts repairOptions
+	fs.StringVar(&opts.project, "project", "", "Only repair the nodes of this project")
+	fs.BoolVar(&opts.noAPOC, "no-apoc", false, "Merge by re-pointing relationships even when APOC is installed")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Only show the duplicates")
+	fs.BoolVar(&opts.yes, "yes", false, "Do not ask for confirmation")
+
+	return func() {
+		driver := connect()
+		defer driver.Close()
+		session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+		defer session.Close()
+
+		groups, err := findDuplicates(session, opts.project)
+		if err != nil {
+			log.Fatalf("Error finding duplicates: %v", err)
+		}
+		var duplicates int64
+		for _, g := range groups {
+			duplicates += int64(len(g.ids) - 1)
+		}
+		printDuplicates(os.Stdout, groups)
+		if opts.dryRun || len(groups) == 0 {
+			return
+		}
+
+		ok, err := confirmDestructive(fmt.Sprintf("merge %d duplicate nodes into %d", duplicates, len(groups)), duplicates, opts.yes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			log.Print("Repair cancelled")
+			return
+		}
+
+		merge := mergeManually
+		if !opts.noAPOC && hasAPOCMerge(session) {
+			merge = mergeWithAPOC
+		}
+		var moved int64
+		for _, g := range groups {
+			result, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+				return merge(tx, g.ids[0], g.ids[1:])
+			})
+			if err != nil {
+				log.Fatalf("Error merging %s %s: %v", g.Label, g.Key, err)
+			}
+			moved += result.(int64)
+		}
+		log.Printf("Merged %d duplicate nodes into %d nodes, %d relationships re-pointed", duplicates, len(groups), moved)
+	}
+}
+
+// findDuplicates returns the groups of nodes that share a label, key and
+// project.
+func findDuplicates(session neo4j.Session, project string) ([]duplicateGroup, error) {
+	var groups []duplicateGroup
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, k := range repairKeys {
+			res, err := tx.Run(`
+			MATCH (n:`+k.label+`)
+			WHERE n.`+k.key+` IS NOT NULL AND `+neo4jwriter.ProjectCond("n")+`
+			WITH n ORDER BY coalesce(n.first_seen, n.started_at), elementId(n)
+			WITH n.`+k.key+` AS key, coalesce(n.project, '') AS project, collect(elementId(n)) AS ids
+			WHERE size(ids) > 1
+			RETURN key, project, ids
+			ORDER BY key, project
+			`, map[string]any{"project": project})
+			if err != nil {
+				return nil, fmt.Errorf("%s query error: %w", k.label, err)
+			}
+			for res.Next() {
+				v := res.Record().Values
+				groups = append(groups, duplicateGroup{
+					Label:   k.label,
+					Key:     propertyString(v[0]),
+					Project: propString(v[1]),
+					ids:     propStrings(v[2]),
+				})
+			}
+			if err := res.Err(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	return groups, err
+}
+
+func printDuplicates(w io.Writer, groups []duplicateGroup) {
+	fmt.Fprintf(w, "Duplicate nodes (%d groups)\n", len(groups))
+	for _, g := range groups {
+		key := g.Key
+		if g.Project != "" {
+			key += " (" + g.Project + ")"
+		}
+		fmt.Fprintf(w, "  %-5s %s  %d nodes\n", g.Label, key, len(g.ids))
+	}
+}
+
+// hasAPOCMerge reports whether apoc.refactor.mergeNodes can be called.
+func hasAPOCMerge(session neo4j.Session) bool {
+	count, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`SHOW PROCEDURES YIELD name WHERE name = 'apoc.refactor.mergeNodes' RETURN count(*)`, nil)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := res.Single()
+		if err != nil {
+			return nil, err
+		}
+		return rec.Values[0], nil
+	})
+	return err == nil && propInt(count) > 0
+}
+
+// mergeWithAPOC merges dups into keep with apoc.refactor.mergeNodes. Properties
+// keep already has win; relationships to the same node are merged.
+func mergeWithAPOC(tx neo4j.Transaction, keep string, dups []string) (int64, error) {
+	res, err := tx.Run(`
+	MATCH (d)-[r]-() WHERE elementId(d) IN $dups
+	RETURN count(DISTINCT r)
+	`, map[string]any{"dups": dups})
+	if err != nil {
+		return 0, fmt.Errorf("Relationship query error: %w", err)
+	}
+	rec, err := res.Single()
+	if err != nil {
+		return 0, err
+	}
+	moved := int64(propInt(rec.Values[0]))
+
+	if err := mergeSeen(tx, keep, dups); err != nil {
+		return 0, err
+	}
+	_, err = tx.Run(`
+	MATCH (keep) WHERE elementId(keep) = $keep
+	UNWIND $dups AS id
+	MATCH (d) WHERE elementId(d) = id
+	WITH keep, collect(d) AS dups
+	CALL apoc.refactor.mergeNodes([keep] + dups, {properties: 'discard', mergeRels: true})
+	YIELD node
+	RETURN node
+	`, map[string]any{"keep": keep, "dups": dups})
+	if err != nil {
+		return 0, fmt.Errorf("Merge query error: %w", err)
+	}
+	return moved, nil
+}
+
+// mergeManually merges dups into keep without APOC: it copies the properties
+// and labels keep lacks, re-points the relationships of dups to keep (merging
+// them with an existing relationship of the same type to the same node) and
+// deletes dups.
+func mergeManually(tx neo4j.Transaction, keep string, dups []string) (int64, error) {
+	params := map[string]any{"keep": keep, "dups": dups, "all": append([]string{keep}, dups...)}
+	if _, err := tx.Run(`
+	MATCH (keep) WHERE elementId(keep) = $keep
+	MATCH (d) WHERE elementId(d) IN $dups
+	WITH keep, collect(d) AS dups, properties(keep) AS kept
+	FOREACH (d IN dups | SET keep += properties(d))
+	SET keep += kept
+	`, params); err != nil {
+		return 0, fmt.Errorf("Property query error: %w", err)
+	}
+	if err := mergeSeen(tx, keep, dups); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Run(`
+	MATCH (d) WHERE elementId(d) IN $dups
+	UNWIND labels(d) AS label
+	RETURN DISTINCT label
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("Label query error: %w", err)
+	}
+	labels, err := collectStrings(res)
+	if err != nil {
+		return 0, err
+	}
+	for _, label := range labels {
+		if _, err := tx.Run(`MATCH (keep) WHERE elementId(keep) = $keep SET keep:`+neo4jwriter.QuoteLabel(label), params); err != nil {
+			return 0, fmt.Errorf("Label query error: %w", err)
+		}
+	}
+
+	res, err = tx.Run(`
+	MATCH (d)-[r]-() WHERE elementId(d) IN $dups
+	RETURN DISTINCT type(r)
+	`, params)
+	if err != nil {
+		return 0, fmt.Errorf("Relationship query error: %w", err)
+	}
+	types, err := collectStrings(res)
+	if err != nil {
+		return 0, err
+	}
+	var moved int64
+	for _, t := range types {
+		// Cypher kent geen dynamische relatietypes, dus per type een query.
+		for _, pattern := range []string{"(d)-[r:%[1]s]->(m)", "(d)<-[r:%[1]s]-(m)"} {
+			match := fmt.Sprintf(pattern, neo4jwriter.QuoteLabel(t))
+			create := strings.NewReplacer("(d)", "(keep)", "[r:", "[nr:").Replace(match)
+			res, err := tx.Run(`
+			MATCH (keep) WHERE elementId(keep) = $keep
+			MATCH `+match+`
+			WHERE elementId(d) IN $dups AND NOT elementId(m) IN $all
+			MERGE `+create+`
+			SET nr += properties(r)
+			DELETE r
+			RETURN count(r)
+			`, params)
+			if err != nil {
+				return 0, fmt.Errorf("Relationship query error: %w", err)
+			}
+			rec, err := res.Single()
+			if err != nil {
+				return 0, err
+			}
+			moved += int64(propInt(rec.Values[0]))
+		}
+	}
+
+	if _, err := tx.Run(`
+	MATCH (d) WHERE elementId(d) IN $dups
+	DETACH DELETE d
+	`, params); err != nil {
+		return 0, fmt.Errorf("Delete query error: %w", err)
+	}
+	return moved, nil
+}
+
+// mergeSeen gives keep the earliest first_seen and latest last_seen of the
+// group.
+func mergeSeen(tx neo4j.Transaction, keep string, dups []string) error {
+	_, err := tx.Run(`
+	MATCH (n) WHERE elementId(n) = $keep OR elementId(n) IN $dups
+	WITH min(n.first_seen) AS first_seen, max(n.last_seen) AS last_seen
+	MATCH (keep) WHERE elementId(keep) = $keep
+	SET keep.first_seen = coalesce(first_seen, keep.first_seen),
+	    keep.last_seen = coalesce(last_seen, keep.last_seen)
+	`, map[string]any{"keep": keep, "dups": dups})
+	if err != nil {
+		return fmt.Errorf("Property query error: %w", err)
+	}
+	return nil
+}
+
+func collectStrings(res neo4j.Result) ([]string, error) {
+	var values []string
+	for res.Next() {
+		values = append(values, propString(res.Record().Values[0]))
+	}
+	return values, res.Err()
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+// reporters maps the -format values of the report command to their writer.
+var reporters = map[string]func(w io.Writer, r *reportData) error{
+	"html": writeHTMLReport,
+	"md":   writeMarkdownReport,
+	"json": func(w io.Writer, r *reportData) error { return writeJSON(w, r) },
+	"csv":  func(w io.Writer, r *reportData) error { return tableOf(r.Hosts).writeCSV(w) },
+}
+
+func reportFormats() []string {
+	formats := make([]string, 0, len(reporters))
+	for f := range reporters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+type reportHost struct {
+	URL    string   `json:"url"`
+	Status int      `json:"status"`
+	Title  string   `json:"title"`
+	IP     string   `json:"ip"`
+	Tech   []string `json:"tech"`
+	ASN    string   `json:"asn"`
+}
+
+type countRow struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type certRow struct {
+	URL      string    `json:"url"`
+	Subject  string    `json:"subject"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+}
+
+// reportData is everything a report shows, read from the graph for a scope.
+type reportData struct {
+	Scope       string    `json:"scope"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Version     string    `json:"version"`
+
+	Hosts        []reportHost `json:"hosts"`
+	LiveHosts    int          `json:"live_hosts"`
+	StatusCounts []countRow   `json:"status_counts"`
+	Techs        []countRow   `json:"technologies"`
+	ASNs         []countRow   `json:"asns"`
+	Certificates []certRow    `json:"certificates"`
+	LatestScan   string       `json:"latest_scan"`
+	NewHosts     []reportHost `json:"new_hosts"`
+}
+
+type reportOptions struct {
+	project  string
+	scope    string
+	format   string
+	output   string
+	certDays int
+	cluster  *clusterOptions
+}
+
+func reportFlags(fs *flag.FlagSet) func() {
+	opts := reportOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only report on hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.format, "format", "html", "Report format ("+strings.Join(reportFormats(), "|")+")")
+	fs.StringVar(&opts.format, "output", "html", "Alias of -format")
+	fs.StringVar(&opts.output, "o", "", "Write the report to this file instead of stdout")
+	fs.StringVar(&opts.output, "out", "", "Alias of -o")
+	fs.IntVar(&opts.certDays, "cert-days", 30, "Report certificates expiring within this many days")
+	fs.StringVar(&opts.project, "project", "", "Only report on the hosts of this project")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.cluster.check()
+		write, ok := reporters[opts.format]
+		if !ok {
+			log.Fatalf("Unknown -format %q (expected %s)", opts.format, strings.Join(reportFormats(), ", "))
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		data, err := loadReportData(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+
+		var w io.Writer = os.Stdout
+		if opts.output != "" {
+			file, err := os.Create(opts.output)
+			if err != nil {
+				log.Fatalf("Error creating output file: %v", err)
+			}
+			defer file.Close()
+			w = file
+		}
+		if err := write(w, data); err != nil {
+			log.Fatalf("Error writing report: %v", err)
+		}
+	}
+}
+
+func loadReportData(session neo4j.Session, opts reportOptions) (*reportData, error) {
+	data := &reportData{Scope: opts.scope, GeneratedAt: time.Now(), Version: version}
+	params := map[string]any{"scope": opts.scope, "days": opts.certDays, "project": opts.project}
+
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE ($scope = '' OR toLower(h.url) CONTAINS toLower($scope)) AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:BELONGS_TO]->(a:ASN)
+		RETURN h.url AS url, h.status AS status, h.title AS title, h.ip AS ip, h.tech AS tech,
+		       a.number AS asn, a.name AS asn_name
+		ORDER BY h.url
+		`, params)
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			v := res.Record().Values
+			h := reportHost{
+				URL:    propString(v[0]),
+				Status: propInt(v[1]),
+				Title:  propString(v[2]),
+				IP:     propString(v[3]),
+				Tech:   propStrings(v[4]),
+				ASN:    strings.TrimSpace(propString(v[5]) + " " + propString(v[6])),
+			}
+			data.Hosts = append(data.Hosts, h)
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		// Nieuw = alleen gezien in de meest recente scan.
+		res, err = tx.Run(`
+		MATCH (s:Scan)
+		WHERE `+neo4jwriter.ProjectCond("s")+`
+		WITH s ORDER BY s.started_at DESC LIMIT 1
+		MATCH (h:Host)-[:SEEN_IN]->(s)
+		WHERE ($scope = '' OR toLower(h.url) CONTAINS toLower($scope)) AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:SEEN_IN]->(o:Scan)
+		WHERE o.started_at < s.started_at
+		WITH s, h, count(o) AS older
+		WHERE older = 0
+		RETURN s.id AS scan, h.url AS url, h.status AS status, h.title AS title, h.ip AS ip
+		ORDER BY h.url
+		`, params)
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			v := res.Record().Values
+			data.LatestScan = propString(v[0])
+			data.NewHosts = append(data.NewHosts, reportHost{
+				URL:    propString(v[1]),
+				Status: propInt(v[2]),
+				Title:  propString(v[3]),
+				IP:     propString(v[4]),
+			})
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		res, err = tx.Run(`
+		MATCH (h:Host)-[:PRESENTS]->(c:Certificate)
+		WHERE ($scope = '' OR toLower(h.url) CONTAINS toLower($scope))
+		  AND `+neo4jwriter.ProjectCond("h")+`
+		  AND c.not_after IS NOT NULL
+		  AND c.not_after < datetime() + duration({days: $days})
+		RETURN h.url AS url, c.subject_cn AS subject, c.not_after AS not_after
+		ORDER BY c.not_after
+		`, params)
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			v := res.Record().Values
+			notAfter, _ := v[2].(time.Time)
+			data.Certificates = append(data.Certificates, certRow{
+				URL:      propString(v[0]),
+				Subject:  propString(v[1]),
+				NotAfter: notAfter,
+				DaysLeft: int(time.Until(notAfter).Hours() / 24),
+			})
+		}
+		return nil, res.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data.summarize()
+	return data, nil
+}
+
+// summarize computes the counts shown in the report from the hosts.
+func (r *reportData) summarize() {
+	statuses := map[string]int{}
+	techs := map[string]int{}
+	asns := map[string]int{}
+	for _, h := range r.Hosts {
+		if h.Status >= 200 && h.Status < 400 {
+			r.LiveHosts++
+		}
+		statuses[fmt.Sprint(h.Status)]++
+		for _, t := range h.Tech {
+			techs[t]++
+		}
+		if h.ASN != "" {
+			asns[h.ASN]++
+		}
+	}
+	r.StatusCounts = countRows(statuses)
+	r.Techs = countRows(techs)
+	r.ASNs = countRows(asns)
+}
+
+// countRows returns counts sorted by descending count, then name.
+func countRows(counts map[string]int) []countRow {
+	rows := make([]countRow, 0, len(counts))
+	for name, n := range counts {
+		rows = append(rows, countRow{name, n})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
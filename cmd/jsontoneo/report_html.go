@@ -0,0 +1,97 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"pct": func(n, total int) int {
+		if total == 0 {
+			return 0
+		}
+		return n * 100 / total
+	},
+	"top": func(rows []countRow, n int) []countRow {
+		if len(rows) > n {
+			return rows[:n]
+		}
+		return rows
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Attack surface report{{if .Scope}} – {{.Scope}}{{end}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; color: #222; }
+h1 { margin-bottom: 0; }
+.meta { color: #777; margin-top: .3em; }
+.cards { display: flex; gap: 1em; margin: 1.5em 0; }
+.card { flex: 1; border: 1px solid #ddd; border-radius: 6px; padding: 1em; }
+.card .n { font-size: 2em; font-weight: bold; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; font-size: .9em; }
+th, td { text-align: left; padding: .35em .6em; border-bottom: 1px solid #eee; vertical-align: top; }
+th { background: #f6f6f6; }
+.bar { background: #4285f4; height: .8em; display: inline-block; }
+.warn { color: #c5221f; font-weight: bold; }
+.empty { color: #777; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>Attack surface report{{if .Scope}}: {{.Scope}}{{end}}</h1>
+<p class="meta">Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}} by jsontoneo {{.Version}}</p>
+
+<div class="cards">
+  <div class="card"><div class="n">{{len .Hosts}}</div>hosts</div>
+  <div class="card"><div class="n">{{.LiveHosts}}</div>live (2xx/3xx)</div>
+  <div class="card"><div class="n">{{len .Techs}}</div>technologies</div>
+  <div class="card"><div class="n">{{len .ASNs}}</div>ASNs</div>
+  <div class="card"><div class="n">{{len .NewHosts}}</div>new since last scan</div>
+</div>
+
+<h2>Status codes</h2>
+<table>
+<tr><th>Status</th><th>Hosts</th><th></th></tr>
+{{range .StatusCounts}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td><span class="bar" style="width: {{pct .Count (len $.Hosts)}}%"></span></td></tr>
+{{end}}</table>
+
+<h2>Technologies</h2>
+{{if .Techs}}<table>
+<tr><th>Technology</th><th>Hosts</th><th></th></tr>
+{{range top .Techs 50}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td><span class="bar" style="width: {{pct .Count (len $.Hosts)}}%"></span></td></tr>
+{{end}}</table>{{else}}<p class="empty">No technologies detected.</p>{{end}}
+
+<h2>ASN distribution</h2>
+{{if .ASNs}}<table>
+<tr><th>ASN</th><th>Hosts</th><th></th></tr>
+{{range top .ASNs 50}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td><span class="bar" style="width: {{pct .Count (len $.Hosts)}}%"></span></td></tr>
+{{end}}</table>{{else}}<p class="empty">No ASN data.</p>{{end}}
+
+<h2>Expiring certificates</h2>
+{{if .Certificates}}<table>
+<tr><th>Host</th><th>Subject</th><th>Expires</th><th>Days left</th></tr>
+{{range .Certificates}}<tr><td>{{.URL}}</td><td>{{.Subject}}</td><td>{{.NotAfter.Format "2006-01-02"}}</td><td{{if lt .DaysLeft 0}} class="warn"{{end}}>{{.DaysLeft}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No expiring certificates (or no certificate data in the graph).</p>{{end}}
+
+<h2>New since last scan{{if .LatestScan}} ({{.LatestScan}}){{end}}</h2>
+{{if .NewHosts}}<table>
+<tr><th>URL</th><th>Status</th><th>Title</th><th>IP</th></tr>
+{{range .NewHosts}}<tr><td>{{.URL}}</td><td>{{.Status}}</td><td>{{.Title}}</td><td>{{.IP}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No new hosts.</p>{{end}}
+
+<h2>All hosts</h2>
+<table>
+<tr><th>URL</th><th>Status</th><th>Title</th><th>IP</th><th>Technologies</th></tr>
+{{range .Hosts}}<tr><td>{{.URL}}</td><td>{{.Status}}</td><td>{{.Title}}</td><td>{{.IP}}</td><td>{{join .Tech ", "}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// writeHTMLReport writes r as a standalone HTML page.
+func writeHTMLReport(w io.Writer, r *reportData) error {
+	return htmlReportTemplate.Execute(w, r)
+}
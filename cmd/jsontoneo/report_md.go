@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// apexDomain returns the registrable domain of a host URL, e.g. example.co.uk
+// for https://www.example.co.uk. IP addresses are returned as-is.
+func apexDomain(rawURL string) string {
+	host := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	host = strings.ToLower(host)
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	apex, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return apex
+}
+
+// writeMarkdownReport writes r as markdown, with the hosts grouped by apex
+// domain, ready to paste into engagement notes or a GitHub issue.
+func writeMarkdownReport(w io.Writer, r *reportData) error {
+	bw := bufio.NewWriter(w)
+
+	title := "Recon summary"
+	if r.Scope != "" {
+		title += ": " + r.Scope
+	}
+	fmt.Fprintf(bw, "# %s\n\n", title)
+	fmt.Fprintf(bw, "_Generated %s by jsontoneo %s_\n\n", r.GeneratedAt.Format("2006-01-02 15:04 MST"), r.Version)
+	fmt.Fprintf(bw, "- **Hosts:** %d (%d live)\n", len(r.Hosts), r.LiveHosts)
+	fmt.Fprintf(bw, "- **Technologies:** %d\n", len(r.Techs))
+	fmt.Fprintf(bw, "- **ASNs:** %d\n", len(r.ASNs))
+	fmt.Fprintf(bw, "- **New since last scan:** %d\n", len(r.NewHosts))
+
+	groups := map[string][]reportHost{}
+	for _, h := range r.Hosts {
+		apex := apexDomain(h.URL)
+		groups[apex] = append(groups[apex], h)
+	}
+	apexes := make([]string, 0, len(groups))
+	for apex := range groups {
+		apexes = append(apexes, apex)
+	}
+	sort.Strings(apexes)
+
+	for _, apex := range apexes {
+		hosts := groups[apex]
+		fmt.Fprintf(bw, "\n## %s (%d)\n\n", mdEscape(apex), len(hosts))
+		fmt.Fprintln(bw, "| URL | Status | Title | IP | Technologies |")
+		fmt.Fprintln(bw, "|-----|--------|-------|----|--------------|")
+		for _, h := range hosts {
+			fmt.Fprintf(bw, "| %s | %d | %s | %s | %s |\n",
+				mdEscape(h.URL), h.Status, mdEscape(h.Title), mdEscape(h.IP), mdEscape(strings.Join(h.Tech, ", ")))
+		}
+	}
+	return bw.Flush()
+}
+
+// mdEscape makes s safe to use inside a markdown table cell.
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(s)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// blocklist is a local copy of a blocklist or threat feed: IPs and
+// prefixes, one per line.
+type blocklist struct {
+	name     string
+	prefixes map[netip.Prefix]bool
+	// lengths holds the prefix lengths in the list, longest first.
+	lengths []int
+}
+
+// loadBlocklist reads a list in the formats the common feeds use: the
+// first field of every line is an IP or prefix, and # and ; start a
+// comment (Spamhaus DROP, FireHOL netsets, abuse.ch, Emerging Threats).
+// Lines without one are skipped.
+func loadBlocklist(path string) (*blocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	l := &blocklist{name: name, prefixes: map[netip.Prefix]bool{}}
+	lengths := map[int]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line, _, _ = strings.Cut(line, ";")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			addr, err := netip.ParseAddr(fields[0])
+			if err != nil {
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefix = prefix.Masked()
+		l.prefixes[prefix] = true
+		lengths[prefix.Bits()] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for n := range lengths {
+		l.lengths = append(l.lengths, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(l.lengths)))
+	return l, nil
+}
+
+func (l *blocklist) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, bits := range l.lengths {
+		if prefix, err := addr.Prefix(bits); err == nil && l.prefixes[prefix] {
+			return true
+		}
+	}
+	return false
+}
+
+// blocklistDir returns the directory enrich reputation reads the lists
+// from: -blocklist-dir, or ~/.config/jsontoneo/blocklists.
+func blocklistDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	return filepath.Join(configDir(), "blocklists")
+}
+
+// loadBlocklists reads every file in dir as a blocklist named after the
+// file, in name order.
+func loadBlocklists(dir string) ([]*blocklist, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var lists []*blocklist
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		l, err := loadBlocklist(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if len(l.prefixes) == 0 {
+			log.Printf("Warning: %s holds no IPs or prefixes", e.Name())
+			continue
+		}
+		lists = append(lists, l)
+	}
+	return lists, nil
+}
+
+// enrichReputation sets blocklists, the names of the lists holding its IP,
+// and blocklisted on IP nodes and hosts.
+func enrichReputation(session neo4j.Session, opts enrichOptions) {
+	dir := blocklistDir(opts.blockDir)
+	lists, err := loadBlocklists(dir)
+	if err != nil {
+		log.Fatalf("Error reading blocklists: %v", err)
+	}
+	if len(lists) == 0 {
+		log.Fatalf("No blocklists in %s", dir)
+	}
+	targets, err := loadEnrichTargets(session, opts, reputationPending)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+
+	var rows []map[string]any
+	listed := 0
+	hits := map[string]int{}
+	for _, t := range targets {
+		var names []string
+		if addr, err := netip.ParseAddr(t.ip); err == nil {
+			for _, l := range lists {
+				if l.contains(addr) {
+					names = append(names, l.name)
+					hits[l.name]++
+				}
+			}
+		}
+		row := map[string]any{"id": t.id, "blocklists": nil, "blocklisted": len(names) > 0}
+		if len(names) > 0 {
+			row["blocklists"] = names
+			listed++
+		}
+		rows = append(rows, row)
+	}
+	for start := 0; start < len(rows); start += opts.batchSize {
+		batch := rows[start:min(start+opts.batchSize, len(rows))]
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+			res, err := tx.Run(`
+			UNWIND $rows AS row
+			MATCH (n) WHERE elementId(n) = row.id
+			SET n.blocklists = row.blocklists, n.blocklisted = row.blocklisted,
+			    n.reputation_checked_at = datetime()
+			`, map[string]any{"rows": batch})
+			if err != nil {
+				return nil, fmt.Errorf("Reputation query error: %w", err)
+			}
+			return res.Consume()
+		})
+		if err != nil {
+			log.Fatalf("Error writing reputation: %v", err)
+		}
+	}
+
+	log.Printf("%d of %d nodes are on one of %d blocklists", listed, len(targets), len(lists))
+	for _, l := range lists {
+		if hits[l.name] > 0 {
+			log.Printf("  %-24s %d", l.name, hits[l.name])
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+type rollbackOptions struct {
+	scanID string
+	dryRun bool
+	yes    bool
+}
+
+// rollbackPlan is what rolling back a scan would do: the hosts only that scan
+// has seen are deleted, the hosts other scans have seen too are reverted.
+type rollbackPlan struct {
+	ScanID   string
+	File     string
+	Created  []string
+	Reverted []string
+	Orphans  int64
+	Changes  int64
+	ids      []string
+}
+
+func rollbackFlags(fs *flag.FlagSet) func() {
+	var opts rollbackOptions
+	fs.StringVar(&opts.scanID, "scan", "", "Id of the Scan node to roll back")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Only show what would be rolled back")
+	fs.BoolVar(&opts.yes, "yes", false, "Do not ask for confirmation")
+
+	return func() {
+		if opts.scanID == "" {
+			log.Fatal("Usage: jsontoneo rollback -scan <id> [-dry-run] [-yes]")
+		}
+
+		driver := connect()
+		defer driver.Close()
+		session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+		defer session.Close()
+
+		plan, err := planRollback(session, opts.scanID)
+		if err != nil {
+			log.Fatalf("Error planning rollback: %v", err)
+		}
+		plan.print(os.Stdout)
+		if opts.dryRun {
+			return
+		}
+
+		total := int64(len(plan.Created)) + plan.Orphans + plan.Changes + 1
+		ok, err := confirmDestructive(fmt.Sprintf("roll back scan %s", plan.ScanID), total, opts.yes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !ok {
+			log.Print("Rollback cancelled")
+			return
+		}
+
+		if err := plan.apply(session); err != nil {
+			log.Fatalf("Error rolling back scan: %v", err)
+		}
+		log.Printf("Rolled back scan %s: deleted %d hosts and %d orphaned nodes, reverted %d hosts", plan.ScanID, len(plan.Created), plan.Orphans, len(plan.Reverted))
+	}
+}
+
+// planRollback finds the hosts seen by the scan, split into those no other
+// scan has seen and those it only updated.
+func planRollback(session neo4j.Session, scanID string) (*rollbackPlan, error) {
+	plan := &rollbackPlan{ScanID: scanID}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		res, err := tx.Run(`MATCH (s:Scan {id: $scan_id}) RETURN s.file`, map[string]any{"scan_id": scanID})
+		if err != nil {
+			return nil, fmt.Errorf("Scan query error: %w", err)
+		}
+		if !res.Next() {
+			return nil, fmt.Errorf("no scan with id %s", scanID)
+		}
+		plan.File = propString(res.Record().Values[0])
+
+		res, err = tx.Run(`
+		MATCH (h:Host)-[:SEEN_IN]->(s:Scan {id: $scan_id})
+		WITH h, EXISTS { MATCH (h)-[:SEEN_IN]->(o:Scan) WHERE o <> s } AS seen_elsewhere
+		RETURN elementId(h) AS id, h.url AS url, seen_elsewhere
+		ORDER BY url
+		`, map[string]any{"scan_id": scanID})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			if v[2] == true {
+				plan.Reverted = append(plan.Reverted, propString(v[1]))
+				continue
+			}
+			plan.ids = append(plan.ids, propString(v[0]))
+			plan.Created = append(plan.Created, propString(v[1]))
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		plan.Orphans, _, err = countDependents(tx, plan.ids)
+		if err != nil {
+			return nil, err
+		}
+		res, err = tx.Run(`
+		MATCH (c)-[:DETECTED_IN|OBSERVED_IN]->(:Scan {id: $scan_id})
+		WHERE c:Change OR c:Observation
+		RETURN count(c)
+		`, map[string]any{"scan_id": scanID})
+		if err != nil {
+			return nil, fmt.Errorf("Change query error: %w", err)
+		}
+		rec, err := res.Single()
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = int64(propInt(rec.Values[0]))
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (p *rollbackPlan) print(w io.Writer) {
+	fmt.Fprintf(w, "Scan %s (%s)\n", p.ScanID, p.File)
+	fmt.Fprintf(w, "Hosts only seen in this scan, to delete (%d)\n", len(p.Created))
+	for _, url := range p.Created {
+		fmt.Fprintf(w, "  %s\n", url)
+	}
+	fmt.Fprintf(w, "Hosts also seen in other scans, to revert (%d)\n", len(p.Reverted))
+	for _, url := range p.Reverted {
+		fmt.Fprintf(w, "  %s\n", url)
+	}
+	fmt.Fprintf(w, "Orphaned ASN/IP/Tech nodes: %d\n", p.Orphans)
+	fmt.Fprintf(w, "Change and Observation nodes of this scan: %d\n", p.Changes)
+}
+
+// apply deletes what only the scan created and reverts the hosts it updated
+// to what the latest other scan saw: status, title and port from its SEEN_IN,
+// tech from the latest remaining Observation and last_seen from its start.
+// Other properties are not recorded per scan and keep their current value.
+func (p *rollbackPlan) apply(session neo4j.Session) error {
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		params := map[string]any{"ids": p.ids, "scan_id": p.ScanID}
+
+		if err := deleteHosts(tx, p.ids); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Run(`
+		MATCH (c)-[:DETECTED_IN|OBSERVED_IN]->(:Scan {id: $scan_id})
+		WHERE c:Change OR c:Observation
+		DETACH DELETE c
+		`, params); err != nil {
+			return nil, fmt.Errorf("Change query error: %w", err)
+		}
+		// Terugzetten naar wat de laatste andere scan zag.
+		if _, err := tx.Run(`
+		MATCH (h:Host)-[:SEEN_IN]->(s:Scan {id: $scan_id})
+		MATCH (h)-[r:SEEN_IN]->(o:Scan) WHERE o <> s
+		WITH h, r, o ORDER BY o.started_at DESC
+		WITH h, collect({r: r, o: o})[0] AS prev
+		WITH h, prev.r AS r, prev.o AS o
+		SET h.status = coalesce(r.status, h.status),
+		    h.title = coalesce(r.title, h.title),
+		    h.port = coalesce(r.port, h.port),
+		    h.last_seen = coalesce(o.started_at, h.last_seen)
+		WITH h
+		OPTIONAL MATCH (h)-[:OBSERVED]->(obs:Observation)
+		WITH h, obs ORDER BY obs.observed_at DESC
+		WITH h, collect(obs)[0] AS latest
+		SET h.tech = CASE WHEN latest IS NULL THEN h.tech ELSE latest.tech END
+		`, params); err != nil {
+			return nil, fmt.Errorf("Revert query error: %w", err)
+		}
+		if _, err := tx.Run(`
+		MATCH (s:Scan {id: $scan_id})
+		DETACH DELETE s
+		`, params); err != nil {
+			return nil, fmt.Errorf("Scan query error: %w", err)
+		}
+		return nil, nil
+	})
+	return err
+}
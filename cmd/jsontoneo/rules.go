@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pocahon/jsontoneo/pkg/derive"
+	"gopkg.in/yaml.v2"
+)
+
+// recordRule drops records whose field matches (drop), or does not match
+// (keep), a regular expression.
+type recordRule struct {
+	Drop    string `yaml:"drop"`
+	Keep    string `yaml:"keep"`
+	Matches string `yaml:"matches"`
+
+	field []string
+	re    *regexp.Regexp
+}
+
+// recordRules is the rules file, by default ~/.config/jsontoneo/rules.yaml.
+// It runs after the transform, script and enrichers, so rules see the final
+// record. Its derive section declares derived properties.
+type recordRules struct {
+	Rules  []recordRule  `yaml:"rules"`
+	Derive []derive.Rule `yaml:"derive"`
+
+	derive *derive.Rules
+}
+
+// defaultRulesFile returns the rules file used without -rules, or "" when
+// there is none.
+func defaultRulesFile() string {
+	path := filepath.Join(configDir(), "rules.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+func loadRules(path string) (*recordRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules recordRules
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range rules.Rules {
+		r := &rules.Rules[i]
+		field := r.Drop + r.Keep
+		switch {
+		case (r.Drop == "") == (r.Keep == ""):
+			return nil, fmt.Errorf("%s: rule %d needs either drop or keep", path, i+1)
+		case r.Matches == "":
+			return nil, fmt.Errorf("%s: rule %d (%s) has no matches", path, i+1, field)
+		}
+		if r.re, err = regexp.Compile(r.Matches); err != nil {
+			return nil, fmt.Errorf("%s: rule %d (%s): %w", path, i+1, field, err)
+		}
+		r.field = strings.Split(field, ".")
+	}
+	if rules.derive, err = derive.Compile(rules.Derive); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &rules, nil
+}
+
+// Apply returns record, or nothing when a drop rule matches it or a keep
+// rule does not.
+func (rr *recordRules) Apply(record []byte) ([][]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	for _, r := range rr.Rules {
+		if r.match(doc) == (r.Drop != "") {
+			return nil, nil
+		}
+	}
+	return [][]byte{record}, nil
+}
+
+// match reports whether the field matches the expression; for a list,
+// whether any item does. A missing field does not match.
+func (r *recordRule) match(doc any) bool {
+	v := doc
+	for _, name := range r.field {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		if v, ok = obj[name]; !ok {
+			return false
+		}
+	}
+	values, ok := v.([]any)
+	if !ok {
+		values = []any{v}
+	}
+	for _, item := range values {
+		if item == nil {
+			continue
+		}
+		if r.re.MatchString(fmt.Sprint(item)) {
+			return true
+		}
+	}
+	return false
+}
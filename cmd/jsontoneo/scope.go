@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// scopeRules decides which records are in scope of an engagement. A record
+// is in scope when it matches an in-scope rule (or there are none) and no
+// out-of-scope rule.
+type scopeRules struct {
+	in  []scopeRule
+	out []scopeRule
+}
+
+// scopeRule is a hostname glob such as *.example.com, or an IP range.
+type scopeRule struct {
+	glob string
+	cidr *net.IPNet
+}
+
+// loadScopeFile reads a scope file: one pattern per line, a hostname,
+// hostname glob, IP or CIDR. Lines starting with ! are out of scope; empty
+// lines and lines starting with # are ignored.
+//
+//	*.example.com
+//	203.0.113.0/24
+//	!admin.example.com
+func loadScopeFile(filename string) (*scopeRules, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := &scopeRules{}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, exclude := strings.CutPrefix(line, "!")
+		rule, err := parseScopeRule(strings.TrimSpace(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, n, err)
+		}
+		if exclude {
+			rules.out = append(rules.out, rule)
+		} else {
+			rules.in = append(rules.in, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseScopeRule(pattern string) (scopeRule, error) {
+	if strings.Contains(pattern, "/") {
+		_, cidr, err := net.ParseCIDR(pattern)
+		if err != nil {
+			return scopeRule{}, fmt.Errorf("invalid CIDR %q", pattern)
+		}
+		return scopeRule{cidr: cidr}, nil
+	}
+	if ip := net.ParseIP(pattern); ip != nil {
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		return scopeRule{cidr: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return scopeRule{}, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	return scopeRule{glob: pattern}, nil
+}
+
+func (r scopeRule) String() string {
+	if r.cidr != nil {
+		return r.cidr.String()
+	}
+	return r.glob
+}
+
+func (r scopeRule) match(host string, ips []net.IP) bool {
+	if r.cidr == nil {
+		ok, _ := path.Match(r.glob, host)
+		return ok
+	}
+	for _, ip := range ips {
+		if r.cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// inScope reports whether the host or IP of result is in scope.
+func (s *scopeRules) InScope(result *model.HttpxResult) bool {
+	host := result.Hostname()
+	var ips []net.IP
+	for _, v := range []string{host, result.Host} {
+		if ip := net.ParseIP(v); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	for _, r := range s.out {
+		if r.match(host, ips) {
+			return false
+		}
+	}
+	if len(s.in) == 0 {
+		return true
+	}
+	for _, r := range s.in {
+		if r.match(host, ips) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+func TestParseScopeRule(t *testing.T) {
+	tests := []struct {
+		pattern, want, err string
+	}{
+		{pattern: "*.Example.com", want: "*.example.com"},
+		{pattern: "203.0.113.0/24", want: "203.0.113.0/24"},
+		{pattern: "203.0.113.7/24", want: "203.0.113.0/24"},
+		{pattern: "203.0.113.7", want: "203.0.113.7/32"},
+		{pattern: "2001:db8::1", want: "2001:db8::1/128"},
+		{pattern: "203.0.113.0/33", err: "invalid CIDR"},
+		{pattern: "[a-", err: "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			r, err := parseScopeRule(tt.pattern)
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("parseScopeRule(%q) = %v, want %q", tt.pattern, err, tt.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case r.String() != tt.want:
+				t.Errorf("parseScopeRule(%q) = %s, want %s", tt.pattern, r, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scope.txt")
+	scope := "# engagement\n*.example.com\n203.0.113.0/24\n\n!admin.example.com\n! 203.0.113.66\n"
+	if err := os.WriteFile(file, []byte(scope), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := loadScopeFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url, ip string
+		want    bool
+	}{
+		{"https://api.example.com", "198.51.100.1", true},
+		{"https://admin.example.com", "203.0.113.5", false},
+		{"https://example.org", "203.0.113.5", true},
+		{"https://example.org", "203.0.113.66", false},
+		{"http://203.0.113.9:8080", "", true},
+		{"https://example.org", "198.51.100.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url+" "+tt.ip, func(t *testing.T) {
+			if got := rules.InScope(&model.HttpxResult{URL: tt.url, Host: tt.ip}); got != tt.want {
+				t.Errorf("InScope = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := os.WriteFile(file, []byte("*.example.com\n10.0.0.0/40\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadScopeFile(file); err == nil || !strings.Contains(err.Error(), "scope.txt:2:") {
+		t.Errorf("loadScopeFile = %v, want an error on line 2", err)
+	}
+}
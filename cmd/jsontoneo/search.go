@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type searchOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	limit   int
+	raw     bool
+	cluster *clusterOptions
+}
+
+// searchHit is a host matching a search, with the fields that matched.
+type searchHit struct {
+	URL     string            `json:"url"`
+	Status  int               `json:"status,omitempty"`
+	Title   string            `json:"title,omitempty"`
+	Score   float64           `json:"score"`
+	Matches map[string]string `json:"matches"`
+}
+
+func searchFlags(fs *flag.FlagSet) func() {
+	opts := searchOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only search hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only search the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.IntVar(&opts.limit, "limit", 50, "Return at most this many hosts")
+	fs.BoolVar(&opts.raw, "raw", false, "Pass the query to the full-text index as Lucene syntax, e.g. 'title:grafana AND NOT url:dev'")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		if fs.NArg() == 0 {
+			log.Fatal("Usage: jsontoneo search [flags] TERM ...")
+		}
+		opts.output.check()
+		opts.cluster.check()
+
+		terms := fs.Args()
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		hits, err := search(session, terms, opts)
+		if err != nil {
+			log.Fatalf("Error searching graph (run jsontoneo migrate to create the full-text indexes): %v", err)
+		}
+		err = opts.output.write(hits, hits, func(w io.Writer) error {
+			return printSearchHits(w, hits)
+		})
+		if err != nil {
+			log.Fatalf("Error writing results: %v", err)
+		}
+	}
+}
+
+// luceneSpecial are the characters with a meaning in Lucene query syntax.
+const luceneSpecial = `+-&|!(){}[]^"~*?:\/`
+
+// luceneQuery turns search terms into a Lucene query matching each term as a
+// word or as part of one, so "grafana" also matches grafana.example.com.
+func luceneQuery(terms []string) string {
+	var parts []string
+	for _, term := range terms {
+		var b strings.Builder
+		for _, r := range strings.ToLower(term) {
+			if strings.ContainsRune(luceneSpecial, r) {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		}
+		t := b.String()
+		parts = append(parts, "("+t+" OR *"+t+"*)")
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func search(session neo4j.Session, terms []string, opts searchOptions) ([]*searchHit, error) {
+	query := strings.Join(terms, " ")
+	if !opts.raw {
+		query = luceneQuery(terms)
+	}
+	words := make([]string, len(terms))
+	for i, t := range terms {
+		words[i] = strings.ToLower(t)
+	}
+	params := map[string]any{
+		"hostIndex": neo4jwriter.HostTextIndex,
+		"techIndex": neo4jwriter.TechTextIndex,
+		"query":     query,
+		"words":     words,
+		"scope":     opts.scope,
+		"project":   opts.project,
+		"limit":     opts.limit,
+	}
+
+	hits := []*searchHit{}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		hits = hits[:0]
+		// Hosts via de index, en via hun technologieën: tech is een lijst op
+		// de Host, die de full-text index niet indexeert, of een Tech node.
+		res, err := tx.Run(`
+		CALL {
+			CALL db.index.fulltext.queryNodes($hostIndex, $query) YIELD node, score
+			RETURN node AS h, score
+			UNION ALL
+			CALL db.index.fulltext.queryNodes($techIndex, $query) YIELD node, score
+			MATCH (h:Host)-[:USES]->(node)
+			RETURN h, score
+			UNION ALL
+			MATCH (h:Host)
+			WHERE all(w IN $words WHERE any(t IN coalesce(h.tech, []) WHERE toLower(t) CONTAINS w))
+			RETURN h, 0.0 AS score
+		}
+		WITH h, max(score) AS score
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:USES]->(t:Tech)
+		RETURN h.url, h.status, h.title, h.webserver, h.tech, collect(t.name), score
+		ORDER BY score DESC, h.url
+		LIMIT $limit
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Search query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			score, _ := v[6].(float64)
+			hit := &searchHit{URL: propString(v[0]), Status: propInt(v[1]), Title: propString(v[2]), Score: score}
+			hit.Matches = matchContext(words, map[string][]string{
+				"url":       {hit.URL},
+				"title":     {hit.Title},
+				"webserver": {propString(v[3])},
+				"tech":      append(propStrings(v[4]), propStrings(v[5])...),
+			})
+			hits = append(hits, hit)
+		}
+		return nil, res.Err()
+	})
+	return hits, err
+}
+
+// matchContext returns per field the values containing one of the words, as
+// the context of a hit. With -raw the words may not appear literally; the
+// hit then has no context.
+func matchContext(words []string, fields map[string][]string) map[string]string {
+	matches := map[string]string{}
+	for field, values := range fields {
+		var found []string
+		for _, v := range values {
+			lower := strings.ToLower(v)
+			for _, w := range words {
+				if w != "" && strings.Contains(lower, w) {
+					found = append(found, v)
+					break
+				}
+			}
+		}
+		if len(found) > 0 {
+			matches[field] = strings.Join(found, ", ")
+		}
+	}
+	return matches
+}
+
+func printSearchHits(w io.Writer, hits []*searchHit) error {
+	if len(hits) == 0 {
+		_, err := fmt.Fprintln(w, "No matching hosts")
+		return err
+	}
+	for _, h := range hits {
+		status := ""
+		if h.Status != 0 {
+			status = fmt.Sprintf(" [%d]", h.Status)
+		}
+		fmt.Fprintf(w, "%s%s\n", h.URL, status)
+		fields := make([]string, 0, len(h.Matches))
+		for f := range h.Matches {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		for _, f := range fields {
+			fmt.Fprintf(w, "    %-10s %s\n", f+":", h.Matches[f])
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d hosts\n", len(hits))
+	return err
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"github.com/pocahon/jsontoneo/pkg/parser"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+)
+
+type serveOptions struct {
+	listen     string
+	grpcListen string
+	token      string
+	tags       stringList
+	project    string
+	operator   string
+	ttl        time.Duration
+	logTarget  string
+	cluster    *clusterOptions
+}
+
+func serveFlags(fs *flag.FlagSet) func() {
+	opts := serveOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.listen, "listen", ":8080", "Address to listen on")
+	fs.StringVar(&opts.grpcListen, "grpc-listen", "", "Also serve the gRPC Ingest service on this address, e.g. :9090")
+	fs.StringVar(&opts.token, "token", os.Getenv("JSONTONEO_TOKEN"), "Bearer token clients must send (default $JSONTONEO_TOKEN)")
+	fs.Var(&opts.tags, "tag", "Add this tag to every node touched by an ingest (comma-separated, repeatable)")
+	fs.StringVar(&opts.project, "project", "", "Import into this project, kept apart from other projects in the same database")
+	fs.StringVar(&opts.operator, "operator", "", "Name recorded as imported_by on the Scan nodes (default the OS user)")
+	ttl := fs.String("ttl", os.Getenv("JSONTONEO_TTL"), "Set expires_at this far ahead on the nodes written, e.g. 180d (default $JSONTONEO_TTL)")
+	fs.StringVar(&opts.logTarget, "log-target", "stderr", "Where to log ("+strings.Join(logTargetNames(), "|")+")")
+	opts.cluster.register(fs, false)
+
+	return func() {
+		if err := setLogTarget(opts.logTarget); err != nil {
+			log.Fatalf("Invalid -log-target: %v", err)
+		}
+		var err error
+		if opts.ttl, err = parseTTL(*ttl); err != nil {
+			log.Fatalf("Invalid -ttl: %v", err)
+		}
+		if opts.token == "" {
+			log.Fatal("serve requires a token: set -token or JSONTONEO_TOKEN")
+		}
+		runServer(opts)
+	}
+}
+
+// importOptions returns the options ingests are imported with.
+func (o serveOptions) importOptions() importOptions {
+	return importOptions{tags: o.tags, project: o.project, attribution: neo4jwriter.NewAttribution(o.operator), ttl: o.ttl, cluster: o.cluster}
+}
+
+func runServer(opts serveOptions) {
+	_, stopTracing := startTracing()
+	defer stopTracing()
+
+	driver := connect()
+	defer driver.Close()
+
+	srv := &ingestServer{opts: opts, driver: driver}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", srv.handleHealth)
+	mux.Handle("POST /ingest/{tool}", srv.authenticate(http.HandlerFunc(srv.handleIngest)))
+	mux.Handle("POST /notify", srv.authenticate(http.HandlerFunc(srv.handleNotify)))
+
+	server := &http.Server{
+		Addr:              opts.listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	var grpcServer *grpc.Server
+	if opts.grpcListen != "" {
+		lis, err := net.Listen("tcp", opts.grpcListen)
+		if err != nil {
+			log.Fatalf("Error listening on %s: %v", opts.grpcListen, err)
+		}
+		grpcServer = newGRPCServer(srv)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatalf("Error running gRPC server: %v", err)
+			}
+		}()
+		log.Printf("gRPC Ingest service listening on %s", opts.grpcListen)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+	}()
+
+	log.Printf("Listening on %s (tools: %s)", opts.listen, strings.Join(parser.Names(), ", "))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Error running server: %v", err)
+	}
+}
+
+type ingestServer struct {
+	opts   serveOptions
+	driver neo4j.Driver
+}
+
+func (s *ingestServer) authenticate(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.opts.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *ingestServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if err := s.driver.VerifyConnectivity(); err != nil {
+		http.Error(w, "neo4j unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	io.WriteString(w, "ok\n")
+}
+
+// handleIngest imports a JSON Lines body as a new scan and responds with the
+// import summary.
+func (s *ingestServer) handleIngest(w http.ResponseWriter, r *http.Request) {
+	tool := r.PathValue("tool")
+	p, ok := parser.Get(tool)
+	if !ok {
+		http.Error(w, "unsupported tool "+tool+" (supported: "+strings.Join(parser.Names(), ", ")+")", http.StatusNotFound)
+		return
+	}
+
+	body, err := ingestBody(w, r, maxIngestBody)
+	if err != nil {
+		http.Error(w, "invalid gzip body", http.StatusBadRequest)
+		return
+	}
+	defer body.Close()
+	s.ingest(w, r, "/ingest/"+tool, p, body)
+}
+
+// maxIngestBody limits the size of an ingest body, both as sent and after
+// decompression, so a client cannot exhaust the server with a gzip bomb.
+const maxIngestBody = 1 << 30
+
+// ingestBody returns the body of r, decompressed when it is gzipped. Reading
+// more than limit bytes of either returns an *http.MaxBytesError.
+func ingestBody(w http.ResponseWriter, r *http.Request, limit int64) (io.ReadCloser, error) {
+	body := http.MaxBytesReader(w, r.Body, limit)
+	if r.Header.Get("Content-Encoding") != "gzip" {
+		return body, nil
+	}
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return http.MaxBytesReader(w, gz, limit), nil
+}
+
+// handleNotify accepts the messages of ProjectDiscovery notify's custom
+// webhook provider. With the default custom_format '{{data}}' the body holds
+// the forwarded JSON lines as is; when the format wraps them in a JSON object
+// (e.g. '{"text": "{{data}}"}') the lines are taken from its string field.
+func (s *ingestServer) handleNotify(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxNotifyBody))
+	if err != nil {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+	s.ingest(w, r, "/notify", nil, bytes.NewReader(unwrapNotifyBody(data)))
+}
+
+// maxNotifyBody limits the size of a notify message; notify sends one
+// result, or one batch with -bulk, per request.
+const maxNotifyBody = 32 << 20
+
+// notifyFields are the fields custom_format templates commonly put {{data}} in.
+var notifyFields = []string{"data", "text", "content", "message"}
+
+func unwrapNotifyBody(data []byte) []byte {
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(bytes.TrimSpace(data), &wrapper); err != nil {
+		return data
+	}
+	if _, ok := wrapper["url"]; ok {
+		return data
+	}
+	for _, field := range notifyFields {
+		var lines string
+		if err := json.Unmarshal(wrapper[field], &lines); err == nil && lines != "" {
+			return []byte(lines)
+		}
+	}
+	return data
+}
+
+// ingest imports body as a new scan and responds with the import summary.
+// A nil parser detects the format from the first line.
+func (s *ingestServer) ingest(w http.ResponseWriter, r *http.Request, path string, p parser.Parser, body io.Reader) {
+	out := neo4jwriter.NewNeo4jTarget(s.driver, s.opts.cluster.configure)
+	defer out.Close()
+
+	source := "http://" + r.RemoteAddr + path
+	scanID := neo4jwriter.NewScanID()
+	opts := s.opts.importOptions().writerOptions()
+	opts.Parser = p
+	if err := neo4jwriter.CreateScan(out, scanID, source, opts); err != nil {
+		log.Printf("Error creating scan node: %v", err)
+		http.Error(w, "neo4j unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	imp := neo4jwriter.New(out, scanID, opts)
+	summary := &neo4jwriter.Summary{ScanID: scanID, File: source}
+	start := time.Now()
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	err := imp.Run(ctx, body, summary)
+	if err := neo4jwriter.FinishScan(out, scanID); err != nil {
+		log.Printf("Error finishing scan node: %v", err)
+	}
+	summary.Finish(time.Since(start))
+	s.opts.cluster.save()
+	log.Printf("Ingested %d records from %s%s (scan %s, %d failed)", summary.Written, r.RemoteAddr, path, scanID, summary.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	case err != nil:
+		w.WriteHeader(http.StatusBadRequest)
+	case summary.Failed > 0:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	writeJSON(w, summary)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIngestBody(t *testing.T) {
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		io.WriteString(gz, s)
+		gz.Close()
+		return buf.Bytes()
+	}
+	// 1000 nullen comprimeren tot ver onder de limiet.
+	bomb := gzipped(strings.Repeat("0", 1000))
+
+	tests := []struct {
+		name     string
+		body     []byte
+		gzip     bool
+		tooLarge bool
+		err      bool
+	}{
+		{name: "plain", body: []byte(`{"url":"https://a.example.com"}`)},
+		{name: "plain too large", body: bytes.Repeat([]byte("0"), 101), tooLarge: true},
+		{name: "gzip", body: gzipped(`{"url":"https://a.example.com"}`), gzip: true},
+		{name: "gzip too large", body: bomb, gzip: true, tooLarge: true},
+		{name: "invalid gzip", body: []byte("not gzip"), gzip: true, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/ingest/httpx", bytes.NewReader(tt.body))
+			if tt.gzip {
+				r.Header.Set("Content-Encoding", "gzip")
+			}
+			body, err := ingestBody(httptest.NewRecorder(), r, 100)
+			if tt.err {
+				if err == nil {
+					t.Error("ingestBody succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = io.ReadAll(body)
+			var maxErr *http.MaxBytesError
+			if got := errors.As(err, &maxErr); got != tt.tooLarge {
+				t.Errorf("reading body: %v, want too large %v", err, tt.tooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+const shodanAPI = "https://api.shodan.io/"
+
+// shodanDelay keeps the requests within the one per second Shodan allows.
+const shodanDelay = time.Second
+
+var (
+	errShodanNotFound     = errors.New("no information available")
+	errShodanUnauthorized = errors.New("invalid API key")
+)
+
+// shodanHost holds the fields of /shodan/host/{ip} that enrich shodan
+// writes.
+type shodanHost struct {
+	Ports      []int    `json:"ports"`
+	Vulns      []string `json:"vulns"`
+	Tags       []string `json:"tags"`
+	Hostnames  []string `json:"hostnames"`
+	Org        string   `json:"org"`
+	OS         string   `json:"os"`
+	LastUpdate string   `json:"last_update"`
+}
+
+type shodanClient struct {
+	base, key string
+	client    *http.Client
+	last      time.Time
+	requests  int
+}
+
+// host looks up ip, waiting out the rate limit. Retries after a 429 count
+// as requests too.
+func (c *shodanClient) host(ip string) (*shodanHost, error) {
+	for attempt := 0; ; attempt++ {
+		if wait := shodanDelay - time.Since(c.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		c.last = time.Now()
+		c.requests++
+
+		u := c.base + "shodan/host/" + url.PathEscape(ip) + "?minify=true&key=" + url.QueryEscape(c.key)
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "jsontoneo/"+version)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			// De fout bevat de URL, en daarmee de sleutel.
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < 3:
+			resp.Body.Close()
+			wait := 5 * time.Second << attempt
+			log.Printf("Rate limited by Shodan, waiting %s", wait)
+			time.Sleep(wait)
+			continue
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, errShodanNotFound
+		case resp.StatusCode == http.StatusUnauthorized:
+			resp.Body.Close()
+			return nil, errShodanUnauthorized
+		case resp.StatusCode != http.StatusOK:
+			var body struct {
+				Error string `json:"error"`
+			}
+			json.NewDecoder(resp.Body).Decode(&body)
+			resp.Body.Close()
+			if body.Error != "" {
+				return nil, fmt.Errorf("status %s: %s", resp.Status, body.Error)
+			}
+			return nil, fmt.Errorf("status %s", resp.Status)
+		}
+		var h shodanHost
+		err = json.NewDecoder(resp.Body).Decode(&h)
+		resp.Body.Close()
+		return &h, err
+	}
+}
+
+// props returns the node properties for h; nil h, an IP Shodan knows
+// nothing of, only clears them.
+func (h *shodanHost) props() map[string]any {
+	props := map[string]any{
+		"shodan_ports": nil, "shodan_vulns": nil, "shodan_tags": nil,
+		"shodan_hostnames": nil, "shodan_org": nil, "shodan_os": nil, "shodan_updated": nil,
+	}
+	if h == nil {
+		return props
+	}
+	sort.Ints(h.Ports)
+	sort.Strings(h.Vulns)
+	for name, list := range map[string][]string{"shodan_vulns": h.Vulns, "shodan_tags": h.Tags, "shodan_hostnames": h.Hostnames} {
+		if len(list) > 0 {
+			props[name] = list
+		}
+	}
+	if len(h.Ports) > 0 {
+		props["shodan_ports"] = h.Ports
+	}
+	if h.Org != "" {
+		props["shodan_org"] = h.Org
+	}
+	if h.OS != "" {
+		props["shodan_os"] = h.OS
+	}
+	// Shodan geeft last_update zonder tijdzone, in UTC.
+	if t, err := time.Parse("2006-01-02T15:04:05.999999", h.LastUpdate); err == nil {
+		props["shodan_updated"] = t
+	}
+	return props
+}
+
+// enrichShodan looks up the IPs of IP nodes and hosts in Shodan and writes
+// their open ports, vulnerabilities and tags. IPs go in address order, at
+// most -budget requests per run; the last IP looked up is kept in the
+// cursor file, so the next run goes on from there.
+func enrichShodan(session neo4j.Session, opts enrichOptions) {
+	if opts.apiKey == "" {
+		opts.apiKey = os.Getenv("SHODAN_API_KEY")
+	}
+	if opts.apiKey == "" {
+		log.Fatal("enrich shodan needs -api-key or $SHODAN_API_KEY")
+	}
+	if opts.budget < 1 {
+		log.Fatal("-budget must be at least 1")
+	}
+
+	targets, err := loadEnrichTargets(session, opts, shodanPending)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+	cursorFile := enrichCursorFile(opts, "shodan", "")
+	addrs := resumeAddrs(publicAddrs(targets), cursorFile)
+
+	client := &shodanClient{base: shodanAPI, key: opts.apiKey, client: &http.Client{Timeout: 30 * time.Second}}
+	var rows []map[string]any
+	found, failed := 0, 0
+	done := 0
+	flush := func() {
+		if len(rows) == 0 {
+			return
+		}
+		if err := writeShodan(session, opts.project, rows); err != nil {
+			log.Fatalf("Error writing Shodan data: %v", err)
+		}
+		writeCursor(cursorFile, propString(rows[len(rows)-1]["ip"]))
+		rows = rows[:0]
+	}
+	for _, addr := range addrs {
+		if client.requests >= opts.budget {
+			break
+		}
+		h, err := client.host(addr.String())
+		switch {
+		case errors.Is(err, errShodanNotFound):
+			h = nil
+		case errors.Is(err, errShodanUnauthorized):
+			flush()
+			log.Fatal("Shodan rejected the API key")
+		case err != nil:
+			log.Printf("Warning: Shodan lookup of %s: %v", addr, err)
+			failed++
+			continue
+		default:
+			found++
+		}
+		rows = append(rows, map[string]any{"ip": addr.String(), "props": h.props()})
+		done++
+		if len(rows) >= opts.batchSize {
+			flush()
+		}
+	}
+	flush()
+
+	left := len(addrs) - done - failed
+	if left > 0 {
+		log.Printf("Looked up %d IPs in %d Shodan requests (%d known to Shodan, %d failed); %d left, run again to resume",
+			done, client.requests, found, failed, left)
+		return
+	}
+	// Rondje klaar: de volgende run begint weer vooraan.
+	os.Remove(cursorFile)
+	log.Printf("Looked up %d IPs in %d Shodan requests (%d known to Shodan, %d failed)",
+		done, client.requests, found, failed)
+}
+
+// writeShodan sets the Shodan properties of the IP nodes and hosts with the
+// IPs of rows.
+func writeShodan(session neo4j.Session, project string, rows []map[string]any) error {
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, q := range []string{
+			`MATCH (n:IP {address: row.ip}) WHERE ` + neo4jwriter.ProjectCond("n"),
+			`MATCH (n:Host {ip: row.ip}) WHERE ` + neo4jwriter.ProjectCond("n"),
+		} {
+			res, err := tx.Run(`
+			UNWIND $rows AS row
+			`+q+`
+			SET n += row.props, n.shodan_checked_at = datetime()
+			`, map[string]any{"rows": rows, "project": project})
+			if err != nil {
+				return nil, fmt.Errorf("Shodan query error: %w", err)
+			}
+			if _, err := res.Consume(); err != nil {
+				return nil, fmt.Errorf("Shodan query error: %w", err)
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
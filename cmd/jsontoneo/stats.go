@@ -0,0 +1,265 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type statsOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	top     int
+	cluster *clusterOptions
+}
+
+// graphStats is what the stats command prints.
+type graphStats struct {
+	Scope         string     `json:"scope,omitempty"`
+	Project       string     `json:"project,omitempty"`
+	Nodes         []countRow `json:"nodes"`
+	Relationships []countRow `json:"relationships"`
+	Hosts         int        `json:"hosts"`
+	LiveHosts     int        `json:"live_hosts"`
+	DeadHosts     int        `json:"dead_hosts"`
+	Techs         []countRow `json:"top_technologies"`
+	ASNs          []countRow `json:"top_asns"`
+	Scans         []scanStat `json:"recent_scans"`
+}
+
+type scanStat struct {
+	ID        string    `json:"id"`
+	StartedAt time.Time `json:"started_at"`
+	File      string    `json:"file"`
+	Hosts     int       `json:"hosts"`
+}
+
+func statsFlags(fs *flag.FlagSet) func() {
+	opts := statsOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only count hosts whose URL contains this string, e.g. example.com, and the nodes linked to them")
+	fs.StringVar(&opts.project, "project", "", "Only count the nodes of this project")
+	opts.output.register(fs, "table")
+	fs.IntVar(&opts.top, "top", 10, "Show this many technologies, ASNs and scans")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		s, err := loadStats(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		err = opts.output.write(s, s.rows(), func(w io.Writer) error {
+			return s.print(w)
+		})
+		if err != nil {
+			log.Fatalf("Error writing stats: %v", err)
+		}
+	}
+}
+
+// hostCond matches the hosts of the -scope.
+const hostCond = "($scope = '' OR toLower(h.url) CONTAINS toLower($scope))"
+
+func loadStats(session neo4j.Session, opts statsOptions) (*graphStats, error) {
+	s := &graphStats{Scope: opts.scope, Project: opts.project}
+	params := map[string]any{"scope": opts.scope, "project": opts.project, "top": opts.top}
+
+	// Zonder scope de hele graaf tellen; met scope de hosts en hun buren.
+	nodes := `
+	MATCH (n)
+	WHERE ` + neo4jwriter.ProjectCond("n") + `
+	UNWIND labels(n) AS label
+	RETURN label, count(*) AS n
+	ORDER BY n DESC, label
+	`
+	rels := `
+	MATCH (a)-[r]->()
+	WHERE ` + neo4jwriter.ProjectCond("a") + `
+	RETURN type(r) AS type, count(*) AS n
+	ORDER BY n DESC, type
+	`
+	if opts.scope != "" {
+		nodes = `
+		MATCH (h:Host)
+		WHERE ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		OPTIONAL MATCH (h)--(n)
+		WITH collect(DISTINCT h) + collect(DISTINCT n) AS nodes
+		UNWIND nodes AS n
+		UNWIND labels(n) AS label
+		RETURN label, count(DISTINCT n) AS n
+		ORDER BY n DESC, label
+		`
+		rels = `
+		MATCH (h:Host)-[r]-()
+		WHERE ` + hostCond + ` AND ` + neo4jwriter.ProjectCond("h") + `
+		WITH DISTINCT r
+		RETURN type(r) AS type, count(*) AS n
+		ORDER BY n DESC, type
+		`
+	}
+
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		var err error
+		if s.Nodes, err = countQuery(tx, nodes, params); err != nil {
+			return nil, fmt.Errorf("Node count query error: %w", err)
+		}
+		if s.Relationships, err = countQuery(tx, rels, params); err != nil {
+			return nil, fmt.Errorf("Relationship count query error: %w", err)
+		}
+		s.Techs, err = countQuery(tx, `
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		UNWIND h.tech AS tech
+		RETURN tech, count(*) AS n
+		ORDER BY n DESC, tech
+		LIMIT $top
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Tech query error: %w", err)
+		}
+		s.ASNs, err = countQuery(tx, `
+		MATCH (h:Host)-[:BELONGS_TO]->(a:ASN)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		WITH a, count(DISTINCT h) AS n
+		RETURN trim(a.number + ' ' + coalesce(a.name, '')) AS asn, n
+		ORDER BY n DESC, asn
+		LIMIT $top
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("ASN query error: %w", err)
+		}
+
+		// Live zoals in het rapport: status 2xx of 3xx.
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN count(h) AS hosts, count(CASE WHEN h.status >= 200 AND h.status < 400 THEN 1 END) AS live
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		if res.Next() {
+			v := res.Record().Values
+			s.Hosts, s.LiveHosts = propInt(v[0]), propInt(v[1])
+			s.DeadHosts = s.Hosts - s.LiveHosts
+		}
+		if err := res.Err(); err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+
+		res, err = tx.Run(`
+		MATCH (s:Scan)
+		WHERE `+neo4jwriter.ProjectCond("s")+`
+		OPTIONAL MATCH (h:Host)-[:SEEN_IN]->(s)
+		WHERE `+hostCond+`
+		WITH s, count(h) AS hosts
+		WHERE $scope = '' OR hosts > 0
+		RETURN s.id AS id, s.started_at AS started_at, s.file AS file, hosts
+		ORDER BY s.started_at DESC
+		LIMIT $top
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Scan query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			started, _ := v[1].(time.Time)
+			s.Scans = append(s.Scans, scanStat{ID: propString(v[0]), StartedAt: started, File: propString(v[2]), Hosts: propInt(v[3])})
+		}
+		return nil, res.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// countQuery runs a query returning names and counts.
+func countQuery(tx neo4j.Transaction, query string, params map[string]any) ([]countRow, error) {
+	res, err := tx.Run(query, params)
+	if err != nil {
+		return nil, err
+	}
+	rows := []countRow{}
+	for res.Next() {
+		v := res.Record().Values
+		rows = append(rows, countRow{Name: propString(v[0]), Count: propInt(v[1])})
+	}
+	return rows, res.Err()
+}
+
+// statRow is a line of the stats as a table row.
+type statRow struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Count   int    `json:"count"`
+}
+
+// rows flattens the stats into a row per count, for csv and md output.
+func (s *graphStats) rows() []statRow {
+	var rows []statRow
+	add := func(section string, counts []countRow) {
+		for _, c := range counts {
+			rows = append(rows, statRow{section, c.Name, c.Count})
+		}
+	}
+	add("nodes", s.Nodes)
+	add("relationships", s.Relationships)
+	add("hosts", []countRow{{"total", s.Hosts}, {"live", s.LiveHosts}, {"dead", s.DeadHosts}})
+	add("top_technologies", s.Techs)
+	add("top_asns", s.ASNs)
+	for _, sc := range s.Scans {
+		rows = append(rows, statRow{"recent_scans", sc.ID, sc.Hosts})
+	}
+	return rows
+}
+
+func (s *graphStats) print(w io.Writer) error {
+	title := "Graph statistics"
+	if s.Scope != "" {
+		title += " for " + s.Scope
+	}
+	if s.Project != "" {
+		title += " (project " + s.Project + ")"
+	}
+	fmt.Fprintln(w, title)
+
+	printCounts(w, "Nodes", s.Nodes)
+	printCounts(w, "Relationships", s.Relationships)
+	fmt.Fprintf(w, "\nHosts\n  %-40s %8d\n  %-40s %8d\n  %-40s %8d\n",
+		"Total", s.Hosts, "Live (2xx/3xx)", s.LiveHosts, "Dead (other or no status)", s.DeadHosts)
+	printCounts(w, "Top technologies", s.Techs)
+	printCounts(w, "Top ASNs", s.ASNs)
+
+	fmt.Fprintln(w, "\nRecent scans")
+	if len(s.Scans) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, sc := range s.Scans {
+		fmt.Fprintf(w, "  %-28s %-20s %6d hosts  %s\n", sc.ID, sc.StartedAt.Local().Format("2006-01-02 15:04:05"), sc.Hosts, sc.File)
+	}
+	return nil
+}
+
+func printCounts(w io.Writer, title string, rows []countRow) {
+	fmt.Fprintf(w, "\n%s\n", title)
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, r := range rows {
+		fmt.Fprintf(w, "  %-40s %8d\n", r.Name, r.Count)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type syncOptions struct {
+	dir  string
+	keep bool
+}
+
+func syncFlags(fs *flag.FlagSet) func() {
+	var opts syncOptions
+	fs.StringVar(&opts.dir, "local-store", defaultStore, "Directory with the imports staged by -target local or -local-store")
+	fs.BoolVar(&opts.keep, "keep", false, "Keep the staging files after replaying them")
+
+	return func() {
+		files, err := neo4jwriter.StagedFiles(opts.dir)
+		if err != nil {
+			log.Fatalf("Error reading local store: %v", err)
+		}
+		if len(files) == 0 {
+			log.Printf("Nothing staged in %s", opts.dir)
+			return
+		}
+
+		driver := connect()
+		defer driver.Close()
+		if err := driver.VerifyConnectivity(); err != nil {
+			log.Fatalf("Neo4j cannot be reached, nothing synced: %v", err)
+		}
+		out := neo4jwriter.NewNeo4jTarget(driver)
+
+		var total neo4jwriter.Stats
+		var records, failed int
+		for _, file := range files {
+			n, stats, err := neo4jwriter.Replay(out, file)
+			records += n
+			total.NodesCreated += stats.NodesCreated
+			total.RelsCreated += stats.RelsCreated
+			total.PropertiesSet += stats.PropertiesSet
+			if err != nil {
+				// Het bestand blijft staan; opnieuw afspelen is veilig omdat alles gemerged wordt.
+				log.Printf("Error syncing %s after %d writes: %v", file, n, err)
+				failed++
+				continue
+			}
+			log.Printf("Synced %s: %d writes", file, n)
+			if !opts.keep {
+				if err := os.Remove(file); err != nil {
+					log.Printf("Error removing %s: %v", file, err)
+				}
+			}
+		}
+
+		fmt.Fprintf(os.Stdout, "Synced %d of %d staged imports (%d writes): %d nodes and %d relationships created, %d properties set\n",
+			len(files)-failed, len(files), records, total.NodesCreated, total.RelsCreated, total.PropertiesSet)
+		if failed > 0 {
+			os.Exit(exitWriteErrors)
+		}
+	}
+}
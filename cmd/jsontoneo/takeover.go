@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type takeoverOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	resolve bool
+	timeout time.Duration
+	cluster *clusterOptions
+}
+
+// takeoverFingerprint is a service whose resources can be claimed by anyone
+// once their owner deleted them, leaving the CNAME pointing at nothing.
+type takeoverFingerprint struct {
+	service string
+	// cnames are the domain suffixes of the service's CNAME targets.
+	cnames []string
+	// title is part of the page title the service serves for an unclaimed
+	// resource, "" when it serves none or it is not recognizable.
+	title string
+}
+
+// takeoverFingerprints is based on the services that are known to be
+// vulnerable in https://github.com/EdOverflow/can-i-take-over-xyz.
+var takeoverFingerprints = []takeoverFingerprint{
+	{service: "AWS S3", cnames: []string{"s3.amazonaws.com", "s3-website.amazonaws.com"}, title: "NoSuchBucket"},
+	{service: "AWS Elastic Beanstalk", cnames: []string{"elasticbeanstalk.com"}},
+	{service: "Azure", cnames: []string{"azurewebsites.net", "cloudapp.net", "cloudapp.azure.com", "blob.core.windows.net", "trafficmanager.net", "azureedge.net", "azure-api.net", "azurecontainer.io", "azurefd.net"}, title: "404 Web Site not found"},
+	{service: "Bitbucket", cnames: []string{"bitbucket.io"}, title: "Repository not found"},
+	{service: "Cargo Collective", cnames: []string{"cargocollective.com"}, title: "404 Not Found"},
+	{service: "Fastly", cnames: []string{"fastly.net"}, title: "Fastly error: unknown domain"},
+	{service: "Ghost", cnames: []string{"ghost.io"}, title: "Site unavailable"},
+	{service: "GitHub Pages", cnames: []string{"github.io"}, title: "Site not found"},
+	{service: "Heroku", cnames: []string{"herokuapp.com", "herokudns.com", "herokussl.com"}, title: "No such app"},
+	{service: "Help Scout", cnames: []string{"helpscoutdocs.com"}},
+	{service: "Netlify", cnames: []string{"netlify.app", "netlify.com"}, title: "Not Found"},
+	{service: "Pantheon", cnames: []string{"pantheonsite.io"}, title: "404 - Unknown site"},
+	{service: "Readme.io", cnames: []string{"readme.io"}, title: "Project doesnt exist"},
+	{service: "Shopify", cnames: []string{"myshopify.com"}, title: "Sorry, this shop is currently unavailable"},
+	{service: "Strikingly", cnames: []string{"strikinglydns.com"}, title: "page not found"},
+	{service: "Surge.sh", cnames: []string{"surge.sh"}, title: "project not found"},
+	{service: "Tumblr", cnames: []string{"domains.tumblr.com"}, title: "There's nothing here"},
+	{service: "Unbounce", cnames: []string{"unbouncepages.com"}, title: "The requested URL was not found"},
+	{service: "Webflow", cnames: []string{"proxy.webflow.com", "proxy-ssl.webflow.com"}, title: "The page you are looking for doesn't exist"},
+	{service: "WordPress.com", cnames: []string{"wordpress.com"}, title: "Do you want to register"},
+	{service: "Zendesk", cnames: []string{"zendesk.com"}, title: "Help Center Closed"},
+}
+
+// Priorities of takeover candidates, from most to least likely.
+const (
+	priorityHigh   = "high"
+	priorityMedium = "medium"
+	priorityLow    = "low"
+)
+
+var priorityOrder = map[string]int{priorityHigh: 0, priorityMedium: 1, priorityLow: 2}
+
+type takeoverCandidate struct {
+	URL      string   `json:"url"`
+	CNAME    []string `json:"cname"`
+	Service  string   `json:"service,omitempty"`
+	Status   int      `json:"status,omitempty"`
+	Title    string   `json:"title,omitempty"`
+	Priority string   `json:"priority"`
+	Reason   string   `json:"reason"`
+
+	// unresolved is true when the last CNAME target does not exist, false
+	// when it resolves or was not looked up.
+	unresolved bool
+}
+
+func takeoverFlags(fs *flag.FlagSet) func() {
+	opts := takeoverOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only check hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only check the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.BoolVar(&opts.resolve, "resolve", true, "Look up the CNAME targets in DNS to find the ones that no longer exist")
+	fs.DurationVar(&opts.timeout, "timeout", 5*time.Second, "Timeout of a DNS lookup")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		candidates, err := loadCNAMEs(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		if opts.resolve {
+			resolveCNAMEs(candidates, opts.timeout)
+		}
+		candidates = rankTakeovers(candidates)
+
+		err = opts.output.write(candidates, candidates, func(w io.Writer) error {
+			return printTakeovers(w, candidates)
+		})
+		if err != nil {
+			log.Fatalf("Error writing candidates: %v", err)
+		}
+	}
+}
+
+// loadCNAMEs returns the hosts that have a CNAME, as unranked candidates.
+func loadCNAMEs(session neo4j.Session, opts takeoverOptions) ([]*takeoverCandidate, error) {
+	var candidates []*takeoverCandidate
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		candidates = nil
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE h.cname IS NOT NULL AND `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		RETURN h.url AS url, h.cname AS cname, h.status AS status, h.title AS title
+		ORDER BY url
+		`, map[string]any{"scope": opts.scope, "project": opts.project})
+		if err != nil {
+			return nil, fmt.Errorf("CNAME query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			cname := propStrings(v[1])
+			if s, ok := v[1].(string); ok {
+				cname = []string{s}
+			}
+			if len(cname) == 0 {
+				continue
+			}
+			candidates = append(candidates, &takeoverCandidate{
+				URL:    propString(v[0]),
+				CNAME:  cname,
+				Status: propInt(v[2]),
+				Title:  propString(v[3]),
+			})
+		}
+		return nil, res.Err()
+	})
+	return candidates, err
+}
+
+// resolveCNAMEs marks the candidates whose last CNAME target does not exist.
+func resolveCNAMEs(candidates []*takeoverCandidate, timeout time.Duration) {
+	targets := map[string][]*takeoverCandidate{}
+	for _, c := range candidates {
+		target := strings.TrimSuffix(strings.ToLower(c.CNAME[len(c.CNAME)-1]), ".")
+		targets[target] = append(targets[target], c)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 20)
+	for target, cs := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			// Alleen NXDOMAIN telt; een timeout zegt niets over het doel.
+			_, err := net.DefaultResolver.LookupHost(ctx, target)
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				for _, c := range cs {
+					c.unresolved = true
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// matchTakeover returns the fingerprint of the service one of the CNAME
+// targets belongs to.
+func matchTakeover(cname []string) (takeoverFingerprint, bool) {
+	for _, target := range cname {
+		target = strings.TrimSuffix(strings.ToLower(target), ".")
+		for _, fp := range takeoverFingerprints {
+			for _, suffix := range fp.cnames {
+				if target == suffix || strings.HasSuffix(target, "."+suffix) {
+					return fp, true
+				}
+			}
+		}
+	}
+	return takeoverFingerprint{}, false
+}
+
+// rankTakeovers keeps the candidates that point at a takeover-prone service
+// or at a name that does not exist, and sorts them by priority.
+func rankTakeovers(candidates []*takeoverCandidate) []*takeoverCandidate {
+	ranked := []*takeoverCandidate{}
+	for _, c := range candidates {
+		fp, ok := matchTakeover(c.CNAME)
+		titleMatch := ok && fp.title != "" && strings.Contains(strings.ToLower(c.Title), strings.ToLower(fp.title))
+		switch {
+		case ok && c.unresolved:
+			c.Priority, c.Reason = priorityHigh, "CNAME to "+fp.service+" does not resolve"
+		case titleMatch:
+			c.Priority, c.Reason = priorityHigh, fp.service+" serves its page for an unclaimed resource"
+		case c.unresolved:
+			c.Priority, c.Reason = priorityMedium, "CNAME target does not resolve; check whether its domain can be registered"
+		case ok:
+			c.Priority, c.Reason = priorityLow, "CNAME to "+fp.service+"; verify the resource is still claimed"
+		default:
+			continue
+		}
+		c.Service = fp.service
+		ranked = append(ranked, c)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return priorityOrder[ranked[i].Priority] < priorityOrder[ranked[j].Priority]
+	})
+	return ranked
+}
+
+func printTakeovers(w io.Writer, candidates []*takeoverCandidate) error {
+	if len(candidates) == 0 {
+		_, err := fmt.Fprintln(w, "No takeover candidates found")
+		return err
+	}
+	for _, c := range candidates {
+		fmt.Fprintf(w, "%-6s  %s\n        CNAME: %s\n        %s\n", strings.ToUpper(c.Priority), c.URL, strings.Join(c.CNAME, " -> "), c.Reason)
+	}
+	_, err := fmt.Fprintf(w, "\n%d candidates\n", len(candidates))
+	return err
+}
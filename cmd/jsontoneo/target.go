@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	neo4jconfig "github.com/neo4j/neo4j-go-driver/v5/neo4j/config"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"github.com/redis/go-redis/v9"
+)
+
+// targetNames lists the graph databases -target writes to.
+var targetNames = []string{"neo4j", "memgraph", "neptune", "gremlin", "arangodb", "falkordb", "age", "dgraph", "local"}
+
+// graphTargets are the targets that are not written with Cypher, see
+// neo4jwriter.GraphTarget.
+var graphTargets = map[string]bool{"gremlin": true, "arangodb": true, "age": true, "dgraph": true, "local": true}
+
+// defaultStore is the directory of -target local and jsontoneo sync.
+const defaultStore = "jsontoneo-store"
+
+// checkTarget rejects the options the target of an import does not support.
+func checkTarget(opts importOptions, templates bool) {
+	switch {
+	case opts.target == "neptune" && opts.neptuneURL == "":
+		log.Fatal("-target neptune requires -neptune-url <endpoint> or $NEPTUNE_ENDPOINT")
+	case opts.output == "cypher" && opts.target != "neo4j" && opts.target != "memgraph":
+		log.Fatalf("-output cypher cannot be used with -target %s", opts.target)
+	}
+	if len(opts.mirrors) > 0 {
+		switch {
+		case graphTargets[opts.target]:
+			log.Fatalf("-mirror cannot be used with -target %s", opts.target)
+		case opts.localStore != "":
+			log.Fatal("-mirror cannot be used with -local-store")
+		}
+		seen := make(map[string]bool)
+		for _, spec := range opts.mirrors {
+			kind, value, _ := strings.Cut(spec, "=")
+			switch {
+			case !slices.Contains(mirrorKinds, kind) || value == "":
+				log.Fatalf("Invalid -mirror %q: want one of %s, followed by =<file, uri or url>", spec, strings.Join(mirrorKinds, ", "))
+			case seen[kind]:
+				log.Fatalf("-mirror %s given more than once", kind)
+			}
+			seen[kind] = true
+		}
+	}
+	with := "-target " + opts.target
+	switch {
+	case opts.localStore != "" && opts.output == "cypher":
+		log.Fatal("-local-store cannot be used with -output cypher")
+	case opts.localStore != "" && !graphTargets[opts.target]:
+		if opts.target != "neo4j" {
+			log.Fatalf("-local-store cannot be used with -target %s", opts.target)
+		}
+		// Een import die op de lokale opslag kan uitkomen, moet daar ook passen.
+		with = "-local-store"
+	case !graphTargets[opts.target]:
+		return
+	}
+	// Deze opties bestaan alleen als Cypher.
+	switch {
+	case templates:
+		log.Fatalf("-cypher-template cannot be used with %s", with)
+	case opts.mergeStrategy == "versioned":
+		log.Fatalf("-merge-strategy versioned cannot be used with %s", with)
+	case opts.maxNewNodes > 0:
+		log.Fatalf("-max-new-nodes cannot be used with %s", with)
+	case opts.outOfScope == "label":
+		log.Fatalf("-out-of-scope label cannot be used with %s", with)
+	case opts.tagLabels:
+		log.Fatalf("-tag-labels cannot be used with %s", with)
+	}
+}
+
+// openTarget opens the target of an import: the database named by -target,
+// or with -output cypher a script for it. The returned driver, when not nil,
+// must be closed after the target.
+func openTarget(ctx context.Context, opts importOptions) (neo4jwriter.Target, neo4j.Driver) {
+	switch opts.target {
+	case "neptune":
+		return openNeptune(ctx, opts.neptuneURL), nil
+	case "gremlin":
+		return openGremlin(opts.gremlinURL), nil
+	case "arangodb":
+		return openArango(opts.arangoURL, opts.arangoDB), nil
+	case "falkordb":
+		return openFalkorDB(ctx, opts.falkorURL, opts.falkorGraph), nil
+	case "age":
+		return openAGE(ctx, opts.ageURL, opts.ageGraph), nil
+	case "dgraph":
+		return openDgraph(opts.dgraphURL), nil
+	case "local":
+		return openStore(opts.localStore), nil
+	}
+
+	var out neo4jwriter.Target
+	var driver neo4j.Driver
+	if opts.output == "cypher" {
+		script, err := neo4jwriter.NewScriptTarget(opts.outFile, version)
+		if err != nil {
+			log.Fatalf("Error creating Cypher script: %v", err)
+		}
+		out = script
+	} else {
+		// Memgraph spreekt Bolt, dus dezelfde driver en configuratie.
+		driver = connect()
+		if opts.localStore != "" {
+			if err := driver.VerifyConnectivity(); err != nil {
+				log.Printf("Neo4j cannot be reached (%v), staging the import for jsontoneo sync", err)
+				driver.Close()
+				return openStore(opts.localStore), nil
+			}
+		}
+		out = neo4jwriter.NewNeo4jTarget(driver, opts.cluster.configure)
+	}
+	if opts.target == "memgraph" {
+		out = neo4jwriter.NewMemgraphTarget(out)
+	}
+	if len(opts.mirrors) > 0 {
+		fan := neo4jwriter.NewFanoutTarget(out, func(mirror string, err error) {
+			log.Printf("Mirror %s write error: %v", mirror, err)
+		})
+		for _, spec := range opts.mirrors {
+			kind, value, _ := strings.Cut(spec, "=")
+			fan.AddMirror(kind, openMirror(ctx, kind, value, opts))
+		}
+		out = fan
+	}
+	return out, driver
+}
+
+// mirrorKinds lists the targets -mirror writes to.
+var mirrorKinds = []string{"cypher", "neo4j", "memgraph", "neptune", "falkordb"}
+
+// openMirror opens the target of a -mirror kind=value.
+func openMirror(ctx context.Context, kind, value string, opts importOptions) neo4jwriter.Target {
+	switch kind {
+	case "cypher":
+		script, err := neo4jwriter.NewScriptTarget(value, version)
+		if err != nil {
+			log.Fatalf("Error creating Cypher script: %v", err)
+		}
+		return script
+	case "neptune":
+		return openNeptune(ctx, value)
+	case "falkordb":
+		return openFalkorDB(ctx, value, opts.falkorGraph)
+	}
+
+	// Neo4j of Memgraph via Bolt, met de inloggegevens in de URI.
+	u, err := url.Parse(value)
+	if err != nil {
+		log.Fatalf("Invalid -mirror %s URI: %v", kind, err)
+	}
+	auth := neo4j.NoAuth()
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = neo4j.BasicAuth(u.User.Username(), password, "")
+		u.User = nil
+	}
+	driver, err := neo4j.NewDriver(u.String(), auth, func(c *neo4jconfig.Config) {
+		// Een mirror die plat ligt mag niet elke import minutenlang ophouden.
+		c.MaxTransactionRetryTime = 5 * time.Second
+	})
+	if err != nil {
+		log.Fatalf("Error connecting to %s mirror: %v", kind, err)
+	}
+	var out neo4jwriter.Target = neo4jwriter.NewNeo4jTarget(driver)
+	if kind == "memgraph" {
+		out = neo4jwriter.NewMemgraphTarget(out)
+	}
+	return driverTarget{Target: out, driver: driver}
+}
+
+// driverTarget is a target that owns its driver.
+type driverTarget struct {
+	neo4jwriter.Target
+	driver neo4j.Driver
+}
+
+func (t driverTarget) Close() error {
+	return errors.Join(t.Target.Close(), t.driver.Close())
+}
+
+// openNeptune returns a target for the Neptune endpoint, signing its requests
+// with the default AWS credentials when there are any.
+func openNeptune(ctx context.Context, endpoint string) neo4jwriter.Target {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("Error loading AWS configuration: %v", err)
+	}
+	if cfg.Credentials != nil {
+		if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			log.Printf("No AWS credentials (%v), sending unsigned requests to Neptune", err)
+			cfg.Credentials = nil
+		}
+	}
+	return neo4jwriter.NewNeptuneTarget(ctx, endpoint, cfg)
+}
+
+// openGremlin connects to the Gremlin server at rawURL, by default
+// $GREMLIN_URL or ws://localhost:8182/gremlin.
+func openGremlin(rawURL string) neo4jwriter.Target {
+	if rawURL == "" {
+		rawURL = os.Getenv("GREMLIN_URL")
+	}
+	if rawURL == "" {
+		rawURL = "ws://localhost:8182/gremlin"
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		log.Fatalf("Invalid -gremlin-url: %v", err)
+	}
+	var auth *gremlingo.AuthInfo
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = gremlingo.BasicAuthInfo(u.User.Username(), password)
+		u.User = nil
+	}
+	out, err := neo4jwriter.NewGremlinTarget(u.String(), func(s *gremlingo.DriverRemoteConnectionSettings) {
+		if auth != nil {
+			s.AuthInfo = auth
+		}
+	})
+	if err != nil {
+		log.Fatalf("Error connecting to Gremlin server: %v", err)
+	}
+	return out
+}
+
+// openArango opens the ArangoDB database on the server at rawURL, by default
+// $ARANGO_URL or http://localhost:8529.
+func openArango(rawURL, database string) neo4jwriter.Target {
+	if rawURL == "" {
+		rawURL = os.Getenv("ARANGO_URL")
+	}
+	if rawURL == "" {
+		rawURL = "http://localhost:8529"
+	}
+	out, err := neo4jwriter.NewArangoTarget(rawURL, database)
+	if err != nil {
+		log.Fatalf("Error connecting to ArangoDB: %v", err)
+	}
+	return out
+}
+
+// openFalkorDB opens graph on the FalkorDB server at rawURL, by default
+// $FALKORDB_URL or redis://localhost:6379.
+func openFalkorDB(ctx context.Context, rawURL, graph string) neo4jwriter.Target {
+	if rawURL == "" {
+		rawURL = os.Getenv("FALKORDB_URL")
+	}
+	if rawURL == "" {
+		rawURL = "redis://localhost:6379"
+	}
+	ropts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		log.Fatalf("Invalid -falkordb-url: %v", err)
+	}
+	client := redis.NewClient(ropts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Fatalf("Error connecting to FalkorDB: %v", err)
+	}
+	return neo4jwriter.NewFalkorDBTarget(ctx, client, graph)
+}
+
+// openAGE opens graph in the PostgreSQL database at connString, by default
+// $AGE_URL or the PG* environment variables.
+func openAGE(ctx context.Context, connString, graph string) neo4jwriter.Target {
+	if connString == "" {
+		connString = os.Getenv("AGE_URL")
+	}
+	out, err := neo4jwriter.NewAGETarget(ctx, connString, graph)
+	if err != nil {
+		log.Fatalf("Error connecting to PostgreSQL: %v", err)
+	}
+	return out
+}
+
+// openDgraph connects to the Dgraph Alpha at rawURL, by default $DGRAPH_URL
+// or http://localhost:8080.
+func openDgraph(rawURL string) neo4jwriter.Target {
+	if rawURL == "" {
+		rawURL = os.Getenv("DGRAPH_URL")
+	}
+	if rawURL == "" {
+		rawURL = "http://localhost:8080"
+	}
+	out, err := neo4jwriter.NewDgraphTarget(rawURL)
+	if err != nil {
+		log.Fatalf("Error connecting to Dgraph: %v", err)
+	}
+	return out
+}
+
+// openStore creates the staging file of an import in dir, by default
+// defaultStore.
+func openStore(dir string) neo4jwriter.Target {
+	if dir == "" {
+		dir = defaultStore
+	}
+	out, err := neo4jwriter.NewStoreTarget(dir)
+	if err != nil {
+		log.Fatalf("Error creating local store: %v", err)
+	}
+	log.Printf("Staging the import in %s", out.Path())
+	return out
+}
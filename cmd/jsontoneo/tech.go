@@ -0,0 +1,246 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type techOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	filter  string
+	hosts   int
+	cluster *clusterOptions
+}
+
+// techUsage is a technology with the versions in use.
+type techUsage struct {
+	Name     string        `json:"name"`
+	Hosts    int           `json:"hosts"`
+	Versions []techVersion `json:"versions"`
+}
+
+type techVersion struct {
+	Version string   `json:"version"`
+	Hosts   int      `json:"hosts"`
+	Oldest  bool     `json:"oldest,omitempty"`
+	URLs    []string `json:"urls,omitempty"`
+}
+
+func techFlags(fs *flag.FlagSet) func() {
+	opts := techOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only count hosts whose URL contains this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only count the hosts of this project")
+	opts.output.register(fs, "table")
+	fs.StringVar(&opts.filter, "tech", "", "Only list technologies whose name contains this string, e.g. php")
+	fs.IntVar(&opts.hosts, "hosts", 3, "List the hosts of versions used by at most this many hosts")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		techs, err := loadTechUsage(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		err = opts.output.write(techs, techRows(techs), func(w io.Writer) error {
+			return printTechUsage(w, techs)
+		})
+		if err != nil {
+			log.Fatalf("Error writing technologies: %v", err)
+		}
+	}
+}
+
+func loadTechUsage(session neo4j.Session, opts techOptions) ([]*techUsage, error) {
+	type row struct {
+		tech string
+		urls []string
+	}
+	var rows []row
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		rows = nil
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		UNWIND h.tech AS tech
+		WITH tech, h
+		WHERE $filter = '' OR toLower(tech) CONTAINS toLower($filter)
+		RETURN tech, collect(DISTINCT h.url) AS urls
+		`, map[string]any{"scope": opts.scope, "project": opts.project, "filter": opts.filter})
+		if err != nil {
+			return nil, fmt.Errorf("Tech query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			rows = append(rows, row{propString(v[0]), propStrings(v[1])})
+		}
+		return nil, res.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// httpx schrijft technologieën als "Naam:versie"; zonder versie alleen de naam.
+	byName := map[string]*techUsage{}
+	hostsByName := map[string]map[string]bool{}
+	versions := map[string]map[string]*techVersion{}
+	for _, r := range rows {
+		name, version := splitTech(r.tech)
+		key := strings.ToLower(name)
+		t := byName[key]
+		if t == nil {
+			t = &techUsage{Name: name}
+			byName[key] = t
+			hostsByName[key] = map[string]bool{}
+			versions[key] = map[string]*techVersion{}
+		}
+		v := versions[key][version]
+		if v == nil {
+			v = &techVersion{Version: version}
+			versions[key][version] = v
+		}
+		for _, u := range r.urls {
+			hostsByName[key][u] = true
+			v.URLs = append(v.URLs, u)
+		}
+	}
+
+	techs := make([]*techUsage, 0, len(byName))
+	for key, t := range byName {
+		t.Hosts = len(hostsByName[key])
+		known := 0
+		for _, v := range versions[key] {
+			sort.Strings(v.URLs)
+			v.Hosts = len(v.URLs)
+			if v.Hosts > opts.hosts {
+				v.URLs = nil
+			}
+			if v.Version != "" {
+				known++
+			}
+			t.Versions = append(t.Versions, *v)
+		}
+		sort.Slice(t.Versions, func(i, j int) bool { return compareVersions(t.Versions[i].Version, t.Versions[j].Version) > 0 })
+		// De oudste bekende versie is vaak de uitschieter waar het om gaat.
+		if known > 1 {
+			for i := len(t.Versions) - 1; i >= 0; i-- {
+				if t.Versions[i].Version != "" {
+					t.Versions[i].Oldest = true
+					break
+				}
+			}
+		}
+		techs = append(techs, t)
+	}
+	sort.Slice(techs, func(i, j int) bool {
+		if techs[i].Hosts != techs[j].Hosts {
+			return techs[i].Hosts > techs[j].Hosts
+		}
+		return techs[i].Name < techs[j].Name
+	})
+	return techs, nil
+}
+
+// splitTech splits a technology as httpx writes it, "PHP:5.6.40", into its
+// name and version.
+func splitTech(tech string) (string, string) {
+	name, version, ok := strings.Cut(tech, ":")
+	if !ok {
+		return strings.TrimSpace(tech), ""
+	}
+	return strings.TrimSpace(name), strings.TrimSpace(version)
+}
+
+// compareVersions compares dotted versions numerically where both parts are
+// numbers, so 5.10 sorts after 5.9. An empty version is the lowest.
+func compareVersions(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+	pa, pb := strings.FieldsFunc(a, versionSep), strings.FieldsFunc(b, versionSep)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			if na < nb {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && pa[i] != pb[i]:
+			return strings.Compare(pa[i], pb[i])
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+func versionSep(r rune) bool { return r == '.' || r == '-' || r == '_' }
+
+// techRow is a version of a technology as a table row.
+type techRow struct {
+	Tech    string   `json:"tech"`
+	Version string   `json:"version"`
+	Hosts   int      `json:"hosts"`
+	Oldest  bool     `json:"oldest"`
+	URLs    []string `json:"urls"`
+}
+
+// techRows flattens techs into a row per version, for csv and md output.
+func techRows(techs []*techUsage) []techRow {
+	var rows []techRow
+	for _, t := range techs {
+		for _, v := range t.Versions {
+			rows = append(rows, techRow{t.Name, v.Version, v.Hosts, v.Oldest, v.URLs})
+		}
+	}
+	return rows
+}
+
+func printTechUsage(w io.Writer, techs []*techUsage) error {
+	if len(techs) == 0 {
+		_, err := fmt.Fprintln(w, "No technologies found")
+		return err
+	}
+	for i, t := range techs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%d hosts, %d versions)\n", t.Name, t.Hosts, len(t.Versions))
+		for _, v := range t.Versions {
+			version := v.Version
+			if version == "" {
+				version = "(no version)"
+			}
+			mark := ""
+			if v.Oldest {
+				mark = "  oldest"
+			}
+			fmt.Fprintf(w, "  %-20s %6d hosts%s\n", version, v.Hosts, mark)
+			for _, u := range v.URLs {
+				fmt.Fprintf(w, "      %s\n", u)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+// tlsTarget is an HTTPS host without certificate data, and where to
+// connect to for it.
+type tlsTarget struct {
+	url, project string
+	// addr is the IP of the host, or its hostname when it has none, with
+	// the port; serverName is the hostname sent as SNI.
+	addr, serverName, port string
+}
+
+// tlsResult is the outcome of a handshake with addr.
+type tlsResult struct {
+	cert    *x509.Certificate
+	version string
+	err     error
+}
+
+// loadTLSTargets returns the https:// hosts in scope that present no
+// certificate, and were not tried less than -max-age ago.
+func loadTLSTargets(session neo4j.Session, opts enrichOptions) ([]tlsTarget, error) {
+	var targets []tlsTarget
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		targets = nil
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE toLower(h.url) STARTS WITH 'https://' AND `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		  AND ($all OR (NOT (h)-[:PRESENTS]->(:Certificate) AND coalesce(h.tls_checked_at < $since, true)))
+		RETURN h.url, coalesce(h.project, ''), h.ip, h.port
+		ORDER BY h.url
+		`, map[string]any{"scope": opts.scope, "project": opts.project, "all": opts.all, "since": opts.since})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			u, err := url.Parse(propString(v[0]))
+			if err != nil || u.Hostname() == "" {
+				continue
+			}
+			t := tlsTarget{url: propString(v[0]), project: propString(v[1]), port: u.Port()}
+			if t.port == "" {
+				t.port = propString(v[3])
+			}
+			if t.port == "" {
+				t.port = "443"
+			}
+			host := u.Hostname()
+			if ip := propString(v[2]); isIP(ip) {
+				host = ip
+			}
+			t.addr = net.JoinHostPort(host, t.port)
+			// SNI mag geen IP zijn.
+			if !isIP(u.Hostname()) {
+				t.serverName = u.Hostname()
+			}
+			targets = append(targets, t)
+		}
+		return nil, res.Err()
+	})
+	return targets, err
+}
+
+// grabCertificate does a TLS handshake with addr and returns the leaf
+// certificate. The certificate is not verified: expired and self-signed
+// ones are what enrich tls is looking for.
+func grabCertificate(addr, serverName string, timeout time.Duration) tlsResult {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return tlsResult{err: err}
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return tlsResult{err: fmt.Errorf("no certificate")}
+	}
+	return tlsResult{cert: state.PeerCertificates[0], version: tls.VersionName(state.Version)}
+}
+
+// certProps returns the properties of the Certificate node for cert, named
+// like the tlsx fields the mappings write.
+func certProps(cert *x509.Certificate) map[string]any {
+	sum := sha256.Sum256(cert.Raw)
+	san := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		san = append(san, ip.String())
+	}
+	props := map[string]any{
+		"fingerprint": hex.EncodeToString(sum[:]),
+		"subject_an":  san,
+		"not_before":  cert.NotBefore.UTC(),
+		"not_after":   cert.NotAfter.UTC(),
+		"serial":      cert.SerialNumber.Text(16),
+		"self_signed": bytes.Equal(cert.RawIssuer, cert.RawSubject),
+	}
+	if cert.Subject.CommonName != "" {
+		props["subject_cn"] = cert.Subject.CommonName
+	}
+	if cert.Issuer.CommonName != "" {
+		props["issuer_cn"] = cert.Issuer.CommonName
+	}
+	if len(cert.Subject.Organization) > 0 {
+		props["subject_org"] = cert.Subject.Organization[0]
+	}
+	if len(cert.Issuer.Organization) > 0 {
+		props["issuer_org"] = cert.Issuer.Organization[0]
+	}
+	return props
+}
+
+// enrichTLS connects to the HTTPS hosts without certificate data, does the
+// TLS handshake and writes the certificate they present, so tlsx does not
+// have to run separately.
+func enrichTLS(session neo4j.Session, opts enrichOptions) {
+	targets, err := loadTLSTargets(session, opts)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+
+	// Hosts op hetzelfde adres en dezelfde naam delen een handshake.
+	type dialKey struct{ addr, serverName string }
+	var keys []dialKey
+	seen := map[dialKey]bool{}
+	for _, t := range targets {
+		if key := (dialKey{t.addr, t.serverName}); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	results := map[dialKey]tlsResult{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 20)
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r := grabCertificate(key.addr, key.serverName, opts.timeout)
+			mu.Lock()
+			results[key] = r
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var rows []map[string]any
+	failed := 0
+	for _, t := range targets {
+		r := results[dialKey{t.addr, t.serverName}]
+		row := map[string]any{"url": t.url, "project": t.project, "port": t.port, "cert": nil, "version": nil, "error": nil}
+		if r.err != nil {
+			row["error"] = r.err.Error()
+			failed++
+		} else {
+			row["cert"] = certProps(r.cert)
+			row["version"] = r.version
+		}
+		rows = append(rows, row)
+	}
+	for start := 0; start < len(rows); start += opts.batchSize {
+		batch := rows[start:min(start+opts.batchSize, len(rows))]
+		if err := writeTLS(session, batch); err != nil {
+			log.Fatalf("Error writing certificates: %v", err)
+		}
+	}
+	log.Printf("Grabbed the certificate of %d of %d hosts in %d handshakes (%d failed)",
+		len(rows)-failed, len(rows), len(results), failed)
+}
+
+// writeTLS records the handshake on the hosts of rows and links them to the
+// certificate they presented.
+func writeTLS(session neo4j.Session, rows []map[string]any) error {
+	var unscoped, scoped []map[string]any
+	for _, row := range rows {
+		if row["project"] == "" {
+			unscoped = append(unscoped, row)
+		} else {
+			scoped = append(scoped, row)
+		}
+	}
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, q := range []struct {
+			key  string
+			rows []map[string]any
+		}{{"{fingerprint: row.cert.fingerprint, project: ''}", unscoped}, {"{fingerprint: row.cert.fingerprint, project: row.project}", scoped}} {
+			if len(q.rows) == 0 {
+				continue
+			}
+			res, err := tx.Run(`
+			UNWIND $rows AS row
+			MATCH (h:Host {url: row.url}) WHERE coalesce(h.project, '') = row.project
+			SET h.tls_checked_at = datetime(), h.tls_version = row.version, h.tls_error = row.error
+			WITH h, row WHERE row.cert IS NOT NULL
+			MERGE (c:Certificate `+q.key+`)
+			SET c += row.cert
+			MERGE (h)-[p:PRESENTS]->(c)
+			SET p.port = row.port
+			`, map[string]any{"rows": q.rows})
+			if err != nil {
+				return nil, fmt.Errorf("Certificate query error: %w", err)
+			}
+			if _, err := res.Consume(); err != nil {
+				return nil, fmt.Errorf("Certificate query error: %w", err)
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
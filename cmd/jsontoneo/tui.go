@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+	"golang.org/x/term"
+)
+
+const tuiMaxLogLines = 200
+
+var tuiTabs = []string{"Technologies", "ASNs", "Hosts", "Log"}
+
+// tui shows live import progress in the terminal and, once the import has
+// finished, a browsable summary of the top technologies, ASNs and hosts.
+type tui struct {
+	mu      sync.Mutex
+	summary neo4jwriter.Summary
+	current string
+	read    int64
+	total   int64
+	start   time.Time
+	logs    []string
+	errors  int
+	techs   map[string]int
+	asns    map[string]int
+	hosts   map[string]int
+
+	done     bool
+	tab      int
+	offset   int
+	keys     chan byte
+	finished chan struct{}
+	stopped  chan struct{}
+	oldState *term.State
+}
+
+// newTUI switches the terminal to the alternate screen and starts rendering.
+// total is the size of the input in bytes, used for the progress bar.
+func newTUI(total int64) (*tui, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, fmt.Errorf("-tui requires an interactive terminal")
+	}
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("switching terminal to raw mode: %w", err)
+	}
+
+	t := &tui{
+		total:    total,
+		start:    time.Now(),
+		techs:    map[string]int{},
+		asns:     map[string]int{},
+		hosts:    map[string]int{},
+		keys:     make(chan byte),
+		finished: make(chan struct{}),
+		stopped:  make(chan struct{}),
+		oldState: oldState,
+	}
+	// Alternate screen, cursor verbergen.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+
+	go t.readKeys()
+	go t.loop()
+	return t, nil
+}
+
+// Write collects log output, which would otherwise garble the screen.
+func (t *tui) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if strings.Contains(line, "Error") {
+			t.errors++
+		}
+		t.logs = append(t.logs, line)
+	}
+	if len(t.logs) > tuiMaxLogLines {
+		t.logs = t.logs[len(t.logs)-tuiMaxLogLines:]
+	}
+	return len(p), nil
+}
+
+// Observe records a processed line of n bytes and, when the record was
+// written, tallies its technologies, ASN and host.
+func (t *tui) Observe(result *model.HttpxResult, n int, summary neo4jwriter.Summary) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.read += int64(n)
+	t.summary = summary
+	if result == nil {
+		return
+	}
+	t.current = result.URL
+	for _, tech := range result.Tech {
+		t.techs[tech]++
+	}
+	if result.ASN.ASNumber != "" {
+		t.asns[strings.TrimSpace(result.ASN.ASNumber+" "+result.ASN.ASName)]++
+	}
+	if host := result.Hostname(); host != "" {
+		t.hosts[host]++
+	}
+}
+
+// finish switches to the browsable summary and blocks until the user quits.
+func (t *tui) finish(summary neo4jwriter.Summary) {
+	t.mu.Lock()
+	t.summary = summary
+	t.done = true
+	t.mu.Unlock()
+	close(t.finished)
+	<-t.stopped
+}
+
+func (t *tui) restore() {
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	term.Restore(int(os.Stdin.Fd()), t.oldState)
+}
+
+func (t *tui) readKeys() {
+	buf := make([]byte, 8)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, b := range buf[:n] {
+			t.keys <- b
+		}
+	}
+}
+
+func (t *tui) loop() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+
+	finished := t.finished
+	var escape []byte
+	for {
+		t.render()
+		select {
+		case <-ticker.C:
+		case <-finished:
+			finished = nil
+		case b := <-t.keys:
+			// Pijltjestoetsen komen binnen als ESC [ A/B/C/D.
+			if len(escape) > 0 || b == 0x1b {
+				escape = append(escape, b)
+				if len(escape) < 3 {
+					continue
+				}
+				switch escape[2] {
+				case 'A':
+					b = 'k'
+				case 'B':
+					b = 'j'
+				case 'C':
+					b = '\t'
+				case 'D':
+					b = 'h'
+				}
+				escape = nil
+			}
+			if t.handleKey(b) {
+				t.restore()
+				t.mu.Lock()
+				done := t.done
+				t.mu.Unlock()
+				if !done {
+					fmt.Fprintln(os.Stderr, "Import aborted")
+					os.Exit(130)
+				}
+				return
+			}
+		}
+	}
+}
+
+// handleKey applies a key press and reports whether the TUI should exit.
+func (t *tui) handleKey(b byte) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch b {
+	case 'q', 0x03: // q, Ctrl-C
+		return true
+	case '\t', 'l':
+		t.tab = (t.tab + 1) % len(tuiTabs)
+		t.offset = 0
+	case 'h':
+		t.tab = (t.tab + len(tuiTabs) - 1) % len(tuiTabs)
+		t.offset = 0
+	case '1', '2', '3', '4':
+		t.tab = int(b - '1')
+		t.offset = 0
+	case 'j':
+		t.offset++
+	case 'k':
+		if t.offset > 0 {
+			t.offset--
+		}
+	}
+	return false
+}
+
+func (t *tui) render() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 10 {
+		width, height = 80, 24
+	}
+
+	var lines []string
+	s := t.summary
+	elapsed := time.Since(t.start).Round(time.Second)
+	state := "Importing"
+	if t.done {
+		state = "Finished"
+		elapsed = s.Elapsed().Round(time.Millisecond)
+	}
+	lines = append(lines,
+		fmt.Sprintf("\x1b[1mjsontoneo %s\x1b[0m  scan %s  %s  %s", version, s.ScanID, state, elapsed),
+		t.progressBar(width),
+		fmt.Sprintf("read %d  parsed %d  skipped %d  parse errors %d  filtered %d  written %d  failed %d",
+			s.Read, s.Parsed, s.Skipped, s.ParseErrors, s.Filtered, s.Written, s.Failed),
+		fmt.Sprintf("nodes %d created / %d matched  relationships %d created / %d matched",
+			s.NodesCreated, s.NodesMatched, s.RelationshipsCreated, s.RelationshipsMatched),
+	)
+	if secs := time.Since(t.start).Seconds(); !t.done && secs > 0 {
+		lines = append(lines, fmt.Sprintf("%.1f records/s  current: %s", float64(s.Read)/secs, t.current))
+	} else {
+		lines = append(lines, fmt.Sprintf("%.1f records/s", s.RecordsPerSecond))
+	}
+	lines = append(lines, "")
+
+	if !t.done {
+		lines = append(lines, fmt.Sprintf("\x1b[1mRecent log (%d errors)\x1b[0m", t.errors))
+		logs := t.logs
+		if room := height - len(lines) - 1; len(logs) > room && room > 0 {
+			logs = logs[len(logs)-room:]
+		}
+		lines = append(lines, logs...)
+		lines = append(lines, "", "q: abort")
+	} else {
+		var tabs []string
+		for i, name := range tuiTabs {
+			if i == t.tab {
+				tabs = append(tabs, "\x1b[7m "+fmt.Sprintf("%d %s", i+1, name)+" \x1b[0m")
+			} else {
+				tabs = append(tabs, fmt.Sprintf(" %d %s ", i+1, name))
+			}
+		}
+		lines = append(lines, strings.Join(tabs, " "), "")
+
+		var body []string
+		switch t.tab {
+		case 0:
+			body = rankedLines(t.techs)
+		case 1:
+			body = rankedLines(t.asns)
+		case 2:
+			body = rankedLines(t.hosts)
+		case 3:
+			body = t.logs
+		}
+		room := height - len(lines) - 2
+		if t.offset > len(body)-room {
+			t.offset = max(0, len(body)-room)
+		}
+		body = body[t.offset:]
+		if len(body) > room {
+			body = body[:room]
+		}
+		lines = append(lines, body...)
+		for len(lines) < height-1 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "tab/1-4: switch view  j/k: scroll  q: quit")
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		if visibleLen(line) > width {
+			line = truncateVisible(line, width)
+		}
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	fmt.Print(b.String())
+}
+
+func (t *tui) progressBar(width int) string {
+	if t.total <= 0 {
+		return ""
+	}
+	frac := float64(t.read) / float64(t.total)
+	if t.done || frac > 1 {
+		frac = 1
+	}
+	barWidth := width - 8
+	filled := int(frac * float64(barWidth))
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled) + fmt.Sprintf("] %3.0f%%", frac*100)
+}
+
+// rankedLines renders counts as lines sorted by descending count.
+func rankedLines(counts map[string]int) []string {
+	rows := countRows(counts)
+	lines := make([]string, len(rows))
+	for i, r := range rows {
+		lines[i] = fmt.Sprintf("%6d  %s", r.Count, r.Name)
+	}
+	return lines
+}
+
+// visibleLen returns the length of s without ANSI escape sequences.
+func visibleLen(s string) int {
+	n, inEscape := 0, false
+	for _, r := range s {
+		switch {
+		case r == 0x1b:
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+func truncateVisible(s string, width int) string {
+	var b strings.Builder
+	n, inEscape := 0, false
+	for _, r := range s {
+		switch {
+		case r == 0x1b:
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			if n >= width {
+				continue
+			}
+			n++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
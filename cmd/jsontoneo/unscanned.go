@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+type unscannedOptions struct {
+	scope   string
+	project string
+	output  outputOptions
+	cluster *clusterOptions
+}
+
+// unscannedName is a domain name the graph knows of, from a certificate or a
+// Domain node, that no Host has.
+type unscannedName struct {
+	Name    string   `json:"name"`
+	Sources []string `json:"sources"`
+	// SeenOn are the hosts presenting a certificate with the name.
+	SeenOn []string `json:"seen_on,omitempty"`
+}
+
+func unscannedFlags(fs *flag.FlagSet) func() {
+	opts := unscannedOptions{cluster: &clusterOptions{}}
+	fs.StringVar(&opts.scope, "scope", "", "Only list names containing this string, e.g. example.com")
+	fs.StringVar(&opts.project, "project", "", "Only list the names of this project")
+	opts.output.register(fs, "table", "list")
+	opts.cluster.register(fs, true)
+
+	return func() {
+		opts.output.check()
+		opts.cluster.check()
+
+		driver := connect()
+		defer driver.Close()
+		session := opts.cluster.session(driver, neo4j.AccessModeRead)
+		defer session.Close()
+
+		names, err := loadUnscanned(session, opts)
+		if err != nil {
+			log.Fatalf("Error reading graph: %v", err)
+		}
+		err = opts.output.write(names, names, func(w io.Writer) error {
+			if opts.output.format == "list" {
+				// Eén naam per regel, als invoer voor httpx.
+				for _, n := range names {
+					fmt.Fprintln(w, n.Name)
+				}
+				return nil
+			}
+			return printUnscanned(w, names)
+		})
+		if err != nil {
+			log.Fatalf("Error writing names: %v", err)
+		}
+	}
+}
+
+// loadUnscanned returns the names in certificate SANs and subjects and of
+// Domain nodes without a Host of that hostname. Wildcard names cannot be
+// scanned as such and are left out.
+func loadUnscanned(session neo4j.Session, opts unscannedOptions) ([]unscannedName, error) {
+	params := map[string]any{"scope": opts.scope, "project": opts.project}
+	scanned := map[string]bool{}
+	var names []unscannedName
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		clear(scanned)
+		names = names[:0]
+		res, err := tx.Run(`
+		MATCH (h:Host) WHERE `+neo4jwriter.ProjectCond("h")+`
+		RETURN h.url, h.input
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			r := model.HttpxResult{URL: propString(v[0]), Input: propString(v[1])}
+			scanned[r.Hostname()] = true
+		}
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+
+		res, err = tx.Run(`
+		CALL {
+			MATCH (c:Certificate) WHERE `+neo4jwriter.ProjectCond("c")+`
+			OPTIONAL MATCH (h:Host)-[:PRESENTS]->(c)
+			WITH c, collect(h.url) AS hosts
+			UNWIND coalesce(c.subject_an, []) + [c.subject_cn] AS name
+			RETURN toLower(name) AS name, 'certificate' AS source, hosts
+			UNION ALL
+			MATCH (d:Domain) WHERE `+neo4jwriter.ProjectCond("d")+`
+			RETURN toLower(d.name) AS name, 'domain' AS source, [] AS hosts
+		}
+		WITH name, source, hosts
+		WHERE name IS NOT NULL AND NOT name STARTS WITH '*.'
+		  AND ($scope = '' OR name CONTAINS toLower($scope))
+		UNWIND CASE WHEN hosts = [] THEN [null] ELSE hosts END AS host
+		RETURN name, collect(DISTINCT source), collect(DISTINCT host)
+		ORDER BY name
+		`, params)
+		if err != nil {
+			return nil, fmt.Errorf("Name query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			name := propString(v[0])
+			if scanned[name] {
+				continue
+			}
+			n := unscannedName{Name: name, Sources: propStrings(v[1]), SeenOn: propStrings(v[2])}
+			sort.Strings(n.Sources)
+			sort.Strings(n.SeenOn)
+			names = append(names, n)
+		}
+		return nil, res.Err()
+	})
+	return names, err
+}
+
+func printUnscanned(w io.Writer, names []unscannedName) error {
+	if len(names) == 0 {
+		_, err := fmt.Fprintln(w, "Every known name has been scanned")
+		return err
+	}
+	fmt.Fprintf(w, "%-50s  %-20s  %s\n", "NAME", "SOURCE", "SEEN ON")
+	for _, n := range names {
+		seenOn := strings.Join(n.SeenOn, ", ")
+		if len(n.SeenOn) > 3 {
+			seenOn = fmt.Sprintf("%s, +%d more", strings.Join(n.SeenOn[:3], ", "), len(n.SeenOn)-3)
+		}
+		fmt.Fprintf(w, "%-50s  %-20s  %s\n", n.Name, strings.Join(n.Sources, ","), seenOn)
+	}
+	_, err := fmt.Fprintf(w, "\n%d names not scanned yet\n", len(names))
+	return err
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/neo4jwriter"
+)
+
+const rdapBootstrap = "https://rdap.org/"
+
+var errRDAPNotFound = errors.New("not found")
+
+// rdapClient queries an RDAP server, at most one request per delay. The
+// rdap.org bootstrap redirects to the registry that holds the object.
+type rdapClient struct {
+	base   string
+	delay  time.Duration
+	client *http.Client
+	last   time.Time
+	// Requests counts the requests sent, retries included.
+	requests int
+}
+
+// rdapObject holds the fields of RDAP domain and IP network responses that
+// enrich whois uses.
+type rdapObject struct {
+	Handle       string       `json:"handle"`
+	Name         string       `json:"name"`
+	StartAddress string       `json:"startAddress"`
+	EndAddress   string       `json:"endAddress"`
+	Country      string       `json:"country"`
+	Events       []rdapEvent  `json:"events"`
+	Entities     []rdapEntity `json:"entities"`
+	CIDRs        []struct {
+		V4Prefix string `json:"v4prefix"`
+		V6Prefix string `json:"v6prefix"`
+		Length   int    `json:"length"`
+	} `json:"cidr0_cidrs"`
+}
+
+type rdapEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+}
+
+type rdapEntity struct {
+	Roles    []string     `json:"roles"`
+	VCard    []any        `json:"vcardArray"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+// whoisInfo is what RDAP says about a domain or netblock.
+type whoisInfo struct {
+	Registrar  string
+	Registrant string
+	Created    time.Time
+	Expires    time.Time
+	Updated    time.Time
+	Handle     string
+	// The name, country, range and CIDRs are those of a netblock.
+	Name, Country string
+	Start, End    netip.Addr
+	CIDRs         []string
+}
+
+func newRDAPClient(base string, delay time.Duration) *rdapClient {
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return &rdapClient{base: base, delay: delay, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// get fetches path, e.g. domain/example.com, waiting out the delay and the
+// Retry-After of 429 responses.
+func (c *rdapClient) get(path string) (*rdapObject, error) {
+	for attempt := 0; ; attempt++ {
+		if wait := c.delay - time.Since(c.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		c.last = time.Now()
+		c.requests++
+
+		req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/rdap+json")
+		req.Header.Set("User-Agent", "jsontoneo/"+version)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < 3:
+			resp.Body.Close()
+			wait := 10 * time.Second << attempt
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(s) * time.Second
+			}
+			log.Printf("Rate limited by RDAP server, waiting %s", wait)
+			time.Sleep(wait)
+			continue
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, errRDAPNotFound
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			return nil, fmt.Errorf("status %s", resp.Status)
+		}
+		var obj rdapObject
+		err = json.NewDecoder(resp.Body).Decode(&obj)
+		resp.Body.Close()
+		return &obj, err
+	}
+}
+
+// info extracts the registrar, registrant and dates of an RDAP object, and
+// the range of a network.
+func (o *rdapObject) info() whoisInfo {
+	w := whoisInfo{Handle: o.Handle, Name: o.Name, Country: o.Country}
+	for _, e := range o.Events {
+		t, err := time.Parse(time.RFC3339, e.Date)
+		if err != nil {
+			continue
+		}
+		switch e.Action {
+		case "registration":
+			w.Created = t
+		case "expiration":
+			w.Expires = t
+		case "last changed":
+			w.Updated = t
+		}
+	}
+	w.Registrar = rdapEntityName(o.Entities, "registrar", "fn")
+	if w.Registrant = rdapEntityName(o.Entities, "registrant", "org"); w.Registrant == "" {
+		w.Registrant = rdapEntityName(o.Entities, "registrant", "fn")
+	}
+	w.Start, _ = netip.ParseAddr(o.StartAddress)
+	w.End, _ = netip.ParseAddr(o.EndAddress)
+	for _, c := range o.CIDRs {
+		prefix := c.V4Prefix + c.V6Prefix
+		if prefix != "" {
+			w.CIDRs = append(w.CIDRs, prefix+"/"+strconv.Itoa(c.Length))
+		}
+	}
+	return w
+}
+
+// contains reports whether addr lies in the range of the netblock.
+func (w whoisInfo) contains(addr netip.Addr) bool {
+	return w.Start.IsValid() && w.End.IsValid() && w.Start.Compare(addr) <= 0 && addr.Compare(w.End) <= 0
+}
+
+// rdapEntityName returns the vCard property prop of the first entity with
+// role, looking into nested entities too.
+func rdapEntityName(entities []rdapEntity, role, prop string) string {
+	for _, e := range entities {
+		for _, r := range e.Roles {
+			if r != role {
+				continue
+			}
+			if s := vcardProperty(e.VCard, prop); s != "" {
+				return s
+			}
+		}
+		if s := rdapEntityName(e.Entities, role, prop); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// vcardProperty returns a text property of a jCard, e.g.
+// ["vcard", [["fn", {}, "text", "Example Registrar, Inc."]]].
+func vcardProperty(card []any, name string) string {
+	if len(card) < 2 {
+		return ""
+	}
+	props, _ := card[1].([]any)
+	for _, p := range props {
+		fields, _ := p.([]any)
+		if len(fields) < 4 || fields[0] != name {
+			continue
+		}
+		switch v := fields[3].(type) {
+		case string:
+			return v
+		case []any:
+			// org kan een lijst van organisatie-eenheden zijn.
+			var parts []string
+			for _, s := range v {
+				if s, ok := s.(string); ok && s != "" {
+					parts = append(parts, s)
+				}
+			}
+			return strings.Join(parts, ", ")
+		}
+	}
+	return ""
+}
+
+// whoisTargets are the apex domains and IPs of the hosts in scope, with the
+// URLs of the hosts per domain and IP, by project.
+type whoisTargets struct {
+	domains map[[2]string][]string
+	ips     map[[2]string][]string
+}
+
+// loadWhoisTargets reads the hosts in scope. Domains and netblocks looked
+// up since opts.since are left out.
+func loadWhoisTargets(session neo4j.Session, opts enrichOptions) (whoisTargets, error) {
+	t := whoisTargets{domains: map[[2]string][]string{}, ips: map[[2]string][]string{}}
+	_, err := session.ReadTransaction(func(tx neo4j.Transaction) (any, error) {
+		clear(t.domains)
+		clear(t.ips)
+		res, err := tx.Run(`
+		MATCH (h:Host)
+		WHERE `+hostCond+` AND `+neo4jwriter.ProjectCond("h")+`
+		OPTIONAL MATCH (h)-[:IN_DOMAIN]->(d:Domain) WHERE d.rdap_checked_at >= $since
+		OPTIONAL MATCH (h)-[:IN_NETBLOCK]->(n:Netblock) WHERE n.rdap_checked_at >= $since
+		RETURN h.url, h.ip, coalesce(h.project, ''), d IS NOT NULL AND NOT $all, n IS NOT NULL AND NOT $all
+		`, map[string]any{"scope": opts.scope, "project": opts.project, "since": opts.since, "all": opts.all})
+		if err != nil {
+			return nil, fmt.Errorf("Host query error: %w", err)
+		}
+		for res.Next() {
+			v := res.Record().Values
+			url, ip, project := propString(v[0]), strings.TrimSpace(propString(v[1])), propString(v[2])
+			if apex := apexDomain(url); v[3] != true && apex != "" && !isIP(apex) {
+				key := [2]string{project, apex}
+				t.domains[key] = append(t.domains[key], url)
+			}
+			if v[4] != true && isIP(ip) {
+				key := [2]string{project, ip}
+				t.ips[key] = append(t.ips[key], url)
+			}
+		}
+		return nil, res.Err()
+	})
+	return t, err
+}
+
+func isIP(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// sortedKeys returns the keys of m sorted by name, then project, so the
+// requests for a domain or IP come together.
+func sortedKeys(m map[[2]string][]string) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][1] != keys[j][1] {
+			return keys[i][1] < keys[j][1]
+		}
+		return keys[i][0] < keys[j][0]
+	})
+	return keys
+}
+
+// enrichWhois looks up the apex domains and netblocks of the hosts in scope
+// with RDAP and writes them as Domain and Netblock nodes. Every domain and
+// IP is queried once per run, and an IP inside a netblock fetched before is
+// not queried at all.
+func enrichWhois(session neo4j.Session, opts enrichOptions) {
+	targets, err := loadWhoisTargets(session, opts)
+	if err != nil {
+		log.Fatalf("Error reading graph: %v", err)
+	}
+	rdap := newRDAPClient(opts.rdapURL, opts.rdapDelay)
+	failed := 0
+
+	var domainRows []map[string]any
+	type lookup struct {
+		info   *whoisInfo
+		failed bool
+	}
+	domains := map[string]lookup{}
+	for _, key := range sortedKeys(targets.domains) {
+		apex := key[1]
+		l, ok := domains[apex]
+		if !ok {
+			obj, err := rdap.get("domain/" + apex)
+			switch {
+			case err == nil:
+				i := obj.info()
+				l.info = &i
+			case !errors.Is(err, errRDAPNotFound):
+				log.Printf("Warning: RDAP lookup of %s: %v", apex, err)
+				l.failed = true
+				failed++
+			}
+			domains[apex] = l
+		}
+		if !l.failed {
+			domainRows = append(domainRows, whoisRow(key, targets.domains[key], l.info))
+		}
+	}
+
+	var netblockRows []map[string]any
+	var netblocks []whoisInfo
+	byHandle := map[[2]string]map[string]any{}
+	unknown := map[string]bool{}
+	for _, key := range sortedKeys(targets.ips) {
+		addr, _ := netip.ParseAddr(key[1])
+		var info *whoisInfo
+		for i := range netblocks {
+			if netblocks[i].contains(addr) {
+				info = &netblocks[i]
+				break
+			}
+		}
+		if info == nil {
+			if unknown[key[1]] {
+				continue
+			}
+			obj, err := rdap.get("ip/" + key[1])
+			if err != nil {
+				if !errors.Is(err, errRDAPNotFound) {
+					log.Printf("Warning: RDAP lookup of %s: %v", key[1], err)
+					failed++
+				}
+				unknown[key[1]] = true
+				continue
+			}
+			i := obj.info()
+			if i.Handle == "" {
+				unknown[key[1]] = true
+				continue
+			}
+			netblocks = append(netblocks, i)
+			info = &i
+		}
+		// Hosts in hetzelfde netblok delen een rij.
+		k := [2]string{key[0], info.Handle}
+		if row := byHandle[k]; row != nil {
+			row["hosts"] = append(row["hosts"].([]string), targets.ips[key]...)
+			continue
+		}
+		row := whoisRow([2]string{key[0], info.Handle}, targets.ips[key], info)
+		byHandle[k] = row
+		netblockRows = append(netblockRows, row)
+	}
+
+	for _, w := range []struct {
+		label, key, rel string
+		rows            []map[string]any
+	}{
+		{"Domain", "name", "IN_DOMAIN", domainRows},
+		{"Netblock", "handle", "IN_NETBLOCK", netblockRows},
+	} {
+		for start := 0; start < len(w.rows); start += opts.batchSize {
+			batch := w.rows[start:min(start+opts.batchSize, len(w.rows))]
+			if err := writeWhois(session, w.label, w.key, w.rel, batch); err != nil {
+				log.Fatalf("Error writing RDAP data: %v", err)
+			}
+		}
+	}
+	log.Printf("Wrote %d domains and %d netblocks from %d RDAP requests (%d failed)",
+		len(domainRows), len(netblockRows), rdap.requests, failed)
+}
+
+// whoisRow is the row writeWhois writes for the node key (project, name)
+// and its hosts. Without info only the lookup time is recorded.
+func whoisRow(key [2]string, hosts []string, info *whoisInfo) map[string]any {
+	row := map[string]any{"project": key[0], "key": key[1], "hosts": hosts, "props": map[string]any{}}
+	if info == nil {
+		return row
+	}
+	props := map[string]any{}
+	for name, v := range map[string]string{
+		"registrar": info.Registrar, "registrant": info.Registrant,
+		"name": info.Name, "country": info.Country,
+	} {
+		if v != "" {
+			props[name] = v
+		}
+	}
+	for name, t := range map[string]time.Time{"created": info.Created, "expires": info.Expires, "updated": info.Updated} {
+		if !t.IsZero() {
+			props[name] = t
+		}
+	}
+	if info.Start.IsValid() {
+		props["start"], props["end"] = info.Start.String(), info.End.String()
+	}
+	if len(info.CIDRs) > 0 {
+		props["cidrs"] = info.CIDRs
+	}
+	row["props"] = props
+	return row
+}
+
+// writeWhois merges the nodes of rows under label, keyed on key within their
+// project, and links their hosts to them with rel.
+func writeWhois(session neo4j.Session, label, key, rel string, rows []map[string]any) error {
+	var unscoped, scoped []map[string]any
+	for _, row := range rows {
+		if row["project"] == "" {
+			unscoped = append(unscoped, row)
+		} else {
+			scoped = append(scoped, row)
+		}
+	}
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		for _, q := range []struct {
+			key  string
+			rows []map[string]any
+		}{{"{" + key + ": row.key, project: ''}", unscoped}, {"{" + key + ": row.key, project: row.project}", scoped}} {
+			if len(q.rows) == 0 {
+				continue
+			}
+			res, err := tx.Run(`
+			UNWIND $rows AS row
+			MERGE (n:`+label+` `+q.key+`)
+			SET n += row.props, n.rdap_checked_at = datetime()
+			WITH n, row
+			UNWIND row.hosts AS url
+			MATCH (h:Host {url: url}) WHERE coalesce(h.project, '') = row.project
+			MERGE (h)-[:`+rel+`]->(n)
+			`, map[string]any{"rows": q.rows})
+			if err != nil {
+				return nil, fmt.Errorf("%s query error: %w", label, err)
+			}
+			if _, err := res.Consume(); err != nil {
+				return nil, fmt.Errorf("%s query error: %w", label, err)
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
@@ -0,0 +1,240 @@
+// Package coerce converts property values to a declared type, to clean up
+// tools that write the same field as a string in one record and a number in
+// the next, dates in their own format, or lists as comma-separated strings.
+package coerce
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types lists the types a property can be coerced to. datetime takes an
+// optional Go time layout, unix or unixms (default RFC 3339); list takes an
+// optional separator (default a comma).
+var Types = []string{"int", "float", "bool", "string", "datetime", "list"}
+
+// Rule coerces a property to Type. Arg is the datetime layout or the list
+// separator.
+type Rule struct {
+	Type string
+	Arg  string
+}
+
+// Rules maps property names to their rule.
+type Rules map[string]Rule
+
+// ParseRule parses a type such as int, datetime:2006-01-02 or list:;.
+func ParseRule(s string) (Rule, error) {
+	typ, arg, _ := strings.Cut(strings.TrimSpace(s), ":")
+	r := Rule{Type: typ, Arg: arg}
+	switch typ {
+	case "int", "float", "bool", "string":
+		if arg != "" {
+			return r, fmt.Errorf("type %s takes no argument", typ)
+		}
+	case "datetime", "list":
+	default:
+		return r, fmt.Errorf("unknown type %q (types: %s)", typ, strings.Join(Types, ", "))
+	}
+	return r, nil
+}
+
+// Parse parses rules given as property: type.
+func Parse(specs map[string]string) (Rules, error) {
+	rules := make(Rules, len(specs))
+	for prop, spec := range specs {
+		r, err := ParseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prop, err)
+		}
+		rules[prop] = r
+	}
+	return rules, nil
+}
+
+// Set parses a rule given as property=type and adds it.
+func (rules Rules) Set(spec string) error {
+	prop, typ, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(prop) == "" {
+		return fmt.Errorf("invalid coercion %q (expected property=type)", spec)
+	}
+	r, err := ParseRule(typ)
+	if err != nil {
+		return fmt.Errorf("%s: %w", prop, err)
+	}
+	rules[strings.TrimSpace(prop)] = r
+	return nil
+}
+
+func (rules Rules) String() string {
+	specs := make([]string, 0, len(rules))
+	for prop, r := range rules {
+		spec := prop + "=" + r.Type
+		if r.Arg != "" {
+			spec += ":" + r.Arg
+		}
+		specs = append(specs, spec)
+	}
+	return strings.Join(specs, " ")
+}
+
+// Apply coerces the properties of props in place. A value that cannot be
+// converted is removed, so it does not overwrite a good value in the graph;
+// null values are left alone.
+func (rules Rules) Apply(props map[string]any) {
+	for prop, r := range rules {
+		v, ok := props[prop]
+		if !ok || v == nil {
+			continue
+		}
+		if v, ok = r.Convert(v); ok {
+			props[prop] = v
+		} else {
+			delete(props, prop)
+		}
+	}
+}
+
+// Convert returns v converted to the type of the rule. The items of a list
+// are converted one by one, dropping those that cannot be.
+func (r Rule) Convert(v any) (any, bool) {
+	if r.Type == "list" {
+		return r.list(v), true
+	}
+	var items []any
+	switch v := v.(type) {
+	case []any:
+		items = v
+	case []string:
+		for _, s := range v {
+			items = append(items, s)
+		}
+	default:
+		return r.scalar(v)
+	}
+	list := make([]any, 0, len(items))
+	for _, item := range items {
+		if item, ok := r.scalar(item); ok {
+			list = append(list, item)
+		}
+	}
+	return list, true
+}
+
+func (r Rule) scalar(v any) (any, bool) {
+	if s, ok := v.(string); ok {
+		v = strings.TrimSpace(s)
+	}
+	switch r.Type {
+	case "int":
+		switch v := v.(type) {
+		case string:
+			n, err := strconv.ParseInt(v, 10, 64)
+			return n, err == nil
+		case int:
+			return int64(v), true
+		case int64:
+			return v, true
+		case float64:
+			return int64(v), v == math.Trunc(v)
+		case bool:
+			if v {
+				return int64(1), true
+			}
+			return int64(0), true
+		}
+	case "float":
+		switch v := v.(type) {
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil
+		case int:
+			return float64(v), true
+		case int64:
+			return float64(v), true
+		case float64:
+			return v, true
+		}
+	case "bool":
+		switch v := v.(type) {
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		case bool:
+			return v, true
+		case int64:
+			return v != 0, true
+		case float64:
+			return v != 0, true
+		}
+	case "string":
+		return fmt.Sprint(v), true
+	case "datetime":
+		return r.datetime(v)
+	}
+	return nil, false
+}
+
+func (r Rule) datetime(v any) (any, bool) {
+	switch r.Arg {
+	case "unix", "unixms":
+		var n float64
+		switch v := v.(type) {
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, false
+			}
+			n = f
+		case int64:
+			n = float64(v)
+		case float64:
+			n = v
+		default:
+			return nil, false
+		}
+		if r.Arg == "unixms" {
+			return time.UnixMilli(int64(n)).UTC(), true
+		}
+		sec, frac := math.Modf(n)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
+	}
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		layout := r.Arg
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, v)
+		return t, err == nil
+	}
+	return nil, false
+}
+
+// list splits a string on the separator, trimming the items and dropping
+// empty ones. Lists are kept; other values become a list of one.
+func (r Rule) list(v any) any {
+	switch v := v.(type) {
+	case []any, []string:
+		return v
+	case string:
+		sep := r.Arg
+		if sep == "" {
+			sep = ","
+		}
+		list := []any{}
+		for _, item := range strings.Split(v, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	default:
+		return []any{v}
+	}
+}
@@ -0,0 +1,122 @@
+package coerce
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Rule
+		err  string
+	}{
+		{spec: "int", want: Rule{Type: "int"}},
+		{spec: " bool ", want: Rule{Type: "bool"}},
+		{spec: "datetime", want: Rule{Type: "datetime"}},
+		{spec: "datetime:2006-01-02 15:04:05", want: Rule{Type: "datetime", Arg: "2006-01-02 15:04:05"}},
+		{spec: "list:;", want: Rule{Type: "list", Arg: ";"}},
+		{spec: "int:10", err: "takes no argument"},
+		{spec: "number", err: `unknown type "number"`},
+		{spec: "", err: "unknown type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRule(tt.spec)
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("ParseRule(%q) = %v, want %q", tt.spec, err, tt.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case got != tt.want:
+				t.Errorf("ParseRule(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	rules := Rules{}
+	for _, spec := range []string{"port=int", " seen = datetime:unix"} {
+		if err := rules.Set(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := Rules{"port": {Type: "int"}, "seen": {Type: "datetime", Arg: "unix"}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %v, want %v", rules, want)
+	}
+	for _, spec := range []string{"port", "=int", "port=number"} {
+		if err := rules.Set(spec); err == nil {
+			t.Errorf("Set(%q) = nil, want an error", spec)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		rule string
+		in   any
+		want any
+		ok   bool
+	}{
+		{"int from string", "int", " 443 ", int64(443), true},
+		{"int from float", "int", 443.0, int64(443), true},
+		{"int from fraction", "int", 1.5, nil, false},
+		{"int from bool", "int", true, int64(1), true},
+		{"int from text", "int", "https", nil, false},
+		{"float", "float", "0.5", 0.5, true},
+		{"float from int", "float", int64(2), 2.0, true},
+		{"bool", "bool", "true", true, true},
+		{"bool from number", "bool", int64(0), false, true},
+		{"bool from text", "bool", "yes", nil, false},
+		{"string", "string", int64(200), "200", true},
+		{"datetime", "datetime", "2024-05-01T12:30:00Z", at, true},
+		{"datetime layout", "datetime:2006-01-02 15:04", "2024-05-01 12:30", at, true},
+		{"datetime unix", "datetime:unix", "1714566600", at, true},
+		{"datetime unixms", "datetime:unixms", int64(1714566600000), at, true},
+		{"datetime invalid", "datetime", "yesterday", nil, false},
+		{"list", "list", "a, b,,c", []any{"a", "b", "c"}, true},
+		{"list separator", "list:;", "a;b", []any{"a", "b"}, true},
+		{"list of one", "list", int64(1), []any{int64(1)}, true},
+		{"list kept", "list", []any{"a,b"}, []any{"a,b"}, true},
+		{"items", "int", []any{"1", "x", 2.0}, []any{int64(1), int64(2)}, true},
+		{"string items", "int", []string{"80", "443"}, []any{int64(80), int64(443)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRule(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := r.Convert(tt.in)
+			if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("Convert(%#v) = %#v, %v, want %#v, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules, err := Parse(map[string]string{"port": "int", "status": "int", "cdn": "bool", "title": "string"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := map[string]any{"port": "443", "status": "n/a", "cdn": nil, "url": "https://a"}
+	rules.Apply(props)
+	// Wat niet te converteren is valt weg; null en onbekende properties blijven.
+	want := map[string]any{"port": int64(443), "cdn": nil, "url": "https://a"}
+	if !reflect.DeepEqual(props, want) {
+		t.Errorf("props = %v, want %v", props, want)
+	}
+
+	if _, err := Parse(map[string]string{"port": "integer"}); err == nil || !strings.HasPrefix(err.Error(), "port: ") {
+		t.Errorf("Parse = %v, want an error naming port", err)
+	}
+}
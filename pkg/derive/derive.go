@@ -0,0 +1,184 @@
+// Package derive computes properties from the fields of a record, such as an
+// environment derived from a naming convention, for environment-aware
+// queries without a pre-processing step.
+//
+// A rule sets a property when a field of the record matches a condition:
+//
+//	derive:
+//	  - name: env
+//	    when: input
+//	    matches: "^(prod|staging|dev)-"
+//	    value: "${1}"
+//	    else: unknown
+//	  - name: is_api
+//	    when: content_type
+//	    contains: json
+//
+// Without a value the property is a boolean: true when the condition holds
+// and false otherwise. Rules for the same property are tried in order; the
+// first that holds sets it.
+package derive
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Rule declares a derived property.
+type Rule struct {
+	Name string `yaml:"name"`
+	// Label selects the nodes that get the property; by default the main
+	// node of the record, e.g. the Host.
+	Label string `yaml:"label"`
+	// When is the field the condition applies to, dot-joined for nested
+	// fields.
+	When     string `yaml:"when"`
+	Matches  string `yaml:"matches"`
+	Contains string `yaml:"contains"`
+	Equals   string `yaml:"equals"`
+	// Value may refer to groups of Matches as ${1}.
+	Value *string `yaml:"value"`
+	Else  *string `yaml:"else"`
+
+	field []string
+	re    *regexp.Regexp
+}
+
+// Rules are compiled derive rules.
+type Rules struct {
+	rules []Rule
+}
+
+// Compile checks and compiles rules.
+func Compile(rules []Rule) (*Rules, error) {
+	for i := range rules {
+		r := &rules[i]
+		conditions := 0
+		for _, c := range []string{r.Matches, r.Contains, r.Equals} {
+			if c != "" {
+				conditions++
+			}
+		}
+		switch {
+		case r.Name == "":
+			return nil, fmt.Errorf("derive rule %d has no name", i+1)
+		case r.When == "":
+			return nil, fmt.Errorf("derive rule %s has no when field", r.Name)
+		case conditions != 1:
+			return nil, fmt.Errorf("derive rule %s needs one of matches, contains or equals", r.Name)
+		}
+		if r.Matches != "" {
+			var err error
+			if r.re, err = regexp.Compile(r.Matches); err != nil {
+				return nil, fmt.Errorf("derive rule %s: %w", r.Name, err)
+			}
+		}
+		r.field = strings.Split(r.When, ".")
+	}
+	return &Rules{rules: rules}, nil
+}
+
+// Names returns the names of the properties the rules derive for the nodes
+// with one of labels; "" is the main node.
+func (rs *Rules) Names(labels ...string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, r := range rs.rules {
+		if slices.Contains(labels, r.Label) && !seen[r.Name] {
+			seen[r.Name] = true
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+// Props is what the rules derive from a record, by label; "" is the main
+// node.
+type Props map[string]map[string]any
+
+// Apply evaluates the rules on a JSON record.
+func (rs *Rules) Apply(record []byte) (Props, error) {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	props := make(Props)
+	for _, r := range rs.rules {
+		if _, done := props[r.Label][r.Name]; done {
+			continue
+		}
+		v, ok := r.eval(doc)
+		if !ok {
+			continue
+		}
+		if props[r.Label] == nil {
+			props[r.Label] = make(map[string]any)
+		}
+		props[r.Label][r.Name] = v
+	}
+	// Else geldt pas als geen enkele regel voor de property raak was.
+	for _, r := range rs.rules {
+		if _, done := props[r.Label][r.Name]; done {
+			continue
+		}
+		var v any
+		switch {
+		case r.Else != nil:
+			v = *r.Else
+		case r.Value == nil:
+			v = false
+		default:
+			continue
+		}
+		if props[r.Label] == nil {
+			props[r.Label] = make(map[string]any)
+		}
+		props[r.Label][r.Name] = v
+	}
+	return props, nil
+}
+
+// eval returns the value the rule sets for doc, if any.
+func (r *Rule) eval(doc any) (any, bool) {
+	v := doc
+	for _, name := range r.field {
+		obj, _ := v.(map[string]any)
+		v = obj[name]
+	}
+	values, ok := v.([]any)
+	if !ok {
+		values = []any{v}
+	}
+
+	for _, item := range values {
+		if item == nil {
+			continue
+		}
+		s := fmt.Sprint(item)
+		switch {
+		case r.re != nil:
+			m := r.re.FindStringSubmatchIndex(s)
+			if m == nil {
+				continue
+			}
+			if r.Value == nil {
+				return true, true
+			}
+			return string(r.re.ExpandString(nil, *r.Value, s, m)), true
+		case r.Contains != "" && strings.Contains(strings.ToLower(s), strings.ToLower(r.Contains)),
+			r.Equals != "" && s == r.Equals:
+			if r.Value == nil {
+				return true, true
+			}
+			return *r.Value, true
+		}
+	}
+	return nil, false
+}
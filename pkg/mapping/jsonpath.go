@@ -0,0 +1,113 @@
+package mapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path is a compiled JSONPath. The supported subset covers what recon tool
+// output needs: $.field.nested, $.list[0] and $.list[*].field.
+type path struct {
+	expr     string
+	segments []segment
+	// wildcard is set when the path can match more than one value.
+	wildcard bool
+}
+
+type segment struct {
+	field string
+	index int
+	// all selects every item of a list ([*]).
+	all bool
+	// isIndex selects the item at index of a list ([n]).
+	isIndex bool
+}
+
+func compilePath(expr string) (path, error) {
+	p := path{expr: expr}
+	rest, ok := strings.CutPrefix(strings.TrimSpace(expr), "$")
+	if !ok {
+		return p, fmt.Errorf("invalid path %q: must start with $", expr)
+	}
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return p, fmt.Errorf("invalid path %q: empty field name", expr)
+			}
+			p.segments = append(p.segments, segment{field: rest[:end]})
+			rest = rest[end:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return p, fmt.Errorf("invalid path %q: missing ]", expr)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if inner == "*" {
+				p.segments = append(p.segments, segment{all: true})
+				p.wildcard = true
+				continue
+			}
+			if n, err := strconv.Atoi(inner); err == nil && n >= 0 {
+				p.segments = append(p.segments, segment{index: n, isIndex: true})
+				continue
+			}
+			if field, err := strconv.Unquote(strings.ReplaceAll(inner, "'", `"`)); err == nil {
+				p.segments = append(p.segments, segment{field: field})
+				continue
+			}
+			return p, fmt.Errorf("invalid path %q: unsupported selector [%s]", expr, inner)
+		default:
+			return p, fmt.Errorf("invalid path %q at %q", expr, rest)
+		}
+	}
+	return p, nil
+}
+
+// find returns the values the path matches in doc.
+func (p path) find(doc any) []any {
+	matches := []any{doc}
+	for _, seg := range p.segments {
+		var next []any
+		for _, m := range matches {
+			switch {
+			case seg.all:
+				if list, ok := m.([]any); ok {
+					next = append(next, list...)
+				}
+			case seg.isIndex:
+				if list, ok := m.([]any); ok && seg.index < len(list) {
+					next = append(next, list[seg.index])
+				}
+			default:
+				if obj, ok := m.(map[string]any); ok {
+					if v, ok := obj[seg.field]; ok {
+						next = append(next, v)
+					}
+				}
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// value returns what the path matches in doc as a property value: the single
+// match, a list for a wildcard path, or nil when nothing matches.
+func (p path) value(doc any) any {
+	matches := p.find(doc)
+	if p.wildcard {
+		return matches
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	return matches[0]
+}
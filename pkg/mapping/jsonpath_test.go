@@ -0,0 +1,74 @@
+package mapping
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompilePathErrors(t *testing.T) {
+	tests := []struct {
+		expr, want string
+	}{
+		{"host", "must start with $"},
+		{"$.", "empty field name"},
+		{"$.a..b", "empty field name"},
+		{"$.list[0", "missing ]"},
+		{"$.list[-1]", "unsupported selector"},
+		{"$.list[?(@.a)]", "unsupported selector"},
+		{"$host", "invalid path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := compilePath(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("compilePath(%q) = %v, want %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathValue(t *testing.T) {
+	doc, err := decode([]byte(`{
+		"host": "a.example.com",
+		"port": 443,
+		"asn": {"as_number": "AS64500"},
+		"a": ["10.0.0.1", "10.0.0.2"],
+		"ports": [{"port": 80}, {"port": 443}, {"proto": "udp"}],
+		"dotted.name": "x"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{"$", doc},
+		{"$.host", "a.example.com"},
+		{" $.host ", "a.example.com"},
+		{"$.port", int64(443)},
+		{"$.asn.as_number", "AS64500"},
+		{"$['asn']['as_number']", "AS64500"},
+		{`$["dotted.name"]`, "x"},
+		{"$.a[1]", "10.0.0.2"},
+		{"$.a[2]", nil},
+		{"$.a[*]", []any{"10.0.0.1", "10.0.0.2"}},
+		{"$.ports[*].port", []any{int64(80), int64(443)}},
+		{"$.missing", nil},
+		{"$.missing[*]", []any(nil)},
+		{"$.host.sub", nil},
+		{"$.host[0]", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := compilePath(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := propertyValue(p.value(doc)); !reflect.DeepEqual(got, propertyValue(tt.want)) {
+				t.Errorf("%s = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
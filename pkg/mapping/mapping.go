@@ -0,0 +1,370 @@
+// Package mapping maps JSON records onto graph nodes and relationships as
+// declared in a YAML mapping file, so the graph model can be adapted without
+// code changes. A Mapping is a parser.Parser.
+//
+// A mapping declares the nodes to create from every record, each with a
+// label, the key properties it is merged on and further properties, and the
+// relationships between them. Values are selected with JSONPath:
+//
+//	tool: dnsx
+//	detect: [$.host, $.a]
+//	nodes:
+//	  - label: Domain
+//	    key: {name: $.host}
+//	  - label: IP
+//	    key: {address: $.a[*]}
+//	relationships:
+//	  - type: RESOLVES_TO
+//	    from: Domain
+//	    to: IP
+//
+// A key path with [*] creates a node per match. Nodes whose key does not
+// match are skipped, with their relationships.
+//
+// A coerce section converts properties of every node and relationship to a
+// type, see package coerce:
+//
+//	coerce:
+//	  port: int
+//	  seen: datetime:2006-01-02 15:04:05
+package mapping
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pocahon/jsontoneo/pkg/coerce"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"gopkg.in/yaml.v2"
+)
+
+//go:embed profiles/*.yaml
+var profiles embed.FS
+
+// Mapping is a compiled mapping file.
+type Mapping struct {
+	Tool string `yaml:"tool"`
+	// DetectPaths lists the paths that must match for a line to be
+	// recognized as output of Tool.
+	DetectPaths   []string           `yaml:"detect"`
+	Nodes         []NodeRule         `yaml:"nodes"`
+	Relationships []RelationshipRule `yaml:"relationships"`
+	// Coerce maps property names to the type their values are converted to.
+	Coerce map[string]string `yaml:"coerce"`
+
+	detect []path
+	coerce coerce.Rules
+	nodes  []compiledNode
+	rels   []compiledRel
+}
+
+// NodeRule declares a node. Name, which defaults to the label, is how
+// relationships refer to it.
+type NodeRule struct {
+	Name       string            `yaml:"name"`
+	Label      string            `yaml:"label"`
+	Key        map[string]string `yaml:"key"`
+	Properties map[string]string `yaml:"properties"`
+}
+
+// RelationshipRule declares a relationship of Type from every node of rule
+// From to every node of rule To.
+type RelationshipRule struct {
+	Type       string            `yaml:"type"`
+	From       string            `yaml:"from"`
+	To         string            `yaml:"to"`
+	Properties map[string]string `yaml:"properties"`
+}
+
+type compiledNode struct {
+	name  string
+	label string
+	key   map[string]path
+	props map[string]path
+	// expand is the key property whose path has a wildcard, if any.
+	expand string
+}
+
+type compiledRel struct {
+	typ      string
+	from, to string
+	props    map[string]path
+}
+
+// Load reads a mapping file. A name without a path separator or extension
+// selects a built-in profile, see Profiles.
+func Load(name string) (*Mapping, error) {
+	if !strings.ContainsAny(name, `/\.`) {
+		return Profile(name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if m.Tool == "" {
+		m.Tool = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	return m, nil
+}
+
+// Profile returns the built-in mapping of a tool.
+func Profile(tool string) (*Mapping, error) {
+	data, err := profiles.ReadFile("profiles/" + tool + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("no built-in mapping profile %q (profiles: %s)", tool, strings.Join(Profiles(), ", "))
+	}
+	return Decode(data)
+}
+
+// Profiles returns the names of the built-in mapping profiles.
+func Profiles() []string {
+	entries, _ := profiles.ReadDir("profiles")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	return names
+}
+
+// Decode parses and compiles a mapping.
+func Decode(data []byte) (*Mapping, error) {
+	var m Mapping
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, err
+	}
+	if err := m.compile(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Mapping) compile() error {
+	if len(m.Nodes) == 0 {
+		return fmt.Errorf("mapping declares no nodes")
+	}
+	for _, expr := range m.DetectPaths {
+		p, err := compilePath(expr)
+		if err != nil {
+			return fmt.Errorf("detect: %w", err)
+		}
+		m.detect = append(m.detect, p)
+	}
+
+	var err error
+	if m.coerce, err = coerce.Parse(m.Coerce); err != nil {
+		return fmt.Errorf("coerce: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for _, rule := range m.Nodes {
+		n := compiledNode{name: rule.Name, label: rule.Label}
+		if n.name == "" {
+			n.name = rule.Label
+		}
+		switch {
+		case rule.Label == "":
+			return fmt.Errorf("node %q has no label", n.name)
+		case len(rule.Key) == 0:
+			return fmt.Errorf("node %s has no key", n.name)
+		case names[n.name]:
+			return fmt.Errorf("node %s is declared twice, give one a name", n.name)
+		}
+		names[n.name] = true
+
+		if n.key, err = compilePaths(rule.Key); err != nil {
+			return fmt.Errorf("node %s: key: %w", n.name, err)
+		}
+		if n.props, err = compilePaths(rule.Properties); err != nil {
+			return fmt.Errorf("node %s: %w", n.name, err)
+		}
+		for prop, p := range n.key {
+			if !p.wildcard {
+				continue
+			}
+			if n.expand != "" {
+				return fmt.Errorf("node %s: only one key property can have a [*] path", n.name)
+			}
+			n.expand = prop
+		}
+		m.nodes = append(m.nodes, n)
+	}
+
+	for _, rule := range m.Relationships {
+		switch {
+		case rule.Type == "":
+			return fmt.Errorf("relationship from %s to %s has no type", rule.From, rule.To)
+		case !names[rule.From]:
+			return fmt.Errorf("relationship %s: unknown node %q", rule.Type, rule.From)
+		case !names[rule.To]:
+			return fmt.Errorf("relationship %s: unknown node %q", rule.Type, rule.To)
+		}
+		props, err := compilePaths(rule.Properties)
+		if err != nil {
+			return fmt.Errorf("relationship %s: %w", rule.Type, err)
+		}
+		m.rels = append(m.rels, compiledRel{typ: rule.Type, from: rule.From, to: rule.To, props: props})
+	}
+	return nil
+}
+
+func compilePaths(exprs map[string]string) (map[string]path, error) {
+	paths := make(map[string]path, len(exprs))
+	for prop, expr := range exprs {
+		p, err := compilePath(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prop, err)
+		}
+		paths[prop] = p
+	}
+	return paths, nil
+}
+
+func (m *Mapping) Name() string { return m.Tool }
+
+// Detect reports whether all detect paths match line. A mapping without
+// detect paths is never detected and must be selected explicitly.
+func (m *Mapping) Detect(line []byte) bool {
+	if len(m.detect) == 0 {
+		return false
+	}
+	doc, err := decode(line)
+	if err != nil {
+		return false
+	}
+	for _, p := range m.detect {
+		if len(p.find(doc)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse maps a JSON line onto the nodes and relationships of the mapping.
+func (m *Mapping) Parse(line []byte) ([]model.Entity, []model.Relation, error) {
+	doc, err := decode(line)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entities []model.Entity
+	byName := make(map[string][]model.Entity)
+	for _, n := range m.nodes {
+		for _, key := range n.keys(doc) {
+			// Een sleutel die niet te converteren is, valt weg: dan geen node.
+			if m.coerce.Apply(key); len(key) < len(n.key) {
+				continue
+			}
+			e := model.Entity{Label: n.label, Key: key, Props: properties(n.props, doc)}
+			m.coerce.Apply(e.Props)
+			entities = append(entities, e)
+			byName[n.name] = append(byName[n.name], e)
+		}
+	}
+
+	var relations []model.Relation
+	for _, r := range m.rels {
+		for _, from := range byName[r.from] {
+			for _, to := range byName[r.to] {
+				props := properties(r.props, doc)
+				m.coerce.Apply(props)
+				relations = append(relations, model.Relation{
+					Type:  r.typ,
+					From:  from.Ref(),
+					To:    to.Ref(),
+					Props: props,
+				})
+			}
+		}
+	}
+	return entities, relations, nil
+}
+
+// keys returns the keys of the nodes the rule creates from doc: one, or one
+// per match of the wildcard key path. Keys with a missing or empty value are
+// left out.
+func (n compiledNode) keys(doc any) []map[string]any {
+	base := make(map[string]any, len(n.key))
+	for prop, p := range n.key {
+		if prop == n.expand {
+			continue
+		}
+		v := propertyValue(p.value(doc))
+		if isEmpty(v) {
+			return nil
+		}
+		base[prop] = v
+	}
+	if n.expand == "" {
+		return []map[string]any{base}
+	}
+
+	var keys []map[string]any
+	for _, match := range n.key[n.expand].find(doc) {
+		v := propertyValue(match)
+		if isEmpty(v) {
+			continue
+		}
+		key := maps.Clone(base)
+		key[n.expand] = v
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func properties(paths map[string]path, doc any) map[string]any {
+	props := make(map[string]any, len(paths))
+	for prop, p := range paths {
+		props[prop] = propertyValue(p.value(doc))
+	}
+	return props
+}
+
+func isEmpty(v any) bool {
+	return v == nil || v == ""
+}
+
+// decode parses a JSON line, keeping integers integers.
+func decode(line []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// propertyValue converts a JSON value into one Neo4j can store as a
+// property: numbers become int64 or float64, and objects, which Neo4j cannot
+// store, are kept as JSON strings.
+func propertyValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = propertyValue(item)
+		}
+		return list
+	case map[string]any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,168 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+const dnsxMapping = `
+tool: dnsx
+detect: [$.host, $.a]
+nodes:
+  - label: Domain
+    key: {name: $.host}
+    properties: {ttl: $.ttl, raw: $.raw}
+  - label: IP
+    key: {address: '$.a[*]'}
+relationships:
+  - type: RESOLVES_TO
+    from: Domain
+    to: IP
+    properties: {resolver: '$.resolver[0]'}
+coerce:
+  ttl: int
+`
+
+func TestParse(t *testing.T) {
+	m, err := Decode([]byte(dnsxMapping))
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(`{"host":"a.example.com","a":["10.0.0.1","","10.0.0.2"],"ttl":"300","raw":{"rcode":0},"resolver":["1.1.1.1:53"]}`)
+	if !m.Detect(line) {
+		t.Error("Detect = false, want true")
+	}
+	if m.Detect([]byte(`{"host":"a.example.com"}`)) {
+		t.Error("Detect without $.a = true, want false")
+	}
+
+	entities, relations, err := m.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain := model.Entity{Label: "Domain", Key: map[string]any{"name": "a.example.com"},
+		Props: map[string]any{"ttl": int64(300), "raw": `{"rcode":0}`}}
+	ip1 := model.Entity{Label: "IP", Key: map[string]any{"address": "10.0.0.1"}, Props: map[string]any{}}
+	ip2 := model.Entity{Label: "IP", Key: map[string]any{"address": "10.0.0.2"}, Props: map[string]any{}}
+	if want := []model.Entity{domain, ip1, ip2}; !reflect.DeepEqual(entities, want) {
+		t.Errorf("entities = %v, want %v", entities, want)
+	}
+	props := map[string]any{"resolver": "1.1.1.1:53"}
+	wantRels := []model.Relation{
+		{Type: "RESOLVES_TO", From: domain.Ref(), To: ip1.Ref(), Props: props},
+		{Type: "RESOLVES_TO", From: domain.Ref(), To: ip2.Ref(), Props: props},
+	}
+	if !reflect.DeepEqual(relations, wantRels) {
+		t.Errorf("relations = %v, want %v", relations, wantRels)
+	}
+
+	// Zonder sleutel geen Domain, en dus ook geen relaties.
+	entities, relations, err = m.Parse([]byte(`{"a":["10.0.0.1"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 || entities[0].Label != "IP" || len(relations) != 0 {
+		t.Errorf("without host: entities = %v, relations = %v", entities, relations)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"no nodes", "tool: x\n", "declares no nodes"},
+		{"no label", "nodes: [{key: {a: $.a}}]", "has no label"},
+		{"no key", "nodes: [{label: A}]", "node A has no key"},
+		{"twice", "nodes: [{label: A, key: {a: $.a}}, {label: A, key: {b: $.b}}]", "declared twice"},
+		{"bad path", "nodes: [{label: A, key: {a: a}}]", "node A: key: a: invalid path"},
+		{"two wildcards", "nodes: [{label: A, key: {a: '$.a[*]', b: '$.b[*]'}}]", "only one key property"},
+		{"unknown node", "nodes: [{label: A, key: {a: $.a}}]\nrelationships: [{type: R, from: A, to: B}]", `unknown node "B"`},
+		{"no type", "nodes: [{label: A, key: {a: $.a}}]\nrelationships: [{from: A, to: A}]", "has no type"},
+		{"bad coerce", "nodes: [{label: A, key: {a: $.a}}]\ncoerce: {a: number}", "coerce: a: unknown type"},
+		{"bad detect", "detect: [a]\nnodes: [{label: A, key: {a: $.a}}]", "detect: invalid path"},
+		{"unknown field", "nodes: [{label: A, key: {a: $.a}, props: {}}]", "field props not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "dnsx.yaml")
+	if err := os.WriteFile(file, []byte(strings.Replace(dnsxMapping, "tool: dnsx\n", "", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, tool, err string
+	}{
+		{name: "httpx", tool: "httpx"},
+		{name: file, tool: "dnsx"},
+		{name: "nuclei", err: `no built-in mapping profile "nuclei"`},
+		{name: filepath.Join(dir, "missing.yaml"), err: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.name), func(t *testing.T) {
+			m, err := Load(tt.name)
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Load(%q) = %v, want %q", tt.name, err, tt.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case m.Name() != tt.tool:
+				t.Errorf("Load(%q).Name() = %q, want %q", tt.name, m.Name(), tt.tool)
+			}
+		})
+	}
+}
+
+// TestProfiles checks every built-in profile compiles, and the httpx one
+// maps httpx output onto a Host and its ASN.
+func TestProfiles(t *testing.T) {
+	names := Profiles()
+	if !slices.Contains(names, "httpx") {
+		t.Fatalf("Profiles() = %v, want httpx among them", names)
+	}
+	for _, name := range names {
+		if _, err := Profile(name); err != nil {
+			t.Errorf("profile %s: %v", name, err)
+		}
+	}
+
+	m, err := Profile("httpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(`{"url":"https://a.example.com","input":"a.example.com","host":"10.0.0.1","status_code":200,"tech":["nginx"],"asn":{"as_number":"AS64500","as_name":"EXAMPLE"}}`)
+	if !m.Detect(line) {
+		t.Fatal("httpx profile does not detect httpx output")
+	}
+	entities, relations, err := m.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 || len(relations) != 1 {
+		t.Fatalf("entities = %v, relations = %v, want a Host, an ASN and BELONGS_TO", entities, relations)
+	}
+	host := entities[0]
+	if host.Label != "Host" || host.Key["url"] != "https://a.example.com" || host.Props["status"] != int64(200) || host.Props["ip"] != "10.0.0.1" {
+		t.Errorf("host = %v", host)
+	}
+	if rel := relations[0]; rel.Type != "BELONGS_TO" || rel.To.Key["number"] != "AS64500" {
+		t.Errorf("relation = %v", rel)
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+// Entity is a node a parser extracted from a record. It is merged on its
+// label and key properties; Props are set on every import.
+type Entity struct {
+	Label string
+	Key   map[string]any
+	Props map[string]any
+}
+
+// Ref returns a reference to the entity, for use in a Relation.
+func (e Entity) Ref() Ref {
+	return Ref{Label: e.Label, Key: e.Key}
+}
+
+// Ref identifies an entity by its label and key properties.
+type Ref struct {
+	Label string
+	Key   map[string]any
+}
+
+// Relation is a relationship of type Type from one entity to another.
+type Relation struct {
+	Type  string
+	From  Ref
+	To    Ref
+	Props map[string]any
+}
@@ -0,0 +1,57 @@
+// Package model holds the records jsontoneo maps onto the graph.
+package model
+
+import (
+	"net/url"
+	"strings"
+)
+
+type ASN struct {
+	ASNumber  string   `json:"as_number"`
+	ASName    string   `json:"as_name"`
+	ASCountry string   `json:"as_country"`
+	ASRange   []string `json:"as_range"`
+}
+
+// GeoIP is the location of the IP of a record, as added by -enrich geoip.
+type GeoIP struct {
+	Country string `json:"country"`
+	City    string `json:"city"`
+}
+
+// HttpxResult is a line of httpx JSON output.
+type HttpxResult struct {
+	Timestamp string   `json:"timestamp"`
+	ASN       ASN      `json:"asn"`
+	Port      string   `json:"port"`
+	URL       string   `json:"url"`
+	Input     string   `json:"input"`
+	Title     string   `json:"title"`
+	Scheme    string   `json:"scheme"`
+	Webserver string   `json:"webserver"`
+	Tech      []string `json:"tech"`
+	Host      string   `json:"host"`
+	Status    int      `json:"status_code"`
+	Words     int      `json:"words"`
+	Lines     int      `json:"lines"`
+	Resolvers []string `json:"resolvers"`
+	CNAME     []string `json:"cname"`
+	Favicon   string   `json:"favicon"`
+	Jarm      string   `json:"jarm_hash"`
+	A         []string `json:"a"`
+	CDN       *bool    `json:"cdn"` // nil when httpx did not report it
+	CDNName   string   `json:"cdn_name"`
+	GeoIP     GeoIP    `json:"geoip"`
+	// Extra holds the fields the struct does not cover, flattened to
+	// dot-joined names, when the parser was asked for them.
+	Extra map[string]any `json:"-"`
+}
+
+// Hostname returns the lowercased hostname of the URL, falling back to the
+// input when the URL has none.
+func (r *HttpxResult) Hostname() string {
+	if u, err := url.Parse(r.URL); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return strings.ToLower(r.Input)
+}
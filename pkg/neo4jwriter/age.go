@@ -0,0 +1,268 @@
+package neo4jwriter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// AGETarget writes to a graph in PostgreSQL with the Apache AGE extension,
+// as openCypher statements run through AGE's cypher() function. AGE has no
+// ON CREATE SET and a single label per vertex, so the target is written with
+// WriteGraph: every record is written in one PostgreSQL transaction, with a
+// lookup before each vertex and edge to tell creates from updates.
+//
+// The graph and its vertex and edge labels are created when first used. AGE
+// has no temporal types; times are stored as RFC 3339 strings in UTC.
+type AGETarget struct {
+	ctx   context.Context
+	pool  *pgxpool.Pool
+	graph string
+
+	mu     sync.Mutex
+	labels map[string]bool
+}
+
+// NewAGETarget connects to the PostgreSQL server of connString, a URL or
+// key/value string, and creates graph when it does not exist. An empty
+// connString uses the PG* environment variables.
+func NewAGETarget(ctx context.Context, connString, graph string) (*AGETarget, error) {
+	cfg, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
+	}
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		// Op beheerde servers is AGE vooraf geladen en mag LOAD niet.
+		_, _ = conn.Exec(ctx, "LOAD 'age'")
+		_, err := conn.Exec(ctx, `SET search_path = ag_catalog, "$user", public`)
+		return err
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	t := &AGETarget{ctx: ctx, pool: pool, graph: graph, labels: make(map[string]bool)}
+
+	var exists bool
+	err = pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM ag_catalog.ag_graph WHERE name = $1)", graph).Scan(&exists)
+	if err == nil && !exists {
+		_, err = pool.Exec(ctx, "SELECT ag_catalog.create_graph($1)", graph)
+	}
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("creating graph %s: %w", graph, err)
+	}
+	return t, nil
+}
+
+// Write returns ErrNoCypher: AGE is written with WriteGraph.
+func (t *AGETarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return Stats{}, ErrNoCypher
+}
+
+func (t *AGETarget) Close() error {
+	t.pool.Close()
+	return nil
+}
+
+// WriteGraph merges the vertices of g and then its edges in one transaction.
+func (t *AGETarget) WriteGraph(g Graph) (Stats, error) {
+	var stats Stats
+	for _, e := range g.Entities {
+		if err := t.ensureLabel(e.Label, false); err != nil {
+			return stats, fmt.Errorf("%s write error: %w", e.Label, err)
+		}
+	}
+	for _, r := range g.Relations {
+		if err := t.ensureLabel(r.Type, true); err != nil {
+			return stats, fmt.Errorf("%s write error: %w", r.Type, err)
+		}
+	}
+
+	tx, err := t.pool.Begin(t.ctx)
+	if err != nil {
+		return stats, err
+	}
+	defer tx.Rollback(t.ctx)
+	for _, e := range g.Entities {
+		created, err := t.mergeVertex(tx, e, g.OnCreate)
+		if err != nil {
+			return stats, fmt.Errorf("%s write error: %w", e.Label, err)
+		}
+		if created {
+			stats.NodesCreated++
+		}
+		stats.NodesMerged++
+		stats.PropertiesSet += len(e.Props)
+	}
+	now := time.Now().UTC()
+	for _, r := range g.Relations {
+		created, err := t.mergeEdge(tx, r, now)
+		if err != nil {
+			return stats, fmt.Errorf("%s write error: %w", r.Type, err)
+		}
+		if created {
+			stats.RelsCreated++
+		}
+		stats.RelsMerged++
+		stats.PropertiesSet += len(r.Props)
+	}
+	return stats, tx.Commit(t.ctx)
+}
+
+// mergeVertex creates e with the properties of onCreate, or updates the
+// properties of the existing vertex, and reports whether it was created.
+func (t *AGETarget) mergeVertex(tx pgx.Tx, e model.Entity, onCreate map[string]any) (bool, error) {
+	label := QuoteLabel(e.Label)
+	p := ageParams{}
+	match := fmt.Sprintf("MATCH (n:%s %s)", label, p.pattern(e.Key))
+	n, err := t.count(tx, match+" RETURN count(n)", p)
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		props := maps.Clone(onCreate)
+		if props == nil {
+			props = make(map[string]any)
+		}
+		maps.Copy(props, e.Props)
+		maps.Copy(props, e.Key)
+		p = ageParams{}
+		_, err := t.cypher(tx, fmt.Sprintf("CREATE (n:%s %s)", label, p.pattern(props)), p)
+		return true, err
+	}
+	if set := p.set("n", e.Props); set != "" {
+		_, err = t.cypher(tx, match+set, p)
+	}
+	return false, err
+}
+
+// mergeEdge creates r with a first_seen of now, or updates the properties of
+// the existing edge, and reports whether it was created. Nothing is written
+// when one of the vertices does not exist.
+func (t *AGETarget) mergeEdge(tx pgx.Tx, r model.Relation, now time.Time) (bool, error) {
+	p := ageParams{}
+	match := fmt.Sprintf("MATCH (a:%s %s), (b:%s %s)",
+		QuoteLabel(r.From.Label), p.pattern(r.From.Key), QuoteLabel(r.To.Label), p.pattern(r.To.Key))
+	rel := "(a)-[r:" + QuoteLabel(r.Type)
+	n, err := t.count(tx, match+" MATCH "+rel+"]->(b) RETURN count(r)", p)
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		props := maps.Clone(r.Props)
+		if props == nil {
+			props = make(map[string]any)
+		}
+		props["first_seen"] = now
+		n, err = t.count(tx, match+" CREATE "+rel+" "+p.pattern(props)+"]->(b) RETURN count(r)", p)
+		return n > 0, err
+	}
+	if set := p.set("r", r.Props); set != "" {
+		_, err = t.cypher(tx, match+" MATCH "+rel+"]->(b)"+set, p)
+	}
+	return false, err
+}
+
+// ensureLabel creates the vertex or edge label when it does not exist yet.
+func (t *AGETarget) ensureLabel(label string, edge bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.labels[label] {
+		return nil
+	}
+	var exists bool
+	err := t.pool.QueryRow(t.ctx, `SELECT EXISTS (
+		SELECT 1 FROM ag_catalog.ag_label l JOIN ag_catalog.ag_graph g ON g.graphid = l.graph
+		WHERE g.name = $1 AND l.name = $2)`, t.graph, label).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		create := "create_vlabel"
+		if edge {
+			create = "create_elabel"
+		}
+		if _, err := t.pool.Exec(t.ctx, "SELECT ag_catalog."+create+"($1, $2)", t.graph, label); err != nil {
+			return err
+		}
+	}
+	t.labels[label] = true
+	return nil
+}
+
+// cypher runs an openCypher statement returning at most one column and
+// returns its values as agtype text.
+func (t *AGETarget) cypher(tx pgx.Tx, query string, params ageParams) ([]string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	// De graafnaam moet voor cypher() een constante zijn, geen parameter.
+	sql := fmt.Sprintf("SELECT * FROM ag_catalog.cypher('%s', $jtn$ %s $jtn$, $1) AS (v ag_catalog.agtype)",
+		strings.ReplaceAll(t.graph, "'", "''"), query)
+	rows, err := tx.Query(t.ctx, sql, string(data))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// count runs a statement returning a single count.
+func (t *AGETarget) count(tx pgx.Tx, query string, params ageParams) (int, error) {
+	values, err := t.cypher(tx, query, params)
+	if err != nil || len(values) == 0 {
+		return 0, err
+	}
+	return strconv.Atoi(values[0])
+}
+
+// ageParams collects the parameters of a statement as $p0, $p1, ...
+type ageParams map[string]any
+
+func (p ageParams) add(v any) string {
+	name := "p" + strconv.Itoa(len(p))
+	p[name] = v
+	return "$" + name
+}
+
+// pattern returns the property map of a pattern, leaving out nil values.
+func (p ageParams) pattern(props map[string]any) string {
+	var parts []string
+	for _, k := range sortedKeys(props) {
+		if props[k] != nil {
+			parts = append(parts, QuoteLabel(k)+": "+p.add(props[k]))
+		}
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// set returns the SET and REMOVE clauses writing props to v, empty when
+// there are no props.
+func (p ageParams) set(v string, props map[string]any) string {
+	var sets, removes []string
+	for _, k := range sortedKeys(props) {
+		if props[k] == nil {
+			removes = append(removes, v+"."+QuoteLabel(k))
+		} else {
+			sets = append(sets, v+"."+QuoteLabel(k)+" = "+p.add(props[k]))
+		}
+	}
+	var clause string
+	if len(sets) > 0 {
+		clause += " SET " + strings.Join(sets, ", ")
+	}
+	if len(removes) > 0 {
+		clause += " REMOVE " + strings.Join(removes, ", ")
+	}
+	return clause
+}
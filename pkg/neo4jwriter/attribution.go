@@ -0,0 +1,42 @@
+package neo4jwriter
+
+import (
+	"os"
+	"os/user"
+)
+
+// Attribution records who loaded data into the graph, for an audit trail in
+// shared team graphs.
+type Attribution struct {
+	Operator string
+	Hostname string
+}
+
+// NewAttribution returns the attribution of this process. operator defaults
+// to the OS user.
+func NewAttribution(operator string) Attribution {
+	if operator == "" {
+		if u, err := user.Current(); err == nil {
+			operator = u.Username
+		} else {
+			operator = os.Getenv("USER")
+		}
+	}
+	hostname, _ := os.Hostname()
+	return Attribution{Operator: operator, Hostname: hostname}
+}
+
+// params returns the query parameters used by attributionCypher.
+func (a Attribution) params(toolVersion string) map[string]any {
+	return map[string]any{
+		"imported_by":   a.Operator,
+		"imported_from": a.Hostname,
+		"tool_version":  toolVersion,
+	}
+}
+
+// attributionCypher returns the assignments that stamp node v with the
+// attribution, for use in an ON CREATE SET clause.
+func attributionCypher(v string) string {
+	return v + ".imported_by = $imported_by, " + v + ".imported_from = $imported_from, " + v + ".tool_version = $tool_version"
+}
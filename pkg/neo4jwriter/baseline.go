@@ -0,0 +1,7 @@
+package neo4jwriter
+
+// baselineCypher labels the Host h :NewSinceBaseline when the Scan s was
+// imported with a baseline pinned and h was not seen in the baseline scan.
+const baselineCypher = `FOREACH (_ IN CASE WHEN s.baseline IS NULL OR EXISTS { (h)-[:SEEN_IN]->(:Scan {id: s.baseline}) } THEN [] ELSE [1] END |
+	    SET h:NewSinceBaseline)
+	`
@@ -0,0 +1,418 @@
+package neo4jwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// DgraphTarget writes to a Dgraph cluster over its HTTP API, with RDF
+// upsert blocks. Every node has a dgraph.type of its label and an xid of its
+// label and key, indexed for the upserts; properties are predicates of the
+// same name and relationships uid predicates with reverse edges.
+//
+// A record takes two upserts: the first creates the missing nodes with their
+// first_seen, the second sets their properties and relationships, so it is
+// not written atomically. Relationship properties are facets, which Dgraph
+// replaces on every write, so relationships have no first_seen. Lists of
+// strings become [string] predicates; other lists and maps are stored as
+// JSON.
+type DgraphTarget struct {
+	base   *url.URL
+	client *http.Client
+	token  string
+
+	mu     sync.Mutex
+	schema map[string]bool
+}
+
+// NewDgraphTarget returns a target for the Dgraph Alpha at rawURL, e.g.
+// http://localhost:8080. With a user and password in the URL it logs in to a
+// cluster with ACLs.
+func NewDgraphTarget(rawURL string) (*DgraphTarget, error) {
+	base, err := url.Parse(strings.TrimSuffix(rawURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	t := &DgraphTarget{
+		base:   base,
+		client: &http.Client{Timeout: time.Minute},
+		schema: make(map[string]bool),
+	}
+	if base.User != nil {
+		password, _ := base.User.Password()
+		var res struct {
+			Data struct {
+				AccessJWT string `json:"accessJWT"`
+			} `json:"data"`
+		}
+		body, _ := json.Marshal(map[string]string{"userid": base.User.Username(), "password": password})
+		if err := t.do("/login", "application/json", body, &res); err != nil {
+			return nil, fmt.Errorf("logging in: %w", err)
+		}
+		t.token = res.Data.AccessJWT
+		t.base.User = nil
+	}
+	if err := t.ensureSchema(map[string]string{"xid": "string @index(exact) @upsert"}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Write returns ErrNoCypher: Dgraph is written with WriteGraph.
+func (t *DgraphTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return Stats{}, ErrNoCypher
+}
+
+func (t *DgraphTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// WriteGraph creates the missing nodes of g, and then sets the properties of
+// all of them and the relationships between them.
+func (t *DgraphTarget) WriteGraph(g Graph) (Stats, error) {
+	var stats Stats
+	schema := make(map[string]string)
+	for _, e := range g.Entities {
+		for k, v := range e.Props {
+			if _, ok := dgraphStrings(v); ok {
+				schema[k] = "[string]"
+			}
+		}
+	}
+	for _, r := range g.Relations {
+		schema[r.Type] = "[uid] @reverse"
+	}
+	if err := t.ensureSchema(schema); err != nil {
+		return stats, fmt.Errorf("Schema write error: %w", err)
+	}
+
+	var vars strings.Builder
+	xids := make(map[string]string)
+	refs := make([]model.Ref, 0, len(g.Entities))
+	for _, e := range g.Entities {
+		refs = append(refs, e.Ref())
+	}
+	for _, r := range g.Relations {
+		refs = append(refs, r.From, r.To)
+	}
+	for _, ref := range refs {
+		xid := dgraphXid(ref)
+		if _, ok := xids[xid]; !ok {
+			xids[xid] = fmt.Sprintf("n%d", len(xids))
+			fmt.Fprintf(&vars, "    %s as var(func: eq(xid, %s))\n", xids[xid], dgraphString(xid))
+		}
+	}
+
+	// Eerst de ontbrekende nodes, zodat de tweede upsert ze allemaal vindt.
+	var create strings.Builder
+	create.WriteString("upsert {\n  query {\n" + vars.String())
+	for i, e := range g.Entities {
+		fmt.Fprintf(&create, "    q%d(func: uid(%s)) { uid }\n", i, xids[dgraphXid(e.Ref())])
+	}
+	create.WriteString("  }\n")
+	for _, e := range g.Entities {
+		v := xids[dgraphXid(e.Ref())]
+		fmt.Fprintf(&create, "  mutation @if(eq(len(%s), 0)) {\n    set {\n", v)
+		node := "uid(" + v + ")"
+		fmt.Fprintf(&create, "      %s <dgraph.type> %s .\n", node, dgraphString(e.Label))
+		fmt.Fprintf(&create, "      %s <xid> %s .\n", node, dgraphString(dgraphXid(e.Ref())))
+		dgraphTriples(&create, node, e.Key)
+		dgraphTriples(&create, node, g.OnCreate)
+		create.WriteString("    }\n  }\n")
+	}
+	create.WriteString("}\n")
+	queries, err := t.upsert(create.String())
+	if err != nil {
+		return stats, fmt.Errorf("Node write error: %w", err)
+	}
+	for i, e := range g.Entities {
+		if len(queries[fmt.Sprintf("q%d", i)]) == 0 {
+			stats.NodesCreated++
+		}
+		stats.NodesMerged++
+		stats.PropertiesSet += len(e.Props)
+	}
+
+	var update strings.Builder
+	update.WriteString("upsert {\n  query {\n" + vars.String())
+	for i, r := range g.Relations {
+		fmt.Fprintf(&update, "    r%d(func: uid(%s)) { %s @filter(uid(%s)) { uid } }\n",
+			i, xids[dgraphXid(r.From)], dgraphPredicate(r.Type), xids[dgraphXid(r.To)])
+	}
+	update.WriteString("  }\n  mutation {\n")
+	var deletes strings.Builder
+	for _, e := range g.Entities {
+		node := "uid(" + xids[dgraphXid(e.Ref())] + ")"
+		for _, k := range sortedKeys(e.Props) {
+			if _, ok := dgraphStrings(e.Props[k]); ok || e.Props[k] == nil {
+				fmt.Fprintf(&deletes, "      %s %s * .\n", node, dgraphPredicate(k))
+			}
+		}
+	}
+	if deletes.Len() > 0 {
+		update.WriteString("    delete {\n" + deletes.String() + "    }\n")
+	}
+	update.WriteString("    set {\n")
+	for _, e := range g.Entities {
+		dgraphTriples(&update, "uid("+xids[dgraphXid(e.Ref())]+")", e.Props)
+	}
+	update.WriteString("    }\n  }\n")
+	// Alleen bestaande nodes verbinden: een lege uid() zou een nieuwe node maken.
+	for _, r := range g.Relations {
+		from, to := xids[dgraphXid(r.From)], xids[dgraphXid(r.To)]
+		fmt.Fprintf(&update, "  mutation @if(gt(len(%s), 0) AND gt(len(%s), 0)) {\n    set {\n", from, to)
+		fmt.Fprintf(&update, "      uid(%s) %s uid(%s)%s .\n", from, dgraphPredicate(r.Type), to, dgraphFacets(r.Props))
+		update.WriteString("    }\n  }\n")
+	}
+	update.WriteString("}\n")
+	queries, err = t.upsert(update.String())
+	if err != nil {
+		return stats, fmt.Errorf("Relationship write error: %w", err)
+	}
+	for i, r := range g.Relations {
+		if len(queries[fmt.Sprintf("r%d", i)]) == 0 {
+			stats.RelsCreated++
+		}
+		stats.RelsMerged++
+		stats.PropertiesSet += len(r.Props)
+	}
+	return stats, nil
+}
+
+// upsert commits an RDF upsert block and returns the results of its named
+// query blocks, as they were before the mutations.
+func (t *DgraphTarget) upsert(block string) (map[string][]map[string]any, error) {
+	var res struct {
+		Data struct {
+			Queries map[string][]map[string]any `json:"queries"`
+		} `json:"data"`
+	}
+	err := t.do("/mutate?commitNow=true", "application/rdf", []byte(block), &res)
+	return res.Data.Queries, err
+}
+
+// ensureSchema declares the predicates of schema, name to type and
+// directives, that have not been declared yet. Predicates that already have
+// a schema of the same kind are left alone, keeping the indexes users added.
+func (t *DgraphTarget) ensureSchema(schema map[string]string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var names []string
+	for name := range schema {
+		if !t.schema[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	var res struct {
+		Data struct {
+			Schema []struct {
+				Predicate string `json:"predicate"`
+				Type      string `json:"type"`
+				List      bool   `json:"list"`
+				Upsert    bool   `json:"upsert"`
+			} `json:"schema"`
+		} `json:"data"`
+	}
+	query := "schema(pred: [" + strings.Join(names, ", ") + "]) { type list upsert }"
+	if err := t.do("/query", "application/dql", []byte(query), &res); err != nil {
+		return err
+	}
+	current := make(map[string]bool)
+	for _, s := range res.Data.Schema {
+		kind := s.Type
+		if s.List {
+			kind = "[" + kind + "]"
+		}
+		want := schema[s.Predicate]
+		current[s.Predicate] = kind == strings.Fields(want)[0] && (s.Upsert || !strings.Contains(want, "@upsert"))
+	}
+
+	var alter strings.Builder
+	for _, name := range names {
+		if !current[name] {
+			fmt.Fprintf(&alter, "%s: %s .\n", dgraphPredicate(name), schema[name])
+		}
+	}
+	if alter.Len() > 0 {
+		if err := t.do("/alter", "application/dql", []byte(alter.String()), nil); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		t.schema[name] = true
+	}
+	return nil
+}
+
+// dgraphError is an error returned by the Dgraph API.
+type dgraphError struct {
+	Message string `json:"message"`
+}
+
+// do posts body to path and decodes the response into out.
+func (t *DgraphTarget) do(path, contentType string, body []byte, out any) error {
+	req, err := http.NewRequest(http.MethodPost, t.base.String()+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if t.token != "" {
+		req.Header.Set("X-Dgraph-AccessToken", t.token)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Errors []dgraphError `json:"errors"`
+	}
+	if json.Unmarshal(data, &res) == nil && len(res.Errors) > 0 {
+		return fmt.Errorf("Dgraph: %s", res.Errors[0].Message)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Dgraph: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// dgraphXid returns the xid of ref: its label and key as JSON.
+func dgraphXid(ref model.Ref) string {
+	key, _ := json.Marshal(ref.Key)
+	return ref.Label + ":" + string(key)
+}
+
+func dgraphPredicate(name string) string {
+	return "<" + strings.NewReplacer("<", "", ">", "", " ", "_").Replace(name) + ">"
+}
+
+// dgraphTriples writes a triple for every property of node, several for a
+// list of strings and none for nil.
+func dgraphTriples(b *strings.Builder, node string, props map[string]any) {
+	for _, k := range sortedKeys(props) {
+		v := props[k]
+		if v == nil {
+			continue
+		}
+		if list, ok := dgraphStrings(v); ok {
+			for _, s := range list {
+				fmt.Fprintf(b, "      %s %s %s .\n", node, dgraphPredicate(k), dgraphString(s))
+			}
+			continue
+		}
+		fmt.Fprintf(b, "      %s %s %s .\n", node, dgraphPredicate(k), dgraphLiteral(v))
+	}
+}
+
+// dgraphStrings returns v as a list of strings, when it is one.
+func dgraphStrings(v any) ([]string, bool) {
+	switch v := v.(type) {
+	case []string:
+		return v, true
+	case []any:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			list[i] = s
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// dgraphLiteral returns v as a typed RDF literal.
+func dgraphLiteral(v any) string {
+	switch v := v.(type) {
+	case string:
+		return dgraphString(v)
+	case bool:
+		return `"` + strconv.FormatBool(v) + `"^^<xs:boolean>`
+	case int, int32, int64:
+		return fmt.Sprintf(`"%d"^^<xs:int>`, v)
+	case float32, float64:
+		return fmt.Sprintf(`"%v"^^<xs:float>`, v)
+	case time.Time:
+		return `"` + v.UTC().Format(time.RFC3339Nano) + `"^^<xs:dateTime>`
+	default:
+		b, _ := json.Marshal(v)
+		return dgraphString(string(b))
+	}
+}
+
+// dgraphFacets returns props as the facets of an edge. Facets cannot hold
+// lists or maps, so those are stored as JSON.
+func dgraphFacets(props map[string]any) string {
+	var facets []string
+	for _, k := range sortedKeys(props) {
+		var value string
+		switch v := props[k].(type) {
+		case nil:
+			continue
+		case string:
+			value = dgraphString(v)
+		case bool, int, int32, int64, float32, float64:
+			value = fmt.Sprint(v)
+		case time.Time:
+			value = v.UTC().Format(time.RFC3339Nano)
+		default:
+			b, _ := json.Marshal(v)
+			value = dgraphString(string(b))
+		}
+		facets = append(facets, strings.NewReplacer("(", "", ")", "", ",", "", "=", "", " ", "_").Replace(k)+"="+value)
+	}
+	if len(facets) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(facets, ", ") + ")"
+}
+
+// dgraphString quotes s for RDF and DQL.
+func dgraphString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package neo4jwriter
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// WriteEntities writes the entities and relations of a parser that does not
+// produce httpx records. Every entity is merged on its label and key, and
+// linked to the Scan with SEEN_IN; the relations are merged between them.
+func (w *Writer) WriteEntities(tx Runner, entities []model.Entity, relations []model.Relation) (Stats, error) {
+	var stats Stats
+
+	for _, e := range entities {
+		w.Coerce.Apply(e.Key)
+		w.Coerce.Apply(e.Props)
+		key, params := keyCypher(e.Key, "key")
+		query := `
+	MERGE (n:` + QuoteLabel(e.Label) + ` ` + projectKey(key) + `)
+	ON CREATE SET n.first_seen = datetime()` + w.attributionCypher("n") + `
+	SET n += $props, n.last_seen = datetime()` + expiryCypher("n", w.TTL) + `
+	` + tagCypher("n", w.Tags, w.TagLabels) + `
+	WITH n
+	MATCH (s:Scan {id: $scan_id})
+	MERGE (n)-[:SEEN_IN]->(s)
+	`
+		params["props"] = props(e.Props)
+		params["scan_id"] = w.ScanID
+		params["tags"] = w.Tags
+		params["project"] = w.Project
+		res, err := tx.Run(query, w.params(params))
+		if err != nil {
+			return stats, fmt.Errorf("%s query error: %w", e.Label, err)
+		}
+		if err := stats.consume(res, 1, 1); err != nil {
+			return stats, fmt.Errorf("%s query error: %w", e.Label, err)
+		}
+	}
+
+	for _, r := range relations {
+		w.Coerce.Apply(r.From.Key)
+		w.Coerce.Apply(r.To.Key)
+		w.Coerce.Apply(r.Props)
+		from, params := keyCypher(r.From.Key, "from")
+		to, toParams := keyCypher(r.To.Key, "to")
+		maps.Copy(params, toParams)
+		query := `
+	MATCH (a:` + QuoteLabel(r.From.Label) + ` ` + projectKey(from) + `)
+	MATCH (b:` + QuoteLabel(r.To.Label) + ` ` + projectKey(to) + `)
+	MERGE (a)-[r:` + QuoteLabel(r.Type) + `]->(b)
+	SET r += $props
+	`
+		params["props"] = props(r.Props)
+		params["project"] = w.Project
+		res, err := tx.Run(query, params)
+		if err != nil {
+			return stats, fmt.Errorf("%s query error: %w", r.Type, err)
+		}
+		if err := stats.consume(res, 0, 1); err != nil {
+			return stats, fmt.Errorf("%s query error: %w", r.Type, err)
+		}
+	}
+
+	return stats, nil
+}
+
+// props returns p, or an empty map for SET += when p is nil.
+func props(p map[string]any) map[string]any {
+	if p == nil {
+		return map[string]any{}
+	}
+	return p
+}
+
+// keyCypher returns the key properties of an entity for projectKey, with
+// their values as the parameters prefix_0, prefix_1 and so on.
+func keyCypher(key map[string]any, prefix string) (string, map[string]any) {
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	props := make([]string, len(names))
+	params := make(map[string]any, len(names))
+	for i, name := range names {
+		param := fmt.Sprintf("%s_%d", prefix, i)
+		props[i] = QuoteLabel(name) + ": $" + param
+		params[param] = key[name]
+	}
+	return strings.Join(props, ", "), params
+}
@@ -0,0 +1,21 @@
+package neo4jwriter
+
+import (
+	"fmt"
+	"time"
+)
+
+// expiryCypher returns the assignment that sets expires_at of node v to ttl
+// from now, for use in a SET clause, or "" without a ttl. The query needs the
+// $ttl parameter from ttlParam.
+func expiryCypher(v string, ttl time.Duration) string {
+	if ttl <= 0 {
+		return ""
+	}
+	return ", " + v + ".expires_at = datetime() + duration($ttl)"
+}
+
+// ttlParam returns ttl as an ISO 8601 duration for Cypher's duration().
+func ttlParam(ttl time.Duration) string {
+	return fmt.Sprintf("PT%dS", int64(ttl.Seconds()))
+}
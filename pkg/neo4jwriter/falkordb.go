@@ -0,0 +1,192 @@
+package neo4jwriter
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/redis/go-redis/v9"
+)
+
+// FalkorDBTarget writes to a FalkorDB (or RedisGraph) graph with
+// GRAPH.QUERY. Every statement is atomic on its own, but the statements of
+// a Write are not atomic together.
+//
+// FalkorDB has no datetime type: datetime() becomes timestamp(), so times
+// are stored as milliseconds since the epoch, as are times in parameters
+// and TTLs. EXISTS subqueries become pattern comprehensions.
+type FalkorDBTarget struct {
+	ctx    context.Context
+	client *redis.Client
+	graph  string
+}
+
+// NewFalkorDBTarget returns a target writing to graph on the FalkorDB server
+// of client. Closing the target closes client.
+func NewFalkorDBTarget(ctx context.Context, client *redis.Client, graph string) *FalkorDBTarget {
+	return &FalkorDBTarget{ctx: ctx, client: client, graph: graph}
+}
+
+func (t *FalkorDBTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return work(t)
+}
+
+func (t *FalkorDBTarget) Close() error {
+	return t.client.Close()
+}
+
+// Run runs the statement with its parameters inlined in a CYPHER prefix.
+func (t *FalkorDBTarget) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	query := FalkorDBCypher(cypher)
+	if len(params) > 0 {
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString("CYPHER")
+		for _, name := range names {
+			b.WriteString(" " + name + "=" + cypherLiteral(falkorValue(name, params[name])))
+		}
+		query = b.String() + " " + query
+	}
+	reply, err := t.client.Do(t.ctx, "GRAPH.QUERY", t.graph, query, "--compact").Slice()
+	if err != nil {
+		return nil, err
+	}
+	return falkorResult(reply), nil
+}
+
+var durationParam = regexp.MustCompile(`\bduration\((\$[A-Za-z_][A-Za-z0-9_]*)\)`)
+
+// FalkorDBCypher rewrites a statement for FalkorDB, leaving string literals
+// as they are.
+func FalkorDBCypher(cypher string) string {
+	return outsideLiterals(cypher, func(cypher string) string {
+		cypher = datetimeNow.ReplaceAllString(cypher, "timestamp()")
+		cypher = durationParam.ReplaceAllString(cypher, "$1")
+		return existsPattern.ReplaceAllString(cypher, "size([$1 | 1]) > 0")
+	})
+}
+
+// falkorValue converts times to milliseconds since the epoch and the ISO
+// 8601 $ttl of expiryCypher to milliseconds.
+func falkorValue(name string, v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UnixMilli()
+	case string:
+		if name == "ttl" && strings.HasPrefix(v, "PT") {
+			secs, err := strconv.ParseInt(strings.TrimSuffix(v[2:], "S"), 10, 64)
+			if err == nil {
+				return secs * 1000
+			}
+		}
+		return v
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = falkorValue("", item)
+		}
+		return list
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[k] = falkorValue("", item)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// Value types of a compact GRAPH.QUERY reply.
+const (
+	falkorNull    = 1
+	falkorString  = 2
+	falkorInteger = 3
+	falkorBoolean = 4
+	falkorDouble  = 5
+	falkorArray   = 6
+)
+
+// falkorResult decodes a compact GRAPH.QUERY reply: a header, rows and
+// statistics, or only statistics for a statement that returns nothing.
+func falkorResult(reply []any) *rowsResult {
+	var keys []string
+	var rows [][]any
+	if len(reply) == 3 {
+		header, _ := reply[0].([]any)
+		for _, column := range header {
+			if c, ok := column.([]any); ok && len(c) == 2 {
+				name, _ := c[1].(string)
+				keys = append(keys, name)
+			}
+		}
+		records, _ := reply[1].([]any)
+		for _, record := range records {
+			values, _ := record.([]any)
+			row := make([]any, len(values))
+			for i, v := range values {
+				row[i] = falkorScalar(v)
+			}
+			rows = append(rows, row)
+		}
+	}
+	res := newRowsResult(keys, rows)
+	if len(reply) == 0 {
+		return res
+	}
+	stats, _ := reply[len(reply)-1].([]any)
+	for _, s := range stats {
+		line, _ := s.(string)
+		name, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		n, _ := strconv.Atoi(value)
+		switch name {
+		case "Nodes created":
+			res.counters.nodes = n
+		case "Relationships created":
+			res.counters.rels = n
+		case "Properties set":
+			res.counters.props = n
+		}
+	}
+	return res
+}
+
+// falkorScalar decodes a [type, value] pair of a compact reply. Nodes, edges
+// and other structures are returned as nil.
+func falkorScalar(v any) any {
+	pair, ok := v.([]any)
+	if !ok || len(pair) != 2 {
+		return nil
+	}
+	typ, _ := pair[0].(int64)
+	switch typ {
+	case falkorString, falkorInteger:
+		return pair[1]
+	case falkorBoolean:
+		return pair[1] == "true"
+	case falkorDouble:
+		s, _ := pair[1].(string)
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	case falkorArray:
+		items, _ := pair[1].([]any)
+		list := make([]any, len(items))
+		for i, item := range items {
+			list[i] = falkorScalar(item)
+		}
+		return list
+	default:
+		return nil
+	}
+}
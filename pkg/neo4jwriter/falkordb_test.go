@@ -0,0 +1,54 @@
+package neo4jwriter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFalkorDBCypher(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "datetime",
+			in:   "SET h.last_seen = datetime()" + expiryCypher("h", time.Hour),
+			want: "SET h.last_seen = timestamp(), h.expires_at = timestamp() + $ttl",
+		},
+		{
+			name: "literal",
+			in:   "SET h.note = 'datetime() or duration($ttl)', h.seen = datetime()",
+			want: "SET h.note = 'datetime() or duration($ttl)', h.seen = timestamp()",
+		},
+		{
+			name: "exists",
+			in:   baselineCypher,
+			want: "FOREACH (_ IN CASE WHEN s.baseline IS NULL OR size([(h)-[:SEEN_IN]->(:Scan {id: s.baseline}) | 1]) > 0 THEN [] ELSE [1] END |\n\t    SET h:NewSinceBaseline)\n\t",
+		},
+		{
+			name: "exists in literal",
+			in:   "WHERE h.title <> 'EXISTS { (h) }'",
+			want: "WHERE h.title <> 'EXISTS { (h) }'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FalkorDBCypher(tt.in); got != tt.want {
+				t.Errorf("FalkorDBCypher(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFalkorValue(t *testing.T) {
+	at := time.UnixMilli(1714566600000)
+	if got := falkorValue("", at); got != int64(1714566600000) {
+		t.Errorf("falkorValue(time) = %v", got)
+	}
+	if got := falkorValue("ttl", ttlParam(time.Hour)); got != int64(3600000) {
+		t.Errorf("falkorValue(ttl) = %v", got)
+	}
+	list, _ := falkorValue("", []any{at, "PT5S"}).([]any)
+	if len(list) != 2 || list[0] != int64(1714566600000) || list[1] != "PT5S" {
+		t.Errorf("falkorValue(list) = %v", list)
+	}
+}
@@ -0,0 +1,104 @@
+package neo4jwriter
+
+import (
+	"errors"
+	"sync"
+)
+
+// FanoutTarget writes to a primary target and a copy of every write to its
+// mirrors, such as a Memgraph mirror or a backup script next to Neo4j. The
+// result of a Write is that of the primary; the mirrors fail independently,
+// so a mirror that is down does not stop the import, nor does a failing
+// primary stop the mirrors. A mirror that fails MirrorFailureLimit writes in
+// a row is given up on for the rest of the import, so that a mirror that is
+// down does not slow every write down with retries; the writes it misses
+// count as failures. All targets must run Cypher.
+type FanoutTarget struct {
+	primary Target
+	onError func(mirror string, err error)
+
+	mu       sync.Mutex
+	mirrors  []*mirror
+	failures map[string]int
+}
+
+// MirrorFailureLimit is the number of consecutive failed writes after which
+// a FanoutTarget stops writing to a mirror.
+const MirrorFailureLimit = 10
+
+// ErrMirrorDown is passed to the onError of a FanoutTarget when it stops
+// writing to a mirror.
+var ErrMirrorDown = errors.New("too many consecutive failures, no longer writing to the mirror")
+
+type mirror struct {
+	name  string
+	t     Target
+	inRow int
+}
+
+// NewFanoutTarget returns a target writing to primary. onError, when not
+// nil, is called for every failed mirror write.
+func NewFanoutTarget(primary Target, onError func(mirror string, err error)) *FanoutTarget {
+	return &FanoutTarget{primary: primary, onError: onError, failures: make(map[string]int)}
+}
+
+// AddMirror adds a mirror, named in errors and Failures. Closing the fan-out
+// closes it.
+func (t *FanoutTarget) AddMirror(name string, target Target) {
+	t.mirrors = append(t.mirrors, &mirror{name: name, t: target})
+}
+
+// Write runs work on the primary and then on every mirror.
+func (t *FanoutTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	stats, err := t.primary.Write(work)
+	for _, m := range t.mirrors {
+		t.mu.Lock()
+		down := m.inRow >= MirrorFailureLimit
+		if down {
+			t.failures[m.name]++
+		}
+		t.mu.Unlock()
+		if down {
+			continue
+		}
+
+		_, merr := m.t.Write(work)
+		t.mu.Lock()
+		if merr == nil {
+			m.inRow = 0
+		} else {
+			m.inRow++
+			t.failures[m.name]++
+		}
+		inRow := m.inRow
+		t.mu.Unlock()
+		if merr != nil && t.onError != nil {
+			t.onError(m.name, merr)
+			if inRow == MirrorFailureLimit {
+				t.onError(m.name, ErrMirrorDown)
+			}
+		}
+	}
+	return stats, err
+}
+
+// Failures returns the number of failed writes per mirror, for the mirrors
+// that had any.
+func (t *FanoutTarget) Failures() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	failures := make(map[string]int, len(t.failures))
+	for name, n := range t.failures {
+		failures[name] = n
+	}
+	return failures
+}
+
+// Close closes the primary and the mirrors.
+func (t *FanoutTarget) Close() error {
+	errs := []error{t.primary.Close()}
+	for _, m := range t.mirrors {
+		errs = append(errs, m.t.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,99 @@
+package neo4jwriter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// flakyTarget counts the writes and closes it gets, and fails them with err.
+type flakyTarget struct {
+	err    error
+	writes int
+	closed bool
+}
+
+func (t *flakyTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	t.writes++
+	if t.err != nil {
+		return Stats{}, t.err
+	}
+	return work(&recordTarget{})
+}
+
+func (t *flakyTarget) Close() error {
+	t.closed = true
+	return t.err
+}
+
+func TestFanoutTarget(t *testing.T) {
+	down := errors.New("connection refused")
+	primary, good, bad := &flakyTarget{}, &flakyTarget{}, &flakyTarget{err: down}
+	var errs []error
+	fanout := NewFanoutTarget(primary, func(mirror string, err error) {
+		if mirror != "bad" {
+			t.Errorf("error for mirror %s: %v", mirror, err)
+		}
+		errs = append(errs, err)
+	})
+	fanout.AddMirror("good", good)
+	fanout.AddMirror("bad", bad)
+
+	work := func(r Runner) (Stats, error) { return Stats{NodesCreated: 1}, nil }
+	writes := MirrorFailureLimit + 5
+	for range writes {
+		stats, err := fanout.Write(work)
+		if err != nil || stats.NodesCreated != 1 {
+			t.Fatalf("Write = %v, %v, want the stats of the primary", stats, err)
+		}
+	}
+
+	if primary.writes != writes || good.writes != writes {
+		t.Errorf("primary and good mirror got %d and %d writes, want %d", primary.writes, good.writes, writes)
+	}
+	if bad.writes != MirrorFailureLimit {
+		t.Errorf("bad mirror got %d writes, want %d before it is given up on", bad.writes, MirrorFailureLimit)
+	}
+	if want := map[string]int{"bad": writes}; !reflect.DeepEqual(fanout.Failures(), want) {
+		t.Errorf("Failures = %v, want %v", fanout.Failures(), want)
+	}
+	if len(errs) != MirrorFailureLimit+1 || errs[len(errs)-1] != ErrMirrorDown {
+		t.Errorf("onError got %v, want %d write errors and ErrMirrorDown", errs, MirrorFailureLimit)
+	}
+
+	if err := fanout.Close(); !errors.Is(err, down) {
+		t.Errorf("Close = %v, want the error of the bad mirror", err)
+	}
+	if !primary.closed || !good.closed || !bad.closed {
+		t.Error("Close did not close every target")
+	}
+}
+
+// TestFanoutPrimaryFails checks that a failing primary does not stop the
+// mirrors, and that a mirror that recovers starts counting again.
+func TestFanoutPrimaryFails(t *testing.T) {
+	failed := errors.New("primary down")
+	primary, mirror := &flakyTarget{err: failed}, &flakyTarget{err: errors.New("timeout")}
+	fanout := NewFanoutTarget(primary, nil)
+	fanout.AddMirror("mirror", mirror)
+
+	work := func(r Runner) (Stats, error) { return Stats{}, nil }
+	for range MirrorFailureLimit - 1 {
+		if _, err := fanout.Write(work); !errors.Is(err, failed) {
+			t.Fatalf("Write = %v, want the error of the primary", err)
+		}
+	}
+	mirror.err = nil
+	for range 3 {
+		fanout.Write(work)
+	}
+	mirror.err = errors.New("timeout")
+	fanout.Write(work)
+
+	if mirror.writes != MirrorFailureLimit+3 {
+		t.Errorf("mirror got %d writes, want %d", mirror.writes, MirrorFailureLimit+3)
+	}
+	if n := fanout.Failures()["mirror"]; n != MirrorFailureLimit {
+		t.Errorf("%d failures, want %d", n, MirrorFailureLimit)
+	}
+}
@@ -0,0 +1,202 @@
+package neo4jwriter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// hostFields are the Host properties that can be selected with -only-fields
+// and -skip-fields; "asn" controls the ASN node and its relationship. The url
+// is the key of a Host and is always written.
+var hostFields = []string{
+	"input", "ip", "port", "title", "scheme", "webserver", "status",
+	"words", "lines", "tech", "resolvers", "cname", "favicon", "jarm", "a", "cdn", "cdn_name", "country", "city", "timestamp", "asn",
+}
+
+// fieldAliases maps httpx JSON field names to the property they are stored as.
+var fieldAliases = map[string]string{
+	"host":        "ip",
+	"status_code": "status",
+	"jarm_hash":   "jarm",
+}
+
+// FieldSelection decides which Host properties are written, and which are
+// written hashed. The zero value keeps every field as is.
+//
+// Besides the known fields it selects the properties -flatten-extra writes,
+// by their dotted name (header.set_cookie) or a prefix (header.*), and
+// derived properties by name. -only-fields only narrows the known fields:
+// extra and derived properties are written unless skipped.
+type FieldSelection struct {
+	only fieldSet
+	skip fieldSet
+	hash fieldSet
+	// hashKey, when set, makes the hashes HMACs so they cannot be reversed
+	// by hashing guessed values.
+	hashKey []byte
+}
+
+// fieldSet is a set of property names and name prefixes.
+type fieldSet struct {
+	names    map[string]bool
+	prefixes []string
+}
+
+func (s fieldSet) has(name string) bool {
+	if s.names[name] {
+		return true
+	}
+	for _, p := range s.prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s fieldSet) empty() bool {
+	return len(s.names) == 0 && len(s.prefixes) == 0
+}
+
+// NewFieldSelection writes only the fields in only, or all but the ones in
+// skip, and hashes the fields in hash. With a hashKey the hashes are HMACs.
+// derived are the names of derived properties, which skip and hash accept
+// as well.
+func NewFieldSelection(only, skip, hash []string, hashKey []byte, derived ...string) (FieldSelection, error) {
+	var sel FieldSelection
+	var err error
+	if sel.only, err = newFieldSet(only, derived); err != nil {
+		return sel, err
+	}
+	if sel.skip, err = newFieldSet(skip, derived); err != nil {
+		return sel, err
+	}
+	if sel.hash, err = newFieldSet(hash, derived); err != nil {
+		return sel, err
+	}
+	if len(sel.only.prefixes) > 0 {
+		return sel, fmt.Errorf("only fields select known fields; extra and derived properties are always written, leave them out with skip fields")
+	}
+	for name := range sel.only.names {
+		if !knownField(name) {
+			return sel, fmt.Errorf("only fields select known fields; extra and derived properties such as %q are always written, leave them out with skip fields", name)
+		}
+	}
+	// Naast only mag skip alleen extra en afgeleide properties weglaten.
+	if !sel.only.empty() {
+		for name := range sel.skip.names {
+			if knownField(name) {
+				return sel, fmt.Errorf("only and skip fields cannot be combined for known fields (%s)", name)
+			}
+		}
+	}
+	if sel.hash.has("asn") {
+		return sel, fmt.Errorf("the asn field cannot be hashed")
+	}
+	sel.hashKey = hashKey
+	return sel, nil
+}
+
+// newFieldSet parses field names: known fields and their httpx aliases,
+// dotted extra names, extra prefixes ending in .* and the derived names.
+func newFieldSet(names, derived []string) (fieldSet, error) {
+	var set fieldSet
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !strings.Contains(name, ".") {
+			name = strings.ToLower(name)
+		}
+		if alias, ok := fieldAliases[name]; ok {
+			name = alias
+		}
+		switch {
+		case name == "url":
+			continue
+		case strings.HasSuffix(name, ".*") && len(name) > 2:
+			set.prefixes = append(set.prefixes, strings.TrimSuffix(name, "*"))
+			continue
+		case !knownField(name) && !strings.Contains(name, ".") && !slices.Contains(derived, name):
+			return set, fmt.Errorf("unknown field %q (known fields: %s; extra fields are dotted, e.g. header.set_cookie or header.*)", name, strings.Join(hostFields, ", "))
+		}
+		if set.names == nil {
+			set.names = map[string]bool{}
+		}
+		set.names[name] = true
+	}
+	return set, nil
+}
+
+func knownField(name string) bool {
+	return slices.Contains(hostFields, name)
+}
+
+// keep reports whether the field with the given property name is written.
+func (s FieldSelection) keep(name string) bool {
+	if !s.only.empty() && knownField(name) {
+		return s.only.has(name)
+	}
+	return !s.skip.has(name)
+}
+
+// filter removes the properties that are not selected from props and hashes
+// the ones selected for hashing.
+func (s FieldSelection) filter(props map[string]any) map[string]any {
+	for name, v := range props {
+		switch {
+		case !s.keep(name):
+			delete(props, name)
+		case s.hash.has(name):
+			props[name] = s.hashValue(v)
+		}
+	}
+	return props
+}
+
+// hashValue replaces v by its hash; lists are hashed per item, so hosts that
+// share a value still share its hash.
+func (s FieldSelection) hashValue(v any) any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []string:
+		if v == nil {
+			return nil
+		}
+		hashed := make([]string, len(v))
+		for i, item := range v {
+			hashed[i] = s.hashString(item)
+		}
+		return hashed
+	case []any:
+		// Lijsten uit -flatten-extra.
+		hashed := make([]string, len(v))
+		for i, item := range v {
+			hashed[i] = s.hashString(fmt.Sprint(item))
+		}
+		return hashed
+	default:
+		return s.hashString(fmt.Sprint(v))
+	}
+}
+
+func (s FieldSelection) hashString(v string) string {
+	if len(s.hashKey) == 0 {
+		sum := sha256.Sum256([]byte(v))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write([]byte(v))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// FieldNames returns the selectable field names, sorted.
+func FieldNames() []string {
+	names := append([]string(nil), hostFields...)
+	sort.Strings(names)
+	return names
+}
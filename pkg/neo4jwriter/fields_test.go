@@ -0,0 +1,186 @@
+package neo4jwriter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/parser"
+)
+
+const httpxLine = `{"url":"https://a.example.com","host":"10.0.0.1","title":"Login","status_code":200,` +
+	`"header":{"set_cookie":"sid=1","authorization":"Bearer x","server":"nginx"},` +
+	`"tls":{"cipher":"TLS_AES_128_GCM_SHA256","sans":["a.example.com","b.example.com"]}}`
+
+func httpxResult(t *testing.T) model.HttpxResult {
+	t.Helper()
+	var result model.HttpxResult
+	if err := json.Unmarshal([]byte(httpxLine), &result); err != nil {
+		t.Fatal(err)
+	}
+	extra, err := parser.HttpxExtra([]byte(httpxLine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Zoals derive een afgeleide property aan de Host toevoegt.
+	extra["login_page"] = true
+	result.Extra = extra
+	return result
+}
+
+func TestHostPropsFieldSelection(t *testing.T) {
+	tests := []struct {
+		name              string
+		only, skip, hash  []string
+		present, absent   []string
+		hashed, unchanged []string
+	}{
+		{
+			name:      "all",
+			present:   []string{"title", "header.set_cookie", "header.authorization", "tls.cipher", "tls.sans", "login_page"},
+			unchanged: []string{"header.set_cookie", "tls.cipher"},
+		},
+		{
+			name:      "skip extra",
+			skip:      []string{"header.set_cookie"},
+			present:   []string{"title", "header.authorization", "header.server"},
+			absent:    []string{"header.set_cookie"},
+			unchanged: []string{"header.authorization"},
+		},
+		{
+			name:    "skip prefix and derived",
+			skip:    []string{"header.*", "login_page"},
+			present: []string{"title", "tls.cipher"},
+			absent:  []string{"header.set_cookie", "header.authorization", "header.server", "login_page"},
+		},
+		{
+			name:      "skip and hash",
+			skip:      []string{"header.set_cookie"},
+			hash:      []string{"header.authorization", "tls.*", "title"},
+			present:   []string{"header.server"},
+			absent:    []string{"header.set_cookie"},
+			hashed:    []string{"header.authorization", "tls.cipher", "title"},
+			unchanged: []string{"header.server"},
+		},
+		{
+			name:    "only keeps extra and derived",
+			only:    []string{"title"},
+			present: []string{"title", "header.set_cookie", "tls.cipher", "login_page"},
+			absent:  []string{"ip", "status", "input"},
+		},
+		{
+			name:    "only with skipped extra",
+			only:    []string{"title", "status_code"},
+			skip:    []string{"HEADER.*"},
+			present: []string{"title", "status", "header.set_cookie"},
+			absent:  []string{"ip"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := NewFieldSelection(tt.only, tt.skip, tt.hash, nil, "login_page")
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := httpxResult(t)
+			props := (&Writer{Fields: sel}).hostProps(result)
+			for _, name := range tt.present {
+				if _, ok := props[name]; !ok {
+					t.Errorf("%s missing", name)
+				}
+			}
+			for _, name := range tt.absent {
+				if _, ok := props[name]; ok {
+					t.Errorf("%s written", name)
+				}
+			}
+			for _, name := range tt.hashed {
+				if !isHashed(props[name]) {
+					t.Errorf("%s = %v, want a hash", name, props[name])
+				}
+			}
+			for _, name := range tt.unchanged {
+				if isHashed(props[name]) {
+					t.Errorf("%s hashed", name)
+				}
+			}
+		})
+	}
+}
+
+// isHashed reports whether v, or every item of it, is a sha256 hash.
+func isHashed(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return strings.HasPrefix(v, "sha256:")
+	case []string:
+		for _, item := range v {
+			if !strings.HasPrefix(item, "sha256:") {
+				return false
+			}
+		}
+		return len(v) > 0
+	}
+	return false
+}
+
+func TestHashValueList(t *testing.T) {
+	sel, err := NewFieldSelection(nil, nil, []string{"tls.sans"}, []byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := (&Writer{Fields: sel}).hostProps(httpxResult(t))
+	sans, ok := props["tls.sans"].([]string)
+	if !ok || len(sans) != 2 {
+		t.Fatalf("tls.sans = %#v, want two hashes", props["tls.sans"])
+	}
+	if sans[0] != sel.hashString("a.example.com") || !strings.HasPrefix(sans[0], "hmac-sha256:") {
+		t.Errorf("tls.sans[0] = %s, want the HMAC of a.example.com", sans[0])
+	}
+}
+
+func TestNewFieldSelectionErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		only, skip, hash []string
+		want             string
+	}{
+		{"unknown", nil, []string{"header"}, nil, "unknown field"},
+		{"unknown hash", nil, nil, []string{"server"}, "unknown field"},
+		{"only extra", []string{"header.set_cookie"}, nil, nil, "only fields select known fields"},
+		{"only prefix", []string{"header.*"}, nil, nil, "only fields select known fields"},
+		{"only derived", []string{"login_page"}, nil, nil, "only fields select known fields"},
+		{"only and skip", []string{"title"}, []string{"ip"}, nil, "cannot be combined"},
+		{"hash asn", nil, nil, []string{"asn"}, "cannot be hashed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFieldSelection(tt.only, tt.skip, tt.hash, nil, "login_page")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestHostPropsUnreported checks that fields httpx did not report are left
+// alone, so they do not erase what enrich wrote, and that a missing cdn is
+// not written as false.
+func TestHostPropsUnreported(t *testing.T) {
+	props := (&Writer{}).hostProps(httpxResult(t))
+	for _, name := range []string{"cname", "favicon", "jarm", "a", "cdn", "cdn_name", "country", "city"} {
+		if v, ok := props[name]; ok {
+			t.Errorf("%s = %v, want it unset", name, v)
+		}
+	}
+
+	var result model.HttpxResult
+	if err := json.Unmarshal([]byte(`{"url":"https://a.example.com","cdn":false,"favicon":"-1234","a":["10.0.0.1"]}`), &result); err != nil {
+		t.Fatal(err)
+	}
+	props = (&Writer{}).hostProps(result)
+	if props["cdn"] != false || props["favicon"] != "-1234" || len(props["a"].([]string)) != 1 {
+		t.Errorf("props = %v, want cdn false, the favicon and the A record", props)
+	}
+}
@@ -0,0 +1,195 @@
+package neo4jwriter
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// GraphTarget is a graph database that is not written with Cypher, such as
+// a Gremlin server. Instead of statements, the importer hands it the nodes
+// and relationships of every record, including the Scan node and the SEEN_IN
+// relationships to it. Its Write returns ErrNoCypher.
+type GraphTarget interface {
+	Target
+	// WriteGraph merges the entities on their label and key and then the
+	// relations between them.
+	WriteGraph(g Graph) (Stats, error)
+}
+
+// ErrNoCypher is returned by the Write of a GraphTarget.
+var ErrNoCypher = errors.New("target does not run Cypher statements")
+
+// Graph is what a GraphTarget writes for a record.
+type Graph struct {
+	Entities  []model.Entity
+	Relations []model.Relation
+	// OnCreate holds the properties that are only set on the entities the
+	// write creates, such as first_seen.
+	OnCreate map[string]any
+}
+
+// scanRef refers to the Scan node of the writer.
+func (w *Writer) scanRef() model.Ref {
+	return model.Ref{Label: "Scan", Key: map[string]any{"id": w.ScanID}}
+}
+
+// HttpxGraph returns the Host and ASN of result as a Graph, the way Write
+// writes them with Cypher.
+func (w *Writer) HttpxGraph(result model.HttpxResult) Graph {
+	host := model.Entity{Label: "Host", Key: map[string]any{"url": result.URL}, Props: w.hostProps(result)}
+	entities := []model.Entity{host}
+	relations := []model.Relation{{
+		Type: "SEEN_IN",
+		From: host.Ref(),
+		To:   w.scanRef(),
+		Props: w.filter(map[string]any{
+			"status": result.Status,
+			"title":  result.Title,
+			"port":   result.Port,
+		}),
+	}}
+	if result.ASN.ASNumber != "" && w.Fields.keep("asn") {
+		asn := model.Entity{
+			Label: "ASN",
+			Key:   map[string]any{"number": result.ASN.ASNumber},
+			Props: map[string]any{
+				"name":    result.ASN.ASName,
+				"country": result.ASN.ASCountry,
+				"range":   result.ASN.ASRange,
+			},
+		}
+		entities = append(entities, asn)
+		relations = append(relations, model.Relation{Type: "BELONGS_TO", From: host.Ref(), To: asn.Ref()})
+	}
+	return w.graph(entities, relations, false)
+}
+
+// EntityGraph returns the entities and relations of a parser as a Graph, the
+// way WriteEntities writes them with Cypher.
+func (w *Writer) EntityGraph(entities []model.Entity, relations []model.Relation) Graph {
+	return w.graph(entities, relations, true)
+}
+
+// graph adds what the Cypher queries add to the nodes: the project, tags,
+// timestamps and attribution, and with seenIn a SEEN_IN relationship from
+// every entity to the Scan.
+func (w *Writer) graph(entities []model.Entity, relations []model.Relation, seenIn bool) Graph {
+	now := time.Now().UTC()
+	g := Graph{OnCreate: map[string]any{"first_seen": now}}
+	if w.Attribution != nil {
+		maps.Copy(g.OnCreate, w.Attribution.params(w.ToolVersion))
+	}
+
+	for _, e := range entities {
+		e.Key, e.Props = maps.Clone(e.Key), maps.Clone(props(e.Props))
+		w.Coerce.Apply(e.Key)
+		w.Coerce.Apply(e.Props)
+		if w.Project != "" {
+			e.Key["project"] = w.Project
+		}
+		e.Props["last_seen"] = now
+		if w.TTL > 0 {
+			e.Props["expires_at"] = now.Add(w.TTL)
+		}
+		if len(w.Tags) > 0 {
+			e.Props["tags"] = w.Tags
+		}
+		g.Entities = append(g.Entities, e)
+	}
+	for _, r := range relations {
+		r.From.Key, r.To.Key, r.Props = w.refKey(r.From), w.refKey(r.To), maps.Clone(r.Props)
+		w.Coerce.Apply(r.Props)
+		g.Relations = append(g.Relations, r)
+	}
+	if seenIn {
+		for _, e := range g.Entities {
+			g.Relations = append(g.Relations, model.Relation{Type: "SEEN_IN", From: e.Ref(), To: w.scanRef()})
+		}
+	}
+	return g
+}
+
+// refKey returns the key of ref as graph gives it to its entity. The Scan
+// is not part of a project.
+func (w *Writer) refKey(ref model.Ref) map[string]any {
+	key := maps.Clone(ref.Key)
+	w.Coerce.Apply(key)
+	if w.Project != "" && ref.Label != "Scan" {
+		key["project"] = w.Project
+	}
+	return key
+}
+
+// createGraphScan writes the Scan node of CreateScan to a GraphTarget.
+// Baselines are not supported there.
+func createGraphScan(t GraphTarget, scanID, source string, opts Options) error {
+	now := time.Now().UTC()
+	props := map[string]any{
+		"file":         source,
+		"started_at":   now,
+		"imported_by":  opts.Attribution.Operator,
+		"hostname":     opts.Attribution.Hostname,
+		"tool":         "jsontoneo",
+		"tool_version": opts.ToolVersion,
+		"tool_commit":  opts.ToolCommit,
+	}
+	if opts.Project != "" {
+		props["project"] = opts.Project
+	}
+	if len(opts.Tags) > 0 {
+		props["tags"] = opts.Tags
+	}
+	if opts.TTL > 0 {
+		props["expires_at"] = now.Add(opts.TTL)
+	}
+	_, err := t.WriteGraph(Graph{Entities: []model.Entity{{Label: "Scan", Key: map[string]any{"id": scanID}, Props: props}}})
+	return err
+}
+
+// WriteGraph writes g with Cypher, the way a GraphTarget writes it: the
+// entities are merged with their own first_seen and last_seen, rather than
+// the time of the write.
+func WriteGraph(tx Runner, g Graph) (Stats, error) {
+	var stats Stats
+	for _, e := range g.Entities {
+		key, params := keyCypher(e.Key, "key")
+		params["on_create"] = props(g.OnCreate)
+		params["props"] = props(e.Props)
+		res, err := tx.Run(`
+	MERGE (n:`+QuoteLabel(e.Label)+` {`+key+`})
+	ON CREATE SET n += $on_create
+	SET n += $props
+	`, params)
+		if err != nil {
+			return stats, fmt.Errorf("%s query error: %w", e.Label, err)
+		}
+		if err := stats.consume(res, 1, 0); err != nil {
+			return stats, fmt.Errorf("%s query error: %w", e.Label, err)
+		}
+	}
+	for _, r := range g.Relations {
+		from, params := keyCypher(r.From.Key, "from")
+		to, toParams := keyCypher(r.To.Key, "to")
+		maps.Copy(params, toParams)
+		params["first_seen"] = g.OnCreate["first_seen"]
+		params["props"] = props(r.Props)
+		res, err := tx.Run(`
+	MATCH (a:`+QuoteLabel(r.From.Label)+` {`+from+`})
+	MATCH (b:`+QuoteLabel(r.To.Label)+` {`+to+`})
+	MERGE (a)-[r:`+QuoteLabel(r.Type)+`]->(b)
+	ON CREATE SET r.first_seen = coalesce($first_seen, datetime())
+	SET r += $props
+	`, params)
+		if err != nil {
+			return stats, fmt.Errorf("%s query error: %w", r.Type, err)
+		}
+		if err := stats.consume(res, 0, 1); err != nil {
+			return stats, fmt.Errorf("%s query error: %w", r.Type, err)
+		}
+	}
+	return stats, nil
+}
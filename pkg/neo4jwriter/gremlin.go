@@ -0,0 +1,190 @@
+package neo4jwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	gremlingo "github.com/apache/tinkerpop/gremlin-go/v3/driver"
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// GremlinTarget writes to a TinkerPop Gremlin server, such as JanusGraph or
+// Neptune's Gremlin endpoint, with upsert traversals. Every entity and
+// relation is a traversal of its own, so a record is not written
+// atomically.
+//
+// Lists become set-cardinality properties on vertices; on edges, which have
+// no multi-properties, and for maps they are written as JSON strings.
+type GremlinTarget struct {
+	conn *gremlingo.DriverRemoteConnection
+	g    *gremlingo.GraphTraversalSource
+}
+
+// NewGremlinTarget connects to the Gremlin server at url, e.g.
+// ws://localhost:8182/gremlin. configure can set authentication and TLS.
+func NewGremlinTarget(url string, configure ...func(*gremlingo.DriverRemoteConnectionSettings)) (*GremlinTarget, error) {
+	configure = append([]func(*gremlingo.DriverRemoteConnectionSettings){func(s *gremlingo.DriverRemoteConnectionSettings) {
+		s.LogVerbosity = gremlingo.Warning
+	}}, configure...)
+	conn, err := gremlingo.NewDriverRemoteConnection(url, configure...)
+	if err != nil {
+		return nil, err
+	}
+	return &GremlinTarget{conn: conn, g: gremlingo.Traversal_().WithRemote(conn)}, nil
+}
+
+// Write returns ErrNoCypher: a Gremlin server is written with WriteGraph.
+func (t *GremlinTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return Stats{}, ErrNoCypher
+}
+
+func (t *GremlinTarget) Close() error {
+	t.conn.Close()
+	return nil
+}
+
+// WriteGraph upserts the vertices of g and then its edges.
+func (t *GremlinTarget) WriteGraph(g Graph) (Stats, error) {
+	var stats Stats
+	for _, e := range g.Entities {
+		created, err := t.upsertVertex(e, g.OnCreate)
+		if err != nil {
+			return stats, fmt.Errorf("%s write error: %w", e.Label, err)
+		}
+		if created {
+			stats.NodesCreated++
+		}
+		stats.NodesMerged++
+		stats.PropertiesSet += len(e.Props)
+	}
+	for _, r := range g.Relations {
+		created, err := t.upsertEdge(r)
+		if err != nil {
+			return stats, fmt.Errorf("%s write error: %w", r.Type, err)
+		}
+		if created {
+			stats.RelsCreated++
+		}
+		stats.RelsMerged++
+		stats.PropertiesSet += len(r.Props)
+	}
+	return stats, nil
+}
+
+// findVertex returns a traversal to the vertex of ref.
+func (t *GremlinTarget) findVertex(traversal *gremlingo.GraphTraversal, ref model.Ref) *gremlingo.GraphTraversal {
+	traversal = traversal.HasLabel(ref.Label)
+	for _, k := range sortedKeys(ref.Key) {
+		traversal = traversal.Has(k, ref.Key[k])
+	}
+	return traversal
+}
+
+// upsertVertex merges e and reports whether it was created. Without a
+// first_seen in onCreate creation is not detected.
+func (t *GremlinTarget) upsertVertex(e model.Entity, onCreate map[string]any) (bool, error) {
+	return created(t.vertexTraversal(e, onCreate), onCreate)
+}
+
+func (t *GremlinTarget) vertexTraversal(e model.Entity, onCreate map[string]any) *gremlingo.GraphTraversal {
+	__ := gremlingo.T__
+	create := __.AddV(e.Label)
+	for _, k := range sortedKeys(e.Key) {
+		create = create.Property(k, e.Key[k])
+	}
+	for _, k := range sortedKeys(onCreate) {
+		create = create.Property(k, onCreate[k])
+	}
+	traversal := t.findVertex(t.g.V(), e.Ref()).Fold().Coalesce(__.Unfold(), create)
+	for _, k := range sortedKeys(e.Props) {
+		switch v := gremlinValue(e.Props[k], true).(type) {
+		case nil:
+			traversal = traversal.SideEffect(__.Properties(k).Drop())
+		case []any:
+			traversal = traversal.SideEffect(__.Properties(k).Drop())
+			for _, item := range v {
+				traversal = traversal.Property(gremlingo.Cardinality.Set, k, item)
+			}
+		default:
+			traversal = traversal.Property(gremlingo.Cardinality.Single, k, v)
+		}
+	}
+	return traversal
+}
+
+// upsertEdge merges r between its vertices and reports whether it was
+// created. Nothing is written when one of the vertices does not exist.
+func (t *GremlinTarget) upsertEdge(r model.Relation) (bool, error) {
+	onCreate := map[string]any{"first_seen": time.Now().UTC()}
+	return created(t.edgeTraversal(r, onCreate), onCreate)
+}
+
+func (t *GremlinTarget) edgeTraversal(r model.Relation, onCreate map[string]any) *gremlingo.GraphTraversal {
+	__ := gremlingo.T__
+	create := __.AddE(r.Type).From("a").Property("first_seen", onCreate["first_seen"])
+	traversal := t.findVertex(t.g.V(), r.From).As("a")
+	traversal = t.findVertex(traversal.V(), r.To).
+		Coalesce(__.InE(r.Type).Where(__.OutV().As("a")), create)
+	for _, k := range sortedKeys(r.Props) {
+		if v := gremlinValue(r.Props[k], false); v != nil {
+			traversal = traversal.Property(k, v)
+		} else {
+			traversal = traversal.SideEffect(__.Properties(k).Drop())
+		}
+	}
+	return traversal
+}
+
+// created runs traversal, which ends at the upserted element, and reports
+// whether its first_seen is the one of onCreate.
+func created(traversal *gremlingo.GraphTraversal, onCreate map[string]any) (bool, error) {
+	first, ok := onCreate["first_seen"]
+	if !ok {
+		_, err := traversal.Count().Next()
+		return false, err
+	}
+	res, err := traversal.Values("first_seen").Is(first).Count().Next()
+	if err != nil {
+		return false, err
+	}
+	n, err := res.GetInt64()
+	return n > 0, err
+}
+
+// gremlinValue returns v as a property value: lists as []any when multi is
+// set and as JSON otherwise, maps as JSON.
+func gremlinValue(v any, multi bool) any {
+	switch v := v.(type) {
+	case []string:
+		if v == nil {
+			return nil
+		}
+		list := make([]any, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return gremlinValue(list, multi)
+	case []any:
+		if multi {
+			return v
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	case map[string]any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return v
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
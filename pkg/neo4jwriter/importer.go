@@ -0,0 +1,573 @@
+// Package neo4jwriter maps parsed recon records onto a Neo4j graph: Host,
+// ASN and Scan nodes, merged so that imports are idempotent. Other Go
+// programs can embed it through Import, or through an Importer when they
+// manage the Scan node themselves.
+package neo4jwriter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/coerce"
+	"github.com/pocahon/jsontoneo/pkg/derive"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/parser"
+	"github.com/pocahon/jsontoneo/pkg/script"
+	"github.com/pocahon/jsontoneo/pkg/transform"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/pocahon/jsontoneo/pkg/neo4jwriter")
+
+// Filter decides whether a record is imported.
+type Filter interface {
+	Match(result *model.HttpxResult) bool
+}
+
+// Observer is told about every processed line of n bytes, with the record
+// when it was written and the summary so far.
+type Observer interface {
+	Observe(result *model.HttpxResult, n int, summary Summary)
+}
+
+// Enricher rewrites a JSON record before it is parsed: it returns the
+// records to import, none to skip it.
+type Enricher interface {
+	Apply(record []byte) ([][]byte, error)
+}
+
+// Options configure an import. The zero value imports every record as is.
+type Options struct {
+	// Parser parses the input lines; nil detects it from the first line.
+	Parser parser.Parser
+	// Transform, when set, rewrites every line before it is parsed.
+	Transform *transform.Transform
+	// Script, when set, enriches every line after Transform.
+	Script *script.Script
+	// Enrichers, such as plugins, rewrite every line after Script.
+	Enrichers []Enricher
+	// Coerce converts property values to their declared type.
+	Coerce coerce.Rules
+	// Derive, when set, computes properties from every record.
+	Derive *derive.Rules
+	// FlattenExtra also writes the httpx fields the Host model does not
+	// cover, as dot-joined properties such as tls.cipher.
+	FlattenExtra bool
+	// Templates, when set, add Cypher statements of their own or replace
+	// the built-in ones.
+	Templates *Templates
+
+	Project   string
+	Fields    FieldSelection
+	Tags      []string
+	TagLabels bool
+	// Versioned also records changes of status, title and tech as
+	// Observation nodes.
+	Versioned bool
+	// Attribution is recorded on the Scan node, and with AttributeNodes on
+	// the nodes the import creates.
+	Attribution    Attribution
+	AttributeNodes bool
+	// TTL, when set, gives the nodes written an expires_at this far ahead.
+	TTL time.Duration
+
+	// Filter, when set, drops the records it does not match.
+	Filter Filter
+	// Scope, when set, drops out-of-scope records, or with LabelOutOfScope
+	// writes them labeled :OutOfScope.
+	Scope           Scope
+	LabelOutOfScope bool
+
+	// Skip the first Skip lines, import a random Sample percentage of the
+	// rest and at most Limit lines. Seed makes the sample reproducible.
+	Skip   int
+	Limit  int
+	Sample float64
+	Seed   int64
+
+	// MaxNewNodes limits the nodes an import may create. Reaching it aborts
+	// the import, or with QuotaDryRun counts what the rest would create
+	// without writing it.
+	MaxNewNodes int
+	QuotaDryRun bool
+
+	Observer Observer
+	// Logger receives the progress and record errors; nil uses the standard
+	// logger.
+	Logger *log.Logger
+
+	// ToolVersion and ToolCommit are recorded on the Scan node.
+	ToolVersion string
+	ToolCommit  string
+
+	// Target and Source are the target and the file name of Run.
+	Target Target
+	Source string
+}
+
+// Import reads the JSON lines of r into t as a new scan, recording source as
+// its file. It returns the summary, and an error when the scan could not be
+// created or reading r failed; record errors are counted in the summary.
+func Import(ctx context.Context, t Target, r io.Reader, source string, opts Options) (*Summary, error) {
+	scanID := NewScanID()
+	if err := CreateScan(t, scanID, source, opts); err != nil {
+		return nil, err
+	}
+
+	imp := New(t, scanID, opts)
+	summary := &Summary{ScanID: scanID, File: source}
+	start := time.Now()
+	err := imp.Run(ctx, r, summary)
+	if ferr := FinishScan(t, scanID); err == nil {
+		err = ferr
+	}
+	summary.Finish(time.Since(start))
+	return summary, err
+}
+
+// Importer parses, selects and filters the records of an input and writes
+// them to a target as part of one scan.
+type Importer struct {
+	opts   Options
+	out    Target
+	writer *Writer
+	parser parser.Parser
+}
+
+// New returns an Importer writing to t as part of the Scan scanID, which
+// must have been created with CreateScan.
+func New(t Target, scanID string, opts Options) *Importer {
+	imp := &Importer{
+		opts:   opts,
+		out:    t,
+		parser: opts.Parser,
+		writer: &Writer{
+			ScanID:      scanID,
+			Project:     opts.Project,
+			Fields:      opts.Fields,
+			Tags:        opts.Tags,
+			TagLabels:   opts.TagLabels,
+			Versioned:   opts.Versioned,
+			TTL:         opts.TTL,
+			ToolVersion: opts.ToolVersion,
+			Coerce:      opts.Coerce,
+		},
+	}
+	if opts.AttributeNodes {
+		imp.writer.Attribution = &opts.Attribution
+	}
+	if opts.LabelOutOfScope {
+		imp.writer.Scope = opts.Scope
+	}
+	return imp
+}
+
+func (imp *Importer) logf(format string, v ...any) {
+	if imp.opts.Logger != nil {
+		imp.opts.Logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+func (imp *Importer) observe(result *model.HttpxResult, n int, summary *Summary) {
+	if imp.opts.Observer != nil {
+		imp.opts.Observer.Observe(result, n, *summary)
+	}
+}
+
+// accept reports whether a parsed record passes the filters and the scope,
+// counting the records that do not.
+func (imp *Importer) accept(result *model.HttpxResult, summary *Summary) bool {
+	if imp.opts.Filter != nil && !imp.opts.Filter.Match(result) {
+		summary.Filtered++
+		return false
+	}
+	if imp.opts.Scope != nil && !imp.opts.Scope.InScope(result) {
+		summary.OutOfScope++
+		// Bij LabelOutOfScope wordt het record wel geschreven, met een :OutOfScope label.
+		return imp.opts.LabelOutOfScope
+	}
+	return true
+}
+
+// Run imports the JSON lines read from r, counting them in summary. It only
+// returns an error when reading r fails or ctx is done; record errors are
+// counted.
+func (imp *Importer) Run(ctx context.Context, r io.Reader, summary *Summary) (err error) {
+	opts := imp.opts
+	selector := newLineSelector(opts.Skip, opts.Limit, opts.Sample, opts.Seed)
+
+	ctx, span := tracer.Start(ctx, "import", trace.WithAttributes(attribute.String("jsontoneo.scan_id", imp.writer.ScanID)))
+	defer func() {
+		span.SetAttributes(
+			attribute.Int("jsontoneo.records_read", summary.Read),
+			attribute.Int("jsontoneo.records_written", summary.Written),
+			attribute.Int("jsontoneo.records_failed", summary.Failed),
+			attribute.Int("jsontoneo.parse_errors", summary.ParseErrors),
+		)
+		endSpan(span, err)
+	}()
+
+	input := newRecordReader(r)
+	for !selector.done() && !imp.aborted(summary) && input.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		summary.Read++
+		lineSize := input.Size()
+		line := input.Record()
+		if !selector.take() || len(line) == 0 {
+			summary.Skipped++
+			imp.observe(nil, lineSize, summary)
+			continue
+		}
+
+		records, err := imp.rewrite(line)
+		if err != nil {
+			imp.logf("Error transforming record: %v", err)
+			summary.ParseErrors++
+			imp.observe(nil, lineSize, summary)
+			continue
+		}
+		if len(records) == 0 {
+			summary.Filtered++
+			imp.observe(nil, lineSize, summary)
+			continue
+		}
+		for i, record := range records {
+			n := lineSize
+			if i > 0 {
+				n = 0
+			}
+			if err := imp.importLine(ctx, record, n, summary); err != nil {
+				return err
+			}
+		}
+	}
+	return input.Err()
+}
+
+// rewrite runs the transform, the enrichment script and the enrichers on
+// line and returns the records to import.
+func (imp *Importer) rewrite(line []byte) ([][]byte, error) {
+	var steps []Enricher
+	// Een nil pointer in een interface is niet nil, dus alleen toevoegen als er iets is.
+	if imp.opts.Transform != nil {
+		steps = append(steps, imp.opts.Transform)
+	}
+	if imp.opts.Script != nil {
+		steps = append(steps, imp.opts.Script)
+	}
+	steps = append(steps, imp.opts.Enrichers...)
+
+	records := [][]byte{line}
+	for _, step := range steps {
+		var next [][]byte
+		for _, record := range records {
+			out, err := step.Apply(record)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, out...)
+		}
+		records = next
+	}
+	return records, nil
+}
+
+// importLine parses and writes a line of n bytes. It only returns an error
+// when the format of the input cannot be detected.
+func (imp *Importer) importLine(ctx context.Context, line []byte, n int, summary *Summary) error {
+	if imp.parser == nil {
+		if imp.parser = parser.Detect(line); imp.parser == nil {
+			return errors.New("no parser recognizes the input (parsers: " + strings.Join(parser.Names(), ", ") + ")")
+		}
+	}
+
+	_, parseSpan := tracer.Start(ctx, "parse", trace.WithAttributes(attribute.Int("jsontoneo.line", summary.Read)))
+	if httpx, ok := imp.parser.(parser.Httpx); ok {
+		result, err := httpx.Record(line)
+		if err == nil && imp.opts.FlattenExtra {
+			result.Extra, err = parser.HttpxExtra(line)
+		}
+		var derived derive.Props
+		if err == nil && imp.opts.Derive != nil {
+			derived, err = imp.opts.Derive.Apply(line)
+		}
+		endSpan(parseSpan, err)
+		if err != nil {
+			imp.parseError(err, n, summary)
+			return nil
+		}
+		for _, label := range []string{"", "Host"} {
+			for name, v := range derived[label] {
+				if result.Extra == nil {
+					result.Extra = make(map[string]any)
+				}
+				result.Extra[name] = v
+			}
+		}
+		summary.Parsed++
+		imp.ImportRecord(ctx, result, n, summary)
+		return nil
+	}
+	entities, relations, err := imp.parser.Parse(line)
+	var derived derive.Props
+	if err == nil && imp.opts.Derive != nil {
+		derived, err = imp.opts.Derive.Apply(line)
+	}
+	endSpan(parseSpan, err)
+	if err != nil {
+		imp.parseError(err, n, summary)
+		return nil
+	}
+	addDerived(entities, derived)
+	summary.Parsed++
+	imp.importEntities(ctx, line, entities, relations, n, summary)
+	return nil
+}
+
+// addDerived adds the derived properties to the entities with their label,
+// and those without a label to the first entity.
+func addDerived(entities []model.Entity, derived derive.Props) {
+	for i := range entities {
+		e := &entities[i]
+		for label, props := range derived {
+			if label != e.Label && (label != "" || i > 0) {
+				continue
+			}
+			if e.Props == nil {
+				e.Props = make(map[string]any)
+			}
+			maps.Copy(e.Props, props)
+		}
+	}
+}
+
+func (imp *Importer) parseError(err error, n int, summary *Summary) {
+	imp.logf("Error parsing %s output: %v", imp.parser.Name(), err)
+	summary.ParseErrors++
+	imp.observe(nil, n, summary)
+}
+
+// importEntities writes the entities and relations parsed from a line of n
+// bytes. Filters, scope and field selection only apply to httpx records.
+func (imp *Importer) importEntities(ctx context.Context, line []byte, entities []model.Entity, relations []model.Relation, n int, summary *Summary) {
+	_, span := tracer.Start(ctx, "write", trace.WithAttributes(attribute.Int("jsontoneo.entities", len(entities))))
+	var attempted, stats Stats
+	var err error
+	if g, ok := imp.out.(GraphTarget); ok {
+		stats, err = g.WriteGraph(imp.writer.EntityGraph(entities, relations))
+	} else {
+		stats, err = imp.out.Write(func(r Runner) (Stats, error) {
+			stats, err := imp.writeEntities(r, line, entities, relations)
+			if err == nil && imp.exceedsQuota(summary, stats) {
+				attempted = stats
+				return stats, errQuotaExceeded
+			}
+			return stats, err
+		})
+	}
+	span.SetAttributes(attribute.Int("jsontoneo.nodes_created", stats.NodesCreated))
+	endSpan(span, err)
+
+	switch {
+	case errors.Is(err, errQuotaExceeded):
+		imp.overQuota(attempted, summary)
+	case err != nil:
+		imp.logf("Error writing %s record: %v", imp.parser.Name(), err)
+		summary.Failed++
+	default:
+		summary.Written++
+		summary.Add(stats)
+	}
+	imp.observe(nil, n, summary)
+}
+
+// ImportRecord filters and writes a parsed record, counting the outcome in
+// summary. n is the number of input bytes the record took.
+func (imp *Importer) ImportRecord(ctx context.Context, result model.HttpxResult, n int, summary *Summary) {
+	if !imp.accept(&result, summary) {
+		imp.observe(nil, n, summary)
+		return
+	}
+
+	imp.logf("Processing URL: %s", result.URL)
+
+	_, span := tracer.Start(ctx, "write", trace.WithAttributes(attribute.String("url.full", result.URL)))
+	var attempted, stats Stats
+	var err error
+	if g, ok := imp.out.(GraphTarget); ok {
+		stats, err = g.WriteGraph(imp.writer.HttpxGraph(result))
+	} else {
+		stats, err = imp.out.Write(func(r Runner) (Stats, error) {
+			stats, err := imp.write(r, result)
+			if err == nil && imp.exceedsQuota(summary, stats) {
+				attempted = stats
+				return stats, errQuotaExceeded
+			}
+			return stats, err
+		})
+	}
+	span.SetAttributes(attribute.Int("jsontoneo.nodes_created", stats.NodesCreated))
+	endSpan(span, err)
+
+	if errors.Is(err, errQuotaExceeded) {
+		imp.overQuota(attempted, summary)
+		imp.observe(nil, n, summary)
+		return
+	}
+	if err != nil {
+		imp.logf("Error processing %s: %v", result.URL, err)
+		summary.Failed++
+		imp.observe(nil, n, summary)
+		return
+	}
+	summary.Written++
+	summary.Add(stats)
+	imp.observe(&result, n, summary)
+}
+
+// write writes a parsed httpx record with the built-in queries and the
+// templates.
+func (imp *Importer) write(r Runner, result model.HttpxResult) (Stats, error) {
+	t := imp.opts.Templates
+	var stats Stats
+	if t == nil || !t.Replace {
+		s, err := imp.writer.Write(r, result)
+		if err != nil || t == nil {
+			return s, err
+		}
+		stats = s
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return stats, err
+	}
+	s, err := t.write(r, imp.writer, templateRecord(b))
+	stats.add(s)
+	return stats, err
+}
+
+// writeEntities writes the entities and relations parsed from line with the
+// built-in queries and the templates.
+func (imp *Importer) writeEntities(r Runner, line []byte, entities []model.Entity, relations []model.Relation) (Stats, error) {
+	t := imp.opts.Templates
+	var stats Stats
+	if t == nil || !t.Replace {
+		s, err := imp.writer.WriteEntities(r, entities, relations)
+		if err != nil || t == nil {
+			return s, err
+		}
+		stats = s
+	}
+	s, err := t.write(r, imp.writer, templateRecord(line))
+	stats.add(s)
+	return stats, err
+}
+
+// errQuotaExceeded rolls back a write that would take the import over
+// MaxNewNodes.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// exceedsQuota reports whether a write with stats must be rolled back: it
+// would create more nodes than MaxNewNodes allows, or the quota was already
+// reached and the import continues as a dry run.
+func (imp *Importer) exceedsQuota(summary *Summary, stats Stats) bool {
+	limit := imp.opts.MaxNewNodes
+	return limit > 0 && (summary.QuotaExceeded || summary.NodesCreated+stats.NodesCreated > limit)
+}
+
+// overQuota counts a record that was rolled back by the quota.
+func (imp *Importer) overQuota(stats Stats, summary *Summary) {
+	if !summary.QuotaExceeded {
+		summary.QuotaExceeded = true
+		if imp.opts.QuotaDryRun {
+			imp.logf("The import would create more than %d new nodes (-max-new-nodes), continuing as a dry run", imp.opts.MaxNewNodes)
+		} else {
+			imp.logf("Aborting: the import would create more than %d new nodes (-max-new-nodes); undo what was written with: jsontoneo rollback -scan %s", imp.opts.MaxNewNodes, imp.writer.ScanID)
+		}
+	}
+	summary.OverQuota++
+	summary.NodesOverQuota += stats.NodesCreated
+}
+
+// aborted reports whether the import stopped at MaxNewNodes.
+func (imp *Importer) aborted(summary *Summary) bool {
+	return summary.QuotaExceeded && !imp.opts.QuotaDryRun
+}
+
+// ImportBatch filters the records and writes them in one transaction. When
+// the transaction fails the records are retried one by one, so a single bad
+// record does not hold back the others.
+func (imp *Importer) ImportBatch(ctx context.Context, results []model.HttpxResult, summary *Summary) {
+	var batch []model.HttpxResult
+	for _, result := range results {
+		if !imp.accept(&result, summary) {
+			continue
+		}
+		batch = append(batch, result)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if _, ok := imp.out.(GraphTarget); ok {
+		for _, result := range batch {
+			imp.ImportRecord(ctx, result, 0, summary)
+		}
+		return
+	}
+
+	_, span := tracer.Start(ctx, "write_batch", trace.WithAttributes(attribute.Int("jsontoneo.batch_size", len(batch))))
+	var perRecord []Stats
+	_, err := imp.out.Write(func(r Runner) (Stats, error) {
+		perRecord = perRecord[:0]
+		var total Stats
+		for _, result := range batch {
+			stats, err := imp.write(r, result)
+			if err != nil {
+				return Stats{}, fmt.Errorf("%s: %w", result.URL, err)
+			}
+			perRecord = append(perRecord, stats)
+			total.NodesCreated += stats.NodesCreated
+		}
+		if imp.exceedsQuota(summary, total) {
+			return Stats{}, errQuotaExceeded
+		}
+		return Stats{}, nil
+	})
+	endSpan(span, err)
+	if err != nil {
+		if !errors.Is(err, errQuotaExceeded) {
+			imp.logf("Error writing batch of %d records, retrying one by one: %v", len(batch), err)
+		}
+		for _, result := range batch {
+			imp.ImportRecord(ctx, result, 0, summary)
+		}
+		return
+	}
+	summary.Written += len(batch)
+	for _, stats := range perRecord {
+		summary.Add(stats)
+	}
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
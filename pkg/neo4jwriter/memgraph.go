@@ -0,0 +1,147 @@
+package neo4jwriter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// MemgraphTarget writes to Memgraph through another target: a Neo4jTarget
+// on a driver connected to Memgraph's Bolt port, or a ScriptTarget for a
+// script to replay with mgconsole. Memgraph has no zoned datetime() before
+// 2.17 and no EXISTS subqueries, so the statements are rewritten on the way:
+// timestamps become localDateTime values in UTC and existence subqueries
+// become exists() pattern predicates. Indexes and constraints are created in
+// Memgraph's syntax; full-text indexes, which Memgraph does not have, are
+// left out.
+type MemgraphTarget struct {
+	Target
+}
+
+// NewMemgraphTarget returns a target writing the statements of t for
+// Memgraph. Closing it closes t.
+func NewMemgraphTarget(t Target) *MemgraphTarget {
+	return &MemgraphTarget{Target: t}
+}
+
+func (t *MemgraphTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return t.Target.Write(func(r Runner) (Stats, error) {
+		return work(memgraphRunner{r})
+	})
+}
+
+type memgraphRunner struct {
+	r Runner
+}
+
+func (m memgraphRunner) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	if fulltextIndex.MatchString(cypher) {
+		return nil, nil
+	}
+	return m.r.Run(MemgraphCypher(cypher), memgraphParams(params))
+}
+
+var (
+	datetimeCall     = regexp.MustCompile(`\bdatetime\(`)
+	existsPattern    = regexp.MustCompile(`\bEXISTS\s*\{\s*(\((?:[^{}]|\{[^{}]*\})*\))\s*\}`)
+	fulltextIndex    = regexp.MustCompile(`(?i)^\s*CREATE\s+FULLTEXT\s+INDEX\b`)
+	createIndex      = regexp.MustCompile(`(?is)^\s*CREATE\s+INDEX\s+(?:\w+\s+)?(?:IF\s+NOT\s+EXISTS\s+)?FOR\s+\((\w+):(\w+)\)\s+ON\s+\((.*)\)\s*$`)
+	createConstraint = regexp.MustCompile(`(?is)^\s*CREATE\s+CONSTRAINT\s+(?:\w+\s+)?(?:IF\s+NOT\s+EXISTS\s+)?FOR\s+(\(\w+:\w+\))\s+REQUIRE\s+(.*?)\s+IS\s+(UNIQUE|NOT\s+NULL)\s*$`)
+)
+
+// MemgraphCypher rewrites a statement for Memgraph. Only the constructs the
+// import uses are rewritten, never inside string literals; EXISTS subqueries
+// with a MATCH clause are left alone.
+func MemgraphCypher(cypher string) string {
+	if schema, ok := memgraphSchema(cypher); ok {
+		return schema
+	}
+	return outsideLiterals(cypher, func(cypher string) string {
+		cypher = datetimeCall.ReplaceAllString(cypher, "localDateTime(")
+		return existsPattern.ReplaceAllString(cypher, "exists($1)")
+	})
+}
+
+// memgraphSchema translates a Neo4j CREATE INDEX or CREATE CONSTRAINT
+// statement, unique and NOT NULL constraints on one or more properties.
+// Memgraph has no IF NOT EXISTS, but leaves existing ones as they are.
+func memgraphSchema(cypher string) (string, bool) {
+	if m := createIndex.FindStringSubmatch(cypher); m != nil {
+		props := strings.Split(m[3], ",")
+		for i, p := range props {
+			props[i] = strings.TrimPrefix(strings.TrimSpace(p), m[1]+".")
+		}
+		return "CREATE INDEX ON :" + m[2] + "(" + strings.Join(props, ", ") + ")", true
+	}
+	if m := createConstraint.FindStringSubmatch(cypher); m != nil {
+		props := strings.TrimSuffix(strings.TrimPrefix(m[2], "("), ")")
+		if strings.EqualFold(m[3], "UNIQUE") {
+			return "CREATE CONSTRAINT ON " + m[1] + " ASSERT " + props + " IS UNIQUE", true
+		}
+		return "CREATE CONSTRAINT ON " + m[1] + " ASSERT EXISTS (" + props + ")", true
+	}
+	return "", false
+}
+
+// memgraphParams returns params with the times, also those in lists and
+// maps such as $props, as local times in UTC. params itself is not changed.
+func memgraphParams(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+	out := make(map[string]any, len(params))
+	for k, v := range params {
+		out[k] = memgraphValue(v)
+	}
+	return out
+}
+
+func memgraphValue(v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		return neo4j.LocalDateTime(v.UTC())
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = memgraphValue(item)
+		}
+		return list
+	case map[string]any:
+		return memgraphParams(v)
+	default:
+		return v
+	}
+}
+
+// outsideLiterals applies rewrite to cypher with its string literals and
+// quoted names masked, so a rewrite only sees the statement itself.
+func outsideLiterals(cypher string, rewrite func(string) string) string {
+	var masked strings.Builder
+	var literals []string
+	for i := 0; i < len(cypher); i++ {
+		quote := cypher[i]
+		if quote != '\'' && quote != '"' && quote != '`' {
+			masked.WriteByte(quote)
+			continue
+		}
+		end := i + 1
+		for ; end < len(cypher) && cypher[end] != quote; end++ {
+			// In een name tussen backticks is een backslash gewoon een teken.
+			if cypher[end] == '\\' && quote != '`' {
+				end++
+			}
+		}
+		end = min(end, len(cypher)-1)
+		fmt.Fprintf(&masked, "\x00%d\x00", len(literals))
+		literals = append(literals, cypher[i:end+1])
+		i = end
+	}
+	out := rewrite(masked.String())
+	for i, literal := range literals {
+		out = strings.Replace(out, fmt.Sprintf("\x00%d\x00", i), literal, 1)
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package neo4jwriter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// recordTarget records the statements written to it and runs nothing.
+type recordTarget struct {
+	stmts  []string
+	params []map[string]any
+}
+
+func (t *recordTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return work(t)
+}
+
+func (t *recordTarget) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	t.stmts = append(t.stmts, cypher)
+	t.params = append(t.params, params)
+	return nil, nil
+}
+
+func (t *recordTarget) Close() error { return nil }
+
+func TestMemgraphCypher(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "datetime",
+			in:   "SET h.first_seen = datetime(), h.seen = datetime($at)",
+			want: "SET h.first_seen = localDateTime(), h.seen = localDateTime($at)",
+		},
+		{
+			name: "datetime in literal",
+			in:   "SET h.note = 'datetime() is not called', h.at = datetime()",
+			want: "SET h.note = 'datetime() is not called', h.at = localDateTime()",
+		},
+		{
+			name: "escaped quote in literal",
+			in:   `SET h.note = 'it\'s datetime()', h.at = datetime()`,
+			want: `SET h.note = 'it\'s datetime()', h.at = localDateTime()`,
+		},
+		{
+			name: "quoted name",
+			in:   "SET h.`datetime(` = datetime()",
+			want: "SET h.`datetime(` = localDateTime()",
+		},
+		{
+			name: "exists",
+			in:   "WHERE NOT EXISTS { (h)-[:SEEN_IN]->(:Scan {id: $id}) }",
+			want: "WHERE NOT exists((h)-[:SEEN_IN]->(:Scan {id: $id}))",
+		},
+		{
+			name: "exists over lines",
+			in:   "WHERE EXISTS {\n\t\t(h)-[:USES]->(:Tech {name: 'nginx'})\n\t}",
+			want: "WHERE exists((h)-[:USES]->(:Tech {name: 'nginx'}))",
+		},
+		{
+			name: "exists in literal",
+			in:   "SET h.note = 'EXISTS { (h) }' WITH h WHERE EXISTS { (h)--() }",
+			want: "SET h.note = 'EXISTS { (h) }' WITH h WHERE exists((h)--())",
+		},
+		{
+			name: "exists with where",
+			in:   "WHERE EXISTS { (h)--(t) WHERE t.name = 'x' } AND EXISTS { (h)--() }",
+			want: "WHERE EXISTS { (h)--(t) WHERE t.name = 'x' } AND exists((h)--())",
+		},
+		{
+			name: "exists with match",
+			in:   "WHERE EXISTS { MATCH (h)--(t) }",
+			want: "WHERE EXISTS { MATCH (h)--(t) }",
+		},
+		{
+			name: "baseline",
+			in:   baselineCypher,
+			want: "FOREACH (_ IN CASE WHEN s.baseline IS NULL OR exists((h)-[:SEEN_IN]->(:Scan {id: s.baseline})) THEN [] ELSE [1] END |\n\t    SET h:NewSinceBaseline)\n\t",
+		},
+		{
+			name: "index",
+			in:   "CREATE INDEX host_url IF NOT EXISTS FOR (n:Host) ON (n.url)",
+			want: "CREATE INDEX ON :Host(url)",
+		},
+		{
+			name: "composite index",
+			in:   "CREATE INDEX IF NOT EXISTS FOR (h:Host) ON (h.url, h.project)",
+			want: "CREATE INDEX ON :Host(url, project)",
+		},
+		{
+			name: "unique constraint",
+			in:   "CREATE CONSTRAINT host_url IF NOT EXISTS FOR (n:Host) REQUIRE n.url IS UNIQUE",
+			want: "CREATE CONSTRAINT ON (n:Host) ASSERT n.url IS UNIQUE",
+		},
+		{
+			name: "composite constraint",
+			in:   "CREATE CONSTRAINT IF NOT EXISTS\nFOR (n:Host) REQUIRE (n.url, n.project) IS UNIQUE",
+			want: "CREATE CONSTRAINT ON (n:Host) ASSERT n.url, n.project IS UNIQUE",
+		},
+		{
+			name: "not null constraint",
+			in:   "CREATE CONSTRAINT IF NOT EXISTS FOR (n:Scan) REQUIRE n.id IS NOT NULL",
+			want: "CREATE CONSTRAINT ON (n:Scan) ASSERT EXISTS (n.id)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MemgraphCypher(tt.in); got != tt.want {
+				t.Errorf("MemgraphCypher(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+var neo4jOnly = regexp.MustCompile(`\bdatetime\(|\bEXISTS\s*\{|\bIF\s+NOT\s+EXISTS\b`)
+
+// TestMemgraphStatements runs the statements of an import and a migration
+// through a MemgraphTarget and checks none of the Neo4j-only constructs
+// reach Memgraph.
+func TestMemgraphStatements(t *testing.T) {
+	rec := &recordTarget{}
+	target := NewMemgraphTarget(rec)
+	opts := Options{Tags: []string{"q3"}, TTL: time.Hour}
+	if err := CreateScan(target, "scan-1", "httpx.json", opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureIndexes(target); err != nil {
+		t.Fatal(err)
+	}
+	w := &Writer{ScanID: "scan-1", Tags: []string{"q3"}, Versioned: true, TTL: time.Hour}
+	_, err := target.Write(func(r Runner) (Stats, error) {
+		return w.Write(r, model.HttpxResult{
+			URL:   "https://a.example.com",
+			Title: "datetime() and EXISTS { (h) }",
+			ASN:   model.ASN{ASNumber: "AS64500"},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(target, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := FinishScan(target, "scan-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.stmts) == 0 {
+		t.Fatal("no statements written")
+	}
+	for _, stmt := range rec.stmts {
+		outsideLiterals(stmt, func(code string) string {
+			if m := neo4jOnly.FindString(code); m != "" {
+				t.Errorf("%q left in statement:\n%s", m, stmt)
+			}
+			return code
+		})
+	}
+}
+
+func TestMemgraphParams(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	local := neo4j.LocalDateTime(at.UTC())
+	tests := []struct {
+		name     string
+		in, want map[string]any
+	}{
+		{"nil", nil, nil},
+		{"plain", map[string]any{"url": "https://a", "port": 443}, map[string]any{"url": "https://a", "port": 443}},
+		{"time", map[string]any{"at": at}, map[string]any{"at": local}},
+		{
+			"nested",
+			map[string]any{"props": map[string]any{"seen": at, "tags": []any{"x", at}}},
+			map[string]any{"props": map[string]any{"seen": local, "tags": []any{"x", local}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := memgraphParams(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("memgraphParams(%v) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	in := map[string]any{"at": at}
+	memgraphParams(in)
+	if in["at"] != at {
+		t.Error("memgraphParams changed its argument")
+	}
+}
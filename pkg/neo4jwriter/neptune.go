@@ -0,0 +1,190 @@
+package neo4jwriter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// NeptuneTarget writes to Amazon Neptune through its openCypher HTTPS
+// endpoint, signing the requests with SigV4 for clusters with IAM
+// authentication.
+//
+// Neptune runs every request as its own transaction, so the statements of a
+// Write are not atomic together. It has no EXISTS subqueries and no
+// duration(), so existence subqueries become exists() pattern predicates
+// and the times of datetime() and TTLs are computed here. Times in
+// parameters are sent as RFC 3339 strings.
+type NeptuneTarget struct {
+	ctx      context.Context
+	endpoint string
+	client   *http.Client
+	config   aws.Config
+	signer   *v4.Signer
+}
+
+// NewNeptuneTarget returns a target for the Neptune endpoint, e.g.
+// https://my-cluster.cluster-xxxx.eu-west-1.neptune.amazonaws.com:8182.
+// Requests are signed with the credentials and region of config, or sent
+// unsigned when it has no credentials. ctx bounds every request.
+func NewNeptuneTarget(ctx context.Context, endpoint string, config aws.Config) *NeptuneTarget {
+	return &NeptuneTarget{
+		ctx:      ctx,
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/openCypher",
+		client:   &http.Client{Timeout: 2 * time.Minute},
+		config:   config,
+		signer:   v4.NewSigner(),
+	}
+}
+
+func (t *NeptuneTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return work(t)
+}
+
+func (t *NeptuneTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// Run sends the statement to Neptune and returns its rows.
+func (t *NeptuneTarget) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	cypher = NeptuneCypher(cypher, params, time.Now())
+	form := url.Values{"query": {cypher}}
+	if len(params) > 0 {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("parameters", string(data))
+	}
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if t.config.Credentials != nil {
+		creds, err := t.config.Credentials.Retrieve(t.ctx)
+		if err != nil {
+			return nil, fmt.Errorf("AWS credentials: %w", err)
+		}
+		hash := sha256.Sum256(body)
+		if err := t.signer.SignHTTP(t.ctx, creds, req, hex.EncodeToString(hash[:]), "neptune-db", t.config.Region, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `json:"code"`
+			Message string `json:"detailedMessage"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			return nil, fmt.Errorf("Neptune: %s: %s", resp.Status, bytes.TrimSpace(data))
+		}
+		return nil, fmt.Errorf("Neptune: %s: %s", e.Code, e.Message)
+	}
+
+	var out struct {
+		Results []map[string]any `json:"results"`
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("Neptune response: %w", err)
+	}
+	return neptuneRows(out.Results), nil
+}
+
+// neptuneRows returns the rows of a response as a result. Neptune returns
+// rows as JSON objects, so the columns are in the order of their names.
+func neptuneRows(results []map[string]any) *rowsResult {
+	var keys []string
+	if len(results) > 0 {
+		for k := range results[0] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	rows := make([][]any, len(results))
+	for i, row := range results {
+		values := make([]any, len(keys))
+		for j, k := range keys {
+			values[j] = neptuneValue(row[k])
+		}
+		rows[i] = values
+	}
+	return newRowsResult(keys, rows)
+}
+
+// neptuneValue converts the numbers of a JSON value to int64 or float64, as
+// the driver returns them.
+func neptuneValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, item := range v {
+			v[i] = neptuneValue(item)
+		}
+		return v
+	case map[string]any:
+		for k, item := range v {
+			v[k] = neptuneValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+var (
+	datetimeNow = regexp.MustCompile(`\bdatetime\(\)`)
+	expiresAt   = regexp.MustCompile(`\bdatetime\(\) \+ duration\(\$ttl\)`)
+)
+
+// NeptuneCypher rewrites a statement for Neptune, with now as the time of
+// datetime(). The $ttl parameter of expiryCypher is resolved from params.
+// String literals are left as they are.
+func NeptuneCypher(cypher string, params map[string]any, now time.Time) string {
+	now = now.UTC()
+	return outsideLiterals(cypher, func(cypher string) string {
+		cypher = expiresAt.ReplaceAllStringFunc(cypher, func(string) string {
+			ttl, _ := params["ttl"].(string)
+			secs, _ := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(ttl, "PT"), "S"), 10, 64)
+			return neptuneDatetime(now.Add(time.Duration(secs) * time.Second))
+		})
+		cypher = datetimeNow.ReplaceAllString(cypher, neptuneDatetime(now))
+		return existsPattern.ReplaceAllString(cypher, "exists($1)")
+	})
+}
+
+func neptuneDatetime(t time.Time) string {
+	return "datetime('" + t.Format("2006-01-02T15:04:05.000Z") + "')"
+}
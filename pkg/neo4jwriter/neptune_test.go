@@ -0,0 +1,42 @@
+package neo4jwriter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeptuneCypher(t *testing.T) {
+	now := time.Date(2024, 5, 1, 14, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "datetime",
+			in:   "SET h.last_seen = datetime()",
+			want: "SET h.last_seen = datetime('2024-05-01T12:30:00.000Z')",
+		},
+		{
+			name: "expiry",
+			in:   "SET h.last_seen = datetime()" + expiryCypher("h", time.Hour),
+			want: "SET h.last_seen = datetime('2024-05-01T12:30:00.000Z'), h.expires_at = datetime('2024-05-01T13:30:00.000Z')",
+		},
+		{
+			name: "literal",
+			in:   "SET h.note = 'datetime() and EXISTS { (h) }', h.seen = datetime()",
+			want: "SET h.note = 'datetime() and EXISTS { (h) }', h.seen = datetime('2024-05-01T12:30:00.000Z')",
+		},
+		{
+			name: "exists",
+			in:   "WHERE NOT EXISTS {\n\t(h)-[:SEEN_IN]->(:Scan {id: $id})\n}",
+			want: "WHERE NOT exists((h)-[:SEEN_IN]->(:Scan {id: $id}))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NeptuneCypher(tt.in, map[string]any{"ttl": ttlParam(time.Hour)}, now)
+			if got != tt.want {
+				t.Errorf("NeptuneCypher(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package neo4jwriter
+
+// Projects keep independent engagements apart in one database. Every node is
+// merged on its key plus the project property, so the same host imported for
+// two projects becomes two nodes. Imports without a project use the empty
+// project, so they do not match the nodes of a project either.
+
+// projectKey returns the map of properties a node is merged on: key plus
+// project: $project, which is empty without a project.
+func projectKey(key string) string {
+	return "{" + key + ", project: $project}"
+}
+
+// ProjectCond returns a condition limiting node variable v to $project, or
+// to all nodes when $project is empty.
+func ProjectCond(v string) string {
+	return "($project = '' OR " + v + ".project = $project)"
+}
+
+// projectSet returns the clause that sets the project of node variable v, if
+// there is one.
+func projectSet(v, project string) string {
+	if project == "" {
+		return ""
+	}
+	return "SET " + v + ".project = $project\n"
+}
@@ -0,0 +1,108 @@
+package neo4jwriter
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+var (
+	mergePattern = regexp.MustCompile("MERGE \\(\\w+:`?(\\w+)`? \\{([^}]*)\\}\\)")
+	keyPattern   = regexp.MustCompile("`?(\\w+)`?: (\\$\\w+|'[^']*')")
+)
+
+// mergeGraph is a graph of labelled nodes that only runs the node MERGEs of
+// the statements written to it: a node is matched when it has every
+// property of the key, and created otherwise.
+type mergeGraph struct {
+	nodes []mergeNode
+}
+
+type mergeNode struct {
+	label string
+	props map[string]any
+}
+
+func (g *mergeGraph) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return work(g)
+}
+
+func (g *mergeGraph) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	for _, m := range mergePattern.FindAllStringSubmatch(cypher, -1) {
+		key := map[string]any{}
+		for _, kv := range keyPattern.FindAllStringSubmatch(m[2], -1) {
+			if kv[2][0] == '$' {
+				key[kv[1]] = params[kv[2][1:]]
+			} else {
+				key[kv[1]] = kv[2][1 : len(kv[2])-1]
+			}
+		}
+		g.merge(m[1], key)
+	}
+	return nil, nil
+}
+
+func (g *mergeGraph) Close() error { return nil }
+
+func (g *mergeGraph) merge(label string, key map[string]any) {
+	for _, n := range g.nodes {
+		if n.label == label && matches(n.props, key) {
+			return
+		}
+	}
+	g.nodes = append(g.nodes, mergeNode{label, key})
+}
+
+func matches(props, key map[string]any) bool {
+	for k, v := range key {
+		if p, ok := props[k]; !ok || p != v {
+			return false
+		}
+	}
+	return true
+}
+
+// count returns the number of nodes with label and project.
+func (g *mergeGraph) count(label, project string) int {
+	n := 0
+	for _, node := range g.nodes {
+		if node.label == label && node.props["project"] == project {
+			n++
+		}
+	}
+	return n
+}
+
+// TestProjectKeyMixedGraph imports the same host with and without a project
+// into one graph, and checks that they stay two nodes.
+func TestProjectKeyMixedGraph(t *testing.T) {
+	g := &mergeGraph{}
+	result := model.HttpxResult{URL: "https://a.example.com", ASN: model.ASN{ASNumber: "AS64500"}}
+	entity := model.Entity{Label: "Domain", Key: map[string]any{"name": "example.com"}}
+
+	for _, project := range []string{"acme", "", "acme", "", "globex"} {
+		w := &Writer{ScanID: "scan-1", Project: project}
+		_, err := g.Write(func(r Runner) (Stats, error) {
+			if _, err := w.Write(r, result); err != nil {
+				return Stats{}, err
+			}
+			return w.WriteEntities(r, []model.Entity{entity}, nil)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, label := range []string{"Host", "ASN", "Domain"} {
+		for _, project := range []string{"acme", "", "globex"} {
+			if n := g.count(label, project); n != 1 {
+				t.Errorf("%d %s nodes in project %q, want 1", n, label, project)
+			}
+		}
+	}
+	if len(g.nodes) != 9 {
+		t.Errorf("%d nodes, want 9: %v", len(g.nodes), g.nodes)
+	}
+}
@@ -0,0 +1,104 @@
+package neo4jwriter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// recordReader reads the records of an input one by one.
+type recordReader interface {
+	Next() bool
+	// Record returns the current record, trimmed; it is empty for a blank
+	// line.
+	Record() []byte
+	// Size is the number of input bytes the current record took.
+	Size() int
+	Err() error
+}
+
+// newRecordReader reads r as a single top-level JSON array when it starts
+// with [, and as JSON Lines otherwise.
+func newRecordReader(r io.Reader) recordReader {
+	br := bufio.NewReader(r)
+	if startsWithArray(br) {
+		return &arrayReader{dec: json.NewDecoder(br)}
+	}
+	return &lineReader{scanner: bufio.NewScanner(br)}
+}
+
+// startsWithArray reports whether the first character of br after
+// whitespace is [, without consuming it.
+func startsWithArray(br *bufio.Reader) bool {
+	for n := 1; n <= br.Size(); n++ {
+		b, _ := br.Peek(n)
+		if len(b) < n {
+			return false
+		}
+		switch c := b[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c == '['
+		}
+	}
+	return false
+}
+
+type lineReader struct {
+	scanner *bufio.Scanner
+}
+
+func (l *lineReader) Next() bool     { return l.scanner.Scan() }
+func (l *lineReader) Record() []byte { return bytes.TrimSpace(l.scanner.Bytes()) }
+func (l *lineReader) Size() int      { return len(l.scanner.Bytes()) + 1 }
+func (l *lineReader) Err() error     { return l.scanner.Err() }
+
+// arrayReader streams the elements of a JSON array, so large array exports
+// are not read into memory at once.
+type arrayReader struct {
+	dec     *json.Decoder
+	started bool
+	record  bytes.Buffer
+	size    int
+	err     error
+}
+
+func (a *arrayReader) Next() bool {
+	if a.err != nil {
+		return false
+	}
+	if !a.started {
+		a.started = true
+		if _, err := a.dec.Token(); err != nil {
+			a.err = err
+			return false
+		}
+	}
+	if !a.dec.More() {
+		if _, err := a.dec.Token(); err != nil {
+			a.err = err
+		}
+		return false
+	}
+
+	start := a.dec.InputOffset()
+	var raw json.RawMessage
+	if err := a.dec.Decode(&raw); err != nil {
+		a.err = fmt.Errorf("JSON array element: %w", err)
+		return false
+	}
+	a.size = int(a.dec.InputOffset() - start)
+	// Elementen kunnen over meerdere regels staan; als één regel doorgeven.
+	a.record.Reset()
+	if err := json.Compact(&a.record, raw); err != nil {
+		a.err = err
+		return false
+	}
+	return true
+}
+
+func (a *arrayReader) Record() []byte { return a.record.Bytes() }
+func (a *arrayReader) Size() int      { return a.size }
+func (a *arrayReader) Err() error     { return a.err }
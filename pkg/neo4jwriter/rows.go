@@ -0,0 +1,95 @@
+package neo4jwriter
+
+import (
+	"errors"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// rowsResult is a neo4j.Result over rows returned by a database that is not
+// reached through the driver, such as Neptune's HTTPS endpoint. Consume
+// returns a summary with the counters the database reported, zero when it
+// reports none; its other methods must not be used.
+type rowsResult struct {
+	keys     []string
+	records  []*neo4j.Record
+	i        int
+	counters rowsCounters
+}
+
+func newRowsResult(keys []string, rows [][]any) *rowsResult {
+	r := &rowsResult{keys: keys}
+	for _, values := range rows {
+		r.records = append(r.records, &neo4j.Record{Keys: keys, Values: values})
+	}
+	return r
+}
+
+func (r *rowsResult) Keys() ([]string, error) { return r.keys, nil }
+func (r *rowsResult) Err() error              { return nil }
+
+func (r *rowsResult) Next() bool {
+	if r.i >= len(r.records) {
+		return false
+	}
+	r.i++
+	return true
+}
+
+func (r *rowsResult) NextRecord(record **neo4j.Record) bool {
+	ok := r.Next()
+	*record = r.Record()
+	return ok
+}
+
+func (r *rowsResult) PeekRecord(record **neo4j.Record) bool {
+	if r.i >= len(r.records) {
+		*record = nil
+		return false
+	}
+	*record = r.records[r.i]
+	return true
+}
+
+func (r *rowsResult) Record() *neo4j.Record {
+	if r.i == 0 || r.i > len(r.records) {
+		return nil
+	}
+	return r.records[r.i-1]
+}
+
+func (r *rowsResult) Collect() ([]*neo4j.Record, error) {
+	rest := r.records[r.i:]
+	r.i = len(r.records)
+	return rest, nil
+}
+
+func (r *rowsResult) Single() (*neo4j.Record, error) {
+	if len(r.records)-r.i != 1 {
+		return nil, errors.New("result does not have exactly one record")
+	}
+	r.i++
+	return r.Record(), nil
+}
+
+func (r *rowsResult) Consume() (neo4j.ResultSummary, error) {
+	r.i = len(r.records)
+	return rowsSummary{counters: r.counters}, nil
+}
+
+type rowsSummary struct {
+	neo4j.ResultSummary
+	counters rowsCounters
+}
+
+func (s rowsSummary) Counters() neo4j.Counters { return s.counters }
+
+type rowsCounters struct {
+	neo4j.Counters
+	nodes, rels, props int
+}
+
+func (c rowsCounters) ContainsUpdates() bool     { return c.nodes+c.rels+c.props > 0 }
+func (c rowsCounters) NodesCreated() int         { return c.nodes }
+func (c rowsCounters) RelationshipsCreated() int { return c.rels }
+func (c rowsCounters) PropertiesSet() int        { return c.props }
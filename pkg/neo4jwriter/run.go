@@ -0,0 +1,81 @@
+package neo4jwriter
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// Event reports the progress of an import after every processed line.
+type Event struct {
+	// Record is the record written for the line, or nil when the line was
+	// skipped, filtered or failed, or not an httpx record.
+	Record *model.HttpxResult
+	// Bytes is the size of the line in the input.
+	Bytes int
+	// Summary holds the counts so far.
+	Summary Summary
+}
+
+// Progress is an import started with Run.
+type Progress struct {
+	events  chan Event
+	done    chan struct{}
+	summary *Summary
+	err     error
+}
+
+// Run imports the JSON lines of r into opts.Target in the background, as a
+// new scan recording opts.Source as its file. Cancelling ctx stops the
+// import.
+func Run(ctx context.Context, r io.Reader, opts Options) *Progress {
+	p := &Progress{events: make(chan Event, 64), done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		defer close(p.events)
+		if opts.Target == nil {
+			p.err = errors.New("neo4jwriter: Run needs Options.Target")
+			return
+		}
+		opts.Observer = &eventObserver{ctx: ctx, events: p.events, next: opts.Observer}
+		p.summary, p.err = Import(ctx, opts.Target, r, opts.Source, opts)
+	}()
+	return p
+}
+
+// Events returns the progress events; the channel is closed when the import
+// ends. The import waits for events to be received, so a caller that does
+// not want them should call Wait right away.
+func (p *Progress) Events() <-chan Event {
+	return p.events
+}
+
+// Wait discards the remaining events, waits for the import to end and
+// returns its summary, and an error when the scan could not be created,
+// reading failed or ctx was cancelled.
+func (p *Progress) Wait() (*Summary, error) {
+	for range p.events {
+	}
+	<-p.done
+	return p.summary, p.err
+}
+
+// eventObserver sends the observed lines as events, and passes them on to
+// the Observer of the options.
+type eventObserver struct {
+	ctx    context.Context
+	events chan<- Event
+	next   Observer
+}
+
+func (o *eventObserver) Observe(result *model.HttpxResult, n int, summary Summary) {
+	if o.next != nil {
+		o.next.Observe(result, n, summary)
+	}
+	select {
+	case o.events <- Event{Record: result, Bytes: n, Summary: summary}:
+	case <-o.ctx.Done():
+	}
+}
@@ -0,0 +1,102 @@
+package neo4jwriter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// NewScanID returns a unique id for an import run, sortable by start time.
+func NewScanID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// CreateScan creates the Scan node that records the provenance of an import:
+// where the data came from, who imported it and with which version. source is
+// the absolute path of the input file, or where it came from. It fails on a
+// graph of a newer schema version, see SchemaVersion.
+func CreateScan(t Target, scanID, source string, opts Options) error {
+	if g, ok := t.(GraphTarget); ok {
+		if err := createGraphScan(g, scanID, source, opts); err != nil {
+			return fmt.Errorf("Scan write error: %w", err)
+		}
+		return nil
+	}
+	if err := checkSchema(t, opts.Logger); err != nil {
+		return err
+	}
+	if hasFulltext(t) {
+		if err := EnsureIndexes(t); err != nil {
+			msg := fmt.Sprintf("Full-text indexes not created, search will not work: %v", err)
+			if opts.Logger != nil {
+				opts.Logger.Print(msg)
+			} else {
+				log.Print(msg)
+			}
+		}
+	}
+	_, err := t.Write(func(r Runner) (Stats, error) {
+		_, err := r.Run(`
+		MERGE (s:Scan {id: $id})
+		SET s.file         = $file,
+		    s.started_at   = datetime(),
+		    s.imported_by  = $imported_by,
+		    s.hostname     = $hostname,
+		    s.tool         = 'jsontoneo',
+		    s.tool_version = $tool_version,
+		    s.tool_commit  = $tool_commit`+expiryCypher("s", opts.TTL)+`
+		`+projectSet("s", opts.Project)+tagCypher("s", opts.Tags, opts.TagLabels)+`
+		WITH s
+		OPTIONAL MATCH (b:Scan:Baseline) WHERE b <> s AND `+ProjectCond("b")+`
+		WITH s, b ORDER BY b.started_at DESC LIMIT 1
+		SET s.baseline = b.id
+		`, map[string]any{
+			"id":           scanID,
+			"file":         source,
+			"imported_by":  opts.Attribution.Operator,
+			"hostname":     opts.Attribution.Hostname,
+			"tool_version": opts.ToolVersion,
+			"tool_commit":  opts.ToolCommit,
+			"tags":         opts.Tags,
+			"project":      opts.Project,
+			"ttl":          ttlParam(opts.TTL),
+		})
+		return Stats{}, err
+	})
+	if err != nil {
+		return fmt.Errorf("Scan query error: %w", err)
+	}
+	return nil
+}
+
+// FinishScan marks the Scan node as completed.
+func FinishScan(t Target, scanID string) error {
+	if g, ok := t.(GraphTarget); ok {
+		_, err := g.WriteGraph(Graph{Entities: []model.Entity{{
+			Label: "Scan",
+			Key:   map[string]any{"id": scanID},
+			Props: map[string]any{"finished_at": time.Now().UTC()},
+		}}})
+		if err != nil {
+			return fmt.Errorf("Scan write error: %w", err)
+		}
+		return nil
+	}
+	_, err := t.Write(func(r Runner) (Stats, error) {
+		_, err := r.Run(`
+		MATCH (s:Scan {id: $id})
+		SET s.finished_at = datetime()
+		`, map[string]any{"id": scanID})
+		return Stats{}, err
+	})
+	if err != nil {
+		return fmt.Errorf("Scan query error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package neo4jwriter
+
+import (
+	"fmt"
+	"log"
+)
+
+// SchemaVersion is the version of the graph model this package writes. It is
+// stored on a (:Schema {name: 'jsontoneo'}) node; graphs of an older version
+// are upgraded with Migrate.
+const SchemaVersion = 4
+
+// minWriteVersion is the oldest schema version imports write to. Before
+// version 4 nodes without a project had no project property, which the
+// project key of their MERGE no longer matches.
+const minWriteVersion = 4
+
+// Migration upgrades a graph from version Version-1 to Version.
+type Migration struct {
+	Version     int
+	Description string
+	cypher      string
+}
+
+// Migrations lists every migration in order. A migration must be safe to run
+// on a graph that already partly has its changes.
+var Migrations = []Migration{
+	{
+		Version:     2,
+		Description: "Add first_seen and last_seen to hosts imported without them",
+		cypher: `
+		MATCH (h:Host) WHERE h.first_seen IS NULL OR h.last_seen IS NULL
+		SET h.first_seen = coalesce(h.first_seen, datetime()),
+		    h.last_seen  = coalesce(h.last_seen, h.first_seen)
+		`,
+	},
+	{
+		Version:     3,
+		Description: "Link hosts imported before scan tracking to a Scan {id: 'legacy'}",
+		cypher: `
+		MATCH (h:Host) WHERE NOT (h)-[:SEEN_IN]->(:Scan)
+		WITH collect(h) AS hosts WHERE size(hosts) > 0
+		MERGE (s:Scan {id: 'legacy'})
+		ON CREATE SET s.file        = 'imported before scan tracking',
+		              s.started_at  = datetime(),
+		              s.finished_at = datetime(),
+		              s.tool        = 'jsontoneo'
+		WITH s, hosts
+		UNWIND hosts AS h
+		MERGE (h)-[:SEEN_IN]->(s)
+		`,
+	},
+	{
+		Version:     4,
+		Description: "Give nodes imported without a project the empty project",
+		cypher: `
+		MATCH (n) WHERE n.project IS NULL AND NOT n:Schema AND NOT n:Scan
+		SET n.project = ''
+		`,
+	},
+}
+
+// GraphSchema returns the schema version of the graph. A graph without a
+// Schema node is given one: the current version when it is empty, or 1 when
+// it was created before versions were tracked. It returns 0 when the version
+// cannot be read, as with a Cypher script.
+func GraphSchema(t Target) (int, error) {
+	var version int
+	_, err := t.Write(func(r Runner) (Stats, error) {
+		res, err := r.Run(`
+		OPTIONAL MATCH (h:Host)
+		WITH count(h) AS hosts
+		MERGE (v:Schema {name: 'jsontoneo'})
+		ON CREATE SET v.version    = CASE WHEN hosts > 0 THEN 1 ELSE $version END,
+		              v.updated_at = datetime()
+		RETURN v.version
+		`, map[string]any{"version": SchemaVersion})
+		if err != nil || res == nil {
+			return Stats{}, err
+		}
+		if res.Next() {
+			if v, ok := res.Record().Values[0].(int64); ok {
+				version = int(v)
+			}
+		}
+		return Stats{}, res.Err()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("Schema query error: %w", err)
+	}
+	return version, nil
+}
+
+// ReadSchema returns the schema version GraphSchema would, without creating
+// the Schema node, so it can run in a read transaction.
+func ReadSchema(r Runner) (int, error) {
+	res, err := r.Run(`
+	OPTIONAL MATCH (v:Schema {name: 'jsontoneo'})
+	OPTIONAL MATCH (h:Host)
+	WITH v, count(h) AS hosts
+	RETURN coalesce(v.version, CASE WHEN hosts > 0 THEN 1 ELSE $version END)
+	`, map[string]any{"version": SchemaVersion})
+	if err != nil {
+		return 0, fmt.Errorf("Schema query error: %w", err)
+	}
+	rec, err := res.Single()
+	if err != nil {
+		return 0, fmt.Errorf("Schema query error: %w", err)
+	}
+	version, _ := rec.Values[0].(int64)
+	return int(version), nil
+}
+
+// Names of the full-text indexes EnsureIndexes creates.
+const (
+	HostTextIndex = "jsontoneo_host_text"
+	TechTextIndex = "jsontoneo_tech_text"
+)
+
+// EnsureIndexes creates the full-text indexes on the URL, title and web
+// server of hosts and on the names of Tech nodes, when they do not exist yet.
+// It needs Neo4j 4.3 or later.
+func EnsureIndexes(t Target) error {
+	// Schema-wijzigingen mogen niet in dezelfde transactie als writes.
+	for _, q := range []string{
+		"CREATE FULLTEXT INDEX " + HostTextIndex + " IF NOT EXISTS FOR (n:Host) ON EACH [n.url, n.title, n.webserver]",
+		"CREATE FULLTEXT INDEX " + TechTextIndex + " IF NOT EXISTS FOR (n:Tech) ON EACH [n.name]",
+	} {
+		_, err := t.Write(func(r Runner) (Stats, error) {
+			_, err := r.Run(q, nil)
+			return Stats{}, err
+		})
+		if err != nil {
+			return fmt.Errorf("Index query error: %w", err)
+		}
+	}
+	return nil
+}
+
+// hasFulltext reports whether t writes to Neo4j, whose full-text indexes
+// the other targets do not have.
+func hasFulltext(t Target) bool {
+	switch t := t.(type) {
+	case *Neo4jTarget:
+		return true
+	case *FanoutTarget:
+		return hasFulltext(t.primary)
+	}
+	return false
+}
+
+// checkSchema refuses to write to a graph of a newer schema version or one
+// older than minWriteVersion, and warns about an older one.
+func checkSchema(t Target, logger *log.Logger) error {
+	version, err := GraphSchema(t)
+	switch {
+	case err != nil:
+		return err
+	case version > SchemaVersion:
+		return fmt.Errorf("graph schema version %d is newer than this jsontoneo supports (%d), upgrade jsontoneo", version, SchemaVersion)
+	case version > 0 && version < minWriteVersion:
+		return fmt.Errorf("graph schema version %d is older than %d, run jsontoneo migrate before importing", version, minWriteVersion)
+	case version > 0 && version < SchemaVersion:
+		msg := fmt.Sprintf("Graph schema version %d is older than %d, run jsontoneo migrate to upgrade it", version, SchemaVersion)
+		if logger != nil {
+			logger.Print(msg)
+		} else {
+			log.Print(msg)
+		}
+	}
+	return nil
+}
+
+// Migrate runs the migrations from the graph's version up to SchemaVersion,
+// each in its own transaction with the version update. It calls done after
+// every migration.
+func Migrate(t Target, done func(m Migration, stats Stats)) error {
+	version, err := GraphSchema(t)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("graph schema version %d is newer than this jsontoneo supports (%d)", version, SchemaVersion)
+	}
+	for _, m := range Migrations {
+		if m.Version <= version {
+			continue
+		}
+		stats, err := t.Write(func(r Runner) (Stats, error) {
+			var stats Stats
+			res, err := r.Run(m.cypher, nil)
+			if err != nil {
+				return stats, err
+			}
+			if err := stats.consume(res, 0, 0); err != nil {
+				return stats, err
+			}
+			_, err = r.Run(`
+			MATCH (v:Schema {name: 'jsontoneo'})
+			SET v.version = $version, v.updated_at = datetime()
+			`, map[string]any{"version": m.Version})
+			return stats, err
+		})
+		if err != nil {
+			return fmt.Errorf("Migration %d query error: %w", m.Version, err)
+		}
+		if done != nil {
+			done(m, stats)
+		}
+	}
+	return nil
+}
+
+// PendingMigrations returns the migrations a graph of version still needs.
+func PendingMigrations(version int) []Migration {
+	var pending []Migration
+	for _, m := range Migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,107 @@
+package neo4jwriter
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// schemaTarget is a recordTarget whose schema version queries return
+// version.
+type schemaTarget struct {
+	recordTarget
+	version int64
+}
+
+func (t *schemaTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return work(t)
+}
+
+func (t *schemaTarget) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	t.recordTarget.Run(cypher, params)
+	if strings.Contains(cypher, "v.version") && strings.Contains(cypher, "RETURN") {
+		return newRowsResult([]string{"version"}, [][]any{{t.version}}), nil
+	}
+	return nil, nil
+}
+
+func TestReadSchema(t *testing.T) {
+	target := &schemaTarget{version: 2}
+	version, err := ReadSchema(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("ReadSchema = %d, want 2", version)
+	}
+	if write := regexp.MustCompile(`\b(MERGE|CREATE|SET|DELETE)\b`); write.MatchString(target.stmts[0]) {
+		t.Errorf("ReadSchema writes:\n%s", target.stmts[0])
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	target := &schemaTarget{version: 1}
+	var done []int
+	err := Migrate(target, func(m Migration, stats Stats) { done = append(done, m.Version) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != SchemaVersion-1 || done[0] != 2 || done[len(done)-1] != SchemaVersion {
+		t.Errorf("migrated to %v, want 2 up to %d", done, SchemaVersion)
+	}
+	// Na de GraphSchema-query volgt per migratie de migratie zelf en de
+	// versie-update.
+	for i, m := range done {
+		stmt, params := target.stmts[2+2*i], target.params[2+2*i]
+		if !strings.Contains(stmt, "SET v.version = $version") || params["version"] != m {
+			t.Errorf("statement %d = %s %v, want the update to version %d", 2+2*i, stmt, params, m)
+		}
+	}
+
+	if err := Migrate(&schemaTarget{version: SchemaVersion + 1}, nil); err == nil {
+		t.Error("Migrate of a newer graph succeeded, want an error")
+	}
+	current := &schemaTarget{version: SchemaVersion}
+	if err := Migrate(current, nil); err != nil || len(current.stmts) != 1 {
+		t.Errorf("Migrate of a current graph = %v after %d statements, want only the version read", err, len(current.stmts))
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	for version := 0; version <= SchemaVersion; version++ {
+		pending := PendingMigrations(version)
+		want := SchemaVersion - max(version, 1)
+		if len(pending) != want {
+			t.Errorf("PendingMigrations(%d) = %d migrations, want %d", version, len(pending), want)
+		}
+		for _, m := range pending {
+			if m.Version <= version {
+				t.Errorf("PendingMigrations(%d) includes version %d", version, m.Version)
+			}
+		}
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		version int64
+		err     string
+	}{
+		{version: 0},
+		{version: 1, err: "run jsontoneo migrate"},
+		{version: minWriteVersion - 1, err: "run jsontoneo migrate"},
+		{version: SchemaVersion},
+		{version: SchemaVersion + 1, err: "upgrade jsontoneo"},
+	}
+	for _, tt := range tests {
+		err := checkSchema(&schemaTarget{version: tt.version}, nil)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("checkSchema(%d) = %v, want nil", tt.version, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("checkSchema(%d) = %v, want %q", tt.version, err, tt.err)
+		}
+	}
+}
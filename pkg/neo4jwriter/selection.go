@@ -0,0 +1,48 @@
+package neo4jwriter
+
+import (
+	"math/rand"
+	"time"
+)
+
+// lineSelector decides which input lines are imported, based on the -skip,
+// -sample and -limit flags. Lines are first skipped, then sampled, and the
+// limit applies to the lines that remain.
+type lineSelector struct {
+	skip     int
+	limit    int
+	sample   float64
+	rng      *rand.Rand
+	seen     int
+	selected int
+}
+
+func newLineSelector(skip, limit int, sample float64, seed int64) *lineSelector {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &lineSelector{
+		skip:   skip,
+		limit:  limit,
+		sample: sample,
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// done reports whether the limit has been reached, so reading can stop.
+func (s *lineSelector) done() bool {
+	return s.limit > 0 && s.selected >= s.limit
+}
+
+// take reports whether the next line should be imported.
+func (s *lineSelector) take() bool {
+	s.seen++
+	if s.seen <= s.skip {
+		return false
+	}
+	if s.sample > 0 && s.sample < 100 && s.rng.Float64()*100 >= s.sample {
+		return false
+	}
+	s.selected++
+	return true
+}
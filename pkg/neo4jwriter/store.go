@@ -0,0 +1,118 @@
+package neo4jwriter
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+func init() {
+	gob.Register(time.Time{})
+	gob.Register([]any{})
+	gob.Register(map[string]any{})
+}
+
+// StoreTarget stages imports in a local directory, for use in the field
+// when the graph database cannot be reached. Every import becomes a file of
+// gob-encoded Graphs, with the times at which the records were seen; Replay
+// writes it to the database later.
+type StoreTarget struct {
+	mu   sync.Mutex
+	f    *os.File
+	enc  *gob.Encoder
+	path string
+}
+
+// NewStoreTarget creates the staging file of an import in dir, creating dir
+// when it does not exist.
+func NewStoreTarget(dir string) (*StoreTarget, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + ".gob"
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &StoreTarget{f: f, enc: gob.NewEncoder(f), path: f.Name()}, nil
+}
+
+// Path returns the staging file.
+func (t *StoreTarget) Path() string {
+	return t.path
+}
+
+// Write returns ErrNoCypher: the store is written with WriteGraph.
+func (t *StoreTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return Stats{}, ErrNoCypher
+}
+
+// WriteGraph appends g to the staging file. Nothing is created yet, so the
+// stats only count what is merged.
+func (t *StoreTarget) WriteGraph(g Graph) (Stats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.enc.Encode(g); err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{NodesMerged: len(g.Entities), RelsMerged: len(g.Relations)}
+	for _, e := range g.Entities {
+		stats.PropertiesSet += len(e.Props)
+	}
+	for _, r := range g.Relations {
+		stats.PropertiesSet += len(r.Props)
+	}
+	return stats, nil
+}
+
+func (t *StoreTarget) Close() error {
+	return t.f.Close()
+}
+
+// StagedFiles returns the staging files in dir, oldest first.
+func StagedFiles(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.gob"))
+	sort.Strings(files)
+	return files, err
+}
+
+// Replay writes the Graphs of a staging file to t, each in a transaction of
+// its own, and returns how many it wrote. A file that was cut off, by a
+// crash during the import, is replayed up to the last complete Graph.
+func Replay(t Target, path string) (int, Stats, error) {
+	var stats Stats
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, stats, err
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(f)
+	for n := 0; ; n++ {
+		var g Graph
+		if err := dec.Decode(&g); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return n, stats, nil
+			}
+			return n, stats, fmt.Errorf("%s: %w", path, err)
+		}
+		var s Stats
+		if gt, ok := t.(GraphTarget); ok {
+			s, err = gt.WriteGraph(g)
+		} else {
+			s, err = t.Write(func(r Runner) (Stats, error) {
+				return WriteGraph(r, g)
+			})
+		}
+		if err != nil {
+			return n, stats, err
+		}
+		stats.add(s)
+	}
+}
@@ -0,0 +1,137 @@
+package neo4jwriter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Stats counts what the queries for a single record did to the graph. The
+// merged counters are the number of MERGE clauses executed; whatever was
+// merged but not created already existed and was matched.
+type Stats struct {
+	NodesCreated  int
+	NodesMerged   int
+	RelsCreated   int
+	RelsMerged    int
+	PropertiesSet int
+}
+
+// consume reads the counters of res, for a query with the given number of
+// node and relationship MERGE clauses.
+// A nil res, from a target that does not execute statements, is ignored.
+func (s *Stats) consume(res neo4j.Result, nodes, rels int) error {
+	if res == nil {
+		return nil
+	}
+	summary, err := res.Consume()
+	if err != nil {
+		return err
+	}
+	counters := summary.Counters()
+	s.NodesCreated += counters.NodesCreated()
+	s.RelsCreated += counters.RelationshipsCreated()
+	s.PropertiesSet += counters.PropertiesSet()
+	s.NodesMerged += nodes
+	s.RelsMerged += rels
+	return nil
+}
+
+func (s *Stats) add(o Stats) {
+	s.NodesCreated += o.NodesCreated
+	s.NodesMerged += o.NodesMerged
+	s.RelsCreated += o.RelsCreated
+	s.RelsMerged += o.RelsMerged
+	s.PropertiesSet += o.PropertiesSet
+}
+
+// Summary counts the records of an import and what they did to the graph.
+type Summary struct {
+	ScanID               string  `json:"scan_id"`
+	File                 string  `json:"file"`
+	Read                 int     `json:"records_read"`
+	Parsed               int     `json:"records_parsed"`
+	Skipped              int     `json:"records_skipped"`
+	ParseErrors          int     `json:"parse_errors"`
+	Filtered             int     `json:"records_filtered"`
+	OutOfScope           int     `json:"records_out_of_scope"`
+	Written              int     `json:"records_written"`
+	Failed               int     `json:"records_failed"`
+	QuotaExceeded        bool    `json:"quota_exceeded"`
+	OverQuota            int     `json:"records_over_quota"`
+	NodesOverQuota       int     `json:"nodes_over_quota"`
+	NodesCreated         int     `json:"nodes_created"`
+	NodesMatched         int     `json:"nodes_matched"`
+	RelationshipsCreated int     `json:"relationships_created"`
+	RelationshipsMatched int     `json:"relationships_matched"`
+	PropertiesSet        int     `json:"properties_set"`
+	ElapsedSeconds       float64 `json:"elapsed_seconds"`
+	RecordsPerSecond     float64 `json:"records_per_second"`
+	// MirrorFailures counts the failed writes per mirror of a FanoutTarget.
+	MirrorFailures map[string]int `json:"mirror_failures,omitempty"`
+
+	elapsed time.Duration
+}
+
+// Add counts the stats of a written record.
+func (s *Summary) Add(stats Stats) {
+	s.NodesCreated += stats.NodesCreated
+	s.NodesMatched += stats.NodesMerged - stats.NodesCreated
+	s.RelationshipsCreated += stats.RelsCreated
+	s.RelationshipsMatched += stats.RelsMerged - stats.RelsCreated
+	s.PropertiesSet += stats.PropertiesSet
+}
+
+// Finish records how long the import took.
+func (s *Summary) Finish(elapsed time.Duration) {
+	s.elapsed = elapsed
+	s.ElapsedSeconds = elapsed.Seconds()
+	if s.ElapsedSeconds > 0 {
+		s.RecordsPerSecond = float64(s.Read) / s.ElapsedSeconds
+	}
+}
+
+// Elapsed returns the duration recorded by Finish.
+func (s *Summary) Elapsed() time.Duration {
+	return s.elapsed
+}
+
+// Print writes the summary to w as "text" or "json".
+func (s *Summary) Print(w io.Writer, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+
+	fmt.Fprintf(w, "\nImport summary (scan %s)\n", s.ScanID)
+	fmt.Fprintf(w, "  Records read:      %d\n", s.Read)
+	fmt.Fprintf(w, "  Records parsed:    %d\n", s.Parsed)
+	fmt.Fprintf(w, "  Records skipped:   %d\n", s.Skipped)
+	fmt.Fprintf(w, "  Parse errors:      %d\n", s.ParseErrors)
+	fmt.Fprintf(w, "  Records filtered:  %d\n", s.Filtered)
+	fmt.Fprintf(w, "  Out of scope:      %d\n", s.OutOfScope)
+	fmt.Fprintf(w, "  Records written:   %d\n", s.Written)
+	fmt.Fprintf(w, "  Records failed:    %d\n", s.Failed)
+	if s.QuotaExceeded {
+		fmt.Fprintf(w, "  Over quota:        %d records not written, %d nodes not created\n", s.OverQuota, s.NodesOverQuota)
+	}
+	fmt.Fprintf(w, "  Nodes:             %d created, %d matched\n", s.NodesCreated, s.NodesMatched)
+	fmt.Fprintf(w, "  Relationships:     %d created, %d matched\n", s.RelationshipsCreated, s.RelationshipsMatched)
+	fmt.Fprintf(w, "  Properties set:    %d\n", s.PropertiesSet)
+	fmt.Fprintf(w, "  Elapsed:           %s\n", s.elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "  Throughput:        %.1f records/s\n", s.RecordsPerSecond)
+	mirrors := make([]string, 0, len(s.MirrorFailures))
+	for name := range s.MirrorFailures {
+		mirrors = append(mirrors, name)
+	}
+	sort.Strings(mirrors)
+	for _, name := range mirrors {
+		fmt.Fprintf(w, "  Mirror %-11s %d writes failed\n", name+":", s.MirrorFailures[name])
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package neo4jwriter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tagCypher returns the Cypher clauses that add $tags to the tags array
+// property of node variable v and, with asLabels, add them as labels too.
+// It returns an empty string when there are no tags.
+func tagCypher(v string, tags []string, asLabels bool) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	clause := fmt.Sprintf("SET %[1]s.tags = coalesce(%[1]s.tags, []) + [t IN $tags WHERE NOT t IN coalesce(%[1]s.tags, [])]\n", v)
+	if asLabels {
+		var b strings.Builder
+		for _, t := range tags {
+			b.WriteString(":" + QuoteLabel(t))
+		}
+		clause += fmt.Sprintf("SET %s%s\n", v, b.String())
+	}
+	return clause
+}
+
+// QuoteLabel quotes a label name so tags such as "bugcrowd-acme" can be used
+// as labels.
+func QuoteLabel(label string) string {
+	return "`" + strings.ReplaceAll(label, "`", "``") + "`"
+}
@@ -0,0 +1,191 @@
+package neo4jwriter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Runner runs a Cypher statement. neo4j.Transaction implements it.
+type Runner interface {
+	Run(cypher string, params map[string]any) (neo4j.Result, error)
+}
+
+// Target is where an import writes its statements: a Neo4j database or a
+// Cypher script.
+type Target interface {
+	// Write runs work as a single unit, e.g. one transaction.
+	Write(work func(r Runner) (Stats, error)) (Stats, error)
+	Close() error
+}
+
+type Neo4jTarget struct {
+	driver  neo4j.Driver
+	session neo4j.Session
+}
+
+// NewNeo4jTarget opens a write session on driver, which in a cluster routes
+// every transaction to the leader. configure can change the session, e.g. to
+// set a BookmarkManager. Closing the target closes the session; the driver is
+// owned by the caller.
+func NewNeo4jTarget(driver neo4j.Driver, configure ...func(*neo4j.SessionConfig)) *Neo4jTarget {
+	config := neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite}
+	for _, c := range configure {
+		c(&config)
+	}
+	return &Neo4jTarget{
+		driver:  driver,
+		session: driver.NewSession(config),
+	}
+}
+
+func (t *Neo4jTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	stats, err := t.session.WriteTransaction(func(tx neo4j.Transaction) (any, error) {
+		return work(tx)
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	return stats.(Stats), nil
+}
+
+func (t *Neo4jTarget) Close() error {
+	return t.session.Close()
+}
+
+// LastBookmarks returns the bookmarks of the last write, for a later session
+// that must see it.
+func (t *Neo4jTarget) LastBookmarks() neo4j.Bookmarks {
+	return t.session.LastBookmarks()
+}
+
+// ScriptTarget writes the statements of an import to a file, with their
+// parameters inlined, instead of executing them. Because the statements MERGE
+// on the node keys the script is idempotent and can be reviewed, versioned or
+// replayed with cypher-shell.
+type ScriptTarget struct {
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewScriptTarget creates the script at path. toolVersion is recorded in its
+// header.
+func NewScriptTarget(path, toolVersion string) (*ScriptTarget, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "// Generated by jsontoneo %s\n// Replay with: cypher-shell -f %s\n\n", toolVersion, path)
+	return &ScriptTarget{file: file, w: w}, nil
+}
+
+func (t *ScriptTarget) Write(work func(r Runner) (Stats, error)) (Stats, error) {
+	return work(t)
+}
+
+// Run writes the statement to the script. It returns a nil result, as
+// nothing is executed.
+func (t *ScriptTarget) Run(cypher string, params map[string]any) (neo4j.Result, error) {
+	stmt := inlineParams(cypher, params)
+	_, err := fmt.Fprintf(t.w, "%s;\n\n", stmt)
+	return nil, err
+}
+
+func (t *ScriptTarget) Close() error {
+	if err := t.w.Flush(); err != nil {
+		t.file.Close()
+		return err
+	}
+	return t.file.Close()
+}
+
+var paramPattern = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// inlineParams replaces the $parameters in cypher by their values as Cypher
+// literals and strips the indentation of the statement. A $ inside a string
+// literal is left as is.
+func inlineParams(cypher string, params map[string]any) string {
+	cypher = outsideLiterals(cypher, func(cypher string) string {
+		return paramPattern.ReplaceAllStringFunc(cypher, func(p string) string {
+			v, ok := params[p[1:]]
+			if !ok {
+				return p
+			}
+			return cypherLiteral(v)
+		})
+	})
+
+	// De inspringing van de eerste regel is die van de statement; regels
+	// die tagCypher en dergelijke zonder inspringing invoegen blijven staan.
+	var lines []string
+	indent := -1
+	for _, line := range strings.Split(cypher, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 {
+			indent = n
+		}
+		lines = append(lines, strings.TrimRight(line[min(n, indent):], " \t"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cypherLiteral renders v as a Cypher literal.
+func cypherLiteral(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(v) + "'"
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return "datetime('" + v.Format(time.RFC3339Nano) + "')"
+	case neo4j.LocalDateTime:
+		return "localDateTime('" + v.Time().Format("2006-01-02T15:04:05.000000") + "')"
+	case []string:
+		if v == nil {
+			return "null"
+		}
+		items := make([]string, len(v))
+		for i, s := range v {
+			items[i] = cypherLiteral(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case []any:
+		items := make([]string, len(v))
+		for i, s := range v {
+			items[i] = cypherLiteral(s)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, len(keys))
+		for i, k := range keys {
+			items[i] = QuoteLabel(k) + ": " + cypherLiteral(v[k])
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return cypherLiteral(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,120 @@
+package neo4jwriter
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestInlineParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		cypher string
+		params map[string]any
+		want   string
+	}{
+		{
+			name:   "params",
+			cypher: "MERGE (h:Host {url: $url}) SET h.port = $port, h.tech = $tech",
+			params: map[string]any{"url": "https://a", "port": 443, "tech": []string{"nginx"}},
+			want:   "MERGE (h:Host {url: 'https://a'}) SET h.port = 443, h.tech = ['nginx']",
+		},
+		{
+			name:   "missing param",
+			cypher: "SET h.project = $project",
+			want:   "SET h.project = $project",
+		},
+		{
+			name:   "param in literal",
+			cypher: "SET h.note = 'costs $url', h.url = $url, h.`$url` = 1",
+			params: map[string]any{"url": "https://a"},
+			want:   "SET h.note = 'costs $url', h.url = 'https://a', h.`$url` = 1",
+		},
+		{
+			name:   "value with quotes",
+			cypher: "SET h.title = $title, h.url = $url",
+			params: map[string]any{"title": "it's $url", "url": "https://a"},
+			want:   `SET h.title = 'it\'s $url', h.url = 'https://a'`,
+		},
+		{
+			name:   "indentation",
+			cypher: "\n\tMERGE (h:Host {url: $url})\n\tSET h.a = 1,\n\t    h.b = 2\nSET h:Tagged\n\tRETURN h\n\t",
+			params: map[string]any{"url": "https://a"},
+			want:   "MERGE (h:Host {url: 'https://a'})\nSET h.a = 1,\n    h.b = 2\nSET h:Tagged\nRETURN h",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inlineParams(tt.cypher, tt.params); got != tt.want {
+				t.Errorf("inlineParams(%q)\n got %q\nwant %q", tt.cypher, got, tt.want)
+			}
+		})
+	}
+}
+
+type scopeFunc func(result *model.HttpxResult) bool
+
+func (f scopeFunc) InScope(result *model.HttpxResult) bool { return f(result) }
+
+// TestScriptGolden compares the script of a small import with
+// testdata/import.cypher; go test -update rewrites it.
+func TestScriptGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.cypher")
+	script, err := NewScriptTarget(path, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Tags: []string{"q3", "bug-bounty"}, TagLabels: true, TTL: 24 * time.Hour}
+	if err := CreateScan(script, "scan-1", "httpx.json", opts); err != nil {
+		t.Fatal(err)
+	}
+	w := &Writer{
+		ScanID:    "scan-1",
+		Tags:      opts.Tags,
+		TagLabels: true,
+		TTL:       opts.TTL,
+		Versioned: true,
+		Scope:     scopeFunc(func(result *model.HttpxResult) bool { return strings.HasSuffix(result.Host, ".1") }),
+	}
+	for _, result := range []model.HttpxResult{
+		{URL: "https://a.example.com", Host: "10.0.0.1", Title: "Costs $5 'a month'", Status: 200, Tech: []string{"nginx"},
+			ASN: model.ASN{ASNumber: "AS64500", ASName: "EXAMPLE"}},
+		{URL: "http://b.example.com", Host: "10.0.0.2", Status: 404},
+	} {
+		if _, err := script.Write(func(r Runner) (Stats, error) { return w.Write(r, result) }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := FinishScan(script, "scan-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := script.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.ReplaceAll(string(data), path, "import.cypher")
+	golden := filepath.Join("testdata", "import.cypher")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("script differs from %s (go test -update rewrites it):\n%s", golden, got)
+	}
+}
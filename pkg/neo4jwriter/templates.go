@@ -0,0 +1,136 @@
+package neo4jwriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Templates are user-supplied Cypher statements, written as Go templates,
+// that run for every record next to or instead of the built-in queries.
+//
+// A template is executed with a TemplateData and renders one statement; a
+// template that renders only whitespace is skipped for that record. Values
+// from the record are best passed as parameters: the statement gets $record,
+// $scan_id, $project and $tags, and {{param .Record.title}} adds any value as
+// a parameter. {{label .Record.kind}} quotes a value for use as a label or
+// relationship type.
+type Templates struct {
+	// Replace runs the templates instead of the built-in queries.
+	Replace bool
+
+	mu    sync.Mutex
+	list  []*template.Template
+	extra map[string]any
+}
+
+// TemplateData is what a Cypher template is executed with.
+type TemplateData struct {
+	// Record is the parsed record as decoded JSON.
+	Record  any
+	ScanID  string
+	Project string
+	Tags    []string
+}
+
+// LoadTemplates parses the Cypher template files at paths.
+func LoadTemplates(paths []string, replace bool) (*Templates, error) {
+	t := &Templates{Replace: replace}
+	funcs := template.FuncMap{
+		"param": t.param,
+		"label": QuoteLabel,
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(filepath.Base(path)).Funcs(funcs).Option("missingkey=zero").Parse(string(data))
+		if err != nil {
+			return nil, err
+		}
+		t.list = append(t.list, tmpl)
+	}
+	return t, nil
+}
+
+// param adds v as a parameter of the statement being rendered and returns
+// its name.
+func (t *Templates) param(v any) string {
+	name := fmt.Sprintf("p%d", len(t.extra))
+	t.extra[name] = v
+	return "$" + name
+}
+
+// write renders the templates for record and runs the statements.
+func (t *Templates) write(tx Runner, w *Writer, record any) (Stats, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var stats Stats
+	data := TemplateData{Record: record, ScanID: w.ScanID, Project: w.Project, Tags: w.Tags}
+	for _, tmpl := range t.list {
+		t.extra = make(map[string]any)
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return stats, err
+		}
+		if strings.TrimSpace(b.String()) == "" {
+			continue
+		}
+
+		params := w.params(map[string]any{
+			"record":  record,
+			"scan_id": w.ScanID,
+			"project": w.Project,
+			"tags":    w.Tags,
+		})
+		maps.Copy(params, t.extra)
+		res, err := tx.Run(b.String(), params)
+		if err != nil {
+			return stats, fmt.Errorf("Template %s query error: %w", tmpl.Name(), err)
+		}
+		if err := stats.consume(res, 0, 0); err != nil {
+			return stats, fmt.Errorf("Template %s query error: %w", tmpl.Name(), err)
+		}
+	}
+	return stats, nil
+}
+
+// templateRecord decodes a JSON record for the templates, keeping integers
+// integers so $record.status_code is stored as 200 rather than 200.0.
+func templateRecord(line []byte) any {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil
+	}
+	return numbers(v)
+}
+
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, item := range v {
+			v[i] = numbers(item)
+		}
+	case map[string]any:
+		for k, item := range v {
+			v[k] = numbers(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+// Generated by jsontoneo test
+// Replay with: cypher-shell -f import.cypher
+
+OPTIONAL MATCH (h:Host)
+WITH count(h) AS hosts
+MERGE (v:Schema {name: 'jsontoneo'})
+ON CREATE SET v.version    = CASE WHEN hosts > 0 THEN 1 ELSE 4 END,
+              v.updated_at = datetime()
+RETURN v.version;
+
+MERGE (s:Scan {id: 'scan-1'})
+SET s.file         = 'httpx.json',
+    s.started_at   = datetime(),
+    s.imported_by  = '',
+    s.hostname     = '',
+    s.tool         = 'jsontoneo',
+    s.tool_version = '',
+    s.tool_commit  = '', s.expires_at = datetime() + duration('PT86400S')
+SET s.tags = coalesce(s.tags, []) + [t IN ['q3', 'bug-bounty'] WHERE NOT t IN coalesce(s.tags, [])]
+SET s:`q3`:`bug-bounty`
+WITH s
+OPTIONAL MATCH (b:Scan:Baseline) WHERE b <> s AND ('' = '' OR b.project = '')
+WITH s, b ORDER BY b.started_at DESC LIMIT 1
+SET s.baseline = b.id;
+
+MERGE (h:Host {url: 'https://a.example.com', project: ''})
+ON CREATE SET h.first_seen = datetime()
+WITH h, {`status`: 200, `tech`: ['nginx'], `title`: 'Costs $5 \'a month\''} AS tracked
+WITH h, tracked, (h.last_seen IS NULL
+     OR h.status IS NULL OR h.status <> tracked.status
+     OR NOT (size(coalesce(h.tech, [])) = size(coalesce(tracked.tech, [])) AND all(t IN coalesce(tracked.tech, []) WHERE t IN coalesce(h.tech, [])))
+     OR h.title IS NULL OR h.title <> tracked.title) AS changed
+SET h += {`input`: '', `ip`: '10.0.0.1', `lines`: 0, `port`: '', `resolvers`: null, `scheme`: '', `status`: 200, `tech`: ['nginx'], `timestamp`: '', `title`: 'Costs $5 \'a month\'', `webserver`: '', `words`: 0}, h.last_seen = datetime(), h.expires_at = datetime() + duration('PT86400S')
+REMOVE h:Stale, h:Retired, h.retired_at
+SET h.tags = coalesce(h.tags, []) + [t IN ['q3', 'bug-bounty'] WHERE NOT t IN coalesce(h.tags, [])]
+SET h:`q3`:`bug-bounty`
+REMOVE h:OutOfScope
+WITH h, tracked, changed
+MATCH (s:Scan {id: 'scan-1'})
+MERGE (h)-[r:SEEN_IN]->(s)
+SET r += {`port`: '', `status`: 200, `title`: 'Costs $5 \'a month\''}
+FOREACH (_ IN CASE WHEN s.baseline IS NULL OR EXISTS { (h)-[:SEEN_IN]->(:Scan {id: s.baseline}) } THEN [] ELSE [1] END |
+    SET h:NewSinceBaseline)
+FOREACH (_ IN CASE WHEN changed THEN [1] ELSE [] END |
+    CREATE (h)-[:OBSERVED]->(o:Observation)
+    SET o += tracked, o.observed_at = datetime()
+    CREATE (o)-[:OBSERVED_IN]->(s))
+RETURN h, changed;
+
+MATCH (h:Host {url: 'https://a.example.com', project: ''})
+MERGE (a:ASN {number: 'AS64500', project: ''})
+SET a.name    = 'EXAMPLE',
+    a.country = '',
+    a.range   = null, a.expires_at = datetime() + duration('PT86400S')
+SET a.tags = coalesce(a.tags, []) + [t IN ['q3', 'bug-bounty'] WHERE NOT t IN coalesce(a.tags, [])]
+SET a:`q3`:`bug-bounty`
+MERGE (h)-[:BELONGS_TO]->(a);
+
+MERGE (h:Host {url: 'http://b.example.com', project: ''})
+ON CREATE SET h.first_seen = datetime()
+WITH h, {`status`: 404, `tech`: null, `title`: ''} AS tracked
+WITH h, tracked, (h.last_seen IS NULL
+     OR h.status IS NULL OR h.status <> tracked.status
+     OR NOT (size(coalesce(h.tech, [])) = size(coalesce(tracked.tech, [])) AND all(t IN coalesce(tracked.tech, []) WHERE t IN coalesce(h.tech, [])))
+     OR h.title IS NULL OR h.title <> tracked.title) AS changed
+SET h += {`input`: '', `ip`: '10.0.0.2', `lines`: 0, `port`: '', `resolvers`: null, `scheme`: '', `status`: 404, `tech`: null, `timestamp`: '', `title`: '', `webserver`: '', `words`: 0}, h.last_seen = datetime(), h.expires_at = datetime() + duration('PT86400S')
+REMOVE h:Stale, h:Retired, h.retired_at
+SET h.tags = coalesce(h.tags, []) + [t IN ['q3', 'bug-bounty'] WHERE NOT t IN coalesce(h.tags, [])]
+SET h:`q3`:`bug-bounty`
+SET h:OutOfScope
+WITH h, tracked, changed
+MATCH (s:Scan {id: 'scan-1'})
+MERGE (h)-[r:SEEN_IN]->(s)
+SET r += {`port`: '', `status`: 404, `title`: ''}
+FOREACH (_ IN CASE WHEN s.baseline IS NULL OR EXISTS { (h)-[:SEEN_IN]->(:Scan {id: s.baseline}) } THEN [] ELSE [1] END |
+    SET h:NewSinceBaseline)
+FOREACH (_ IN CASE WHEN changed THEN [1] ELSE [] END |
+    CREATE (h)-[:OBSERVED]->(o:Observation)
+    SET o += tracked, o.observed_at = datetime()
+    CREATE (o)-[:OBSERVED_IN]->(s))
+RETURN h, changed;
+
+MATCH (s:Scan {id: 'scan-1'})
+SET s.finished_at = datetime();
+
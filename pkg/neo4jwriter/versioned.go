@@ -0,0 +1,40 @@
+package neo4jwriter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// trackedFields are the Host properties whose changes the versioned merge
+// strategy records as Observation nodes.
+var trackedFields = []string{"status", "title", "tech"}
+
+// changedCypher returns an expression that is true when the host is new or
+// one of the tracked properties differs from the Host h. The tech list is
+// compared as a set.
+func changedCypher(tracked map[string]any) string {
+	keys := make([]string, 0, len(tracked))
+	for k := range tracked {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	conds := []string{"h.last_seen IS NULL"}
+	for _, k := range keys {
+		if k == "tech" {
+			conds = append(conds, "NOT (size(coalesce(h.tech, [])) = size(coalesce(tracked.tech, [])) AND all(t IN coalesce(tracked.tech, []) WHERE t IN coalesce(h.tech, [])))")
+			continue
+		}
+		conds = append(conds, fmt.Sprintf("h.%[1]s IS NULL OR h.%[1]s <> tracked.%[1]s", k))
+	}
+	return "(" + strings.Join(conds, "\n\t     OR ") + ")"
+}
+
+// observeCypher creates an Observation of the tracked properties, linked to
+// the Host and the Scan, when changed is true.
+const observeCypher = `FOREACH (_ IN CASE WHEN changed THEN [1] ELSE [] END |
+	    CREATE (h)-[:OBSERVED]->(o:Observation)
+	    SET o += tracked, o.observed_at = datetime()
+	    CREATE (o)-[:OBSERVED_IN]->(s))
+	`
@@ -0,0 +1,225 @@
+package neo4jwriter
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/pocahon/jsontoneo/pkg/coerce"
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// Scope decides whether a record is in scope.
+type Scope interface {
+	InScope(result *model.HttpxResult) bool
+}
+
+// Writer writes parsed records to Neo4j within a transaction.
+type Writer struct {
+	ScanID    string
+	Project   string
+	Fields    FieldSelection
+	Tags      []string
+	TagLabels bool
+	// Scope, when set, labels out-of-scope hosts :OutOfScope.
+	Scope Scope
+	// Versioned records changes of the tracked properties as Observations.
+	Versioned bool
+	// Attribution, when set, is recorded on the nodes the writer creates.
+	Attribution *Attribution
+	// TTL, when set, gives the nodes written an expires_at this far ahead.
+	TTL time.Duration
+	// ToolVersion is recorded with the attribution.
+	ToolVersion string
+	// Coerce converts property values to their declared type.
+	Coerce coerce.Rules
+}
+
+// filter coerces the Host properties in props and applies the field
+// selection.
+func (w *Writer) filter(props map[string]any) map[string]any {
+	w.Coerce.Apply(props)
+	return w.Fields.filter(props)
+}
+
+// Write writes the Host node for result, plus its ASN when present.
+func (w *Writer) Write(tx Runner, result model.HttpxResult) (Stats, error) {
+	var stats Stats
+
+	props := w.hostProps(result)
+	// Wat deze scan zag wordt ook op SEEN_IN bewaard, zodat scans te vergelijken zijn.
+	observed := w.filter(map[string]any{
+		"status": result.Status,
+		"title":  result.Title,
+		"port":   result.Port,
+	})
+	tracked := w.filter(map[string]any{
+		"status": result.Status,
+		"title":  result.Title,
+		"tech":   result.Tech,
+	})
+
+	// Host node met alle relevante properties
+	vars, ret, changed, observe := "h", "h", "", ""
+	if w.Versioned {
+		vars, ret = "h, tracked, changed", "h, changed"
+		changed = "WITH h, $tracked AS tracked\n\tWITH h, tracked, " + changedCypher(tracked) + " AS changed\n\t"
+		observe = observeCypher
+	}
+	hostQuery := `
+	MERGE (h:Host ` + projectKey("url: $url") + `)
+	ON CREATE SET h.first_seen = datetime()` + w.attributionCypher("h") + `
+	` + changed + `SET h += $props, h.last_seen = datetime()` + expiryCypher("h", w.TTL) + `
+	REMOVE h:Stale, h:Retired, h.retired_at
+	` + tagCypher("h", w.Tags, w.TagLabels) + w.scopeCypher(&result) + `
+	WITH ` + vars + `
+	MATCH (s:Scan {id: $scan_id})
+	MERGE (h)-[r:SEEN_IN]->(s)
+	SET r += $observed
+	` + baselineCypher + observe + `RETURN ` + ret + `
+	`
+	res, err := tx.Run(hostQuery, w.params(map[string]any{
+		"url":      result.URL,
+		"props":    props,
+		"observed": observed,
+		"tracked":  tracked,
+		"scan_id":  w.ScanID,
+		"tags":     w.Tags,
+		"project":  w.Project,
+	}))
+	if err != nil {
+		return stats, fmt.Errorf("Host query error: %w", err)
+	}
+	// Een Observation is een extra node met twee relaties.
+	nodes, rels := 1, 1
+	if w.Versioned && res != nil && res.Next() {
+		if c, _ := res.Record().Get("changed"); c == true {
+			nodes, rels = 2, 3
+		}
+	}
+	if err := stats.consume(res, nodes, rels); err != nil {
+		return stats, fmt.Errorf("Host query error: %w", err)
+	}
+
+	// ASN node met relatie naar Host, alleen als ASN beschikbaar is
+	if result.ASN.ASNumber != "" && w.Fields.keep("asn") {
+		asnQuery := `
+		MATCH (h:Host ` + projectKey("url: $url") + `)
+		MERGE (a:ASN ` + projectKey("number: $as_number") + `)` + w.onCreate("a") + `
+		SET a.name    = $as_name,
+		    a.country = $as_country,
+		    a.range   = $as_range` + expiryCypher("a", w.TTL) + `
+		` + tagCypher("a", w.Tags, w.TagLabels) + `
+		MERGE (h)-[:BELONGS_TO]->(a)
+		`
+		res, err = tx.Run(asnQuery, w.params(map[string]any{
+			"url":        result.URL,
+			"as_number":  result.ASN.ASNumber,
+			"as_name":    result.ASN.ASName,
+			"as_country": result.ASN.ASCountry,
+			"as_range":   result.ASN.ASRange,
+			"tags":       w.Tags,
+			"project":    w.Project,
+		}))
+		if err != nil {
+			return stats, fmt.Errorf("ASN query error: %w", err)
+		}
+		if err := stats.consume(res, 1, 1); err != nil {
+			return stats, fmt.Errorf("ASN query error: %w", err)
+		}
+	}
+
+	return stats, nil
+}
+
+// hostProps returns the properties of the Host node of result, coerced and
+// selected.
+func (w *Writer) hostProps(result model.HttpxResult) map[string]any {
+	props := map[string]any{
+		"input":     result.Input,
+		"ip":        result.Host,
+		"port":      result.Port,
+		"title":     result.Title,
+		"scheme":    result.Scheme,
+		"webserver": result.Webserver,
+		"status":    result.Status,
+		"words":     result.Words,
+		"lines":     result.Lines,
+		"tech":      result.Tech,
+		"resolvers": result.Resolvers,
+		"timestamp": result.Timestamp,
+	}
+	// Alleen zetten als bekend, anders wist een import zonder geoip wat enrich
+	// schreef. Een ontbrekende cdn is onbekend, niet false.
+	if len(result.CNAME) > 0 {
+		props["cname"] = result.CNAME
+	}
+	if result.Favicon != "" {
+		props["favicon"] = result.Favicon
+	}
+	if result.Jarm != "" {
+		props["jarm"] = result.Jarm
+	}
+	if len(result.A) > 0 {
+		props["a"] = result.A
+	}
+	if result.CDN != nil {
+		props["cdn"] = *result.CDN
+	}
+	if result.CDNName != "" {
+		props["cdn_name"] = result.CDNName
+	}
+	if result.GeoIP.Country != "" {
+		props["country"] = result.GeoIP.Country
+	}
+	if result.GeoIP.City != "" {
+		props["city"] = result.GeoIP.City
+	}
+	for name, v := range result.Extra {
+		if _, ok := props[name]; !ok {
+			props[name] = v
+		}
+	}
+	return w.filter(props)
+}
+
+// attributionCypher returns the assignments that record the attribution on
+// a node v created by the writer, to append to its ON CREATE SET clause.
+func (w *Writer) attributionCypher(v string) string {
+	if w.Attribution == nil {
+		return ""
+	}
+	return ", " + attributionCypher(v)
+}
+
+// onCreate returns an ON CREATE SET clause recording the attribution on v.
+func (w *Writer) onCreate(v string) string {
+	if w.Attribution == nil {
+		return ""
+	}
+	return "\n\t\tON CREATE SET " + attributionCypher(v)
+}
+
+// params adds the attribution and ttl parameters to params.
+func (w *Writer) params(params map[string]any) map[string]any {
+	if w.Attribution != nil {
+		maps.Copy(params, w.Attribution.params(w.ToolVersion))
+	}
+	if w.TTL > 0 {
+		params["ttl"] = ttlParam(w.TTL)
+	}
+	return params
+}
+
+// scopeCypher returns the clause that labels the Host of result :OutOfScope,
+// or removes the label once the host is in scope.
+func (w *Writer) scopeCypher(result *model.HttpxResult) string {
+	switch {
+	case w.Scope == nil:
+		return ""
+	case w.Scope.InScope(result):
+		return "REMOVE h:OutOfScope\n"
+	default:
+		return "SET h:OutOfScope\n"
+	}
+}
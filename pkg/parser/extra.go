@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// knownHttpx holds the JSON fields of model.HttpxResult, nested objects as
+// their own maps.
+var knownHttpx = jsonFields(reflect.TypeOf(model.HttpxResult{}))
+
+func jsonFields(t reflect.Type) map[string]any {
+	fields := make(map[string]any)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Struct {
+			fields[name] = jsonFields(f.Type)
+		} else {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// HttpxExtra returns the fields of an httpx line that model.HttpxResult does
+// not cover, so fields added by newer httpx versions are not dropped. Nested
+// objects are flattened to dot-joined names (tls.cipher); lists of numbers,
+// strings or booleans are kept as lists, other lists and mixed lists become
+// JSON strings, as Neo4j cannot store them.
+func HttpxExtra(line []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	extra := make(map[string]any)
+	flatten(extra, "", doc, knownHttpx)
+	return extra, nil
+}
+
+// flatten adds the fields of obj that are not in known to extra, prefixing
+// their names with prefix.
+func flatten(extra map[string]any, prefix string, obj map[string]any, known map[string]any) {
+	for k, v := range obj {
+		name := prefix + k
+		sub, isObj := v.(map[string]any)
+		switch kn := known[k].(type) {
+		case bool:
+			continue
+		case map[string]any:
+			if isObj {
+				flatten(extra, name+".", sub, kn)
+				continue
+			}
+		}
+		switch {
+		case v == nil:
+		case isObj:
+			flatten(extra, name+".", sub, nil)
+		default:
+			extra[name] = flatValue(v)
+		}
+	}
+}
+
+func flatValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		return number(v)
+	case []any:
+		var kind reflect.Kind
+		list := make([]any, len(v))
+		for i, item := range v {
+			if n, ok := item.(json.Number); ok {
+				item = number(n)
+			}
+			k := reflect.Invalid
+			if item != nil {
+				k = reflect.TypeOf(item).Kind()
+			}
+			switch {
+			case k != reflect.String && k != reflect.Bool && k != reflect.Int64 && k != reflect.Float64,
+				i > 0 && k != kind:
+				b, _ := json.Marshal(v)
+				return string(b)
+			}
+			kind = k
+			list[i] = item
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+func number(n json.Number) any {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"encoding/json"
+
+	"github.com/pocahon/jsontoneo/pkg/mapping"
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+func init() {
+	Register(Httpx{})
+}
+
+var httpxProfile = mustProfile("httpx")
+
+func mustProfile(tool string) *mapping.Mapping {
+	m, err := mapping.Profile(tool)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Httpx parses httpx JSON Lines output with the built-in httpx mapping
+// profile. It also returns the typed record, which the writer maps with the
+// full Host model: field selection, scope, versioning and baselines.
+type Httpx struct{}
+
+func (Httpx) Name() string { return "httpx" }
+
+func (Httpx) Detect(line []byte) bool {
+	return httpxProfile.Detect(line)
+}
+
+func (Httpx) Parse(line []byte) ([]model.Entity, []model.Relation, error) {
+	return httpxProfile.Parse(line)
+}
+
+// Record parses line into an httpx record.
+func (Httpx) Record(line []byte) (model.HttpxResult, error) {
+	return ParseHttpx(line)
+}
+
+// ParseHttpx parses a line of httpx JSON output.
+func ParseHttpx(line []byte) (model.HttpxResult, error) {
+	var result model.HttpxResult
+	err := json.Unmarshal(line, &result)
+	return result, err
+}
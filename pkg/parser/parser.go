@@ -0,0 +1,74 @@
+// Package parser parses the output of recon tools into model records.
+//
+// Every tool format is a Parser registered under the name of the tool. A new
+// format is added as a file that registers its Parser in an init function;
+// the import loop picks it up by name or by detecting it from the input.
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pocahon/jsontoneo/pkg/model"
+)
+
+// Parser parses the lines of one tool's output.
+type Parser interface {
+	// Name is the name of the tool, e.g. httpx.
+	Name() string
+	// Detect reports whether line looks like output of the tool.
+	Detect(line []byte) bool
+	// Parse returns the entities in line and the relations between them.
+	Parse(line []byte) ([]model.Entity, []model.Relation, error)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Parser)
+	// order keeps the registration order, so Detect tries the built-in
+	// parsers before the ones added later.
+	order []string
+)
+
+// Register makes p available under its name. It panics when a parser with
+// that name is already registered, like database/sql drivers.
+func Register(p Parser) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := p.Name()
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("parser: Register called twice for %s", name))
+	}
+	registry[name] = p
+	order = append(order, name)
+}
+
+// Get returns the parser registered under name.
+func Get(name string) (Parser, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Detect returns the first registered parser that recognizes line, or nil.
+func Detect(line []byte) Parser {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, name := range order {
+		if p := registry[name]; p.Detect(line) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the registered parsers, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := append([]string(nil), order...)
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,315 @@
+// Package plugin runs third-party parsers and enrichers as separate
+// binaries over hashicorp/go-plugin, so private formats can be supported
+// without forking jsontoneo.
+//
+// A plugin binary serves a parser.Parser or an Enricher from its main
+// function:
+//
+//	func main() {
+//		plugin.ServeParser(acmeParser{})
+//	}
+//
+// jsontoneo starts the binary the first time the plugin is used, and stops
+// it with Close.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/pocahon/jsontoneo/pkg/model"
+	"github.com/pocahon/jsontoneo/pkg/parser"
+)
+
+// Handshake is shared by jsontoneo and its plugins. It keeps plugin binaries
+// from being run by accident and rejects plugins of another protocol
+// version.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "JSONTONEO_PLUGIN",
+	MagicCookieValue: "d1c4a3b8-jsontoneo",
+}
+
+// Enricher rewrites a JSON record before it is mapped: it returns the
+// records to import, none to skip it.
+type Enricher interface {
+	Apply(record []byte) ([][]byte, error)
+}
+
+// Spec declares a plugin binary.
+type Spec struct {
+	Name    string   `yaml:"name"`
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+// ServeParser serves p to jsontoneo. It is called from the main function of
+// a parser plugin and does not return.
+func ServeParser(p parser.Parser) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         goplugin.PluginSet{"parser": &parserGoPlugin{impl: p}},
+	})
+}
+
+// ServeEnricher serves e to jsontoneo. It is called from the main function
+// of an enricher plugin and does not return.
+func ServeEnricher(e Enricher) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         goplugin.PluginSet{"enricher": &enricherGoPlugin{impl: e}},
+	})
+}
+
+var (
+	mu      sync.Mutex
+	clients []*goplugin.Client
+)
+
+// Close stops the plugin binaries that were started.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, c := range clients {
+		c.Kill()
+	}
+	clients = nil
+}
+
+// client holds a plugin binary that is started on first use.
+type client struct {
+	spec Spec
+	kind string
+
+	once sync.Once
+	impl any
+	err  error
+}
+
+func (c *client) start() (any, error) {
+	c.once.Do(func() {
+		pc := goplugin.NewClient(&goplugin.ClientConfig{
+			HandshakeConfig: Handshake,
+			Plugins: goplugin.PluginSet{
+				"parser":   &parserGoPlugin{},
+				"enricher": &enricherGoPlugin{},
+			},
+			Cmd:              exec.Command(c.spec.Command, c.spec.Args...),
+			SyncStderr:       os.Stderr,
+			AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolNetRPC},
+			Logger:           hclog.New(&hclog.LoggerOptions{Name: c.spec.Name, Level: hclog.Warn, Output: os.Stderr}),
+		})
+		mu.Lock()
+		clients = append(clients, pc)
+		mu.Unlock()
+
+		rpcClient, err := pc.Client()
+		if err != nil {
+			c.err = fmt.Errorf("plugin %s: %w", c.spec.Name, err)
+			return
+		}
+		if c.impl, err = rpcClient.Dispense(c.kind); err != nil {
+			c.err = fmt.Errorf("plugin %s: %w", c.spec.Name, err)
+		}
+	})
+	return c.impl, c.err
+}
+
+// ParserPlugin is a parser plugin. It registers under the name of its Spec.
+type ParserPlugin struct {
+	c *client
+}
+
+// NewParser returns the parser plugin declared by spec.
+func NewParser(spec Spec) *ParserPlugin {
+	return &ParserPlugin{c: &client{spec: spec, kind: "parser"}}
+}
+
+func (p *ParserPlugin) Name() string { return p.c.spec.Name }
+
+// Detect starts the plugin; a plugin that fails to start detects nothing.
+func (p *ParserPlugin) Detect(line []byte) bool {
+	impl, err := p.c.start()
+	if err != nil {
+		return false
+	}
+	return impl.(*parserClient).Detect(line)
+}
+
+func (p *ParserPlugin) Parse(line []byte) ([]model.Entity, []model.Relation, error) {
+	impl, err := p.c.start()
+	if err != nil {
+		return nil, nil, err
+	}
+	return impl.(*parserClient).Parse(line)
+}
+
+// EnricherPlugin is an enricher plugin.
+type EnricherPlugin struct {
+	c *client
+}
+
+// NewEnricher returns the enricher plugin declared by spec.
+func NewEnricher(spec Spec) *EnricherPlugin {
+	return &EnricherPlugin{c: &client{spec: spec, kind: "enricher"}}
+}
+
+func (e *EnricherPlugin) String() string { return e.c.spec.Name }
+
+func (e *EnricherPlugin) Apply(record []byte) ([][]byte, error) {
+	impl, err := e.c.start()
+	if err != nil {
+		return nil, err
+	}
+	return impl.(*enricherClient).Apply(record)
+}
+
+// parserGoPlugin and enricherGoPlugin implement goplugin.Plugin: impl is set on
+// the plugin side only.
+type parserGoPlugin struct {
+	impl parser.Parser
+}
+
+func (p *parserGoPlugin) Server(*goplugin.MuxBroker) (any, error) {
+	return &parserServer{impl: p.impl}, nil
+}
+
+func (p *parserGoPlugin) Client(_ *goplugin.MuxBroker, c *rpc.Client) (any, error) {
+	return &parserClient{rpc: c}, nil
+}
+
+type enricherGoPlugin struct {
+	impl Enricher
+}
+
+func (p *enricherGoPlugin) Server(*goplugin.MuxBroker) (any, error) {
+	return &enricherServer{impl: p.impl}, nil
+}
+
+func (p *enricherGoPlugin) Client(_ *goplugin.MuxBroker, c *rpc.Client) (any, error) {
+	return &enricherClient{rpc: c}, nil
+}
+
+// ParseReply carries the result of Parse as JSON, as the property values of
+// the entities do not survive gob.
+type ParseReply struct {
+	Entities  []byte
+	Relations []byte
+}
+
+// parserServer is the net/rpc service of a parser plugin.
+type parserServer struct {
+	impl parser.Parser
+}
+
+func (s *parserServer) Detect(line []byte, reply *bool) error {
+	*reply = s.impl.Detect(line)
+	return nil
+}
+
+func (s *parserServer) Parse(line []byte, reply *ParseReply) error {
+	entities, relations, err := s.impl.Parse(line)
+	if err != nil {
+		return err
+	}
+	if reply.Entities, err = json.Marshal(entities); err != nil {
+		return err
+	}
+	reply.Relations, err = json.Marshal(relations)
+	return err
+}
+
+type parserClient struct {
+	rpc *rpc.Client
+}
+
+func (c *parserClient) Detect(line []byte) bool {
+	var ok bool
+	if err := c.rpc.Call("Plugin.Detect", line, &ok); err != nil {
+		return false
+	}
+	return ok
+}
+
+func (c *parserClient) Parse(line []byte) ([]model.Entity, []model.Relation, error) {
+	var reply ParseReply
+	if err := c.rpc.Call("Plugin.Parse", line, &reply); err != nil {
+		return nil, nil, err
+	}
+	var entities []model.Entity
+	var relations []model.Relation
+	if err := decode(reply.Entities, &entities); err != nil {
+		return nil, nil, err
+	}
+	if err := decode(reply.Relations, &relations); err != nil {
+		return nil, nil, err
+	}
+	for _, e := range entities {
+		numbers(e.Key)
+		numbers(e.Props)
+	}
+	for _, r := range relations {
+		numbers(r.From.Key)
+		numbers(r.To.Key)
+		numbers(r.Props)
+	}
+	return entities, relations, nil
+}
+
+// enricherServer is the net/rpc service of an enricher plugin.
+type enricherServer struct {
+	impl Enricher
+}
+
+func (s *enricherServer) Apply(record []byte, reply *[][]byte) error {
+	records, err := s.impl.Apply(record)
+	*reply = records
+	return err
+}
+
+type enricherClient struct {
+	rpc *rpc.Client
+}
+
+func (c *enricherClient) Apply(record []byte) ([][]byte, error) {
+	var records [][]byte
+	err := c.rpc.Call("Plugin.Apply", record, &records)
+	return records, err
+}
+
+// decode decodes JSON, keeping numbers as json.Number.
+func decode(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// numbers turns the json.Number values of props into int64 or float64.
+func numbers(props map[string]any) {
+	for k, v := range props {
+		props[k] = number(v)
+	}
+}
+
+func number(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, item := range v {
+			v[i] = number(item)
+		}
+	}
+	return v
+}
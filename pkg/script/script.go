@@ -0,0 +1,193 @@
+// Package script enriches input records with a Starlark script before they
+// are mapped, for logic a jq expression cannot express comfortably, such as
+// normalizing hostnames or deriving tags from naming conventions.
+//
+// The script defines a function enrich that is called with every record as
+// a dict and returns the record to import, None to skip it, or a list of
+// records:
+//
+//	def enrich(record):
+//	    record["host"] = record["host"].lower().rstrip(".")
+//	    if record["host"].startswith("dev-"):
+//	        record["environment"] = "dev"
+//	    return record
+//
+// The json module of Starlark is available for records with embedded JSON.
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// maxSteps bounds the work of a single call, so a script stuck in a loop
+// fails the record rather than hanging the import.
+const maxSteps = 10_000_000
+
+// Script is a loaded enrichment script.
+type Script struct {
+	path   string
+	enrich starlark.Callable
+}
+
+// Load reads and runs the script at path and looks up its enrich function.
+func Load(path string) (*Script, error) {
+	thread := &starlark.Thread{Name: path, Print: printLog}
+	predeclared := starlark.StringDict{"json": starlarkjson.Module}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{While: true, Recursion: true}, thread, path, nil, predeclared)
+	if err != nil {
+		return nil, err
+	}
+	enrich, ok := globals["enrich"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s does not define an enrich(record) function", path)
+	}
+	return &Script{path: path, enrich: enrich}, nil
+}
+
+func (s *Script) String() string { return s.path }
+
+// Apply calls enrich with a JSON record and returns the records it returns,
+// as JSON.
+func (s *Script) Apply(record []byte) ([][]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	in, err := toStarlark(v)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := &starlark.Thread{Name: s.path, Print: printLog}
+	thread.SetMaxExecutionSteps(maxSteps)
+	res, err := starlark.Call(thread, s.enrich, starlark.Tuple{in}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("script: %w", err)
+	}
+
+	var outputs []starlark.Value
+	switch res := res.(type) {
+	case starlark.NoneType:
+	case *starlark.List:
+		for i := range res.Len() {
+			outputs = append(outputs, res.Index(i))
+		}
+	default:
+		outputs = []starlark.Value{res}
+	}
+
+	var out [][]byte
+	for _, o := range outputs {
+		if _, ok := o.(*starlark.Dict); !ok {
+			return nil, fmt.Errorf("script: enrich returned %s, want dict, list or None", o.Type())
+		}
+		v, err := fromStarlark(o)
+		if err != nil {
+			return nil, fmt.Errorf("script: %w", err)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("script: %w", err)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+func printLog(thread *starlark.Thread, msg string) {
+	log.Printf("%s: %s", thread.Name, msg)
+}
+
+// toStarlark converts a decoded JSON value.
+func toStarlark(v any) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(n), nil
+		}
+		f, err := v.Float64()
+		return starlark.Float(f), err
+	case []any:
+		list := make([]starlark.Value, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = toStarlark(item); err != nil {
+				return nil, err
+			}
+		}
+		return starlark.NewList(list), nil
+	case map[string]any:
+		dict := starlark.NewDict(len(v))
+		for k, item := range v {
+			sv, err := toStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(k), sv); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("unsupported JSON value %T", v)
+	}
+}
+
+// fromStarlark converts a value returned by the script back into one that
+// can be encoded as JSON.
+func fromStarlark(v starlark.Value) (any, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		if n, ok := v.Int64(); ok {
+			return n, nil
+		}
+		return json.Number(v.BigInt().Text(10)), nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Indexable: // list, tuple
+		list := make([]any, v.Len())
+		for i := range v.Len() {
+			var err error
+			if list[i], err = fromStarlark(v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case *starlark.Dict:
+		obj := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			k, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict key %s is not a string", item[0])
+			}
+			var err error
+			if obj[string(k)], err = fromStarlark(item[1]); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to JSON", v.Type())
+	}
+}
@@ -0,0 +1,63 @@
+// Package transform rewrites input records with jq expressions before they
+// are mapped, for renames, computed fields and filtering without a
+// pre-processing step.
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/itchyny/gojq"
+)
+
+// Transform is a compiled jq expression.
+type Transform struct {
+	expr string
+	code *gojq.Code
+}
+
+// Compile compiles a jq expression, e.g. 'select(.status_code < 500)' or
+// '.title |= ascii_downcase'.
+func Compile(expr string) (*Transform, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return nil, err
+	}
+	return &Transform{expr: expr, code: code}, nil
+}
+
+func (t *Transform) String() string { return t.expr }
+
+// Apply runs the expression on a JSON record and returns the records it
+// outputs, as JSON. An expression can drop a record, with select or empty,
+// or split it in several; null outputs are dropped too.
+func (t *Transform) Apply(record []byte) ([][]byte, error) {
+	var v any
+	if err := json.Unmarshal(record, &v); err != nil {
+		return nil, err
+	}
+
+	var out [][]byte
+	iter := t.code.Run(v)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return out, nil
+		}
+		if err, ok := v.(error); ok {
+			return nil, fmt.Errorf("transform: %w", err)
+		}
+		if v == nil {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("transform: %w", err)
+		}
+		out = append(out, b)
+	}
+}